@@ -107,10 +107,10 @@ func (h *ConnectorHandler) CreateConnector(c *gin.Context) {
 		return
 	}
 
-	// If it's a Shopware connector, register webhooks automatically
-	if connector.Type == models.ConnectorTypeShopware {
+	// Register webhooks automatically
+	if connector.Type == models.ConnectorTypeShopware || connector.Type == models.ConnectorTypeShopify {
 		// Get the callback URL from configuration
-		callbackURL := h.config.Server.CallbackURL + "/api/v1/webhook/shopware"
+		callbackURL := h.config.Server.CallbackURL + "/api/v1/webhook/" + string(connector.Type)
 
 		// Register webhooks
 		if err := h.service.RegisterWebhooks(connector.ID, callbackURL); err != nil {
@@ -32,17 +32,22 @@ func NewDataflowHandler(
 
 // DataflowResponse represents a dataflow response
 type DataflowResponse struct {
-	ID                uint                  `json:"id"`
-	Name              string                `json:"name"`
-	Description       string                `json:"description"`
-	Type              models.DataflowType   `json:"type"`
-	Status            models.DataflowStatus `json:"status"`
-	SourceConnectorID uint                  `json:"source_connector_id"`
-	DestConnectorID   uint                  `json:"dest_connector_id"`
-	SourceConnector   ConnectorResponse     `json:"source_connector"`
-	DestConnector     ConnectorResponse     `json:"dest_connector"`
-	CreatedAt         string                `json:"created_at"`
-	UpdatedAt         string                `json:"updated_at"`
+	ID                uint                     `json:"id"`
+	Name              string                   `json:"name"`
+	Description       string                   `json:"description"`
+	Type              models.DataflowType      `json:"type"`
+	Status            models.DataflowStatus    `json:"status"`
+	Direction         models.DataflowDirection `json:"direction"`
+	SourceConnectorID uint                     `json:"source_connector_id"`
+	DestConnectorID   uint                     `json:"dest_connector_id"`
+	RetryPolicy       models.RetryPolicy       `json:"retry_policy"`
+	Inventory         *models.InventoryConfig  `json:"inventory,omitempty"`
+	Pricing           *models.PricingConfig    `json:"pricing,omitempty"`
+	Category          *models.CategoryConfig   `json:"category,omitempty"`
+	SourceConnector   ConnectorResponse        `json:"source_connector"`
+	DestConnector     ConnectorResponse        `json:"dest_connector"`
+	CreatedAt         string                   `json:"created_at"`
+	UpdatedAt         string                   `json:"updated_at"`
 }
 
 // toResponse converts a dataflow model to a response
@@ -53,8 +58,13 @@ func toDataflowResponse(dataflow *models.Dataflow) DataflowResponse {
 		Description:       dataflow.Description,
 		Type:              dataflow.Type,
 		Status:            dataflow.Status,
+		Direction:         dataflow.Direction,
 		SourceConnectorID: dataflow.SourceConnectorID,
 		DestConnectorID:   dataflow.DestConnectorID,
+		RetryPolicy:       dataflow.RetryPolicy.WithDefaults(),
+		Inventory:         dataflow.Inventory,
+		Pricing:           dataflow.Pricing,
+		Category:          dataflow.Category,
 		SourceConnector:   toConnectorResponse(&dataflow.SourceConnector),
 		DestConnector:     toConnectorResponse(&dataflow.DestConnector),
 		CreatedAt:         dataflow.CreatedAt.Format("2006-01-02T15:04:05Z"),
@@ -64,31 +74,35 @@ func toDataflowResponse(dataflow *models.Dataflow) DataflowResponse {
 
 // FieldMappingResponse represents a field mapping response
 type FieldMappingResponse struct {
-	ID              uint                      `json:"id"`
-	DataflowID      uint                      `json:"dataflow_id"`
-	SourceField     string                    `json:"source_field"`
-	DestField       string                    `json:"dest_field"`
-	IsRequired      bool                      `json:"is_required"`
-	DefaultValue    string                    `json:"default_value"`
-	TransformType   models.TransformationType `json:"transform_type"`
-	TransformConfig string                    `json:"transform_config"`
-	CreatedAt       string                    `json:"created_at"`
-	UpdatedAt       string                    `json:"updated_at"`
+	ID               uint                      `json:"id"`
+	DataflowID       uint                      `json:"dataflow_id"`
+	SourceField      string                    `json:"source_field"`
+	SourceExpression string                    `json:"source_expression,omitempty"`
+	DestField        string                    `json:"dest_field"`
+	IsRequired       bool                      `json:"is_required"`
+	DefaultValue     string                    `json:"default_value"`
+	TransformType    models.TransformationType `json:"transform_type"`
+	TransformConfig  string                    `json:"transform_config"`
+	TransformSteps   []models.TransformStep    `json:"transform_steps,omitempty"`
+	CreatedAt        string                    `json:"created_at"`
+	UpdatedAt        string                    `json:"updated_at"`
 }
 
 // toFieldMappingResponse converts a field mapping model to a response
 func toFieldMappingResponse(fieldMapping *models.FieldMapping) FieldMappingResponse {
 	return FieldMappingResponse{
-		ID:              fieldMapping.ID,
-		DataflowID:      fieldMapping.DataflowID,
-		SourceField:     fieldMapping.SourceField,
-		DestField:       fieldMapping.DestField,
-		IsRequired:      fieldMapping.IsRequired,
-		DefaultValue:    fieldMapping.DefaultValue,
-		TransformType:   fieldMapping.TransformType,
-		TransformConfig: fieldMapping.TransformConfig,
-		CreatedAt:       fieldMapping.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		UpdatedAt:       fieldMapping.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		ID:               fieldMapping.ID,
+		DataflowID:       fieldMapping.DataflowID,
+		SourceField:      fieldMapping.SourceField,
+		SourceExpression: fieldMapping.SourceExpression,
+		DestField:        fieldMapping.DestField,
+		IsRequired:       fieldMapping.IsRequired,
+		DefaultValue:     fieldMapping.DefaultValue,
+		TransformType:    fieldMapping.TransformType,
+		TransformConfig:  fieldMapping.TransformConfig,
+		TransformSteps:   fieldMapping.TransformSteps,
+		CreatedAt:        fieldMapping.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:        fieldMapping.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 	}
 }
 
@@ -101,11 +115,24 @@ type MigrationLogResponse struct {
 	DestIdentifier   string                 `json:"dest_identifier"`
 	ExecutionARN     string                 `json:"execution_arn"`
 	ErrorMessage     string                 `json:"error_message"`
+	ErrorClass       models.ErrorClass      `json:"error_class,omitempty"`
+	Attempts         int                    `json:"attempts"`
+	NextRetryAt      string                 `json:"next_retry_at,omitempty"`
 	CompletedAt      string                 `json:"completed_at,omitempty"`
 	CreatedAt        string                 `json:"created_at"`
 	UpdatedAt        string                 `json:"updated_at"`
 }
 
+// isInvalidFieldMapping reports whether a field mapping was rejected by
+// validation rather than failing to save
+func isInvalidFieldMapping(err error) bool {
+	return errors.Is(err, models.ErrInvalidFieldMapping) ||
+		errors.Is(err, models.ErrInvalidExpression) ||
+		errors.Is(err, models.ErrInvalidPath) ||
+		errors.Is(err, models.ErrInvalidTransformConfig) ||
+		errors.Is(err, models.ErrInvalidTransformStep)
+}
+
 // toMigrationLogResponse converts a migration log model to a response
 func toMigrationLogResponse(log *models.MigrationLog) MigrationLogResponse {
 	response := MigrationLogResponse{
@@ -116,10 +143,16 @@ func toMigrationLogResponse(log *models.MigrationLog) MigrationLogResponse {
 		DestIdentifier:   log.DestIdentifier,
 		ExecutionARN:     log.ExecutionARN,
 		ErrorMessage:     log.ErrorMessage,
+		ErrorClass:       log.ErrorClass,
+		Attempts:         log.Attempts,
 		CreatedAt:        log.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:        log.UpdatedAt.Format("2006-01-02T15:04:05Z"),
 	}
 
+	if log.NextRetryAt != nil {
+		response.NextRetryAt = log.NextRetryAt.Format("2006-01-02T15:04:05Z")
+	}
+
 	if log.CompletedAt != nil {
 		response.CompletedAt = log.CompletedAt.Format("2006-01-02T15:04:05Z")
 	}
@@ -371,7 +404,12 @@ func (h *DataflowHandler) CreateFieldMapping(c *gin.Context) {
 	fieldMapping.DataflowID = uint(id)
 
 	if err := h.fieldMappingService.CreateFieldMapping(&fieldMapping); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if isInvalidFieldMapping(err) {
+			status = http.StatusBadRequest
+		}
+
+		c.JSON(status, gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -429,6 +467,8 @@ func (h *DataflowHandler) UpdateFieldMapping(c *gin.Context) {
 		status := http.StatusInternalServerError
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			status = http.StatusNotFound
+		} else if isInvalidFieldMapping(err) {
+			status = http.StatusBadRequest
 		}
 
 		c.JSON(status, gin.H{
@@ -652,12 +692,12 @@ func (h *DataflowHandler) ApplyDefaultMappings(c *gin.Context) {
 
 	if dataflow.Type == models.DataflowTypeProduct {
 		defaultMappings = h.fieldMappingService.GetDefaultProductMappings(uint(id))
+	} else if dataflow.Type == models.DataflowTypeCategory {
+		defaultMappings = h.fieldMappingService.GetDefaultCategoryMappings(uint(id))
 	} else if dataflow.Type == models.DataflowTypeOrder {
-		// Add order mappings when implemented
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Default mappings for order type not implemented yet",
-		})
-		return
+		defaultMappings = h.fieldMappingService.GetDefaultOrderMappings(uint(id))
+	} else if dataflow.Type == models.DataflowTypeCustomer {
+		defaultMappings = h.fieldMappingService.GetDefaultCustomerMappings(uint(id))
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Unknown dataflow type",
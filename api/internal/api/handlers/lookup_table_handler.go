@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LookupTableHandler handles lookup table API requests
+type LookupTableHandler struct {
+	lookupTableService *services.LookupTableService
+}
+
+// NewLookupTableHandler creates a new lookup table handler
+func NewLookupTableHandler(lookupTableService *services.LookupTableService) *LookupTableHandler {
+	return &LookupTableHandler{
+		lookupTableService: lookupTableService,
+	}
+}
+
+// LookupTableResponse represents a lookup table response
+type LookupTableResponse struct {
+	ID           uint              `json:"id"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	Version      int               `json:"version"`
+	EntryCount   int               `json:"entry_count"`
+	Entries      map[string]string `json:"entries,omitempty"`
+	DefaultValue *string           `json:"default_value"`
+	CreatedAt    string            `json:"created_at"`
+	UpdatedAt    string            `json:"updated_at"`
+}
+
+// LookupTableVersionResponse represents a lookup table version response
+type LookupTableVersionResponse struct {
+	Version      int               `json:"version"`
+	Change       string            `json:"change"`
+	EntryCount   int               `json:"entry_count"`
+	Entries      map[string]string `json:"entries"`
+	DefaultValue *string           `json:"default_value"`
+	CreatedAt    string            `json:"created_at"`
+}
+
+// toLookupTableResponse converts a lookup table model to a response. Lists
+// leave out the entries, which can be large.
+func toLookupTableResponse(table *models.LookupTable, withEntries bool) LookupTableResponse {
+	response := LookupTableResponse{
+		ID:           table.ID,
+		Name:         table.Name,
+		Description:  table.Description,
+		Version:      table.Version,
+		EntryCount:   len(table.Entries),
+		DefaultValue: table.DefaultValue,
+		CreatedAt:    table.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:    table.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+
+	if withEntries {
+		response.Entries = table.Entries
+	}
+
+	return response
+}
+
+// CreateLookupTable creates a new lookup table
+func (h *LookupTableHandler) CreateLookupTable(c *gin.Context) {
+	var table models.LookupTable
+	if err := c.ShouldBindJSON(&table); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if err := h.lookupTableService.CreateLookupTable(&table); err != nil {
+		h.respondLookupTableError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Lookup table created successfully",
+		"data":    toLookupTableResponse(&table, true),
+	})
+}
+
+// GetLookupTable gets a lookup table by ID
+func (h *LookupTableHandler) GetLookupTable(c *gin.Context) {
+	id, ok := lookupTableID(c)
+	if !ok {
+		return
+	}
+
+	table, err := h.lookupTableService.GetLookupTable(id)
+	if err != nil {
+		h.respondLookupTableError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": toLookupTableResponse(table, true),
+	})
+}
+
+// ListLookupTables lists all lookup tables
+func (h *LookupTableHandler) ListLookupTables(c *gin.Context) {
+	tables, err := h.lookupTableService.ListLookupTables()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	response := make([]LookupTableResponse, 0, len(tables))
+	for _, table := range tables {
+		response = append(response, toLookupTableResponse(&table, false))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": response,
+	})
+}
+
+// UpdateLookupTable replaces a lookup table's contents as a new version
+func (h *LookupTableHandler) UpdateLookupTable(c *gin.Context) {
+	id, ok := lookupTableID(c)
+	if !ok {
+		return
+	}
+
+	var table models.LookupTable
+	if err := c.ShouldBindJSON(&table); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if err := h.lookupTableService.UpdateLookupTable(id, &table); err != nil {
+		h.respondLookupTableError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Lookup table updated successfully",
+		"data":    toLookupTableResponse(&table, true),
+	})
+}
+
+// DeleteLookupTable deletes a lookup table
+func (h *LookupTableHandler) DeleteLookupTable(c *gin.Context) {
+	id, ok := lookupTableID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.lookupTableService.DeleteLookupTable(id); err != nil {
+		h.respondLookupTableError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Lookup table deleted successfully",
+	})
+}
+
+// ListVersions lists the versions of a lookup table
+func (h *LookupTableHandler) ListVersions(c *gin.Context) {
+	id, ok := lookupTableID(c)
+	if !ok {
+		return
+	}
+
+	versions, err := h.lookupTableService.ListVersions(id)
+	if err != nil {
+		h.respondLookupTableError(c, err)
+		return
+	}
+
+	response := make([]LookupTableVersionResponse, 0, len(versions))
+	for _, version := range versions {
+		response = append(response, LookupTableVersionResponse{
+			Version:      version.Version,
+			Change:       version.Change,
+			EntryCount:   len(version.Entries),
+			Entries:      version.Entries,
+			DefaultValue: version.DefaultValue,
+			CreatedAt:    version.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": response,
+	})
+}
+
+// RestoreVersion restores an earlier version of a lookup table
+func (h *LookupTableHandler) RestoreVersion(c *gin.Context) {
+	id, ok := lookupTableID(c)
+	if !ok {
+		return
+	}
+
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid version",
+		})
+		return
+	}
+
+	table, err := h.lookupTableService.RestoreVersion(id, version)
+	if err != nil {
+		h.respondLookupTableError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": fmt.Sprintf("Version %d restored as version %d", version, table.Version),
+		"data":    toLookupTableResponse(table, true),
+	})
+}
+
+// ImportCSV imports key,value rows into a lookup table. The CSV is read from
+// a multipart "file" field or from the raw request body. ?mode=replace
+// replaces all entries; by default the rows are merged.
+func (h *LookupTableHandler) ImportCSV(c *gin.Context) {
+	id, ok := lookupTableID(c)
+	if !ok {
+		return
+	}
+
+	mode := c.DefaultQuery("mode", "merge")
+	if mode != "merge" && mode != "replace" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid mode: must be merge or replace",
+		})
+		return
+	}
+
+	var body io.Reader
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Error reading uploaded file",
+			})
+			return
+		}
+		defer f.Close()
+		body = f
+	} else {
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Error reading request body",
+			})
+			return
+		}
+		body = bytes.NewReader(data)
+	}
+
+	table, imported, err := h.lookupTableService.ImportCSV(id, body, mode == "replace")
+	if err != nil {
+		h.respondLookupTableError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Lookup table imported successfully",
+		"imported": imported,
+		"data":     toLookupTableResponse(table, false),
+	})
+}
+
+// ExportCSV downloads the entries of a lookup table as CSV
+func (h *LookupTableHandler) ExportCSV(c *gin.Context) {
+	id, ok := lookupTableID(c)
+	if !ok {
+		return
+	}
+
+	table, err := h.lookupTableService.GetLookupTable(id)
+	if err != nil {
+		h.respondLookupTableError(c, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := h.lookupTableService.ExportCSV(id, &buf); err != nil {
+		h.respondLookupTableError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", table.Name+".csv"))
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
+
+// lookupTableID parses the lookup table ID parameter, responding with an
+// error if it is invalid
+func lookupTableID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid lookup table ID",
+		})
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+// respondLookupTableError maps a lookup table service error to a response
+func (h *LookupTableHandler) respondLookupTableError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, models.ErrInvalidLookupTable), errors.Is(err, models.ErrInvalidLookupCSV):
+		status = http.StatusBadRequest
+	case errors.Is(err, models.ErrLookupTableExists), errors.Is(err, models.ErrLookupTableChanged):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
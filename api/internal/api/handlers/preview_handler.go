@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PreviewHandler handles dataflow preview API requests
+type PreviewHandler struct {
+	previewService *services.PreviewService
+}
+
+// NewPreviewHandler creates a new preview handler
+func NewPreviewHandler(previewService *services.PreviewService) *PreviewHandler {
+	return &PreviewHandler{
+		previewService: previewService,
+	}
+}
+
+// PreviewDataflow runs a dataflow's transformation without writing anything
+func (h *PreviewHandler) PreviewDataflow(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid dataflow ID",
+		})
+		return
+	}
+
+	var request struct {
+		SourceID string          `json:"source_id"` // Shopware entity ID to fetch
+		Payload  json.RawMessage `json:"payload"`   // Raw Shopware entity JSON
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if request.SourceID == "" && len(request.Payload) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Either source_id or payload is required",
+		})
+		return
+	}
+
+	preview, err := h.previewService.PreviewDataflow(uint(id), request.SourceID, request.Payload)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": preview,
+	})
+}
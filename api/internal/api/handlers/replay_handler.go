@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReplayHandler handles migration replay API requests
+type ReplayHandler struct {
+	replayService *services.ReplayService
+}
+
+// NewReplayHandler creates a new replay handler
+func NewReplayHandler(replayService *services.ReplayService) *ReplayHandler {
+	return &ReplayHandler{
+		replayService: replayService,
+	}
+}
+
+// ReplayMigrationLog replays a single migration log
+func (h *ReplayHandler) ReplayMigrationLog(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid dataflow ID",
+		})
+		return
+	}
+
+	logID, err := strconv.ParseUint(c.Param("logId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid migration log ID",
+		})
+		return
+	}
+
+	// The body is optional
+	var request struct {
+		Refetch bool `json:"refetch"` // Fetch fresh source data from Shopware
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Invalid request body",
+			})
+			return
+		}
+	}
+
+	migrationLog, err := h.replayService.ReplayMigration(uint(id), uint(logID), request.Refetch)
+	if err != nil {
+		h.respondReplayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Migration replay started",
+		"data":    toMigrationLogResponse(migrationLog),
+	})
+}
+
+// ReplayMigrationLogs replays the migration logs that match a filter
+func (h *ReplayHandler) ReplayMigrationLogs(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid dataflow ID",
+		})
+		return
+	}
+
+	var request struct {
+		Status        models.MigrationStatus `json:"status"`
+		From          *time.Time             `json:"from"`
+		To            *time.Time             `json:"to"`
+		ErrorContains string                 `json:"error_contains"`
+		Limit         int                    `json:"limit"`
+		Refetch       bool                   `json:"refetch"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	result, err := h.replayService.ReplayMigrations(uint(id), services.ReplayFilter{
+		Status:        request.Status,
+		From:          request.From,
+		To:            request.To,
+		ErrorContains: request.ErrorContains,
+		Limit:         request.Limit,
+	}, request.Refetch)
+	if err != nil {
+		h.respondReplayError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Migration replay started",
+		"data":    result,
+	})
+}
+
+// respondReplayError writes the error response for a failed replay
+func (h *ReplayHandler) respondReplayError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, models.ErrInvalidReplayState) {
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// RunHandler handles dataflow run API requests
+type RunHandler struct {
+	dataflowService *services.DataflowService
+	backfillService *services.BackfillService
+}
+
+// NewRunHandler creates a new run handler
+func NewRunHandler(
+	dataflowService *services.DataflowService,
+	backfillService *services.BackfillService,
+) *RunHandler {
+	return &RunHandler{
+		dataflowService: dataflowService,
+		backfillService: backfillService,
+	}
+}
+
+// DataflowRunResponse represents a dataflow run response
+type DataflowRunResponse struct {
+	ID          uint             `json:"id"`
+	DataflowID  uint             `json:"dataflow_id"`
+	Type        models.RunType   `json:"type"`
+	Status      models.RunStatus `json:"status"`
+	PageSize    int              `json:"page_size"`
+	NextPage    int              `json:"next_page"`
+	Bulk        bool             `json:"bulk"`
+	Total       int              `json:"total"`
+	Processed   int              `json:"processed"`
+	Succeeded   int              `json:"succeeded"`
+	Failed      int              `json:"failed"`
+	Progress    float64          `json:"progress"`
+	LastError   string           `json:"last_error,omitempty"`
+	StartedAt   string           `json:"started_at,omitempty"`
+	CompletedAt string           `json:"completed_at,omitempty"`
+	CreatedAt   string           `json:"created_at"`
+	UpdatedAt   string           `json:"updated_at"`
+}
+
+// toDataflowRunResponse converts a dataflow run model to a response
+func toDataflowRunResponse(run *models.DataflowRun) DataflowRunResponse {
+	response := DataflowRunResponse{
+		ID:         run.ID,
+		DataflowID: run.DataflowID,
+		Type:       run.Type,
+		Status:     run.Status,
+		PageSize:   run.PageSize,
+		NextPage:   run.NextPage,
+		Bulk:       run.Bulk,
+		Total:      run.Total,
+		Processed:  run.Processed,
+		Succeeded:  run.Succeeded,
+		Failed:     run.Failed,
+		LastError:  run.LastError,
+		CreatedAt:  run.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:  run.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+	}
+
+	if run.Total > 0 {
+		response.Progress = float64(run.Processed) / float64(run.Total)
+	}
+	if run.Status == models.RunStatusCompleted {
+		response.Progress = 1
+	}
+
+	if run.StartedAt != nil {
+		response.StartedAt = run.StartedAt.Format("2006-01-02T15:04:05Z")
+	}
+	if run.CompletedAt != nil {
+		response.CompletedAt = run.CompletedAt.Format("2006-01-02T15:04:05Z")
+	}
+
+	return response
+}
+
+// CreateRun starts a new run for a dataflow
+func (h *RunHandler) CreateRun(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid dataflow ID",
+		})
+		return
+	}
+
+	var request struct {
+		Type     models.RunType `json:"type"`
+		PageSize int            `json:"page_size"`
+		Bulk     bool           `json:"bulk"`
+	}
+
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid request body",
+		})
+		return
+	}
+
+	if request.Type == "" {
+		request.Type = models.RunTypeBackfill
+	}
+
+	if request.Type != models.RunTypeBackfill {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unsupported run type: " + string(request.Type),
+		})
+		return
+	}
+
+	run, err := h.backfillService.StartBackfill(uint(id), request.PageSize, request.Bulk)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, models.ErrBulkNotSupported) || errors.Is(err, models.ErrBackfillNotSupported) {
+			status = http.StatusBadRequest
+		}
+
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Run started successfully",
+		"data":    toDataflowRunResponse(run),
+	})
+}
+
+// ListRuns lists the runs of a dataflow
+func (h *RunHandler) ListRuns(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid dataflow ID",
+		})
+		return
+	}
+
+	// Verify the dataflow exists
+	if _, err := h.dataflowService.GetDataflow(uint(id)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	runs, err := h.backfillService.ListRuns(uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	var response []DataflowRunResponse
+	for _, run := range runs {
+		response = append(response, toDataflowRunResponse(&run))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": response,
+	})
+}
+
+// GetRun gets a run of a dataflow
+func (h *RunHandler) GetRun(c *gin.Context) {
+	run, ok := h.findRun(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": toDataflowRunResponse(run),
+	})
+}
+
+// PauseRun pauses a running run
+func (h *RunHandler) PauseRun(c *gin.Context) {
+	run, ok := h.findRun(c)
+	if !ok {
+		return
+	}
+
+	run, err := h.backfillService.PauseRun(run.ID)
+	if err != nil {
+		h.respondRunError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Run paused successfully",
+		"data":    toDataflowRunResponse(run),
+	})
+}
+
+// ResumeRun resumes a paused or failed run from its checkpoint
+func (h *RunHandler) ResumeRun(c *gin.Context) {
+	run, ok := h.findRun(c)
+	if !ok {
+		return
+	}
+
+	run, err := h.backfillService.ResumeRun(run.ID)
+	if err != nil {
+		h.respondRunError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Run resumed successfully",
+		"data":    toDataflowRunResponse(run),
+	})
+}
+
+// findRun loads the run from the URL and checks it belongs to the dataflow
+func (h *RunHandler) findRun(c *gin.Context) (*models.DataflowRun, bool) {
+	dataflowID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid dataflow ID",
+		})
+		return nil, false
+	}
+
+	runID, err := strconv.ParseUint(c.Param("runId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid run ID",
+		})
+		return nil, false
+	}
+
+	run, err := h.backfillService.GetRun(uint(runID))
+	if err != nil {
+		h.respondRunError(c, err)
+		return nil, false
+	}
+
+	if run.DataflowID != uint(dataflowID) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Run not found for this dataflow",
+		})
+		return nil, false
+	}
+
+	return run, true
+}
+
+// respondRunError writes the error response for a failed run operation
+func (h *RunHandler) respondRunError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		status = http.StatusNotFound
+	} else if errors.Is(err, models.ErrInvalidRunState) {
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"error": err.Error(),
+	})
+}
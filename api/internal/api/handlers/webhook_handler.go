@@ -9,56 +9,34 @@ import (
 	"gorm.io/gorm"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 )
 
 // WebhookHandler handles webhook requests
 type WebhookHandler struct {
-	db                   *gorm.DB
-	shopwareService      *services.ShopwareService
-	stepFunctionsService *services.StepFunctionsService
+	db              *gorm.DB
+	webhookService  *services.WebhookService
+	dataflowService *services.DataflowService
 }
 
 // NewWebhookHandler creates a new webhook handler
 func NewWebhookHandler(
 	db *gorm.DB,
-	shopwareService *services.ShopwareService,
-	stepFunctionsService *services.StepFunctionsService,
+	webhookService *services.WebhookService,
+	dataflowService *services.DataflowService,
 ) *WebhookHandler {
 	return &WebhookHandler{
-		db:                   db,
-		shopwareService:      shopwareService,
-		stepFunctionsService: stepFunctionsService,
+		db:              db,
+		webhookService:  webhookService,
+		dataflowService: dataflowService,
 	}
 }
 
-// ShopwareWebhookRequest represents a webhook request from Shopware
-
-type ShopwareWebhookRequest struct {
-	Data struct {
-		Payload []struct {
-			Entity        string   `json:"entity"`
-			Operation     string   `json:"operation"`
-			PrimaryKey    string   `json:"primaryKey"`
-			UpdatedFields []string `json:"updatedFields"`
-			VersionId     string   `json:"versionId"`
-		} `json:"payload"`
-		Event string `json:"event"`
-	} `json:"data"`
-	Source struct {
-		URL     string `json:"url"`
-		EventID string `json:"eventId"`
-	} `json:"source"`
-	Timestamp int64 `json:"timestamp"`
-}
-
+// HandleShopwareWebhook persists a Shopware webhook into the job queue and
+// acknowledges it immediately. The webhook is processed by a queue worker.
 func (h *WebhookHandler) HandleShopwareWebhook(c *gin.Context) {
-
-	println("nnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnnn")
 	// Read and validate the webhook payload
 	body, err := io.ReadAll(c.Request.Body)
-	//print(string(body))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Error reading request body",
@@ -66,7 +44,7 @@ func (h *WebhookHandler) HandleShopwareWebhook(c *gin.Context) {
 		return
 	}
 
-	var webhook ShopwareWebhookRequest
+	var webhook services.ShopwareWebhookRequest
 	if err := json.Unmarshal(body, &webhook); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid JSON payload",
@@ -74,8 +52,6 @@ func (h *WebhookHandler) HandleShopwareWebhook(c *gin.Context) {
 		return
 	}
 
-	fmt.Print(webhook)
-
 	// Check if there's a valid payload
 	if len(webhook.Data.Payload) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -84,127 +60,66 @@ func (h *WebhookHandler) HandleShopwareWebhook(c *gin.Context) {
 		return
 	}
 
-	// Determine data type and event type
-	var dataflowType models.DataflowType
-	if webhook.Data.Event == "product.written" {
-		dataflowType = models.DataflowTypeProduct
-	} else if webhook.Data.Event == "order.placed" {
-		dataflowType = models.DataflowTypeOrder
-	} else {
+	if _, err := services.DataflowTypeForEvent(webhook.Data.Event); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Unsupported event type: " + webhook.Data.Event,
 		})
 		return
 	}
 
-	// Find active dataflows for this data type
-	var dataflows []models.Dataflow
-	if err := h.db.Preload("SourceConnector").Preload("DestConnector").
-		Where("type = ? AND status = ?", dataflowType, models.DataflowStatusActive).
-		Find(&dataflows).Error; err != nil {
+	job, err := h.webhookService.EnqueueShopwareWebhook(body)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Error finding dataflows",
+			"error": "Error queuing webhook",
 		})
 		return
 	}
 
-	println("dataflowssssssssssssssssssssssssssssssssssssssssssssssss")
-	print(dataflows)
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Webhook queued for processing",
+		"job_id":  job.ID,
+	})
+}
 
-	if len(dataflows) == 0 {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "No active dataflows found for this data type",
+// HandleShopifyWebhook verifies the HMAC signature of a Shopify webhook with
+// the connector's client secret, persists it into the job queue and
+// acknowledges it immediately. The webhook is processed by a queue worker.
+func (h *WebhookHandler) HandleShopifyWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Error reading request body",
 		})
 		return
 	}
 
-	// Extract source identifier from data
-	sourceID := ""
-	for _, payload := range webhook.Data.Payload {
-		if dataflowType == models.DataflowTypeProduct && payload.Entity == "product" {
-			sourceID = payload.PrimaryKey
-		} else if dataflowType == models.DataflowTypeOrder && payload.Entity == "order" {
-			sourceID = payload.PrimaryKey
-		}
+	connector, err := h.webhookService.FindShopifyConnector(c.GetHeader("X-Shopify-Shop-Domain"))
+	if err != nil || !services.VerifyShopifyWebhook(connector.ApiSecret, body, c.GetHeader("X-Shopify-Hmac-Sha256")) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid webhook signature",
+		})
+		return
 	}
 
-	if sourceID == "" {
+	topic := c.GetHeader("X-Shopify-Topic")
+	if !services.IsShopifyWebhookTopic(topic) {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Could not determine source identifier",
+			"error": "Unsupported webhook topic: " + topic,
 		})
 		return
 	}
 
-	// For products, we need to fetch the full product data
-	var sourceData []byte
-	if dataflowType == models.DataflowTypeProduct {
-		// Find the Shopware connector that has the URL matching the source URL
-		var connector models.Connector
-		domain := strings.TrimPrefix(webhook.Source.URL, "https://")
-		if err := h.db.Where("type = ? AND url LIKE ?", models.ConnectorTypeShopware, "%"+domain+"%").First(&connector).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Could not find matching connector for the source URL",
-			})
-			return
-		}
-
-		// Get the full product data
-		product, err := h.shopwareService.GetProduct(&connector, sourceID)
-		println("Proooooooooooooooooooooooduct")
-		println(*&product)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to get product data: " + err.Error(),
-			})
-			return
-		}
-
-		sourceData, err = json.Marshal(product)
-
-		println(string(sourceData))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Failed to marshal product data",
-			})
-			return
-		}
-	} else {
-		// For orders, just pass the webhook payload as is
-		sourceData = body
-	}
-
-	// Process each matching dataflow
-	for _, dataflow := range dataflows {
-		// Create a migration log entry
-		migrationLog := models.MigrationLog{
-			DataflowID:       dataflow.ID,
-			Status:           models.MigrationStatusPending,
-			SourceIdentifier: sourceID,
-			SourcePayload:    string(sourceData),
-		}
-
-		if err := h.db.Create(&migrationLog).Error; err != nil {
-			// Log the error but continue with other dataflows
-			continue
-		}
-
-		// Start a Step Functions execution
-		executionARN, err := h.stepFunctionsService.StartExecution(dataflow.ID, migrationLog.ID, sourceData)
-		if err != nil {
-			migrationLog.Status = models.MigrationStatusFailed
-			migrationLog.ErrorMessage = err.Error()
-			h.db.Save(&migrationLog)
-			continue
-		}
-
-		// Update the migration log with the execution ARN
-		migrationLog.Status = models.MigrationStatusInProgress
-		migrationLog.ExecutionARN = executionARN
-		h.db.Save(&migrationLog)
+	job, err := h.webhookService.EnqueueShopifyWebhook(connector, topic, body)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Error queuing webhook",
+		})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Webhook processed successfully",
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Webhook queued for processing",
+		"job_id":  job.ID,
 	})
 }
 
@@ -269,6 +184,7 @@ func (h *WebhookHandler) UpdateMigrationStatus(c *gin.Context) {
 		Status          models.MigrationStatus `json:"status"`
 		DestIdentifier  interface{}            `json:"dest_identifier,omitempty"` // Changed from string to interface{}
 		ErrorMessage    string                 `json:"error_message,omitempty"`
+		ErrorClass      models.ErrorClass      `json:"error_class,omitempty"` // Decides whether a failure is retried
 		TransformedData json.RawMessage        `json:"transformed_data,omitempty"`
 	}
 
@@ -303,18 +219,36 @@ func (h *WebhookHandler) UpdateMigrationStatus(c *gin.Context) {
 		migrationLog.TransformedPayload = string(request.TransformedData)
 	}
 
-	if request.Status == models.MigrationStatusSuccess || request.Status == models.MigrationStatusFailed {
+	if request.Status == models.MigrationStatusSuccess {
 		now := time.Now()
+		migrationLog.Attempts++
+		migrationLog.ErrorClass = ""
+		migrationLog.NextRetryAt = nil
 		migrationLog.CompletedAt = &now
 	}
 
-	if err := h.db.Save(&migrationLog).Error; err != nil {
+	var err error
+	if request.Status == models.MigrationStatusFailed {
+		// Schedule a retry according to the dataflow's retry policy
+		err = h.dataflowService.RecordFailedAttempt(&migrationLog, request.ErrorClass)
+	} else {
+		err = h.db.Save(&migrationLog).Error
+	}
+
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Error updating migration log",
 		})
 		return
 	}
 
+	// Track the created entity so later syncs update it instead of duplicating it
+	if migrationLog.Status == models.MigrationStatusSuccess {
+		if err := h.dataflowService.RecordDestIdentifier(&migrationLog); err != nil {
+			fmt.Printf("Error recording entity mapping for migration %d: %v\n", migrationLog.ID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Migration status updated",
 	})
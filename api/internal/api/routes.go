@@ -1,10 +1,18 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/api/handlers"
 	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/api/middleware"
 	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/config"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
 	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/services"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -15,8 +23,14 @@ type Server struct {
 	router   *gin.Engine
 	config   *config.Config
 	database *gorm.DB
+	executor services.Executor
+	workers  *services.JobWorkerPool
+	retries  *services.RetryScheduler
 }
 
+// shutdownTimeout is how long in-flight requests get to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
 // NewServer creates a new API server
 func NewServer(cfg *config.Config, db *gorm.DB) *Server {
 	router := gin.Default()
@@ -42,13 +56,35 @@ func (s *Server) setupRoutes() {
 	fieldMappingService := services.NewFieldMappingService(s.database)
 	shopwareService := services.NewShopwareService(s.database)
 	//shopifyService := services.NewShopifyService(s.database)
-	stepFunctionsService := services.NewStepFunctionsService(s.config.AWS, s.database)
+	executor := services.NewExecutor(s.config, s.database)
+	s.executor = executor
+	jobQueueService := services.NewJobQueueService(s.database, s.config.Queue.MaxAttempts)
+	webhookService := services.NewWebhookService(s.database, shopwareService, executor, jobQueueService)
+	backfillService := services.NewBackfillService(s.database, shopwareService, jobQueueService)
+	replayService := services.NewReplayService(s.database, shopwareService, executor)
+	previewService := services.NewPreviewService(s.database, shopwareService)
+	lookupTableService := services.NewLookupTableService(s.database)
+
+	// Start the job queue workers
+	s.workers = services.NewJobWorkerPool(jobQueueService, s.config.Queue)
+	s.workers.Register(models.JobTypeShopwareWebhook, webhookService.HandleShopwareWebhookJob)
+	s.workers.Register(models.JobTypeBackfillPage, backfillService.HandleBackfillPageJob)
+	s.workers.Register(models.JobTypeShopifyWebhook, webhookService.HandleShopifyWebhookJob)
+	s.workers.Start()
+
+	// Start the scheduler that retries failed migrations
+	s.retries = services.NewRetryScheduler(s.database, executor, s.config.Retry)
+	s.retries.Start()
 
 	// Create handlers
 	//connectorHandler := handlers.NewConnectorHandler(connectorService)
 	connectorHandler := handlers.NewConnectorHandler(connectorService, s.config)
 	dataflowHandler := handlers.NewDataflowHandler(dataflowService, fieldMappingService)
-	webhookHandler := handlers.NewWebhookHandler(s.database, shopwareService, stepFunctionsService)
+	runHandler := handlers.NewRunHandler(dataflowService, backfillService)
+	replayHandler := handlers.NewReplayHandler(replayService)
+	previewHandler := handlers.NewPreviewHandler(previewService)
+	lookupTableHandler := handlers.NewLookupTableHandler(lookupTableService)
+	webhookHandler := handlers.NewWebhookHandler(s.database, webhookService, dataflowService)
 
 	keycloakMiddleware := middleware.NewKeycloakMiddleware(s.config.Keycloak)
 
@@ -60,6 +96,7 @@ func (s *Server) setupRoutes() {
 			c.JSON(200, gin.H{"status": "Healthy!"})
 		})
 		publicGroup.POST("/webhook/shopware", webhookHandler.HandleShopwareWebhook)
+		publicGroup.POST("/webhook/shopify", webhookHandler.HandleShopifyWebhook)
 		publicGroup.GET("shopify/callback", func(c *gin.Context) {
 			c.JSON(200, gin.H{"status": "Shopify"})
 		})
@@ -88,6 +125,7 @@ func (s *Server) setupRoutes() {
 		privateGroup.PUT("/dataflows/:id", dataflowHandler.UpdateDataflow)
 		privateGroup.DELETE("/dataflows/:id", dataflowHandler.DeleteDataflow)
 		privateGroup.POST("/dataflows/:id/mappings/defaults", dataflowHandler.ApplyDefaultMappings)
+		privateGroup.POST("/dataflows/:id/preview", previewHandler.PreviewDataflow)
 
 		// Field mapping routes
 		privateGroup.GET("/dataflows/:id/mappings", dataflowHandler.ListFieldMappings)
@@ -98,6 +136,26 @@ func (s *Server) setupRoutes() {
 		// Migration log routes
 		privateGroup.GET("/dataflows/:id/logs", dataflowHandler.ListMigrationLogs)
 		privateGroup.GET("/dataflows/:id/logs/:logId", dataflowHandler.GetMigrationLog)
+		privateGroup.POST("/dataflows/:id/logs/replay", replayHandler.ReplayMigrationLogs)
+		privateGroup.POST("/dataflows/:id/logs/:logId/replay", replayHandler.ReplayMigrationLog)
+
+		// Dataflow run routes
+		privateGroup.GET("/dataflows/:id/runs", runHandler.ListRuns)
+		privateGroup.POST("/dataflows/:id/runs", runHandler.CreateRun)
+		privateGroup.GET("/dataflows/:id/runs/:runId", runHandler.GetRun)
+		privateGroup.POST("/dataflows/:id/runs/:runId/pause", runHandler.PauseRun)
+		privateGroup.POST("/dataflows/:id/runs/:runId/resume", runHandler.ResumeRun)
+
+		// Lookup table routes
+		privateGroup.GET("/lookup-tables", lookupTableHandler.ListLookupTables)
+		privateGroup.POST("/lookup-tables", lookupTableHandler.CreateLookupTable)
+		privateGroup.GET("/lookup-tables/:id", lookupTableHandler.GetLookupTable)
+		privateGroup.PUT("/lookup-tables/:id", lookupTableHandler.UpdateLookupTable)
+		privateGroup.DELETE("/lookup-tables/:id", lookupTableHandler.DeleteLookupTable)
+		privateGroup.GET("/lookup-tables/:id/versions", lookupTableHandler.ListVersions)
+		privateGroup.POST("/lookup-tables/:id/versions/:version/restore", lookupTableHandler.RestoreVersion)
+		privateGroup.POST("/lookup-tables/:id/import", lookupTableHandler.ImportCSV)
+		privateGroup.GET("/lookup-tables/:id/export", lookupTableHandler.ExportCSV)
 	}
 
 	// Route group for Lambda function callbacks with API key auth
@@ -112,7 +170,46 @@ func (s *Server) setupRoutes() {
 	}
 }
 
-// Run starts the API server
+// Run starts the API server and blocks until it is stopped by SIGINT or
+// SIGTERM. On shutdown the background workers are stopped after the last
+// request, so running jobs finish and release their leases.
 func (s *Server) Run() error {
-	return s.router.Run(fmt.Sprintf(":%d", s.config.Server.Port))
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", s.config.Server.Port),
+		Handler: s.router,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = server.Shutdown(shutdownCtx)
+	}
+
+	s.Stop()
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Stop stops the job queue workers, the retry scheduler and the executor's
+// local workers, waiting for their running work to finish
+func (s *Server) Stop() {
+	s.workers.Stop()
+	s.retries.Stop()
+	if executor, ok := s.executor.(interface{ Stop() }); ok {
+		executor.Stop()
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 )
 
 type KeycloakConfig struct {
@@ -20,6 +21,9 @@ type Config struct {
 	Database DatabaseConfig
 	AWS      AWSConfig
 	Keycloak KeycloakConfig
+	Executor ExecutorConfig
+	Queue    QueueConfig
+	Retry    RetryConfig
 }
 
 // ServerConfig holds server related configuration
@@ -47,6 +51,35 @@ type AWSConfig struct {
 	StepFunctionsARN string
 }
 
+// ExecutorConfig holds configuration for the migration execution engine
+type ExecutorConfig struct {
+	Type      string // "step_functions" or "local"
+	Workers   int
+	QueueSize int
+}
+
+// QueueConfig holds configuration for the durable job queue workers
+type QueueConfig struct {
+	Workers       int
+	PollInterval  time.Duration
+	LeaseDuration time.Duration
+	MaxAttempts   int
+}
+
+// RetryConfig holds configuration for the failed migration retry scheduler
+type RetryConfig struct {
+	Interval     time.Duration
+	BatchSize    int
+	PendingLease time.Duration // How long a locally queued migration may stay pending before it is dispatched again
+}
+
+const (
+	// ExecutorTypeStepFunctions runs migrations through AWS Step Functions
+	ExecutorTypeStepFunctions = "step_functions"
+	// ExecutorTypeLocal runs migrations in-process on a goroutine worker pool
+	ExecutorTypeLocal = "local"
+)
+
 func Load() (*Config, error) {
 	// Load existing config
 	cfg, err := loadExistingConfig()
@@ -61,9 +94,107 @@ func Load() (*Config, error) {
 		ClientID: getEnv("KEYCLOAK_CLIENT_ID", "shopware-shopify-integration"),
 	}
 
+	// Add executor configuration
+	executor, err := loadExecutorConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Executor = executor
+
+	// Add job queue configuration
+	queue, err := loadQueueConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Queue = queue
+
+	retry, err := loadRetryConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Retry = retry
+
 	return cfg, nil
 }
 
+// loadExecutorConfig loads the migration executor configuration
+func loadExecutorConfig() (ExecutorConfig, error) {
+	executorType := getEnv("EXECUTOR_TYPE", ExecutorTypeStepFunctions)
+	if executorType != ExecutorTypeStepFunctions && executorType != ExecutorTypeLocal {
+		return ExecutorConfig{}, fmt.Errorf("invalid EXECUTOR_TYPE: %s", executorType)
+	}
+
+	workers, err := strconv.Atoi(getEnv("EXECUTOR_WORKERS", "4"))
+	if err != nil || workers < 1 {
+		return ExecutorConfig{}, fmt.Errorf("invalid EXECUTOR_WORKERS: %s", getEnv("EXECUTOR_WORKERS", "4"))
+	}
+
+	queueSize, err := strconv.Atoi(getEnv("EXECUTOR_QUEUE_SIZE", "100"))
+	if err != nil || queueSize < 1 {
+		return ExecutorConfig{}, fmt.Errorf("invalid EXECUTOR_QUEUE_SIZE: %s", getEnv("EXECUTOR_QUEUE_SIZE", "100"))
+	}
+
+	return ExecutorConfig{
+		Type:      executorType,
+		Workers:   workers,
+		QueueSize: queueSize,
+	}, nil
+}
+
+// loadQueueConfig loads the job queue configuration
+func loadQueueConfig() (QueueConfig, error) {
+	workers, err := strconv.Atoi(getEnv("QUEUE_WORKERS", "2"))
+	if err != nil || workers < 1 {
+		return QueueConfig{}, fmt.Errorf("invalid QUEUE_WORKERS: %s", getEnv("QUEUE_WORKERS", "2"))
+	}
+
+	pollInterval, err := time.ParseDuration(getEnv("QUEUE_POLL_INTERVAL", "2s"))
+	if err != nil {
+		return QueueConfig{}, fmt.Errorf("invalid QUEUE_POLL_INTERVAL: %w", err)
+	}
+
+	leaseDuration, err := time.ParseDuration(getEnv("QUEUE_LEASE_DURATION", "60s"))
+	if err != nil {
+		return QueueConfig{}, fmt.Errorf("invalid QUEUE_LEASE_DURATION: %w", err)
+	}
+
+	maxAttempts, err := strconv.Atoi(getEnv("QUEUE_MAX_ATTEMPTS", "5"))
+	if err != nil || maxAttempts < 1 {
+		return QueueConfig{}, fmt.Errorf("invalid QUEUE_MAX_ATTEMPTS: %s", getEnv("QUEUE_MAX_ATTEMPTS", "5"))
+	}
+
+	return QueueConfig{
+		Workers:       workers,
+		PollInterval:  pollInterval,
+		LeaseDuration: leaseDuration,
+		MaxAttempts:   maxAttempts,
+	}, nil
+}
+
+// loadRetryConfig loads the retry scheduler configuration
+func loadRetryConfig() (RetryConfig, error) {
+	interval, err := time.ParseDuration(getEnv("RETRY_SCHEDULER_INTERVAL", "30s"))
+	if err != nil || interval <= 0 {
+		return RetryConfig{}, fmt.Errorf("invalid RETRY_SCHEDULER_INTERVAL: %s", getEnv("RETRY_SCHEDULER_INTERVAL", "30s"))
+	}
+
+	batchSize, err := strconv.Atoi(getEnv("RETRY_BATCH_SIZE", "100"))
+	if err != nil || batchSize < 1 {
+		return RetryConfig{}, fmt.Errorf("invalid RETRY_BATCH_SIZE: %s", getEnv("RETRY_BATCH_SIZE", "100"))
+	}
+
+	pendingLease, err := time.ParseDuration(getEnv("RETRY_PENDING_LEASE", "5m"))
+	if err != nil || pendingLease <= 0 {
+		return RetryConfig{}, fmt.Errorf("invalid RETRY_PENDING_LEASE: %s", getEnv("RETRY_PENDING_LEASE", "5m"))
+	}
+
+	return RetryConfig{
+		Interval:     interval,
+		BatchSize:    batchSize,
+		PendingLease: pendingLease,
+	}, nil
+}
+
 // loadExistingConfig loads the existing configuration
 func loadExistingConfig() (*Config, error) {
 	// Load .env file if it exists
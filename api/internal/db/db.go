@@ -34,5 +34,11 @@ func AutoMigrate(db *gorm.DB) error {
 		&models.Dataflow{},
 		&models.FieldMapping{},
 		&models.MigrationLog{},
+		&models.Job{},
+		&models.EntityMapping{},
+		&models.DataflowRun{},
+		&models.LookupTable{},
+		&models.LookupTableVersion{},
+		&models.SyncEcho{},
 	)
 }
@@ -0,0 +1,334 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Env holds the variables an expression can read. Field mappings pass the
+// parsed source document, so its top-level fields are variables.
+type Env map[string]interface{}
+
+// Program is a compiled expression. A program is safe for concurrent use.
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile parses an expression into a program
+func Compile(source string) (*Program, error) {
+	root, err := parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling expression %q: %w", source, err)
+	}
+
+	return &Program{source: source, root: root}, nil
+}
+
+// Source returns the expression the program was compiled from
+func (p *Program) Source() string {
+	return p.source
+}
+
+// Run evaluates the program. Fields that do not exist evaluate to null.
+func (p *Program) Run(env Env) (interface{}, error) {
+	value, err := p.root.eval(env)
+	if err != nil {
+		return nil, fmt.Errorf("error evaluating expression %q: %w", p.source, err)
+	}
+	return value, nil
+}
+
+func (n *literalNode) eval(env Env) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *identNode) eval(env Env) (interface{}, error) {
+	return env[n.name], nil
+}
+
+func (n *memberNode) eval(env Env) (interface{}, error) {
+	object, err := n.object.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	key, err := n.key.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return member(object, key), nil
+}
+
+// member reads a field of an object or an element of an array. Missing
+// fields and out of range indexes read as null.
+func member(object, key interface{}) interface{} {
+	switch o := object.(type) {
+	case map[string]interface{}:
+		return o[ToString(key)]
+
+	case []interface{}:
+		index, ok := ToNumber(key)
+		if !ok {
+			return nil
+		}
+		i := int(index)
+		if i < 0 {
+			i += len(o)
+		}
+		if i < 0 || i >= len(o) {
+			return nil
+		}
+		return o[i]
+	}
+
+	return nil
+}
+
+func (n *unaryNode) eval(env Env) (interface{}, error) {
+	operand, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.op == "!" {
+		return !Truthy(operand), nil
+	}
+
+	number, ok := ToNumber(operand)
+	if !ok {
+		return nil, fmt.Errorf("cannot negate %v", operand)
+	}
+	return -number, nil
+}
+
+func (n *binaryNode) eval(env Env) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit operators only evaluate the right side when needed
+	switch n.op {
+	case "??":
+		if left != nil {
+			return left, nil
+		}
+		return n.right.eval(env)
+	case "||":
+		if Truthy(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(env)
+		return Truthy(right), err
+	case "&&":
+		if !Truthy(left) {
+			return false, nil
+		}
+		right, err := n.right.eval(env)
+		return Truthy(right), err
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return Equal(left, right), nil
+	case "!=":
+		return !Equal(left, right), nil
+	case "+":
+		return add(left, right)
+	case "<", "<=", ">", ">=":
+		return compare(n.op, left, right)
+	}
+
+	// The remaining operators are arithmetic
+	l, lok := ToNumber(left)
+	r, rok := ToNumber(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator %s needs numbers, got %v and %v", n.op, left, right)
+	}
+
+	switch n.op {
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return l / r, nil
+	default: // %
+		if r == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(l, r), nil
+	}
+}
+
+// add adds two numbers, or concatenates when either side is a string. Null
+// concatenates as an empty string.
+func add(left, right interface{}) (interface{}, error) {
+	_, lstr := left.(string)
+	_, rstr := right.(string)
+	if lstr || rstr {
+		return ToString(left) + ToString(right), nil
+	}
+
+	l, lok := ToNumber(left)
+	r, rok := ToNumber(right)
+	if !lok || !rok {
+		return nil, fmt.Errorf("operator + needs numbers or strings, got %v and %v", left, right)
+	}
+	return l + r, nil
+}
+
+// compare applies an ordering operator to two numbers or two strings
+func compare(op string, left, right interface{}) (bool, error) {
+	var cmp int
+
+	ls, lstr := left.(string)
+	rs, rstr := right.(string)
+	if lstr && rstr {
+		cmp = strings.Compare(ls, rs)
+	} else {
+		l, lok := ToNumber(left)
+		r, rok := ToNumber(right)
+		if !lok || !rok {
+			return false, fmt.Errorf("cannot compare %v and %v", left, right)
+		}
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func (n *conditionalNode) eval(env Env) (interface{}, error) {
+	cond, err := n.cond.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if Truthy(cond) {
+		return n.then.eval(env)
+	}
+	return n.otherwise.eval(env)
+}
+
+func (n *callNode) eval(env Env) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+
+	result, err := n.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return result, nil
+}
+
+// Truthy reports whether a value counts as true. Null, false, zero, empty
+// strings and empty arrays and objects are false.
+func Truthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+
+	if number, ok := ToNumber(value); ok {
+		return number != 0
+	}
+	return true
+}
+
+// ToNumber converts a number or a numeric string to a float64
+func ToNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ToString formats a value for string concatenation. Null is empty and
+// whole numbers have no decimal point.
+func ToString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+// Equal compares two values loosely, so 1 equals "1". Two strings are
+// compared as strings, so "007" does not equal "7".
+func Equal(left, right interface{}) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+
+	ls, lstr := left.(string)
+	rs, rstr := right.(string)
+	if lstr && rstr {
+		return ls == rs
+	}
+
+	l, lok := ToNumber(left)
+	r, rok := ToNumber(right)
+	if lok && rok {
+		return l == r
+	}
+
+	if lb, ok := left.(bool); ok {
+		rb, ok := right.(bool)
+		return ok && lb == rb
+	}
+
+	return ToString(left) == ToString(right)
+}
@@ -0,0 +1,112 @@
+package expr
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	env := Env{
+		"name":  "Shirt",
+		"price": 10.0,
+		"stock": 0.0,
+		"tags":  []interface{}{"a", "b"},
+		"tax":   map[string]interface{}{"rate": 19.0},
+	}
+
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"1 + 2 * 3", `7`},
+		{"(1 + 2) * 3", `9`},
+		{"7 % 4", `3`},
+		{"-price", `-10`},
+		{"price / 4", `2.5`},
+		{"name + ' ' + price", `"Shirt 10"`},
+		{"missing + 'x'", `"x"`},
+		{"tax.rate", `19`},
+		{"tax['rate']", `19`},
+		{"tags[1]", `"b"`},
+		{"tags[-1]", `"b"`},
+		{"tags[5]", `null`},
+		{"missing.field", `null`},
+		{"price > 5 && stock == 0", `true`},
+		{"stock || 'none'", `true`},
+		{"!stock", `true`},
+		{"'a' < 'b'", `true`},
+		{"'7' == 7", `true`},
+		{"'007' == '7'", `false`},
+		{"'9007199254740993' == '9007199254740992'", `false`},
+		{"'1.0' != '1'", `true`},
+		{"missing ?? 'default'", `"default"`},
+		{"stock ?? 'default'", `0`},
+		{"stock > 0 ? 'in stock' : 'sold out'", `"sold out"`},
+		{"upper(name)", `"SHIRT"`},
+		{"concat(name, '-', 1)", `"Shirt-1"`},
+		{"join(tags, ',')", `"a,b"`},
+		{"contains(tags, 'b')", `true`},
+		{"len(tags)", `2`},
+		{"coalesce(missing, name)", `"Shirt"`},
+		{"round(price * 1.19, 2)", `11.9`},
+	}
+
+	for _, test := range tests {
+		program, err := Compile(test.source)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", test.source, err)
+			continue
+		}
+
+		value, err := program.Run(env)
+		if err != nil {
+			t.Errorf("Run(%q): %v", test.source, err)
+			continue
+		}
+
+		got, _ := json.Marshal(value)
+		if string(got) != test.want {
+			t.Errorf("Run(%q) = %s, want %s", test.source, got, test.want)
+		}
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"1 +",
+		"(1",
+		"'unterminated",
+		"a ? b",
+		"unknown(1)",
+		"upper()",
+		"upper(1, 2)",
+	}
+
+	for _, source := range tests {
+		if _, err := Compile(source); err == nil {
+			t.Errorf("Compile(%q) succeeded, want an error", source)
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []string{
+		"1 / 0",
+		"1 % 0",
+		"-'a'",
+		"'a' * 2",
+		"'a' < 1",
+	}
+
+	for _, source := range tests {
+		program, err := Compile(source)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", source, err)
+			continue
+		}
+		if _, err := program.Run(Env{}); err == nil {
+			t.Errorf("Run(%q) succeeded, want an error", source)
+		}
+	}
+}
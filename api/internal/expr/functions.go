@@ -0,0 +1,208 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// function is a built-in function. A maxArgs of -1 accepts any number of
+// arguments.
+type function struct {
+	minArgs int
+	maxArgs int
+	call    func(args []interface{}) (interface{}, error)
+}
+
+// functions lists the built-in functions by name
+var functions = map[string]function{
+	"upper": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.ToUpper(ToString(args[0])), nil
+	}},
+	"lower": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.ToLower(ToString(args[0])), nil
+	}},
+	"trim": {1, 1, func(args []interface{}) (interface{}, error) {
+		return strings.TrimSpace(ToString(args[0])), nil
+	}},
+	"concat": {1, -1, func(args []interface{}) (interface{}, error) {
+		var sb strings.Builder
+		for _, arg := range args {
+			sb.WriteString(ToString(arg))
+		}
+		return sb.String(), nil
+	}},
+	"join": {1, 2, joinFunc},
+	"split": {2, 2, func(args []interface{}) (interface{}, error) {
+		parts := strings.Split(ToString(args[0]), ToString(args[1]))
+		result := make([]interface{}, len(parts))
+		for i, part := range parts {
+			result[i] = part
+		}
+		return result, nil
+	}},
+	"replace": {3, 3, func(args []interface{}) (interface{}, error) {
+		return strings.ReplaceAll(ToString(args[0]), ToString(args[1]), ToString(args[2])), nil
+	}},
+	"substr": {2, 3, substrFunc},
+	"contains": {2, 2, func(args []interface{}) (interface{}, error) {
+		if list, ok := args[0].([]interface{}); ok {
+			for _, item := range list {
+				if Equal(item, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(ToString(args[0]), ToString(args[1])), nil
+	}},
+	"len": {1, 1, func(args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case nil:
+			return float64(0), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return float64(len([]rune(ToString(args[0])))), nil
+	}},
+	"coalesce": {1, -1, func(args []interface{}) (interface{}, error) {
+		for _, arg := range args {
+			if arg != nil && arg != "" {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	}},
+	"string": {1, 1, func(args []interface{}) (interface{}, error) {
+		return ToString(args[0]), nil
+	}},
+	"number": {1, 1, func(args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		number, ok := ToNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("%v is not a number", args[0])
+		}
+		return number, nil
+	}},
+	"round": {1, 2, roundFunc},
+	"floor": {1, 1, numberFunc(math.Floor)},
+	"ceil":  {1, 1, numberFunc(math.Ceil)},
+	"abs":   {1, 1, numberFunc(math.Abs)},
+	"min":   {1, -1, extremeFunc(math.Min)},
+	"max":   {1, -1, extremeFunc(math.Max)},
+}
+
+// joinFunc joins the non-null elements of an array with a separator
+// (default ", ")
+func joinFunc(args []interface{}) (interface{}, error) {
+	list, ok := args[0].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("first argument must be an array")
+	}
+
+	separator := ", "
+	if len(args) > 1 {
+		separator = ToString(args[1])
+	}
+
+	parts := make([]string, 0, len(list))
+	for _, item := range list {
+		if item != nil {
+			parts = append(parts, ToString(item))
+		}
+	}
+	return strings.Join(parts, separator), nil
+}
+
+// substrFunc returns part of a string by rune offset and optional length
+func substrFunc(args []interface{}) (interface{}, error) {
+	runes := []rune(ToString(args[0]))
+
+	start, ok := ToNumber(args[1])
+	if !ok {
+		return nil, fmt.Errorf("start must be a number")
+	}
+	from := clamp(int(start), len(runes))
+
+	to := len(runes)
+	if len(args) > 2 {
+		length, ok := ToNumber(args[2])
+		if !ok {
+			return nil, fmt.Errorf("length must be a number")
+		}
+		to = clamp(from+int(length), len(runes))
+	}
+
+	return string(runes[from:to]), nil
+}
+
+// clamp limits an index to [0, n]
+func clamp(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+// roundFunc rounds a number to an optional number of decimal places
+func roundFunc(args []interface{}) (interface{}, error) {
+	number, ok := ToNumber(args[0])
+	if !ok {
+		return nil, fmt.Errorf("%v is not a number", args[0])
+	}
+
+	precision := 0.0
+	if len(args) > 1 {
+		if precision, ok = ToNumber(args[1]); !ok {
+			return nil, fmt.Errorf("precision must be a number")
+		}
+	}
+
+	scale := math.Pow(10, precision)
+	return math.Round(number*scale) / scale, nil
+}
+
+// numberFunc adapts a one-argument math function
+func numberFunc(fn func(float64) float64) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		number, ok := ToNumber(args[0])
+		if !ok {
+			return nil, fmt.Errorf("%v is not a number", args[0])
+		}
+		return fn(number), nil
+	}
+}
+
+// extremeFunc folds the numeric arguments, or the elements of a single array
+// argument, with min or max. Null values are ignored.
+func extremeFunc(fn func(float64, float64) float64) func([]interface{}) (interface{}, error) {
+	return func(args []interface{}) (interface{}, error) {
+		if list, ok := args[0].([]interface{}); ok && len(args) == 1 {
+			args = list
+		}
+
+		var result interface{}
+		for _, arg := range args {
+			if arg == nil {
+				continue
+			}
+			number, ok := ToNumber(arg)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a number", arg)
+			}
+			if result == nil {
+				result = number
+			} else {
+				result = fn(result.(float64), number)
+			}
+		}
+		return result, nil
+	}
+}
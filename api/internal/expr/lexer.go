@@ -0,0 +1,149 @@
+// Package expr implements the small expression language used by field
+// mappings to combine several source fields into one value.
+//
+// Expressions support number, string, boolean and null literals, source
+// field paths (name, manufacturer.name, price[0].gross), arithmetic, string
+// concatenation with +, comparisons, && and ||, null coalescing with ??,
+// the ternary operator and a set of built-in functions.
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenKind is the kind of a lexical token
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOperator
+)
+
+// token is a lexical token of an expression
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// operators lists the operators, longest first so that "??" wins over "?"
+var operators = []string{
+	"??", "==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", "[", "]", ".", ",",
+}
+
+// lex splits an expression into tokens
+func lex(source string) ([]token, error) {
+	var tokens []token
+
+	for pos := 0; pos < len(source); {
+		c := rune(source[pos])
+
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+
+		case isDigit(source[pos]):
+			start := pos
+			pos = skipDigits(source, pos)
+			// A dot only continues the number when a digit follows it, so
+			// that price.0.gross reads as a path
+			if pos+1 < len(source) && source[pos] == '.' && isDigit(source[pos+1]) {
+				pos = skipDigits(source, pos+1)
+			}
+			num, err := strconv.ParseFloat(source[start:pos], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", source[start:pos], start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[start:pos], num: num, pos: start})
+
+		case c == '"' || c == '\'':
+			str, end, err := lexString(source, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: str, pos: pos})
+			pos = end
+
+		case c == '_' || c == '$' || c == '@' || unicode.IsLetter(c):
+			start := pos
+			pos++
+			for pos < len(source) && isIdentChar(rune(source[pos])) {
+				pos++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[start:pos], pos: start})
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(source[pos:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, pos)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, pos: pos})
+			pos += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// lexString reads a quoted string starting at pos and returns its unescaped
+// value and the position after the closing quote
+func lexString(source string, pos int) (string, int, error) {
+	quote := source[pos]
+	var sb strings.Builder
+
+	for i := pos + 1; i < len(source); i++ {
+		switch source[i] {
+		case quote:
+			return sb.String(), i + 1, nil
+		case '\\':
+			i++
+			if i >= len(source) {
+				break
+			}
+			switch source[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			default:
+				sb.WriteByte(source[i])
+			}
+		default:
+			sb.WriteByte(source[i])
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string at %d", pos)
+}
+
+// isIdentChar reports whether c can continue an identifier
+func isIdentChar(c rune) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// isDigit reports whether c is an ASCII digit
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// skipDigits returns the position of the first non-digit at or after pos
+func skipDigits(source string, pos int) int {
+	for pos < len(source) && isDigit(source[pos]) {
+		pos++
+	}
+	return pos
+}
@@ -0,0 +1,316 @@
+package expr
+
+import (
+	"fmt"
+)
+
+// node is a node of a compiled expression
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+// literalNode is a number, string, boolean or null literal
+type literalNode struct {
+	value interface{}
+}
+
+// identNode reads a top-level variable from the environment
+type identNode struct {
+	name string
+}
+
+// memberNode reads a field or an index of an object or array
+type memberNode struct {
+	object node
+	key    node
+}
+
+// unaryNode applies ! or - to an operand
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+// binaryNode applies a binary operator to two operands
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+// conditionalNode is the ternary operator
+type conditionalNode struct {
+	cond, then, otherwise node
+}
+
+// callNode calls a built-in function
+type callNode struct {
+	name string
+	fn   function
+	args []node
+}
+
+// binaryPrecedence lists the binary operators from the loosest to the
+// tightest binding level
+var binaryPrecedence = [][]string{
+	{"??"},
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// parser is a recursive descent parser over a token list
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse parses an expression
+func parse(source string) (node, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	if next := p.peek(); next.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", next.text, next.pos)
+	}
+
+	return root, nil
+}
+
+// peek returns the current token
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next returns the current token and advances past it
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept advances past the current token if it is the operator op
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokenOperator && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect advances past the operator op or fails
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		if t.kind == tokenEOF {
+			return fmt.Errorf("expected %q at end of expression", op)
+		}
+		return fmt.Errorf("expected %q at %d, found %q", op, t.pos, t.text)
+	}
+	return nil
+}
+
+// parseExpression parses a full expression including the ternary operator
+func (p *parser) parseExpression() (node, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.accept("?") {
+		return cond, nil
+	}
+
+	then, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	return &conditionalNode{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// parseBinary parses left-associative binary operators of a precedence level
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(binaryPrecedence) {
+		return p.parseUnary()
+	}
+
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.matchOperator(binaryPrecedence[level])
+		if op == "" {
+			return left, nil
+		}
+
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+}
+
+// matchOperator advances past the current token if it is one of ops and
+// returns it
+func (p *parser) matchOperator(ops []string) string {
+	for _, op := range ops {
+		if p.accept(op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// parseUnary parses ! and unary minus
+func (p *parser) parseUnary() (node, error) {
+	if op := p.matchOperator([]string{"!", "-"}); op != "" {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+// parsePostfix parses member access, indexing and function calls
+func (p *parser) parsePostfix() (node, error) {
+	t := p.peek()
+
+	// A function call
+	if t.kind == tokenIdent && p.tokens[p.pos+1].kind == tokenOperator && p.tokens[p.pos+1].text == "(" {
+		return p.parseCall()
+	}
+
+	current, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case p.accept("."):
+			key := p.next()
+			switch key.kind {
+			case tokenIdent:
+				current = &memberNode{object: current, key: &literalNode{value: key.text}}
+			case tokenNumber:
+				current = &memberNode{object: current, key: &literalNode{value: key.num}}
+			default:
+				return nil, fmt.Errorf("expected a field name at %d", key.pos)
+			}
+
+		case p.accept("["):
+			key, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			current = &memberNode{object: current, key: key}
+
+		default:
+			return current, nil
+		}
+	}
+}
+
+// parseCall parses a call of a built-in function
+func (p *parser) parseCall() (node, error) {
+	name := p.next()
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at %d", name.text, name.pos)
+	}
+
+	p.next() // (
+
+	var args []node
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if p.accept(")") {
+				break
+			}
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, fmt.Errorf("wrong number of arguments for %s at %d", name.text, name.pos)
+	}
+
+	return &callNode{name: name.text, fn: fn, args: args}, nil
+}
+
+// parsePrimary parses literals, identifiers and parenthesized expressions
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokenNumber:
+		return &literalNode{value: t.num}, nil
+
+	case tokenString:
+		return &literalNode{value: t.text}, nil
+
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		return &identNode{name: t.text}, nil
+
+	case tokenOperator:
+		if t.text == "(" {
+			inner, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+
+	default:
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+}
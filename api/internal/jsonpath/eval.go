@@ -0,0 +1,380 @@
+package jsonpath
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/expr"
+)
+
+// Get returns every value the path selects in a document, in document order
+func (p *Path) Get(doc interface{}) []interface{} {
+	nodes := []interface{}{doc}
+
+	for _, seg := range p.segments {
+		var next []interface{}
+		for _, node := range nodes {
+			if seg.recursive {
+				for _, descendant := range descendants(node) {
+					next = append(next, seg.selectFrom(descendant, doc)...)
+				}
+			} else {
+				next = append(next, seg.selectFrom(node, doc)...)
+			}
+		}
+		nodes = next
+	}
+
+	return nodes
+}
+
+// Lookup returns the value a singular path selects, or the array of all
+// selected values for other paths. It reports false when nothing matches.
+func (p *Path) Lookup(doc interface{}) (interface{}, bool) {
+	values := p.Get(doc)
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	if p.Singular() {
+		return values[0], true
+	}
+	return values, true
+}
+
+// selectFrom applies the segment's selector to a single node
+func (seg *segment) selectFrom(node, root interface{}) []interface{} {
+	var result []interface{}
+
+	switch seg.kind {
+	case selectName:
+		for _, name := range seg.names {
+			if value, ok := child(node, name); ok {
+				result = append(result, value)
+			}
+		}
+
+	case selectIndex:
+		if arr, ok := node.([]interface{}); ok {
+			for _, index := range seg.indexes {
+				if i, ok := normalizeIndex(index, len(arr)); ok {
+					result = append(result, arr[i])
+				}
+			}
+		}
+
+	case selectWildcard:
+		result = children(node)
+
+	case selectSlice:
+		if arr, ok := node.([]interface{}); ok {
+			for _, i := range seg.sliceIndexes(len(arr)) {
+				result = append(result, arr[i])
+			}
+		}
+
+	case selectFilter:
+		for _, value := range children(node) {
+			if seg.matches(value, root) {
+				result = append(result, value)
+			}
+		}
+	}
+
+	return result
+}
+
+// matches reports whether a value passes the segment's filter
+func (seg *segment) matches(value, root interface{}) bool {
+	result, err := seg.filter.Run(expr.Env{"@": value, "$": root})
+	return err == nil && expr.Truthy(result)
+}
+
+// sliceIndexes returns the array indexes a slice selects
+func (seg *segment) sliceIndexes(length int) []int {
+	start, end := 0, length
+	if seg.start != nil {
+		start = clampIndex(*seg.start, length)
+	}
+	if seg.end != nil {
+		end = clampIndex(*seg.end, length)
+	}
+
+	var indexes []int
+	for i := start; i < end; i += seg.step {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// clampIndex resolves a negative slice bound and limits it to [0, length]
+func clampIndex(index, length int) int {
+	if index < 0 {
+		index += length
+	}
+	if index < 0 {
+		return 0
+	}
+	if index > length {
+		return length
+	}
+	return index
+}
+
+// normalizeIndex resolves a negative index and checks it is in range
+func normalizeIndex(index, length int) (int, bool) {
+	if index < 0 {
+		index += length
+	}
+	return index, index >= 0 && index < length
+}
+
+// child reads a field of an object. A numeric name also reads an array
+// element, so a.0.b works like a[0].b.
+func child(node interface{}, name string) (interface{}, bool) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		value, ok := v[name]
+		return value, ok
+	case []interface{}:
+		index, err := strconv.Atoi(name)
+		if err != nil {
+			return nil, false
+		}
+		if i, ok := normalizeIndex(index, len(v)); ok {
+			return v[i], true
+		}
+	}
+	return nil, false
+}
+
+// children returns the elements of an array or the field values of an
+// object, the latter ordered by field name
+func children(node interface{}) []interface{} {
+	switch v := node.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			values[i] = v[key]
+		}
+		return values
+	}
+	return nil
+}
+
+// descendants returns a node followed by all nodes nested in it
+func descendants(node interface{}) []interface{} {
+	result := []interface{}{node}
+	for _, c := range children(node) {
+		result = append(result, descendants(c)...)
+	}
+	return result
+}
+
+// Set writes a value at the path, creating missing objects and arrays along
+// definite segments. At the first wildcard, an array value is spread over
+// the elements (element i goes to the i-th match, growing the array as
+// needed); any other value is written to every existing match. Filters and
+// slices only write to existing elements. Recursive descent cannot be
+// written.
+func (p *Path) Set(doc map[string]interface{}, value interface{}) error {
+	if len(p.segments) == 0 {
+		return fmt.Errorf("cannot replace the document root")
+	}
+
+	_, err := p.set(doc, p.segments, value, doc, true)
+	return err
+}
+
+// set writes the value below node and returns the node, which is a new
+// value when node was nil or an array that had to grow
+func (p *Path) set(node interface{}, segments []segment, value, root interface{}, spread bool) (interface{}, error) {
+	if len(segments) == 0 {
+		return value, nil
+	}
+
+	seg := &segments[0]
+	rest := segments[1:]
+
+	if seg.recursive {
+		return nil, fmt.Errorf("cannot write to recursive path %s", p.source)
+	}
+
+	switch seg.kind {
+	case selectName:
+		// A numeric name on an array writes an element
+		if arr, ok := node.([]interface{}); ok && len(seg.names) == 1 {
+			if index, err := strconv.Atoi(seg.names[0]); err == nil {
+				return p.setIndexes(arr, []int{index}, rest, value, root, spread)
+			}
+		}
+
+		obj, ok := node.(map[string]interface{})
+		if node == nil {
+			obj = make(map[string]interface{})
+		} else if !ok {
+			return nil, fmt.Errorf("cannot set field %s of a non-object in %s", seg.names[0], p.source)
+		}
+
+		for _, name := range seg.names {
+			child, err := p.set(obj[name], rest, value, root, spread)
+			if err != nil {
+				return nil, err
+			}
+			obj[name] = child
+		}
+		return obj, nil
+
+	case selectIndex:
+		arr, ok := node.([]interface{})
+		if node != nil && !ok {
+			return nil, fmt.Errorf("cannot index a non-array in %s", p.source)
+		}
+		return p.setIndexes(arr, seg.indexes, rest, value, root, spread)
+	}
+
+	// Wildcards, slices and filters write to several elements
+	if values, ok := value.([]interface{}); ok && spread && seg.kind == selectWildcard {
+		arr, ok := node.([]interface{})
+		if node != nil && !ok {
+			return nil, fmt.Errorf("cannot spread an array over a non-array in %s", p.source)
+		}
+
+		for len(arr) < len(values) {
+			arr = append(arr, nil)
+		}
+		for i, v := range values {
+			child, err := p.set(arr[i], rest, v, root, false)
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = child
+		}
+		return arr, nil
+	}
+
+	switch v := node.(type) {
+	case []interface{}:
+		for i := range v {
+			if !p.selects(seg, v, i, root) {
+				continue
+			}
+			child, err := p.set(v[i], rest, value, root, false)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = child
+		}
+
+	case map[string]interface{}:
+		for key := range v {
+			if seg.kind == selectSlice || (seg.kind == selectFilter && !seg.matches(v[key], root)) {
+				continue
+			}
+			child, err := p.set(v[key], rest, value, root, false)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = child
+		}
+	}
+
+	return node, nil
+}
+
+// selects reports whether a wildcard, slice or filter segment selects the
+// i-th element of an array
+func (p *Path) selects(seg *segment, arr []interface{}, i int, root interface{}) bool {
+	switch seg.kind {
+	case selectSlice:
+		for _, index := range seg.sliceIndexes(len(arr)) {
+			if index == i {
+				return true
+			}
+		}
+		return false
+	case selectFilter:
+		return seg.matches(arr[i], root)
+	}
+	return true
+}
+
+// maxSetIndex is the largest array index Set writes to. Writing an index
+// pads the array with nulls up to it, so a mistyped index could otherwise
+// allocate an array of any size.
+const maxSetIndex = 10000
+
+// setIndexes writes the value below array elements, growing the array
+func (p *Path) setIndexes(arr []interface{}, indexes []int, rest []segment, value, root interface{}, spread bool) (interface{}, error) {
+	for _, index := range indexes {
+		if index < 0 {
+			index += len(arr)
+			if index < 0 {
+				return nil, fmt.Errorf("array index out of bounds in %s", p.source)
+			}
+		}
+		if index > maxSetIndex {
+			return nil, fmt.Errorf("array index %d exceeds the maximum of %d in %s", index, maxSetIndex, p.source)
+		}
+
+		for len(arr) <= index {
+			arr = append(arr, nil)
+		}
+
+		child, err := p.set(arr[index], rest, value, root, spread)
+		if err != nil {
+			return nil, err
+		}
+		arr[index] = child
+	}
+	return arr, nil
+}
+
+// compiled caches compiled paths by source, since mappings use the same few
+// paths for every document
+var compiled sync.Map
+
+// cachedCompile compiles a path, reusing an earlier compilation
+func cachedCompile(path string) (*Path, error) {
+	if p, ok := compiled.Load(path); ok {
+		return p.(*Path), nil
+	}
+
+	p, err := Compile(path)
+	if err != nil {
+		return nil, err
+	}
+	compiled.Store(path, p)
+	return p, nil
+}
+
+// Lookup compiles a path and looks it up in a document. See Path.Lookup.
+func Lookup(doc interface{}, path string) (interface{}, bool, error) {
+	p, err := cachedCompile(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, ok := p.Lookup(doc)
+	return value, ok, nil
+}
+
+// Set compiles a path and writes a value at it. See Path.Set.
+func Set(doc map[string]interface{}, path string, value interface{}) error {
+	p, err := cachedCompile(path)
+	if err != nil {
+		return err
+	}
+
+	return p.Set(doc, value)
+}
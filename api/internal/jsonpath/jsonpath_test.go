@@ -0,0 +1,253 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decode parses a JSON document as encoding/json would for a mapping
+func decode(t *testing.T, document string) map[string]interface{} {
+	t.Helper()
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		t.Fatalf("decoding %s: %v", document, err)
+	}
+	return doc
+}
+
+// encode writes a value as JSON, for comparing results
+func encode(t *testing.T, value interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("encoding %v: %v", value, err)
+	}
+	return string(data)
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		path     string
+		singular bool
+	}{
+		{"$", true},
+		{"$.a.b", true},
+		{"a.b", true},
+		{"a[0]", true},
+		{"a.0", true},
+		{"a[-1]", true},
+		{"a['b c']", true},
+		{`a["b"]`, true},
+		{"a[?(@.price > 10)]", true},
+		{"price[?(@.currencyId=='x')].gross", true},
+		{"a[*]", false},
+		{"a.*", false},
+		{"a[0,2]", false},
+		{"a['x','y']", false},
+		{"a[1:3]", false},
+		{"a[::2]", false},
+		{"a..b", false},
+		{"$..[0]", false},
+	}
+
+	for _, test := range tests {
+		p, err := Compile(test.path)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", test.path, err)
+			continue
+		}
+		if p.String() != test.path {
+			t.Errorf("Compile(%q).String() = %q", test.path, p.String())
+		}
+		if got := p.Singular(); got != test.singular {
+			t.Errorf("Compile(%q).Singular() = %v, want %v", test.path, got, test.singular)
+		}
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	tests := []string{
+		"a[",
+		"a[0",
+		"a[x]",
+		"a['b",
+		"a['b']x",
+		"a.",
+		"a[1:2:3:4]",
+		"a[::0]",
+		"a[::-1]",
+		"a[?(@.x ==)]",
+		"a[?(@.x == 'y)]",
+		"$x",
+	}
+
+	for _, path := range tests {
+		if _, err := Compile(path); err == nil {
+			t.Errorf("Compile(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	doc := decode(t, `{
+		"name": "Shirt",
+		"tags": ["a", "b", "c", "d"],
+		"price": [
+			{"currencyId": "eur", "gross": 10},
+			{"currencyId": "usd", "gross": 12}
+		],
+		"media": [
+			{"media": {"url": "one.png"}},
+			{"media": {"url": "two.png"}}
+		],
+		"items": [
+			{"id": 1, "productNumber": "007"},
+			{"id": 2, "productNumber": "7"}
+		],
+		"b c": 1,
+		"dims": {"w": 2, "h": 3}
+	}`)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"$", encode(t, []interface{}{doc})},
+		{"name", `["Shirt"]`},
+		{"$.name", `["Shirt"]`},
+		{"missing", `null`},
+		{"name.missing", `null`},
+		{"tags[0]", `["a"]`},
+		{"tags.1", `["b"]`},
+		{"tags[-1]", `["d"]`},
+		{"tags[4]", `null`},
+		{"tags[0,2]", `["a","c"]`},
+		{"tags[1:3]", `["b","c"]`},
+		{"tags[::2]", `["a","c"]`},
+		{"tags[-2:]", `["c","d"]`},
+		{"tags[*]", `["a","b","c","d"]`},
+		{"['b c']", `[1]`},
+		{"dims.*", `[3,2]`},
+		{"dims['w','h']", `[2,3]`},
+		{"price[*].gross", `[10,12]`},
+		{"price[?(@.currencyId == 'usd')].gross", `[12]`},
+		{"price[?(@.gross > 100)].gross", `null`},
+		{"items[?(@.productNumber == '7')].id", `[2]`},
+		{"media..url", `["one.png","two.png"]`},
+		{"$..gross", `[10,12]`},
+	}
+
+	for _, test := range tests {
+		p, err := Compile(test.path)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", test.path, err)
+			continue
+		}
+		if got := encode(t, p.Get(doc)); got != test.want {
+			t.Errorf("Get(%q) = %s, want %s", test.path, got, test.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	doc := decode(t, `{"a": {"b": null}, "items": [{"id": 1}, {"id": 2}]}`)
+
+	tests := []struct {
+		path  string
+		want  string
+		found bool
+	}{
+		{"a.b", `null`, true},
+		{"a.c", `null`, false},
+		{"items[0].id", `1`, true},
+		{"items[*].id", `[1,2]`, true},
+		{"items[?(@.id > 1)].id", `2`, true},
+	}
+
+	for _, test := range tests {
+		value, found, err := Lookup(doc, test.path)
+		if err != nil {
+			t.Errorf("Lookup(%q): %v", test.path, err)
+			continue
+		}
+		if found != test.found || encode(t, value) != test.want {
+			t.Errorf("Lookup(%q) = %s, %v, want %s, %v", test.path, encode(t, value), found, test.want, test.found)
+		}
+	}
+
+	if _, _, err := Lookup(doc, "a["); err == nil {
+		t.Errorf("Lookup of an invalid path succeeded")
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		doc   string
+		path  string
+		value interface{}
+		want  string
+	}{
+		{`{}`, "a.b", "x", `{"a":{"b":"x"}}`},
+		{`{"a":{"c":1}}`, "$.a.b", "x", `{"a":{"b":"x","c":1}}`},
+		{`{}`, "a[1]", "x", `{"a":[null,"x"]}`},
+		{`{"a":["x","y"]}`, "a[-1]", "z", `{"a":["x","z"]}`},
+		{`{"a":["x","y"]}`, "a.0", "z", `{"a":["z","y"]}`},
+		{`{}`, "a['b c','d']", 1, `{"a":{"b c":1,"d":1}}`},
+		{`{}`, "variants[*].sku", []interface{}{"s1", "s2"}, `{"variants":[{"sku":"s1"},{"sku":"s2"}]}`},
+		{`{"v":[{"sku":"a"}]}`, "v[*].sku", []interface{}{"s1", "s2"}, `{"v":[{"sku":"s1"},{"sku":"s2"}]}`},
+		{`{"v":[{},{}]}`, "v[*].tax", true, `{"v":[{"tax":true},{"tax":true}]}`},
+		{`{"v":[{"n":1},{"n":2},{"n":3}]}`, "v[1:].x", 0, `{"v":[{"n":1},{"n":2,"x":0},{"n":3,"x":0}]}`},
+		{`{"v":[{"n":1},{"n":2}]}`, "v[?(@.n == 2)].x", "y", `{"v":[{"n":1},{"n":2,"x":"y"}]}`},
+	}
+
+	for _, test := range tests {
+		doc := decode(t, test.doc)
+		if err := Set(doc, test.path, test.value); err != nil {
+			t.Errorf("Set(%s, %q): %v", test.doc, test.path, err)
+			continue
+		}
+		if got := encode(t, doc); got != test.want {
+			t.Errorf("Set(%s, %q) = %s, want %s", test.doc, test.path, got, test.want)
+		}
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	tests := []struct {
+		doc     string
+		path    string
+		message string
+	}{
+		{`{}`, "$", "document root"},
+		{`{}`, "a..b", "recursive"},
+		{`{"a":"x"}`, "a.b", "non-object"},
+		{`{"a":"x"}`, "a[0]", "non-array"},
+		{`{"a":["x"]}`, "a[-2]", "out of bounds"},
+		{`{}`, "a[100000000]", "exceeds the maximum"},
+		{`{"a":[]}`, "a.100000000", "exceeds the maximum"},
+	}
+
+	for _, test := range tests {
+		doc := decode(t, test.doc)
+		err := Set(doc, test.path, "x")
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Set(%s, %q) = %v, want an error about %q", test.doc, test.path, err, test.message)
+		}
+	}
+}
+
+func TestSetMaxIndex(t *testing.T) {
+	doc := map[string]interface{}{}
+	if err := Set(doc, "a[10000]", "x"); err != nil {
+		t.Fatalf("Set at the maximum index: %v", err)
+	}
+
+	arr, _ := doc["a"].([]interface{})
+	if len(arr) != maxSetIndex+1 || !reflect.DeepEqual(arr[maxSetIndex], "x") {
+		t.Errorf("Set at the maximum index made an array of %d elements", len(arr))
+	}
+}
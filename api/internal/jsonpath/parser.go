@@ -0,0 +1,332 @@
+// Package jsonpath evaluates JSONPath expressions against decoded JSON
+// documents (maps, slices and scalars as produced by encoding/json).
+//
+// Supported syntax:
+//
+//	$.a.b, a.b                 child fields; the leading $ is optional
+//	a[0], a.0, a[-1]           array indexes, negative from the end
+//	a['b c'], a["b"]           quoted field names
+//	a[*], a.*                  all elements or all field values
+//	a[0,2], a['x','y']         unions
+//	a[1:3], a[::2]             slices
+//	a..b                       recursive descent
+//	a[?(@.price > 10)]         filters, written in the expr language with @
+//	                           as the current element and $ as the root
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/expr"
+)
+
+// selectorKind is the kind of a path segment's selector
+type selectorKind int
+
+const (
+	selectName selectorKind = iota
+	selectIndex
+	selectWildcard
+	selectSlice
+	selectFilter
+)
+
+// segment is one step of a path
+type segment struct {
+	kind      selectorKind
+	recursive bool // Applies the selector to the node and all its descendants
+	names     []string
+	indexes   []int
+	start     *int
+	end       *int
+	step      int
+	filter    *expr.Program
+}
+
+// Path is a compiled JSONPath. A path is safe for concurrent use.
+type Path struct {
+	source   string
+	segments []segment
+}
+
+// Compile parses a JSONPath
+func Compile(path string) (*Path, error) {
+	p := &pathParser{source: path}
+	segments, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON path %q: %w", path, err)
+	}
+
+	return &Path{source: path, segments: segments}, nil
+}
+
+// String returns the path the Path was compiled from
+func (p *Path) String() string {
+	return p.source
+}
+
+// Singular reports whether the path selects a single value: it has no
+// wildcards, unions, slices or recursive descent. Filters are allowed, and
+// Lookup returns their first match, so price[?(@.currencyId=='x')].gross
+// reads a scalar.
+func (p *Path) Singular() bool {
+	for _, seg := range p.segments {
+		if seg.recursive {
+			return false
+		}
+		switch seg.kind {
+		case selectName:
+			if len(seg.names) != 1 {
+				return false
+			}
+		case selectIndex:
+			if len(seg.indexes) != 1 {
+				return false
+			}
+		case selectFilter:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// pathParser parses a path string into segments
+type pathParser struct {
+	source string
+	pos    int
+}
+
+// parse parses the whole path
+func (p *pathParser) parse() ([]segment, error) {
+	var segments []segment
+
+	p.skipSpaces()
+	if p.peek() == '$' {
+		p.pos++
+	} else if p.pos < len(p.source) && p.peek() != '[' && p.peek() != '.' {
+		// A path without $ starts with a field name
+		segments = append(segments, segment{kind: selectName, names: []string{p.readName()}})
+	}
+
+	for p.pos < len(p.source) {
+		recursive := false
+
+		switch p.peek() {
+		case '.':
+			p.pos++
+			if p.peek() == '.' {
+				p.pos++
+				recursive = true
+			}
+
+			switch p.peek() {
+			case '[':
+				// Handled below, e.g. a..[0]
+			case '*':
+				p.pos++
+				segments = append(segments, segment{kind: selectWildcard, recursive: recursive})
+				continue
+			default:
+				name := p.readName()
+				if name == "" {
+					return nil, fmt.Errorf("expected a field name at %d", p.pos)
+				}
+				segments = append(segments, segment{kind: selectName, names: []string{name}, recursive: recursive})
+				continue
+			}
+
+		case '[':
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", p.peek(), p.pos)
+		}
+
+		seg, err := p.parseBracket()
+		if err != nil {
+			return nil, err
+		}
+		seg.recursive = recursive
+		segments = append(segments, seg)
+	}
+
+	return segments, nil
+}
+
+// parseBracket parses a bracketed selector
+func (p *pathParser) parseBracket() (segment, error) {
+	p.pos++ // [
+	p.skipSpaces()
+
+	var seg segment
+	var err error
+
+	switch c := p.peek(); {
+	case c == '*':
+		p.pos++
+		seg = segment{kind: selectWildcard}
+
+	case c == '?':
+		p.pos++
+		seg, err = p.parseFilter()
+
+	case c == '\'' || c == '"':
+		seg, err = p.parseNames()
+
+	default:
+		seg, err = p.parseIndexes()
+	}
+	if err != nil {
+		return segment{}, err
+	}
+
+	p.skipSpaces()
+	if p.peek() != ']' {
+		return segment{}, fmt.Errorf("expected ']' at %d", p.pos)
+	}
+	p.pos++
+
+	return seg, nil
+}
+
+// parseFilter parses a filter expression, with or without parentheses
+func (p *pathParser) parseFilter() (segment, error) {
+	p.skipSpaces()
+	start := p.pos
+
+	depth := 0
+	for ; p.pos < len(p.source); p.pos++ {
+		switch c := p.source[p.pos]; c {
+		case '\'', '"':
+			end := strings.IndexByte(p.source[p.pos+1:], c)
+			if end < 0 {
+				return segment{}, fmt.Errorf("unterminated string at %d", p.pos)
+			}
+			p.pos += end + 1
+		case '(', '[':
+			depth++
+		case ')':
+			depth--
+		case ']':
+			if depth == 0 {
+				program, err := expr.Compile(p.source[start:p.pos])
+				if err != nil {
+					return segment{}, err
+				}
+				return segment{kind: selectFilter, filter: program}, nil
+			}
+			depth--
+		}
+	}
+
+	return segment{}, fmt.Errorf("unterminated filter at %d", start)
+}
+
+// parseNames parses a union of quoted field names
+func (p *pathParser) parseNames() (segment, error) {
+	seg := segment{kind: selectName}
+
+	for {
+		p.skipSpaces()
+		quote := p.peek()
+		if quote != '\'' && quote != '"' {
+			return segment{}, fmt.Errorf("expected a quoted name at %d", p.pos)
+		}
+
+		end := strings.IndexByte(p.source[p.pos+1:], quote)
+		if end < 0 {
+			return segment{}, fmt.Errorf("unterminated string at %d", p.pos)
+		}
+		seg.names = append(seg.names, p.source[p.pos+1:p.pos+1+end])
+		p.pos += end + 2
+
+		p.skipSpaces()
+		if p.peek() != ',' {
+			return seg, nil
+		}
+		p.pos++
+	}
+}
+
+// parseIndexes parses a union of indexes or a slice
+func (p *pathParser) parseIndexes() (segment, error) {
+	end := strings.IndexByte(p.source[p.pos:], ']')
+	if end < 0 {
+		return segment{}, fmt.Errorf("expected ']' after %d", p.pos)
+	}
+	content := p.source[p.pos : p.pos+end]
+	p.pos += end
+
+	if strings.Contains(content, ":") {
+		return parseSlice(content)
+	}
+
+	seg := segment{kind: selectIndex}
+	for _, part := range strings.Split(content, ",") {
+		index, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return segment{}, fmt.Errorf("invalid array index %q", part)
+		}
+		seg.indexes = append(seg.indexes, index)
+	}
+	return seg, nil
+}
+
+// parseSlice parses a start:end:step slice
+func parseSlice(content string) (segment, error) {
+	parts := strings.Split(content, ":")
+	if len(parts) > 3 {
+		return segment{}, fmt.Errorf("invalid slice %q", content)
+	}
+
+	seg := segment{kind: selectSlice, step: 1}
+	bounds := []**int{&seg.start, &seg.end}
+
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return segment{}, fmt.Errorf("invalid slice %q", content)
+		}
+
+		if i < 2 {
+			*bounds[i] = &n
+		} else {
+			seg.step = n
+		}
+	}
+
+	if seg.step <= 0 {
+		return segment{}, fmt.Errorf("slice step must be positive")
+	}
+	return seg, nil
+}
+
+// readName reads an unquoted field name up to the next . or [
+func (p *pathParser) readName() string {
+	start := p.pos
+	for p.pos < len(p.source) && p.source[p.pos] != '.' && p.source[p.pos] != '[' {
+		p.pos++
+	}
+	return strings.TrimSpace(p.source[start:p.pos])
+}
+
+// peek returns the current character, or 0 at the end
+func (p *pathParser) peek() byte {
+	if p.pos >= len(p.source) {
+		return 0
+	}
+	return p.source[p.pos]
+}
+
+// skipSpaces advances past whitespace
+func (p *pathParser) skipSpaces() {
+	for p.pos < len(p.source) && p.source[p.pos] == ' ' {
+		p.pos++
+	}
+}
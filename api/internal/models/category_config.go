@@ -0,0 +1,49 @@
+package models
+
+// CategoryTitleMode selects how a category's collection is titled
+type CategoryTitleMode string
+
+const (
+	// CategoryTitleName titles a collection with the category name, which
+	// flattens the tree
+	CategoryTitleName CategoryTitleMode = "name"
+	// CategoryTitlePath titles a collection with the names of the category
+	// and its parents, e.g. "Clothing > Men > Shirts"
+	CategoryTitlePath CategoryTitleMode = "path"
+)
+
+// DefaultCategoryPathSeparator joins the category names of a path title
+const DefaultCategoryPathSeparator = " > "
+
+// CategoryConfig configures how a category dataflow titles its collections
+type CategoryConfig struct {
+	Title         CategoryTitleMode `json:"title"`                    // name (default) or path
+	PathSeparator string            `json:"path_separator,omitempty"` // Defaults to " > "
+	IncludeRoot   bool              `json:"include_root,omitempty"`   // Keep the root category, e.g. "Home", in path titles
+}
+
+// TitleMode returns the title mode, defaulting to the category name
+func (c *CategoryConfig) TitleMode() CategoryTitleMode {
+	if c == nil || c.Title == "" {
+		return CategoryTitleName
+	}
+	return c.Title
+}
+
+// Separator returns the separator of path titles
+func (c *CategoryConfig) Separator() string {
+	if c == nil || c.PathSeparator == "" {
+		return DefaultCategoryPathSeparator
+	}
+	return c.PathSeparator
+}
+
+// Validate checks the title mode. A nil config is valid.
+func (c *CategoryConfig) Validate() error {
+	switch c.TitleMode() {
+	case CategoryTitleName, CategoryTitlePath:
+		return nil
+	default:
+		return ErrInvalidCategoryConfig
+	}
+}
@@ -14,6 +14,13 @@ const (
 	DataflowTypeProduct DataflowType = "product"
 	// DataflowTypeOrder represents an order dataflow
 	DataflowTypeOrder DataflowType = "order"
+	// DataflowTypeInventory represents a dataflow that only syncs stock levels
+	DataflowTypeInventory DataflowType = "inventory"
+	// DataflowTypeCategory represents a dataflow that mirrors categories as
+	// collections
+	DataflowTypeCategory DataflowType = "category"
+	// DataflowTypeCustomer represents a dataflow that upserts customers
+	DataflowTypeCustomer DataflowType = "customer"
 )
 
 // DataflowStatus represents the status of a dataflow
@@ -26,6 +33,18 @@ const (
 	DataflowStatusInactive DataflowStatus = "inactive"
 )
 
+// DataflowDirection represents which system a dataflow reads from
+type DataflowDirection string
+
+const (
+	// DataflowDirectionShopwareToShopify represents a dataflow from Shopware
+	// to Shopify
+	DataflowDirectionShopwareToShopify DataflowDirection = "shopware_to_shopify"
+	// DataflowDirectionShopifyToShopware represents a dataflow from Shopify
+	// to Shopware. Only inventory dataflows support it.
+	DataflowDirectionShopifyToShopware DataflowDirection = "shopify_to_shopware"
+)
+
 // Dataflow represents a data flow between connectors
 type Dataflow struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
@@ -33,12 +52,17 @@ type Dataflow struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
-	Name              string         `json:"name" gorm:"not null"`
-	Description       string         `json:"description"`
-	Type              DataflowType   `json:"type" gorm:"not null"`
-	Status            DataflowStatus `json:"status" gorm:"default:'active'"`
-	SourceConnectorID uint           `json:"source_connector_id" gorm:"not null"`
-	DestConnectorID   uint           `json:"dest_connector_id" gorm:"not null"`
+	Name              string            `json:"name" gorm:"not null"`
+	Description       string            `json:"description"`
+	Type              DataflowType      `json:"type" gorm:"not null"`
+	Status            DataflowStatus    `json:"status" gorm:"default:'active'"`
+	Direction         DataflowDirection `json:"direction" gorm:"default:'shopware_to_shopify'"` // Set from the connector types
+	SourceConnectorID uint              `json:"source_connector_id" gorm:"not null"`
+	DestConnectorID   uint              `json:"dest_connector_id" gorm:"not null"`
+	RetryPolicy       RetryPolicy       `json:"retry_policy" gorm:"embedded;embeddedPrefix:retry_"`
+	Inventory         *InventoryConfig  `json:"inventory,omitempty" gorm:"type:jsonb;serializer:json"` // Required for inventory dataflows
+	Pricing           *PricingConfig    `json:"pricing,omitempty" gorm:"type:jsonb;serializer:json"`   // Price lists of product dataflows
+	Category          *CategoryConfig   `json:"category,omitempty" gorm:"type:jsonb;serializer:json"`  // Collection titles of category dataflows
 
 	// Relations
 	SourceConnector Connector      `json:"source_connector" gorm:"foreignKey:SourceConnectorID"`
@@ -53,12 +77,42 @@ func (d *Dataflow) BeforeCreate(tx *gorm.DB) error {
 		return ErrInvalidDataflow
 	}
 
+	return nil
+}
+
+// BeforeSave is a GORM hook that runs before creating or updating a record
+func (d *Dataflow) BeforeSave(tx *gorm.DB) error {
+	if err := d.setDirection(tx); err != nil {
+		return err
+	}
+
+	if d.Type == DataflowTypeInventory {
+		if err := d.Inventory.Validate(); err != nil {
+			return err
+		}
+		if err := d.validateInventoryPair(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := d.Pricing.Validate(); err != nil {
+		return err
+	}
+	if err := d.Category.Validate(); err != nil {
+		return err
+	}
+
+	return d.RetryPolicy.Validate()
+}
+
+// setDirection sets the direction of the dataflow from the types of its
+// connectors. One connector must be Shopware and the other Shopify.
+func (d *Dataflow) setDirection(tx *gorm.DB) error {
 	// Ensure source and destination connectors are different
 	if d.SourceConnectorID == d.DestConnectorID {
 		return ErrSameConnector
 	}
 
-	// Validate connector types
 	var sourceConnector, destConnector Connector
 
 	if err := tx.First(&sourceConnector, d.SourceConnectorID).Error; err != nil {
@@ -69,14 +123,63 @@ func (d *Dataflow) BeforeCreate(tx *gorm.DB) error {
 		return err
 	}
 
-	// For this project, source must be Shopware and dest must be Shopify
-	if sourceConnector.Type != ConnectorTypeShopware {
-		return ErrInvalidSourceConnector
+	switch {
+	case sourceConnector.Type == ConnectorTypeShopware && destConnector.Type == ConnectorTypeShopify:
+		d.Direction = DataflowDirectionShopwareToShopify
+	case sourceConnector.Type == ConnectorTypeShopify && destConnector.Type == ConnectorTypeShopware:
+		if d.Type != DataflowTypeInventory {
+			return ErrUnsupportedDirection
+		}
+		d.Direction = DataflowDirectionShopifyToShopware
+	default:
+		return ErrInvalidConnectorPair
+	}
+
+	return nil
+}
+
+// IsReverse reports whether the dataflow reads from Shopify and writes to
+// Shopware
+func (d *Dataflow) IsReverse() bool {
+	return d.Direction == DataflowDirectionShopifyToShopware
+}
+
+// validateInventoryPair checks an inventory dataflow against the inventory
+// dataflows running the other way between the same connectors. Shopify to
+// Shopware writes the sum of its locations to the product's stock, while
+// Shopware to Shopify writes one quantity to each location, so a pair only
+// undoes each other's writes when both use the same single location and
+// Shopware to Shopify reads the stock field.
+func (d *Dataflow) validateInventoryPair(tx *gorm.DB) error {
+	var opposite []Dataflow
+	if err := tx.Where("type = ? AND id <> ? AND source_connector_id = ? AND dest_connector_id = ?",
+		DataflowTypeInventory, d.ID, d.DestConnectorID, d.SourceConnectorID).
+		Find(&opposite).Error; err != nil {
+		return err
 	}
 
-	if destConnector.Type != ConnectorTypeShopify {
-		return ErrInvalidDestConnector
+	for _, other := range opposite {
+		if !invertibleInventory(d, &other) {
+			return ErrInvalidInventoryPair
+		}
 	}
 
 	return nil
 }
+
+// invertibleInventory reports whether two inventory dataflows running in
+// opposite directions write back exactly what the other one read
+func invertibleInventory(a, b *Dataflow) bool {
+	if a.Inventory == nil || b.Inventory == nil || len(a.Inventory.Locations) != 1 || len(b.Inventory.Locations) != 1 {
+		return false
+	}
+	if a.Inventory.Locations[0].LocationID != b.Inventory.Locations[0].LocationID {
+		return false
+	}
+
+	forward := a
+	if a.IsReverse() {
+		forward = b
+	}
+	return forward.Inventory.Locations[0].Path() == "stock"
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RunType represents the type of a dataflow run
+type RunType string
+
+const (
+	// RunTypeBackfill migrates the full source catalog page by page
+	RunTypeBackfill RunType = "backfill"
+)
+
+// RunStatus represents the status of a dataflow run
+type RunStatus string
+
+const (
+	// RunStatusRunning represents a run that is processing pages
+	RunStatusRunning RunStatus = "running"
+	// RunStatusPaused represents a run paused by a user
+	RunStatusPaused RunStatus = "paused"
+	// RunStatusCompleted represents a run that processed every page
+	RunStatusCompleted RunStatus = "completed"
+	// RunStatusFailed represents a run that stopped because of an error
+	RunStatusFailed RunStatus = "failed"
+)
+
+// DataflowRun tracks a long-running migration of many entities as one unit
+type DataflowRun struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	DataflowID      uint       `json:"dataflow_id" gorm:"not null;index"`
+	Type            RunType    `json:"type" gorm:"not null"`
+	Status          RunStatus  `json:"status" gorm:"default:'running'"`
+	PageSize        int        `json:"page_size" gorm:"default:100"`
+	NextPage        int        `json:"next_page" gorm:"default:1"` // Checkpoint: the next page to fetch
+	Bulk            bool       `json:"bulk"`                       // Create products with Shopify bulk mutations
+	BulkOperationID string     `json:"bulk_operation_id"`          // Shopify bulk operation of the page in progress
+	Total           int        `json:"total"`                      // Total entities reported by the source
+	Processed       int        `json:"processed"`
+	Succeeded       int        `json:"succeeded"`
+	Failed          int        `json:"failed"`
+	LastError       string     `json:"last_error"`
+	StartedAt       *time.Time `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at"`
+
+	// Source IDs of the input lines of the bulk operation, by line number
+	BulkSourceIDs []string `json:"bulk_source_ids,omitempty" gorm:"type:jsonb;serializer:json"`
+
+	// Relations
+	Dataflow Dataflow `json:"-" gorm:"foreignKey:DataflowID"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// EntityType represents the type of entity tracked by an entity mapping
+type EntityType string
+
+const (
+	// EntityTypeProduct represents a product mapping
+	EntityTypeProduct EntityType = "product"
+	// EntityTypeOrder represents an order mapping
+	EntityTypeOrder EntityType = "order"
+	// EntityTypeVariant represents a product variant mapping
+	EntityTypeVariant EntityType = "variant"
+	// EntityTypeMedia represents a product media mapping
+	EntityTypeMedia EntityType = "media"
+	// EntityTypeCollection represents a category mapped to a collection
+	EntityTypeCollection EntityType = "collection"
+	// EntityTypeInventoryItem represents the Shopify inventory item of a
+	// Shopware product or variant
+	EntityTypeInventoryItem EntityType = "inventory_item"
+	// EntityTypeCustomer represents a customer mapping
+	EntityTypeCustomer EntityType = "customer"
+)
+
+// EntityMapping links an entity in the source system to its counterpart in
+// the destination system for a connector pair
+type EntityMapping struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	SourceConnectorID uint       `json:"source_connector_id" gorm:"not null;uniqueIndex:idx_entity_mapping_source"`
+	DestConnectorID   uint       `json:"dest_connector_id" gorm:"not null;uniqueIndex:idx_entity_mapping_source;index:idx_entity_mapping_dest"`
+	EntityType        EntityType `json:"entity_type" gorm:"not null;uniqueIndex:idx_entity_mapping_source;index:idx_entity_mapping_dest"`
+	SourceID          string     `json:"source_id" gorm:"not null;uniqueIndex:idx_entity_mapping_source"` // Shopware UUID, or Shopify GID when the source is Shopify
+	DestID            string     `json:"dest_id" gorm:"not null;index:idx_entity_mapping_dest"`           // Shopify GID, or Shopware UUID when the destination is Shopware
+	ParentID          string     `json:"parent_id,omitempty" gorm:"index"`                                // Shopify GID of the product a variant belongs to
+	LastSyncedHash    string     `json:"last_synced_hash"`                                                // Hash of the last payload written
+	LastSyncedAt      *time.Time `json:"last_synced_at"`
+}
@@ -9,7 +9,24 @@ var (
 	ErrInvalidCredentials     = errors.New("invalid credentials for connector type")
 	ErrInvalidDataflow        = errors.New("invalid dataflow: name is required")
 	ErrSameConnector          = errors.New("source and destination connectors must be different")
-	ErrInvalidSourceConnector = errors.New("source connector must be a Shopware connector")
-	ErrInvalidDestConnector   = errors.New("destination connector must be a Shopify connector")
-	ErrInvalidFieldMapping    = errors.New("invalid field mapping: source and destination fields are required")
+	ErrInvalidConnectorPair   = errors.New("a dataflow needs one Shopware and one Shopify connector")
+	ErrUnsupportedDirection   = errors.New("only inventory dataflows can run from Shopify to Shopware")
+	ErrInvalidFieldMapping    = errors.New("invalid field mapping: a source field or expression and a destination field are required")
+	ErrInvalidRunState        = errors.New("operation not allowed in the current run status")
+	ErrBulkNotSupported       = errors.New("bulk mode is only supported for product dataflows")
+	ErrBackfillNotSupported   = errors.New("backfills are only supported for Shopware to Shopify dataflows")
+	ErrInvalidRetryPolicy     = errors.New("invalid retry policy")
+	ErrInvalidReplayState     = errors.New("only failed, retrying or dead-lettered migrations can be replayed")
+	ErrInvalidTransformStep   = errors.New("invalid transform step")
+	ErrInvalidTransformConfig = errors.New("invalid transform config")
+	ErrInvalidExpression      = errors.New("invalid source expression")
+	ErrInvalidPath            = errors.New("invalid field path")
+	ErrInvalidLookupTable     = errors.New("invalid lookup table: name is required")
+	ErrLookupTableExists      = errors.New("a lookup table with this name already exists")
+	ErrLookupTableChanged     = errors.New("the lookup table was changed by another request; reload it and try again")
+	ErrInvalidLookupCSV       = errors.New("invalid lookup table CSV")
+	ErrInvalidPricingConfig   = errors.New("invalid pricing config: every price list needs a unique Shopify price list GID, an ISO currency code and a valid rule ID if set")
+	ErrInvalidCategoryConfig  = errors.New("invalid category config: title must be name or path")
+	ErrInvalidInventoryConfig = errors.New("invalid inventory config: every location needs a unique Shopify location GID and a valid quantity path")
+	ErrInvalidInventoryPair   = errors.New("inventory dataflows in both directions between two connectors need the same single location, with the quantity read from stock")
 )
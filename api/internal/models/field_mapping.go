@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -27,8 +28,29 @@ const (
 	TransformationTypeMediaMap     TransformationType = "media_map"
 	TransformationTypeMetafield    TransformationType = "metafield"
 	TransformationTypeEntityLookup TransformationType = "entity_lookup"
+	// TransformationTypeMath means arithmetic on a numeric value
+	TransformationTypeMath TransformationType = "math"
+	// TransformationTypeLookupTable means mapping values through a shared lookup table
+	TransformationTypeLookupTable TransformationType = "lookup_table"
+	// TransformationTypeReference means resolving a Shopware country, state, currency or salutation ID
+	TransformationTypeReference TransformationType = "reference"
 )
 
+// TransformStep is one step of a field mapping's transformation pipeline
+type TransformStep struct {
+	Type   TransformationType `json:"type"`
+	Config json.RawMessage    `json:"config,omitempty"` // Config object, or a JSON string like TransformConfig
+}
+
+// ConfigString returns the step's config in the form of TransformConfig
+func (s TransformStep) ConfigString() string {
+	var config string
+	if err := json.Unmarshal(s.Config, &config); err == nil {
+		return config
+	}
+	return string(s.Config)
+}
+
 // FieldMapping represents a mapping between source and destination fields
 type FieldMapping struct {
 	ID        uint           `json:"id" gorm:"primaryKey"`
@@ -36,13 +58,15 @@ type FieldMapping struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
-	DataflowID      uint               `json:"dataflow_id" gorm:"not null"`
-	SourceField     string             `json:"source_field" gorm:"not null"`
-	DestField       string             `json:"dest_field" gorm:"not null"`
-	IsRequired      bool               `json:"is_required" gorm:"default:false"`
-	DefaultValue    string             `json:"default_value"`
-	TransformType   TransformationType `json:"transform_type" gorm:"default:'none'"`
-	TransformConfig string             `json:"transform_config"` // JSON string with transformation config
+	DataflowID       uint               `json:"dataflow_id" gorm:"not null"`
+	SourceField      string             `json:"source_field" gorm:"not null"`
+	SourceExpression string             `json:"source_expression"` // Expression combining several source fields, used instead of SourceField when set
+	DestField        string             `json:"dest_field" gorm:"not null"`
+	IsRequired       bool               `json:"is_required" gorm:"default:false"`
+	DefaultValue     string             `json:"default_value"`
+	TransformType    TransformationType `json:"transform_type" gorm:"default:'none'"`
+	TransformConfig  string             `json:"transform_config"`                                            // JSON string with transformation config
+	TransformSteps   []TransformStep    `json:"transform_steps,omitempty" gorm:"type:jsonb;serializer:json"` // Ordered pipeline used instead of TransformType when set
 
 	// Relations
 	Dataflow Dataflow `json:"-" gorm:"foreignKey:DataflowID"`
@@ -50,7 +74,7 @@ type FieldMapping struct {
 
 // BeforeCreate is a GORM hook that runs before creating a new record
 func (fm *FieldMapping) BeforeCreate(tx *gorm.DB) error {
-	if fm.SourceField == "" || fm.DestField == "" {
+	if (fm.SourceField == "" && fm.SourceExpression == "") || fm.DestField == "" {
 		return ErrInvalidFieldMapping
 	}
 
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/jsonpath"
+)
+
+// DefaultInventoryQuantityPath is the Shopware field a location's quantity
+// is read from when the location sets no path
+const DefaultInventoryQuantityPath = "availableStock"
+
+// InventoryConfig configures an inventory dataflow: which Shopify locations
+// receive stock and where in the Shopware product the quantity is read. A
+// Shopify to Shopware dataflow writes the sum of the locations' available
+// quantities to the product's stock and ignores the quantity paths.
+type InventoryConfig struct {
+	Locations    []InventoryLocation `json:"locations"`
+	Associations []string            `json:"associations,omitempty"` // Extra product associations to load, e.g. warehouses
+}
+
+// InventoryLocation maps a Shopware stock source, such as a warehouse or a
+// sales channel, to a Shopify location
+type InventoryLocation struct {
+	Name         string `json:"name"`          // Shopware warehouse or sales channel, for reference
+	LocationID   string `json:"location_id"`   // Shopify location GID
+	QuantityPath string `json:"quantity_path"` // JSON path of the quantity in the product; defaults to availableStock
+}
+
+// Path returns the quantity path of the location
+func (l InventoryLocation) Path() string {
+	if l.QuantityPath == "" {
+		return DefaultInventoryQuantityPath
+	}
+	return l.QuantityPath
+}
+
+// Validate checks that the config has at least one location and that every
+// location has a Shopify location ID and a valid quantity path
+func (c *InventoryConfig) Validate() error {
+	if c == nil || len(c.Locations) == 0 {
+		return ErrInvalidInventoryConfig
+	}
+
+	seen := make(map[string]bool, len(c.Locations))
+	for _, location := range c.Locations {
+		if !strings.HasPrefix(location.LocationID, "gid://shopify/Location/") || seen[location.LocationID] {
+			return ErrInvalidInventoryConfig
+		}
+		seen[location.LocationID] = true
+
+		if _, err := jsonpath.Compile(location.Path()); err != nil {
+			return ErrInvalidInventoryConfig
+		}
+	}
+
+	for _, association := range c.Associations {
+		if association == "" {
+			return ErrInvalidInventoryConfig
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobType represents the kind of work a background job performs
+type JobType string
+
+const (
+	// JobTypeShopwareWebhook processes a webhook received from Shopware
+	JobTypeShopwareWebhook JobType = "shopware_webhook"
+	// JobTypeBackfillPage processes one page of a backfill run
+	JobTypeBackfillPage JobType = "backfill_page"
+	// JobTypeShopifyWebhook processes a webhook received from Shopify
+	JobTypeShopifyWebhook JobType = "shopify_webhook"
+)
+
+// JobStatus represents the status of a background job
+type JobStatus string
+
+const (
+	// JobStatusQueued represents a job waiting to be claimed by a worker
+	JobStatusQueued JobStatus = "queued"
+	// JobStatusRunning represents a job claimed by a worker
+	JobStatusRunning JobStatus = "running"
+	// JobStatusCompleted represents a successfully processed job
+	JobStatusCompleted JobStatus = "completed"
+	// JobStatusFailed represents a job that exhausted its attempts
+	JobStatusFailed JobStatus = "failed"
+)
+
+// Job represents a unit of work in the durable job queue
+type Job struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Type        JobType    `json:"type" gorm:"not null;index"`
+	Status      JobStatus  `json:"status" gorm:"default:'queued';index"`
+	Payload     string     `json:"payload"` // JSON string with job input
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	MaxAttempts int        `json:"max_attempts" gorm:"default:5"`
+	RunAt       time.Time  `json:"run_at" gorm:"index"`       // Earliest time the job may run
+	LockedBy    string     `json:"locked_by"`                 // Worker holding the lease
+	LockedUntil *time.Time `json:"locked_until" gorm:"index"` // Lease expiry, extended by heartbeats
+	LastError   string     `json:"last_error"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
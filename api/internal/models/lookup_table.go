@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LookupTable is a named key-value table that field mappings of any dataflow
+// can reference, e.g. Shopware tax IDs to Shopify tax codes
+type LookupTable struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name         string            `json:"name" gorm:"not null;uniqueIndex"`
+	Description  string            `json:"description"`
+	Version      int               `json:"version" gorm:"not null;default:1"` // Incremented on every change
+	Entries      map[string]string `json:"entries" gorm:"type:jsonb;serializer:json"`
+	DefaultValue *string           `json:"default_value"` // Returned for keys without an entry; nil fails the lookup
+}
+
+// BeforeSave is a GORM hook that runs before creating or updating a record
+func (t *LookupTable) BeforeSave(tx *gorm.DB) error {
+	if t.Name == "" {
+		return ErrInvalidLookupTable
+	}
+
+	if t.Entries == nil {
+		t.Entries = map[string]string{}
+	}
+
+	return nil
+}
+
+// LookupTableVersion is a snapshot of a lookup table's contents, saved every
+// time the table changes
+type LookupTableVersion struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	LookupTableID uint              `json:"lookup_table_id" gorm:"not null;uniqueIndex:idx_lookup_table_version"`
+	Version       int               `json:"version" gorm:"not null;uniqueIndex:idx_lookup_table_version"`
+	Entries       map[string]string `json:"entries" gorm:"type:jsonb;serializer:json"`
+	DefaultValue  *string           `json:"default_value"`
+	Change        string            `json:"change"` // What produced the version: create, update, import or restore
+
+	// Relations
+	LookupTable LookupTable `json:"-" gorm:"foreignKey:LookupTableID;constraint:OnDelete:CASCADE"`
+}
@@ -18,6 +18,10 @@ const (
 	MigrationStatusSuccess MigrationStatus = "success"
 	// MigrationStatusFailed represents a failed migration
 	MigrationStatusFailed MigrationStatus = "failed"
+	// MigrationStatusRetrying represents a failed migration waiting for its next attempt
+	MigrationStatusRetrying MigrationStatus = "retrying"
+	// MigrationStatusDeadLetter represents a migration that exhausted its retries
+	MigrationStatusDeadLetter MigrationStatus = "dead_letter"
 )
 
 // MigrationLog represents a log entry for a migration
@@ -28,6 +32,8 @@ type MigrationLog struct {
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
 	DataflowID         uint            `json:"dataflow_id" gorm:"not null"`
+	RunID              *uint           `json:"run_id" gorm:"index"` // Dataflow run that created the log, if any
+	RunPage            int             `json:"run_page,omitempty"`  // Page of the run that created the log
 	Status             MigrationStatus `json:"status" gorm:"default:'pending'"`
 	SourceIdentifier   string          `json:"source_identifier" gorm:"not null"` // ID in the source system
 	DestIdentifier     string          `json:"dest_identifier"`                   // ID in the destination system
@@ -35,6 +41,9 @@ type MigrationLog struct {
 	SourcePayload      string          `json:"source_payload"`                    // JSON string with source data
 	TransformedPayload string          `json:"transformed_payload"`               // JSON string with transformed data
 	ErrorMessage       string          `json:"error_message"`
+	ErrorClass         ErrorClass      `json:"error_class"`
+	Attempts           int             `json:"attempts"`                   // Attempts made so far
+	NextRetryAt        *time.Time      `json:"next_retry_at" gorm:"index"` // When a retrying migration is attempted again
 	CompletedAt        *time.Time      `json:"completed_at"`
 
 	// Relations
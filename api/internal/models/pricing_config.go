@@ -0,0 +1,55 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// currencyCodePattern matches an ISO 4217 currency code
+	currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	// shopwareIDPattern matches a Shopware entity ID
+	shopwareIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+)
+
+// PricingConfig configures the Shopify price lists a product dataflow fills
+// besides the variant prices
+type PricingConfig struct {
+	PriceLists []PriceListConfig `json:"price_lists"`
+}
+
+// PriceListConfig maps a Shopify price list to the Shopware prices it
+// receives. Without a rule the list gets the product's price in its
+// currency; with one it gets the advanced prices of that rule, including
+// their quantity breaks.
+type PriceListConfig struct {
+	Name        string `json:"name"`              // For reference
+	PriceListID string `json:"price_list_id"`     // Shopify price list GID
+	Currency    string `json:"currency"`          // ISO code of the price list currency, e.g. USD
+	RuleID      string `json:"rule_id,omitempty"` // Shopware rule of the advanced prices
+}
+
+// Validate checks that every price list has a unique Shopify GID, an ISO
+// currency code and, if set, a valid Shopware rule ID. A nil config is valid.
+func (c *PricingConfig) Validate() error {
+	if c == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(c.PriceLists))
+	for _, priceList := range c.PriceLists {
+		if !strings.HasPrefix(priceList.PriceListID, "gid://shopify/PriceList/") || seen[priceList.PriceListID] {
+			return ErrInvalidPricingConfig
+		}
+		seen[priceList.PriceListID] = true
+
+		if !currencyCodePattern.MatchString(priceList.Currency) {
+			return ErrInvalidPricingConfig
+		}
+		if priceList.RuleID != "" && !shopwareIDPattern.MatchString(priceList.RuleID) {
+			return ErrInvalidPricingConfig
+		}
+	}
+
+	return nil
+}
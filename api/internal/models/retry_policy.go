@@ -0,0 +1,127 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// ErrorClass classifies why a migration attempt failed
+type ErrorClass string
+
+const (
+	// ErrorClassRateLimited represents a request rejected by an API rate limit
+	ErrorClassRateLimited ErrorClass = "rate_limited"
+	// ErrorClassServerError represents a 5xx response from an API
+	ErrorClassServerError ErrorClass = "server_error"
+	// ErrorClassNetwork represents a request that did not reach the API
+	ErrorClassNetwork ErrorClass = "network"
+	// ErrorClassAuth represents a failure to authenticate, such as a Shopware token failure
+	ErrorClassAuth ErrorClass = "auth"
+	// ErrorClassValidation represents input rejected by the API, such as Shopify userErrors
+	ErrorClassValidation ErrorClass = "validation"
+	// ErrorClassTransform represents a failure to apply the dataflow's field mappings
+	ErrorClassTransform ErrorClass = "transform"
+	// ErrorClassDependency represents an entity that references another one not synced yet
+	ErrorClassDependency ErrorClass = "dependency"
+	// ErrorClassUnknown represents a failure that could not be classified
+	ErrorClassUnknown ErrorClass = "unknown"
+)
+
+// Retry policy defaults
+const (
+	DefaultRetryMaxAttempts  = 5
+	DefaultRetryInitialDelay = 30   // seconds
+	DefaultRetryMaxDelay     = 3600 // seconds
+	DefaultRetryMultiplier   = 2.0
+)
+
+// DefaultRetryableErrors are the error classes retried when a policy does not
+// list any. Failures that could not be classified are not retried, since
+// nothing suggests another attempt would succeed.
+var DefaultRetryableErrors = []ErrorClass{
+	ErrorClassRateLimited,
+	ErrorClassServerError,
+	ErrorClassNetwork,
+	ErrorClassAuth,
+	ErrorClassDependency,
+}
+
+// RetryPolicy controls how failed migrations of a dataflow are retried.
+// Unset fields fall back to the defaults.
+type RetryPolicy struct {
+	MaxAttempts     int          `json:"max_attempts"`          // Total attempts including the first; 1 disables retries
+	InitialDelay    int          `json:"initial_delay_seconds"` // Delay before the first retry
+	MaxDelay        int          `json:"max_delay_seconds"`     // Upper bound for the delay between retries
+	Multiplier      float64      `json:"multiplier"`            // Growth of the delay per retry; 1 keeps it constant
+	RetryableErrors []ErrorClass `json:"retryable_errors" gorm:"type:jsonb;serializer:json"`
+}
+
+// WithDefaults returns the policy with unset fields replaced by the defaults
+func (p RetryPolicy) WithDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = DefaultRetryInitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryMaxDelay
+	}
+	if p.Multiplier <= 0 {
+		p.Multiplier = DefaultRetryMultiplier
+	}
+	if len(p.RetryableErrors) == 0 {
+		p.RetryableErrors = DefaultRetryableErrors
+	}
+	return p
+}
+
+// Validate checks the policy for values that cannot be used
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 || p.InitialDelay < 0 || p.MaxDelay < 0 {
+		return ErrInvalidRetryPolicy
+	}
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return ErrInvalidRetryPolicy
+	}
+	if p.MaxDelay > 0 && p.InitialDelay > p.MaxDelay {
+		return ErrInvalidRetryPolicy
+	}
+
+	for _, class := range p.RetryableErrors {
+		switch class {
+		case ErrorClassRateLimited, ErrorClassServerError, ErrorClassNetwork, ErrorClassAuth,
+			ErrorClassValidation, ErrorClassTransform, ErrorClassDependency, ErrorClassUnknown:
+		default:
+			return ErrInvalidRetryPolicy
+		}
+	}
+
+	return nil
+}
+
+// IsRetryable reports whether failures of the given class are retried
+func (p RetryPolicy) IsRetryable(class ErrorClass) bool {
+	for _, retryable := range p.WithDefaults().RetryableErrors {
+		if retryable == class {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay before the next attempt after the given number
+// of failed attempts
+func (p RetryPolicy) Backoff(failedAttempts int) time.Duration {
+	p = p.WithDefaults()
+	if failedAttempts < 1 {
+		failedAttempts = 1
+	}
+
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(failedAttempts-1))
+	if delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+
+	return time.Duration(delay) * time.Second
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// SyncEcho records a value the integration wrote to a connector, so the
+// webhook the write triggers is recognized as an echo and not synced back
+// to the other side
+type SyncEcho struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ConnectorID uint      `json:"connector_id" gorm:"not null;index:idx_sync_echo_key"` // Connector that was written to
+	Key         string    `json:"key" gorm:"not null;index:idx_sync_echo_key"`          // Entity written, e.g. a product ID
+	Value       string    `json:"value" gorm:"not null"`                                // Value written, e.g. a stock level
+	ExpiresAt   time.Time `json:"expires_at" gorm:"not null;index"`
+	ConsumedBy  string    `json:"consumed_by"` // Webhook delivery the write was matched to, kept until it expires
+}
@@ -0,0 +1,640 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// BackfillService migrates a dataflow's full source catalog page by page.
+// Each page is processed by a job queue worker, and the run stores a
+// checkpoint after every page so it can be paused and resumed.
+type BackfillService struct {
+	db              *gorm.DB
+	dataflowService *DataflowService
+	shopwareService *ShopwareService
+	jobQueue        *JobQueueService
+}
+
+// NewBackfillService creates a new backfill service
+func NewBackfillService(db *gorm.DB, shopwareService *ShopwareService, jobQueue *JobQueueService) *BackfillService {
+	return &BackfillService{
+		db:              db,
+		dataflowService: NewDataflowService(db),
+		shopwareService: shopwareService,
+		jobQueue:        jobQueue,
+	}
+}
+
+// backfillPageJob is the payload of a backfill page job
+type backfillPageJob struct {
+	RunID uint `json:"run_id"`
+	Page  int  `json:"page"`
+}
+
+// StartBackfill starts a new backfill run for a dataflow. In bulk mode new
+// products are created with one Shopify bulk mutation per page.
+func (s *BackfillService) StartBackfill(dataflowID uint, pageSize int, bulk bool) (*models.DataflowRun, error) {
+	dataflow, err := s.dataflowService.GetDataflow(dataflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	if dataflow.IsReverse() {
+		return nil, models.ErrBackfillNotSupported
+	}
+
+	if _, err := backfillSourceEntity(dataflow.Type); err != nil {
+		return nil, err
+	}
+
+	if bulk && dataflow.Type != models.DataflowTypeProduct {
+		return nil, models.ErrBulkNotSupported
+	}
+
+	if pageSize < 1 || pageSize > 500 {
+		pageSize = 100
+	}
+
+	now := time.Now()
+	run := models.DataflowRun{
+		DataflowID: dataflow.ID,
+		Type:       models.RunTypeBackfill,
+		Status:     models.RunStatusRunning,
+		PageSize:   pageSize,
+		NextPage:   1,
+		Bulk:       bulk,
+		StartedAt:  &now,
+	}
+
+	if err := s.db.Create(&run).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.enqueuePage(&run); err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// GetRun gets a dataflow run by ID
+func (s *BackfillService) GetRun(id uint) (*models.DataflowRun, error) {
+	var run models.DataflowRun
+
+	if err := s.db.First(&run, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+// ListRuns lists the runs of a dataflow, newest first
+func (s *BackfillService) ListRuns(dataflowID uint) ([]models.DataflowRun, error) {
+	var runs []models.DataflowRun
+
+	if err := s.db.Where("dataflow_id = ?", dataflowID).Order("created_at DESC").Find(&runs).Error; err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
+
+// PauseRun pauses a running backfill after the page in progress
+func (s *BackfillService) PauseRun(id uint) (*models.DataflowRun, error) {
+	result := s.db.Model(&models.DataflowRun{}).
+		Where("id = ? AND status = ?", id, models.RunStatusRunning).
+		Update("status", models.RunStatusPaused)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		if _, err := s.GetRun(id); err != nil {
+			return nil, err
+		}
+		return nil, models.ErrInvalidRunState
+	}
+
+	return s.GetRun(id)
+}
+
+// ResumeRun resumes a paused or failed backfill from its checkpoint
+func (s *BackfillService) ResumeRun(id uint) (*models.DataflowRun, error) {
+	result := s.db.Model(&models.DataflowRun{}).
+		Where("id = ? AND status IN ?", id, []models.RunStatus{models.RunStatusPaused, models.RunStatusFailed}).
+		Updates(map[string]interface{}{
+			"status":     models.RunStatusRunning,
+			"last_error": "",
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		if _, err := s.GetRun(id); err != nil {
+			return nil, err
+		}
+		return nil, models.ErrInvalidRunState
+	}
+
+	run, err := s.GetRun(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.enqueuePage(run); err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// HandleBackfillPageJob processes one page of a backfill run
+func (s *BackfillService) HandleBackfillPageJob(ctx context.Context, job *models.Job) error {
+	var input backfillPageJob
+	if err := json.Unmarshal([]byte(job.Payload), &input); err != nil {
+		return fmt.Errorf("invalid backfill job payload: %w", err)
+	}
+
+	run, err := s.GetRun(input.RunID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// Skip stale jobs for paused or finished runs and pages already checkpointed
+	if run.Status != models.RunStatusRunning || run.NextPage != input.Page {
+		return nil
+	}
+
+	err = s.processPage(ctx, run)
+	if err != nil && job.Attempts >= job.MaxAttempts {
+		s.db.Model(run).Updates(map[string]interface{}{
+			"status":     models.RunStatusFailed,
+			"last_error": err.Error(),
+		})
+		ReleaseEntityLookupCache(run.ID)
+	}
+
+	return err
+}
+
+// processPage fetches the run's next page, migrates every entity on it and
+// stores the checkpoint. Once the context is cancelled no further entity is
+// migrated and the page is left for a retry.
+func (s *BackfillService) processPage(ctx context.Context, run *models.DataflowRun) error {
+	dataflow, err := s.dataflowService.GetDataflow(run.DataflowID)
+	if err != nil {
+		return err
+	}
+
+	entity, err := backfillSourceEntity(dataflow.Type)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.shopwareService.SearchEntities(&dataflow.SourceConnector, entity, backfillCriteria(dataflow, run.NextPage, run.PageSize))
+	if err != nil {
+		return fmt.Errorf("error fetching page %d: %w", run.NextPage, err)
+	}
+
+	succeeded, failed := 0, 0
+	if run.Bulk {
+		succeeded, failed, err = s.migratePageInBulk(ctx, run, dataflow, result.Data)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, document := range result.Data {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if s.migrateEntity(run, dataflow, document) {
+				succeeded++
+			} else {
+				failed++
+			}
+		}
+	}
+
+	processed := run.Processed + len(result.Data)
+	finished := len(result.Data) < run.PageSize || (result.Total > 0 && processed >= result.Total)
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]interface{}{
+			"next_page": run.NextPage + 1,
+			"processed": gorm.Expr("processed + ?", len(result.Data)),
+			"succeeded": gorm.Expr("succeeded + ?", succeeded),
+			"failed":    gorm.Expr("failed + ?", failed),
+		}
+		if result.Total > 0 {
+			updates["total"] = result.Total
+		}
+		if finished {
+			updates["status"] = models.RunStatusCompleted
+			updates["completed_at"] = time.Now()
+		}
+
+		// Only advance the checkpoint if no other worker already did
+		update := tx.Model(&models.DataflowRun{}).
+			Where("id = ? AND next_page = ?", run.ID, run.NextPage).
+			Updates(updates)
+		if update.Error != nil {
+			return update.Error
+		}
+		if update.RowsAffected == 0 {
+			return nil
+		}
+		if finished {
+			ReleaseEntityLookupCache(run.ID)
+			return nil
+		}
+
+		payload, err := json.Marshal(backfillPageJob{RunID: run.ID, Page: run.NextPage + 1})
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&models.Job{
+			Type:        models.JobTypeBackfillPage,
+			Status:      models.JobStatusQueued,
+			Payload:     string(payload),
+			MaxAttempts: s.jobQueue.maxAttempts,
+			RunAt:       time.Now(),
+		}).Error
+	})
+}
+
+// migrateEntity migrates a single source document and reports whether it succeeded
+func (s *BackfillService) migrateEntity(run *models.DataflowRun, dataflow *models.Dataflow, document map[string]interface{}) bool {
+	migrationLog, sourceData, finished, err := s.createMigrationLog(run, dataflow, document)
+	if err != nil {
+		return false
+	}
+	if finished {
+		return migrationLog.Status == models.MigrationStatusSuccess
+	}
+
+	return s.dataflowService.processMigration(dataflow, migrationLog, sourceData) == nil
+}
+
+// migratePageInBulk migrates a page of products. Products that are already
+// mapped are synced one by one; all others are created by a single Shopify
+// bulk mutation.
+func (s *BackfillService) migratePageInBulk(ctx context.Context, run *models.DataflowRun, dataflow *models.Dataflow, documents []map[string]interface{}) (int, int, error) {
+	// Finish the bulk operation of an earlier attempt at this page first, so
+	// its products are mapped and not created a second time
+	if run.BulkOperationID != "" {
+		if _, _, err := s.finishBulkOperation(run, dataflow); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	mappingService := NewEntityMappingService(s.db)
+	productSyncService := NewProductSyncService(s.db)
+
+	succeeded, failed := 0, 0
+	var pendingLogs []*models.MigrationLog
+	var products []BulkProductCreate
+
+	for _, document := range documents {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+
+		migrationLog, sourceData, finished, err := s.createMigrationLog(run, dataflow, document)
+		if err != nil {
+			failed++
+			continue
+		}
+		if finished {
+			if migrationLog.Status == models.MigrationStatusSuccess {
+				succeeded++
+			} else {
+				failed++
+			}
+			continue
+		}
+
+		transformedJSON, err := s.dataflowService.transformMigration(dataflow, migrationLog, sourceData)
+		if err != nil {
+			failed++
+			continue
+		}
+
+		mapping, err := mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeProduct, migrationLog.SourceIdentifier)
+		if err != nil {
+			s.dataflowService.failMigration(migrationLog, "Error looking up product mapping", err)
+			failed++
+			continue
+		}
+
+		if mapping != nil {
+			if s.dataflowService.syncMigration(dataflow, migrationLog, transformedJSON) == nil {
+				succeeded++
+			} else {
+				failed++
+			}
+			continue
+		}
+
+		productRequest, err := toProductCreateRequest(transformedJSON)
+		if err != nil {
+			s.dataflowService.failMigration(migrationLog, "Error unmarshaling transformed data", err)
+			failed++
+			continue
+		}
+
+		pendingLogs = append(pendingLogs, migrationLog)
+		products = append(products, BulkProductCreate{
+			SourceID: migrationLog.SourceIdentifier,
+			Request:  productRequest,
+			Hash:     payloadHash(transformedJSON),
+		})
+	}
+
+	if len(products) == 0 {
+		return succeeded, failed, nil
+	}
+
+	operation, err := productSyncService.StartBulkCreate(dataflow, products)
+	if err != nil {
+		// The page job is retried as a whole, so these logs are closed
+		// without scheduling retries of their own
+		now := time.Now()
+		for _, migrationLog := range pendingLogs {
+			migrationLog.Status = models.MigrationStatusFailed
+			migrationLog.ErrorMessage = fmt.Sprintf("Error starting bulk import: %v", err)
+			migrationLog.ErrorClass = ClassifyError(err)
+			migrationLog.Attempts++
+			migrationLog.CompletedAt = &now
+			s.db.Save(migrationLog)
+		}
+		return succeeded, failed, err
+	}
+
+	// Remember the operation so a retry of this page can pick it up
+	for _, migrationLog := range pendingLogs {
+		migrationLog.ExecutionARN = operation.ID
+		if err := s.db.Save(migrationLog).Error; err != nil {
+			return succeeded, failed, err
+		}
+	}
+
+	// The results name the input line of each product, so the source ID of
+	// every line is kept with the operation
+	run.BulkOperationID = operation.ID
+	run.BulkSourceIDs = make([]string, len(products))
+	for i, product := range products {
+		run.BulkSourceIDs[i] = product.SourceID
+	}
+	if err := s.db.Model(run).Select("bulk_operation_id", "bulk_source_ids").Updates(run).Error; err != nil {
+		return succeeded, failed, err
+	}
+
+	bulkSucceeded, bulkFailed, err := s.finishBulkOperation(run, dataflow)
+	if err != nil {
+		return succeeded, failed, err
+	}
+
+	return succeeded + bulkSucceeded, failed + bulkFailed, nil
+}
+
+// finishBulkOperation waits for the run's bulk operation and records the
+// outcome of every product it created on the product's migration log. The
+// results are matched to the logs through the source ID of their input line.
+func (s *BackfillService) finishBulkOperation(run *models.DataflowRun, dataflow *models.Dataflow) (int, int, error) {
+	var migrationLogs []models.MigrationLog
+	if err := s.db.Where("run_id = ? AND execution_arn = ? AND status = ?", run.ID, run.BulkOperationID, models.MigrationStatusInProgress).
+		Order("id ASC").
+		Find(&migrationLogs).Error; err != nil {
+		return 0, 0, err
+	}
+
+	logsBySource := make(map[string]*models.MigrationLog, len(migrationLogs))
+	for i := range migrationLogs {
+		logsBySource[migrationLogs[i].SourceIdentifier] = &migrationLogs[i]
+	}
+
+	// Operations started before the source IDs were kept submitted the
+	// logs in ID order
+	sourceIDs := run.BulkSourceIDs
+	if len(sourceIDs) == 0 {
+		for _, migrationLog := range migrationLogs {
+			sourceIDs = append(sourceIDs, migrationLog.SourceIdentifier)
+		}
+	}
+
+	products := make([]BulkProductCreate, len(sourceIDs))
+	for i, sourceID := range sourceIDs {
+		products[i].SourceID = sourceID
+
+		// The request is needed to create the product's variants. A line
+		// without a log is still mapped, so its product is not created again.
+		if migrationLog, ok := logsBySource[sourceID]; ok {
+			products[i].Request, _ = toProductCreateRequest([]byte(migrationLog.TransformedPayload))
+			products[i].Hash = payloadHash([]byte(migrationLog.TransformedPayload))
+		}
+	}
+
+	productSyncService := NewProductSyncService(s.db)
+	results, err := productSyncService.FinishBulkCreate(dataflow, run.BulkOperationID, products)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error finishing bulk operation %s: %w", run.BulkOperationID, err)
+	}
+
+	succeeded, failed := 0, 0
+	for i, sourceID := range sourceIDs {
+		migrationLog, ok := logsBySource[sourceID]
+		if !ok {
+			continue
+		}
+		delete(logsBySource, sourceID)
+
+		if results[i].Err != nil {
+			s.dataflowService.failMigration(migrationLog, "Error creating product in Shopify", results[i].Err)
+			failed++
+			continue
+		}
+
+		migrationLog.DestIdentifier = results[i].ProductID
+		if err := s.dataflowService.completeMigration(migrationLog); err != nil {
+			failed++
+			continue
+		}
+		succeeded++
+	}
+
+	// Logs of the operation that none of its lines belong to
+	for _, migrationLog := range logsBySource {
+		s.dataflowService.failMigration(migrationLog, "Error creating product in Shopify", fmt.Errorf("no line of bulk operation %s", run.BulkOperationID))
+		failed++
+	}
+
+	run.BulkOperationID = ""
+	run.BulkSourceIDs = nil
+	if err := s.db.Model(run).Select("bulk_operation_id", "bulk_source_ids").Updates(run).Error; err != nil {
+		return succeeded, failed, err
+	}
+
+	return succeeded, failed, nil
+}
+
+// createMigrationLog creates the migration log of a source document on the
+// run's current page. A retry of the page reuses the log of an earlier
+// attempt: one that was interrupted or failed without a retry of its own is
+// migrated again, while any other is reported as finished and left alone.
+func (s *BackfillService) createMigrationLog(run *models.DataflowRun, dataflow *models.Dataflow, document map[string]interface{}) (*models.MigrationLog, []byte, bool, error) {
+	sourceID, _ := document["id"].(string)
+
+	var existing models.MigrationLog
+	err := s.db.Where("run_id = ? AND run_page = ? AND source_identifier = ?", run.ID, run.NextPage, sourceID).
+		Order("id DESC").
+		First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, false, err
+	}
+	if err == nil && existing.Status != models.MigrationStatusInProgress && existing.Status != models.MigrationStatusFailed {
+		return &existing, nil, true, nil
+	}
+
+	switch dataflow.Type {
+	case models.DataflowTypeProduct:
+		currencies, err := s.shopwareService.CurrencyCodes(&dataflow.SourceConnector)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		document = productSourceDocument(document, currencies)
+	case models.DataflowTypeInventory:
+		// Stock levels are read from the document as it is
+	case models.DataflowTypeCategory:
+		document = categorySourceDocument(document, dataflow.Category)
+	case models.DataflowTypeOrder:
+		references, err := s.shopwareService.ReferenceData(&dataflow.SourceConnector)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		document = orderSourceDocument(document, references)
+	case models.DataflowTypeCustomer:
+		var err error
+		document, err = s.shopwareService.prepareCustomer(&dataflow.SourceConnector, document)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	default:
+		document = withTranslatedFallback(document, "name", "description")
+	}
+
+	sourceData, err := json.Marshal(document)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if existing.ID != 0 {
+		existing.SourcePayload = string(sourceData)
+		existing.Status = models.MigrationStatusInProgress
+		existing.ExecutionARN = ""
+		if err := s.db.Save(&existing).Error; err != nil {
+			return nil, nil, false, err
+		}
+		return &existing, sourceData, false, nil
+	}
+
+	runID := run.ID
+	migrationLog := models.MigrationLog{
+		DataflowID:       dataflow.ID,
+		RunID:            &runID,
+		RunPage:          run.NextPage,
+		SourceIdentifier: sourceID,
+		SourcePayload:    string(sourceData),
+		Status:           models.MigrationStatusInProgress,
+	}
+
+	if err := s.db.Create(&migrationLog).Error; err != nil {
+		return nil, nil, false, err
+	}
+
+	return &migrationLog, sourceData, false, nil
+}
+
+// enqueuePage queues the job for the run's next page, unless that page's
+// job is still queued or running. A run paused mid-page keeps its job, which
+// would otherwise process the page a second time next to the new one.
+func (s *BackfillService) enqueuePage(run *models.DataflowRun) error {
+	payload, err := json.Marshal(backfillPageJob{RunID: run.ID, Page: run.NextPage})
+	if err != nil {
+		return err
+	}
+
+	var active int64
+	if err := s.db.Model(&models.Job{}).
+		Where("type = ? AND payload = ? AND status IN ?", models.JobTypeBackfillPage, string(payload),
+			[]models.JobStatus{models.JobStatusQueued, models.JobStatusRunning}).
+		Count(&active).Error; err != nil {
+		return fmt.Errorf("error checking for a queued page job: %w", err)
+	}
+	if active > 0 {
+		return nil
+	}
+
+	_, err = s.jobQueue.Enqueue(models.JobTypeBackfillPage, payload)
+	return err
+}
+
+// backfillSourceEntity returns the Shopware entity read by a backfill
+func backfillSourceEntity(dataflowType models.DataflowType) (string, error) {
+	switch dataflowType {
+	case models.DataflowTypeProduct, models.DataflowTypeInventory:
+		return "product", nil
+	case models.DataflowTypeOrder:
+		return "order", nil
+	case models.DataflowTypeCategory:
+		return "category", nil
+	case models.DataflowTypeCustomer:
+		return "customer", nil
+	default:
+		return "", fmt.Errorf("backfill not supported for dataflow type: %s", dataflowType)
+	}
+}
+
+// backfillCriteria returns the search criteria for a backfill page. Results
+// are sorted by ID so pages stay stable while the run is in progress.
+func backfillCriteria(dataflow *models.Dataflow, page, pageSize int) SearchCriteria {
+	criteria := SearchCriteria{
+		Page:           page,
+		Limit:          pageSize,
+		TotalCountMode: 1,
+		Sort:           []map[string]interface{}{{"field": "id", "order": "ASC"}},
+	}
+
+	// Only parent products are migrated; variant children belong to them
+	// and are loaded with them
+	switch dataflow.Type {
+	case models.DataflowTypeProduct:
+		criteria.Filter = []map[string]interface{}{
+			{"type": "equals", "field": "parentId", "value": nil},
+		}
+		criteria.Associations = productAssociations
+	case models.DataflowTypeInventory:
+		criteria.Filter = []map[string]interface{}{
+			{"type": "equals", "field": "parentId", "value": nil},
+		}
+		criteria.Associations = inventoryAssociations(dataflow.Inventory)
+	case models.DataflowTypeCategory:
+		criteria.Associations = categoryAssociations
+	case models.DataflowTypeOrder:
+		criteria.Associations = orderAssociations
+	case models.DataflowTypeCustomer:
+		criteria.Associations = customerAssociations
+	}
+
+	return criteria
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// CollectionSyncService writes Shopware categories to Shopify custom
+// collections, using the entity mapping table to track synced categories
+type CollectionSyncService struct {
+	db              *gorm.DB
+	shopifyService  *ShopifyService
+	shopwareService *ShopwareService
+	mappingService  *EntityMappingService
+}
+
+// NewCollectionSyncService creates a new collection sync service
+func NewCollectionSyncService(db *gorm.DB) *CollectionSyncService {
+	return &CollectionSyncService{
+		db:              db,
+		shopifyService:  NewShopifyService(db),
+		shopwareService: NewShopwareService(db),
+		mappingService:  NewEntityMappingService(db),
+	}
+}
+
+// SyncCollection creates or updates the Shopify collection of a Shopware
+// category and returns its ID. A new collection is filled with the already
+// synced products of the category; later changes to the products keep it
+// up to date.
+func (s *CollectionSyncService) SyncCollection(dataflow *models.Dataflow, sourceID string, collection *ShopifyCollection, hash string) (string, error) {
+	connector := &dataflow.DestConnector
+
+	mapping, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeCollection, sourceID)
+	if err != nil {
+		return "", fmt.Errorf("error looking up collection mapping: %w", err)
+	}
+
+	if mapping != nil && mapping.LastSyncedHash == hash {
+		// Nothing changed since the last sync
+		return mapping.DestID, nil
+	}
+
+	var collectionID string
+	if mapping != nil {
+		collectionID, err = s.shopifyService.UpdateCollection(connector, mapping.DestID, collection)
+		if err != nil {
+			return "", fmt.Errorf("error updating collection in Shopify: %w", err)
+		}
+	} else {
+		collectionID, err = s.shopifyService.CreateCollection(connector, collection)
+		if err != nil {
+			return "", fmt.Errorf("error creating collection in Shopify: %w", err)
+		}
+	}
+
+	// A new collection is mapped before it is filled, so a retry does not
+	// create it again; without the hash the retry fills it
+	var fillErr error
+	if mapping == nil {
+		fillErr = s.addCategoryProducts(dataflow, sourceID, collectionID)
+		if fillErr != nil {
+			hash = ""
+		}
+	}
+
+	if err := s.mappingService.UpsertMapping(&models.EntityMapping{
+		SourceConnectorID: dataflow.SourceConnectorID,
+		DestConnectorID:   dataflow.DestConnectorID,
+		EntityType:        models.EntityTypeCollection,
+		SourceID:          sourceID,
+		DestID:            collectionID,
+		LastSyncedHash:    hash,
+	}); err != nil {
+		return collectionID, fmt.Errorf("error saving collection mapping: %w", err)
+	}
+
+	if fillErr != nil {
+		return collectionID, fmt.Errorf("error adding products to collection: %w", fillErr)
+	}
+
+	return collectionID, nil
+}
+
+// addCategoryProducts adds the synced products of a Shopware category to
+// its collection
+func (s *CollectionSyncService) addCategoryProducts(dataflow *models.Dataflow, categoryID, collectionID string) error {
+	var productIDs []string
+	for page := 1; ; page++ {
+		result, err := s.shopwareService.SearchEntities(&dataflow.SourceConnector, "product", SearchCriteria{
+			Page:  page,
+			Limit: 500,
+			Filter: []map[string]interface{}{
+				{"type": "equals", "field": "categories.id", "value": categoryID},
+				{"type": "equals", "field": "parentId", "value": nil},
+			},
+			Sort: []map[string]interface{}{{"field": "id", "order": "ASC"}},
+		})
+		if err != nil {
+			return fmt.Errorf("error fetching products of category %s: %w", categoryID, err)
+		}
+
+		for _, product := range result.Data {
+			mapping, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeProduct, stringField(product, "id"))
+			if err != nil {
+				return fmt.Errorf("error looking up product mapping: %w", err)
+			}
+			if mapping != nil {
+				productIDs = append(productIDs, mapping.DestID)
+			}
+		}
+
+		if len(result.Data) < 500 {
+			break
+		}
+	}
+
+	if len(productIDs) == 0 {
+		return nil
+	}
+	return s.shopifyService.AddProductsToCollection(&dataflow.DestConnector, collectionID, productIDs)
+}
+
+// syncCollections puts a product in the collections of its categories and
+// takes it out of the other synced collections. Collections that were not
+// created from a category are left alone, and so are categories that have
+// no collection yet. A request without collections leaves them alone.
+func (s *ProductSyncService) syncCollections(dataflow *models.Dataflow, productID string, product *ShopifyProduct) error {
+	if product.Collections == nil {
+		return nil
+	}
+
+	connector := &dataflow.DestConnector
+
+	wanted := make(map[string]bool, len(product.Collections))
+	for _, categoryID := range product.Collections {
+		mapping, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeCollection, categoryID)
+		if err != nil {
+			return fmt.Errorf("error looking up collection mapping: %w", err)
+		}
+		if mapping != nil {
+			wanted[mapping.DestID] = true
+		}
+	}
+
+	current, err := s.shopifyService.GetProductCollections(connector, productID)
+	if err != nil {
+		return fmt.Errorf("error getting collections: %w", err)
+	}
+
+	member := make(map[string]bool, len(current))
+	for _, collectionID := range current {
+		member[collectionID] = true
+		if wanted[collectionID] {
+			continue
+		}
+
+		mapping, err := s.mappingService.FindMappingByDestID(dataflow.DestConnectorID, models.EntityTypeCollection, collectionID)
+		if err != nil {
+			return fmt.Errorf("error looking up collection mapping: %w", err)
+		}
+		if mapping == nil {
+			continue
+		}
+
+		if err := s.shopifyService.RemoveProductsFromCollection(connector, collectionID, []string{productID}); err != nil {
+			return err
+		}
+	}
+
+	for collectionID := range wanted {
+		if member[collectionID] {
+			continue
+		}
+		if err := s.shopifyService.AddProductsToCollection(connector, collectionID, []string{productID}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// errSkipField is returned by a transformation that decided the destination
+// field should not be set
+var errSkipField = errors.New("field skipped")
+
+// ConditionalConfig is the transform config of a conditional mapping. The
+// first rule whose conditions match decides the output. If no rule matches,
+// the source value is passed through unchanged.
+type ConditionalConfig struct {
+	Rules []ConditionalRule `json:"rules"`
+}
+
+// ConditionalRule emits an output or skips the field when its conditions
+// match. A rule without conditions always matches.
+type ConditionalRule struct {
+	Conditions []Condition `json:"conditions"`
+	Match      string      `json:"match"`  // "all" (default) or "any"
+	Output     interface{} `json:"output"` // Omitted or null passes the source value through
+	Skip       bool        `json:"skip"`
+}
+
+// Condition compares the source value, or another field of the source
+// document, with a value
+type Condition struct {
+	Field    string      `json:"field"`    // Source field in dot notation; empty compares the mapped value
+	Operator string      `json:"operator"` // equals, not_equals, contains, regex, greater_than, less_than, exists
+	Value    interface{} `json:"value"`
+}
+
+// parseConditionalConfig parses and validates a conditional transform config
+func parseConditionalConfig(transformConfig string) (*ConditionalConfig, error) {
+	var config ConditionalConfig
+	if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
+		return nil, fmt.Errorf("invalid transform config: %w", err)
+	}
+
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("conditional transform needs at least one rule")
+	}
+
+	for i, rule := range config.Rules {
+		if rule.Match != "" && rule.Match != "all" && rule.Match != "any" {
+			return nil, fmt.Errorf("rule %d: invalid match %q", i, rule.Match)
+		}
+
+		for _, condition := range rule.Conditions {
+			switch condition.Operator {
+			case "equals", "not_equals", "contains", "exists":
+			case "regex":
+				if _, err := regexp.Compile(fmt.Sprintf("%v", condition.Value)); err != nil {
+					return nil, fmt.Errorf("rule %d: invalid regex: %w", i, err)
+				}
+			case "greater_than", "less_than":
+				if _, ok := toFloat(condition.Value); !ok {
+					return nil, fmt.Errorf("rule %d: %s needs a numeric value", i, condition.Operator)
+				}
+			default:
+				return nil, fmt.Errorf("rule %d: unsupported operator %q", i, condition.Operator)
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+// applyConditional applies a conditional transform to a value
+func applyConditional(value interface{}, transformConfig string, source map[string]interface{}) (interface{}, error) {
+	config, err := parseConditionalConfig(transformConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range config.Rules {
+		if !rule.matches(value, source) {
+			continue
+		}
+
+		if rule.Skip {
+			return nil, errSkipField
+		}
+		if rule.Output == nil {
+			return value, nil
+		}
+		return rule.Output, nil
+	}
+
+	return value, nil
+}
+
+// matches reports whether the rule's conditions hold
+func (r ConditionalRule) matches(value interface{}, source map[string]interface{}) bool {
+	if len(r.Conditions) == 0 {
+		return true
+	}
+
+	for _, condition := range r.Conditions {
+		matched := condition.holds(value, source)
+		if r.Match == "any" && matched {
+			return true
+		}
+		if r.Match != "any" && !matched {
+			return false
+		}
+	}
+
+	return r.Match != "any"
+}
+
+// holds evaluates the condition
+func (c Condition) holds(value interface{}, source map[string]interface{}) bool {
+	subject, found := value, true
+	if c.Field != "" {
+		var err error
+		subject, err = getNestedValue(source, c.Field)
+		found = err == nil
+	}
+
+	switch c.Operator {
+	case "exists":
+		return found && subject != nil
+	case "equals":
+		return found && looselyEqual(subject, c.Value)
+	case "not_equals":
+		return !found || !looselyEqual(subject, c.Value)
+	}
+
+	if !found || subject == nil {
+		return false
+	}
+
+	switch c.Operator {
+	case "contains":
+		if items, ok := subject.([]interface{}); ok {
+			for _, item := range items {
+				if looselyEqual(item, c.Value) {
+					return true
+				}
+			}
+			return false
+		}
+		return strings.Contains(fmt.Sprintf("%v", subject), fmt.Sprintf("%v", c.Value))
+
+	case "regex":
+		pattern, err := regexp.Compile(fmt.Sprintf("%v", c.Value))
+		if err != nil {
+			return false
+		}
+		return pattern.MatchString(fmt.Sprintf("%v", subject))
+
+	case "greater_than", "less_than":
+		left, ok := toFloat(subject)
+		if !ok {
+			return false
+		}
+		right, ok := toFloat(c.Value)
+		if !ok {
+			return false
+		}
+		if c.Operator == "greater_than" {
+			return left > right
+		}
+		return left < right
+	}
+
+	return false
+}
+
+// looselyEqual compares two JSON values, treating values with the same
+// text form as equal so 0 matches "0" and true matches "true"
+func looselyEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// toFloat converts a JSON number or numeric string to a float
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
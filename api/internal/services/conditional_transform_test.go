@@ -0,0 +1,88 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestApplyConditional(t *testing.T) {
+	source := map[string]interface{}{
+		"stock":      0.0,
+		"isCloseout": true,
+		"name":       "Summer Shirt",
+		"tags":       []interface{}{"sale", "new"},
+		"price":      map[string]interface{}{"gross": 19.99},
+		"ean":        nil,
+	}
+
+	statusRules := `{"rules": [
+		{"conditions": [{"field": "stock", "operator": "equals", "value": 0}, {"field": "isCloseout", "operator": "equals", "value": true}], "output": "DRAFT"},
+		{"output": "ACTIVE"}
+	]}`
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		config string
+		want   interface{}
+	}{
+		{"all conditions match", "x", statusRules, "DRAFT"},
+		{"rule without conditions matches", "x", `{"rules": [{"conditions": [{"field": "stock", "operator": "greater_than", "value": 0}], "output": "A"}, {"output": "B"}]}`, "B"},
+		{"no rule matches passes the value through", "x", `{"rules": [{"conditions": [{"operator": "equals", "value": "y"}], "output": "A"}]}`, "x"},
+		{"null output passes the value through", "x", `{"rules": [{"conditions": [{"operator": "equals", "value": "x"}]}]}`, "x"},
+		{"any matches one condition", "x", `{"rules": [{"match": "any", "conditions": [{"operator": "equals", "value": "y"}, {"field": "isCloseout", "operator": "exists"}], "output": "A"}]}`, "A"},
+		{"any without a match", "x", `{"rules": [{"match": "any", "conditions": [{"operator": "equals", "value": "y"}, {"field": "missing", "operator": "exists"}], "output": "A"}]}`, "x"},
+		{"equals compares loosely", 0.0, `{"rules": [{"conditions": [{"operator": "equals", "value": "0"}], "output": "zero"}]}`, "zero"},
+		{"not_equals holds for missing fields", "x", `{"rules": [{"conditions": [{"field": "missing", "operator": "not_equals", "value": 1}], "output": "A"}]}`, "A"},
+		{"exists is false for null", "x", `{"rules": [{"conditions": [{"field": "ean", "operator": "exists"}], "output": "A"}]}`, "x"},
+		{"contains searches strings", "x", `{"rules": [{"conditions": [{"field": "name", "operator": "contains", "value": "Shirt"}], "output": "A"}]}`, "A"},
+		{"contains searches arrays", "x", `{"rules": [{"conditions": [{"field": "tags", "operator": "contains", "value": "sale"}], "output": "A"}]}`, "A"},
+		{"regex", "SW-1001", `{"rules": [{"conditions": [{"operator": "regex", "value": "^SW-\\d+$"}], "output": "A"}]}`, "A"},
+		{"greater_than reads nested fields", "x", `{"rules": [{"conditions": [{"field": "price.gross", "operator": "greater_than", "value": 10}], "output": "A"}]}`, "A"},
+		{"less_than needs a number", "x", `{"rules": [{"conditions": [{"field": "name", "operator": "less_than", "value": 10}], "output": "A"}]}`, "x"},
+		{"output can be any JSON value", "x", `{"rules": [{"output": ["a", 1]}]}`, []interface{}{"a", 1.0}},
+	}
+
+	for _, test := range tests {
+		got, err := applyConditional(test.value, test.config, source)
+		if err != nil {
+			t.Errorf("%s: applyConditional: %v", test.name, err)
+			continue
+		}
+
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(test.want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%s: applyConditional = %s, want %s", test.name, gotJSON, wantJSON)
+		}
+	}
+}
+
+func TestApplyConditionalSkip(t *testing.T) {
+	config := `{"rules": [{"conditions": [{"operator": "equals", "value": ""}], "skip": true}]}`
+
+	if _, err := applyConditional("", config, nil); !errors.Is(err, errSkipField) {
+		t.Errorf("applyConditional of a skipped value = %v, want %v", err, errSkipField)
+	}
+	if got, err := applyConditional("x", config, nil); err != nil || got != "x" {
+		t.Errorf("applyConditional of a kept value = %v, %v, want x", got, err)
+	}
+}
+
+func TestParseConditionalConfigInvalid(t *testing.T) {
+	tests := []string{
+		`not json`,
+		`{"rules": []}`,
+		`{"rules": [{"match": "some", "output": 1}]}`,
+		`{"rules": [{"conditions": [{"operator": "like", "value": "x"}]}]}`,
+		`{"rules": [{"conditions": [{"operator": "regex", "value": "("}]}]}`,
+		`{"rules": [{"conditions": [{"operator": "greater_than", "value": "many"}]}]}`,
+	}
+
+	for _, config := range tests {
+		if _, err := parseConditionalConfig(config); err == nil {
+			t.Errorf("parseConditionalConfig(%s) succeeded, want an error", config)
+		}
+	}
+}
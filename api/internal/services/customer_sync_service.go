@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// CustomerSyncService writes Shopware customers to Shopify, using the entity
+// mapping table to track synced customers
+type CustomerSyncService struct {
+	db             *gorm.DB
+	shopifyService *ShopifyService
+	mappingService *EntityMappingService
+}
+
+// NewCustomerSyncService creates a new customer sync service
+func NewCustomerSyncService(db *gorm.DB) *CustomerSyncService {
+	return &CustomerSyncService{
+		db:             db,
+		shopifyService: NewShopifyService(db),
+		mappingService: NewEntityMappingService(db),
+	}
+}
+
+// SyncCustomer creates or updates the Shopify customer of a Shopware
+// customer and returns its ID. An unmapped customer whose email address
+// already belongs to a Shopify customer is linked to that customer and
+// updated, since Shopify email addresses are unique.
+func (s *CustomerSyncService) SyncCustomer(dataflow *models.Dataflow, sourceID string, customer *ShopifyCustomer, hash string) (string, error) {
+	connector := &dataflow.DestConnector
+
+	mapping, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeCustomer, sourceID)
+	if err != nil {
+		return "", fmt.Errorf("error looking up customer mapping: %w", err)
+	}
+
+	if mapping != nil && mapping.LastSyncedHash == hash {
+		// Nothing changed since the last sync
+		return mapping.DestID, nil
+	}
+
+	customerID := ""
+	if mapping != nil {
+		customerID = mapping.DestID
+	} else if customer.Email != "" {
+		customerID, err = s.shopifyService.FindCustomerByEmail(connector, customer.Email)
+		if err != nil {
+			return "", fmt.Errorf("error looking up customer by email: %w", err)
+		}
+	}
+
+	if customerID != "" {
+		customerID, err = s.shopifyService.UpdateCustomer(connector, customerID, customer)
+		if err != nil {
+			return "", fmt.Errorf("error updating customer in Shopify: %w", err)
+		}
+	} else {
+		customerID, err = s.shopifyService.CreateCustomer(connector, customer)
+		if err != nil {
+			return "", fmt.Errorf("error creating customer in Shopify: %w", err)
+		}
+	}
+
+	if err := s.mappingService.UpsertMapping(&models.EntityMapping{
+		SourceConnectorID: dataflow.SourceConnectorID,
+		DestConnectorID:   dataflow.DestConnectorID,
+		EntityType:        models.EntityTypeCustomer,
+		SourceID:          sourceID,
+		DestID:            customerID,
+		LastSyncedHash:    hash,
+	}); err != nil {
+		return customerID, fmt.Errorf("error saving customer mapping: %w", err)
+	}
+
+	return customerID, nil
+}
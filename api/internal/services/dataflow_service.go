@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
 	"gorm.io/gorm"
@@ -152,99 +153,406 @@ func (s *DataflowService) ExecuteDataflow(dataflowID uint, sourceIdentifier stri
 		return err
 	}
 
-	// Execute the dataflow
-	// This would normally be handled by the Step Functions workflow
-	// For testing purposes, we'll implement a basic flow here
+	return s.processMigration(dataflow, &migrationLog, sourceData)
+}
 
-	// 1. Transform the data
-	fieldMappingService := NewFieldMappingService(s.db)
-	result, err := fieldMappingService.TransformData(dataflow.ID, sourceData)
+// RunMigration runs the migration pipeline for an existing migration log.
+// It is used by the local executor in place of the Step Functions workflow.
+func (s *DataflowService) RunMigration(migrationID uint, sourceData []byte) error {
+	migrationLog, err := s.GetMigrationLog(migrationID)
 	if err != nil {
-		migrationLog.Status = models.MigrationStatusFailed
-		migrationLog.ErrorMessage = fmt.Sprintf("Error transforming data: %v", err)
-		s.db.Save(&migrationLog)
 		return err
 	}
 
+	dataflow, err := s.GetDataflow(migrationLog.DataflowID)
+	if err != nil {
+		return s.failMigration(migrationLog, "Error loading dataflow", err)
+	}
+
+	if len(sourceData) == 0 {
+		sourceData = []byte(migrationLog.SourcePayload)
+	}
+
+	// Claim the migration, since a migration dispatched again after its
+	// pending lease ran out can be queued twice
+	claim := s.db.Model(&models.MigrationLog{}).
+		Where("id = ? AND status = ?", migrationLog.ID, models.MigrationStatusPending).
+		Update("status", models.MigrationStatusInProgress)
+	if claim.Error != nil {
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return nil
+	}
+	migrationLog.Status = models.MigrationStatusInProgress
+
+	return s.processMigration(dataflow, migrationLog, sourceData)
+}
+
+// processMigration transforms the source data, writes it to the destination and
+// records the outcome on the migration log
+func (s *DataflowService) processMigration(dataflow *models.Dataflow, migrationLog *models.MigrationLog, sourceData []byte) error {
+	// Inventory dataflows write stock levels without field mappings
+	if dataflow.IsReverse() {
+		return s.syncStockMigration(dataflow, migrationLog, sourceData)
+	}
+	if dataflow.Type == models.DataflowTypeInventory {
+		return s.syncInventoryMigration(dataflow, migrationLog, sourceData)
+	}
+
+	transformedJSON, err := s.transformMigration(dataflow, migrationLog, sourceData)
+	if err != nil {
+		return err
+	}
+
+	return s.syncMigration(dataflow, migrationLog, transformedJSON)
+}
+
+// transformMigration applies the dataflow's field mappings to the source
+// data and stores the result on the migration log
+func (s *DataflowService) transformMigration(dataflow *models.Dataflow, migrationLog *models.MigrationLog, sourceData []byte) ([]byte, error) {
+	fieldMappingService := NewFieldMappingService(s.db)
+
+	// Documents of a run share entity lookups with the rest of the run
+	var result *MappingResult
+	var err error
+	if migrationLog.RunID != nil {
+		result, err = fieldMappingService.TransformDataForRun(dataflow.ID, *migrationLog.RunID, sourceData)
+	} else {
+		result, err = fieldMappingService.TransformData(dataflow.ID, sourceData)
+	}
+	if err != nil {
+		return nil, s.failMigration(migrationLog, "Error transforming data", classifyAs(models.ErrorClassTransform, err))
+	}
+
 	if result.Error != nil {
-		migrationLog.Status = models.MigrationStatusFailed
-		migrationLog.ErrorMessage = fmt.Sprintf("Error in transformation: %v", result.Error)
-		s.db.Save(&migrationLog)
-		return result.Error
+		return nil, s.failMigration(migrationLog, "Error in transformation", classifyAs(models.ErrorClassTransform, result.Error))
 	}
 
-	// 2. Upload to Shopify
-	shopifyService := NewShopifyService(s.db)
+	transformedJSON, err := json.Marshal(result.Data)
+	if err != nil {
+		return nil, s.failMigration(migrationLog, "Error marshaling transformed data", classifyAs(models.ErrorClassTransform, err))
+	}
 
+	migrationLog.TransformedPayload = string(transformedJSON)
+	return transformedJSON, nil
+}
+
+// syncMigration writes transformed data to Shopify and records the outcome
+// on the migration log
+func (s *DataflowService) syncMigration(dataflow *models.Dataflow, migrationLog *models.MigrationLog, transformedJSON []byte) error {
 	switch dataflow.Type {
 	case models.DataflowTypeProduct:
-		// Create a Shopify product
-		transformedJSON, err := json.Marshal(result.Data)
+		productRequest, err := toProductCreateRequest(transformedJSON)
+		if err != nil {
+			return s.failMigration(migrationLog, "Error unmarshaling transformed data", err)
+		}
+
+		productSyncService := NewProductSyncService(s.db)
+		productID, err := productSyncService.SyncProduct(dataflow, migrationLog.SourceIdentifier, productRequest, payloadHash(transformedJSON))
 		if err != nil {
-			migrationLog.Status = models.MigrationStatusFailed
-			migrationLog.ErrorMessage = fmt.Sprintf("Error marshaling transformed data: %v", err)
-			s.db.Save(&migrationLog)
-			return err
+			return s.failMigration(migrationLog, "Error syncing product to Shopify", err)
 		}
 
-		migrationLog.TransformedPayload = string(transformedJSON)
+		migrationLog.DestIdentifier = productID
 
-		var productRequest ProductCreateRequest
-		if err := json.Unmarshal(transformedJSON, &productRequest); err != nil {
-			migrationLog.Status = models.MigrationStatusFailed
-			migrationLog.ErrorMessage = fmt.Sprintf("Error unmarshaling transformed data: %v", err)
-			s.db.Save(&migrationLog)
-			return err
+	case models.DataflowTypeOrder:
+		orderRequest, err := toOrderCreateRequest(transformedJSON)
+		if err != nil {
+			return s.failMigration(migrationLog, "Error unmarshaling transformed data", err)
+		}
+		if err := checkOrderDiscount([]byte(migrationLog.SourcePayload), &orderRequest.Order); err != nil {
+			return s.failMigration(migrationLog, "Error in transformation", err)
 		}
 
-		response, err := shopifyService.CreateProduct(&dataflow.DestConnector, &productRequest)
+		orderID, err := s.syncOrder(dataflow, migrationLog.SourceIdentifier, orderRequest, payloadHash(transformedJSON))
 		if err != nil {
-			migrationLog.Status = models.MigrationStatusFailed
-			migrationLog.ErrorMessage = fmt.Sprintf("Error creating product in Shopify: %v", err)
-			s.db.Save(&migrationLog)
-			return err
+			return s.failMigration(migrationLog, "Error creating order in Shopify", err)
 		}
 
-		migrationLog.DestIdentifier = fmt.Sprintf("%d", response.Product.ID)
+		migrationLog.DestIdentifier = orderID
 
-	case models.DataflowTypeOrder:
-		// Create a Shopify order
-		transformedJSON, err := json.Marshal(result.Data)
+	case models.DataflowTypeCategory:
+		collection, err := toCollectionRequest(transformedJSON)
+		if err != nil {
+			return s.failMigration(migrationLog, "Error unmarshaling transformed data", err)
+		}
+
+		collectionSyncService := NewCollectionSyncService(s.db)
+		collectionID, err := collectionSyncService.SyncCollection(dataflow, migrationLog.SourceIdentifier, collection, payloadHash(transformedJSON))
 		if err != nil {
-			migrationLog.Status = models.MigrationStatusFailed
-			migrationLog.ErrorMessage = fmt.Sprintf("Error marshaling transformed data: %v", err)
-			s.db.Save(&migrationLog)
-			return err
+			return s.failMigration(migrationLog, "Error syncing collection to Shopify", err)
 		}
 
-		migrationLog.TransformedPayload = string(transformedJSON)
+		migrationLog.DestIdentifier = collectionID
 
-		var orderRequest OrderCreateRequest
-		if err := json.Unmarshal(transformedJSON, &orderRequest); err != nil {
-			migrationLog.Status = models.MigrationStatusFailed
-			migrationLog.ErrorMessage = fmt.Sprintf("Error unmarshaling transformed data: %v", err)
-			s.db.Save(&migrationLog)
-			return err
+	case models.DataflowTypeCustomer:
+		customer, err := toCustomerRequest(transformedJSON)
+		if err != nil {
+			return s.failMigration(migrationLog, "Error unmarshaling transformed data", err)
 		}
 
-		response, err := shopifyService.CreateOrder(&dataflow.DestConnector, &orderRequest)
+		customerSyncService := NewCustomerSyncService(s.db)
+		customerID, err := customerSyncService.SyncCustomer(dataflow, migrationLog.SourceIdentifier, customer, payloadHash(transformedJSON))
 		if err != nil {
-			migrationLog.Status = models.MigrationStatusFailed
-			migrationLog.ErrorMessage = fmt.Sprintf("Error creating order in Shopify: %v", err)
-			s.db.Save(&migrationLog)
-			return err
+			return s.failMigration(migrationLog, "Error syncing customer to Shopify", err)
 		}
 
-		migrationLog.DestIdentifier = fmt.Sprintf("%d", response.Order.ID)
+		migrationLog.DestIdentifier = customerID
 
 	default:
-		migrationLog.Status = models.MigrationStatusFailed
-		migrationLog.ErrorMessage = "Unsupported dataflow type"
-		s.db.Save(&migrationLog)
-		return fmt.Errorf("unsupported dataflow type: %s", dataflow.Type)
+		return s.failMigration(migrationLog, "Unsupported dataflow type", fmt.Errorf("unsupported dataflow type: %s", dataflow.Type))
 	}
 
-	// Update the migration log
+	return s.completeMigration(migrationLog)
+}
+
+// syncInventoryMigration writes the stock levels of a Shopware product to
+// Shopify and records the outcome on the migration log
+func (s *DataflowService) syncInventoryMigration(dataflow *models.Dataflow, migrationLog *models.MigrationLog, sourceData []byte) error {
+	inventorySyncService := NewInventorySyncService(s.db)
+	if _, err := inventorySyncService.SyncInventory(dataflow, sourceData); err != nil {
+		return s.failMigration(migrationLog, "Error setting inventory quantities in Shopify", err)
+	}
+
+	return s.completeMigration(migrationLog)
+}
+
+// syncStockMigration writes the stock of a Shopify inventory item to
+// Shopware and records the outcome on the migration log
+func (s *DataflowService) syncStockMigration(dataflow *models.Dataflow, migrationLog *models.MigrationLog, sourceData []byte) error {
+	stockSyncService := NewStockSyncService(s.db)
+	productID, err := stockSyncService.SyncStock(dataflow, sourceData)
+	if err != nil {
+		return s.failMigration(migrationLog, "Error setting product stock in Shopware", err)
+	}
+
+	migrationLog.DestIdentifier = productID
+	return s.completeMigration(migrationLog)
+}
+
+// completeMigration marks a migration log as successful
+func (s *DataflowService) completeMigration(migrationLog *models.MigrationLog) error {
+	now := time.Now()
+	migrationLog.Attempts++
 	migrationLog.Status = models.MigrationStatusSuccess
-	return s.db.Save(&migrationLog).Error
+	migrationLog.ErrorMessage = ""
+	migrationLog.ErrorClass = ""
+	migrationLog.NextRetryAt = nil
+	migrationLog.CompletedAt = &now
+	return s.db.Save(migrationLog).Error
+}
+
+// syncOrder creates a Shopify order for a Shopware order. Orders that were
+// already created are not created a second time.
+func (s *DataflowService) syncOrder(dataflow *models.Dataflow, sourceID string, orderRequest *OrderCreateRequest, hash string) (string, error) {
+	mappingService := NewEntityMappingService(s.db)
+
+	mapping, err := mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeOrder, sourceID)
+	if err != nil {
+		return "", fmt.Errorf("error looking up order mapping: %w", err)
+	}
+
+	if mapping != nil {
+		return mapping.DestID, nil
+	}
+
+	if err := s.resolveLineItems(dataflow, &orderRequest.Order); err != nil {
+		return "", err
+	}
+
+	shopifyService := NewShopifyService(s.db)
+	response, err := shopifyService.CreateOrder(&dataflow.DestConnector, orderRequest)
+	if err != nil {
+		return "", err
+	}
+
+	if err := mappingService.UpsertMapping(&models.EntityMapping{
+		SourceConnectorID: dataflow.SourceConnectorID,
+		DestConnectorID:   dataflow.DestConnectorID,
+		EntityType:        models.EntityTypeOrder,
+		SourceID:          sourceID,
+		DestID:            response.Order.ID,
+		LastSyncedHash:    hash,
+	}); err != nil {
+		return response.Order.ID, fmt.Errorf("error saving order mapping: %w", err)
+	}
+
+	return response.Order.ID, nil
+}
+
+// RecordDestIdentifier stores the entity mapping for a migration completed
+// outside this service, such as by the Step Functions workflow
+func (s *DataflowService) RecordDestIdentifier(migrationLog *models.MigrationLog) error {
+	if migrationLog.DestIdentifier == "" {
+		return nil
+	}
+
+	dataflow, err := s.GetDataflow(migrationLog.DataflowID)
+	if err != nil {
+		return err
+	}
+
+	var entityType models.EntityType
+	switch dataflow.Type {
+	case models.DataflowTypeProduct:
+		entityType = models.EntityTypeProduct
+	case models.DataflowTypeOrder:
+		entityType = models.EntityTypeOrder
+	case models.DataflowTypeCategory:
+		entityType = models.EntityTypeCollection
+	case models.DataflowTypeCustomer:
+		entityType = models.EntityTypeCustomer
+	default:
+		return nil
+	}
+
+	mappingService := NewEntityMappingService(s.db)
+	return mappingService.UpsertMapping(&models.EntityMapping{
+		SourceConnectorID: dataflow.SourceConnectorID,
+		DestConnectorID:   dataflow.DestConnectorID,
+		EntityType:        entityType,
+		SourceID:          migrationLog.SourceIdentifier,
+		DestID:            migrationLog.DestIdentifier,
+		LastSyncedHash:    payloadHash([]byte(migrationLog.TransformedPayload)),
+	})
+}
+
+// failMigration records a failed attempt on a migration log and returns the
+// cause. The migration is scheduled for a retry if the dataflow's retry
+// policy allows it.
+func (s *DataflowService) failMigration(migrationLog *models.MigrationLog, message string, cause error) error {
+	migrationLog.ErrorMessage = fmt.Sprintf("%s: %v", message, cause)
+	s.RecordFailedAttempt(migrationLog, ClassifyError(cause))
+	return cause
+}
+
+// RecordFailedAttempt records a failed attempt of the given error class on
+// a migration log. The migration moves to retrying with its next attempt
+// scheduled by the dataflow's retry policy. A retryable failure that used up
+// all attempts moves to the dead-letter state; other failures are final.
+func (s *DataflowService) RecordFailedAttempt(migrationLog *models.MigrationLog, errorClass models.ErrorClass) error {
+	if errorClass == "" {
+		errorClass = models.ErrorClassUnknown
+	}
+
+	now := time.Now()
+	migrationLog.Attempts++
+	migrationLog.ErrorClass = errorClass
+
+	policy := s.retryPolicy(migrationLog.DataflowID)
+	switch {
+	case !policy.IsRetryable(errorClass):
+		migrationLog.Status = models.MigrationStatusFailed
+		migrationLog.NextRetryAt = nil
+		migrationLog.CompletedAt = &now
+	case migrationLog.Attempts >= policy.MaxAttempts:
+		migrationLog.Status = models.MigrationStatusDeadLetter
+		migrationLog.NextRetryAt = nil
+		migrationLog.CompletedAt = &now
+	default:
+		nextRetryAt := now.Add(policy.Backoff(migrationLog.Attempts))
+		migrationLog.Status = models.MigrationStatusRetrying
+		migrationLog.NextRetryAt = &nextRetryAt
+		migrationLog.CompletedAt = nil
+	}
+
+	return s.db.Save(migrationLog).Error
+}
+
+// retryPolicy returns the retry policy of a dataflow, or the default policy
+// if the dataflow cannot be loaded
+func (s *DataflowService) retryPolicy(dataflowID uint) models.RetryPolicy {
+	var dataflow models.Dataflow
+	if err := s.db.First(&dataflow, dataflowID).Error; err != nil {
+		return models.RetryPolicy{}.WithDefaults()
+	}
+
+	return dataflow.RetryPolicy.WithDefaults()
+}
+
+// toProductCreateRequest builds a product request from transformed data.
+// Field mappings write product fields at the top level, but a payload that
+// is already wrapped in a "product" key is accepted as well.
+func toProductCreateRequest(transformedJSON []byte) (*ProductCreateRequest, error) {
+	var wrapped map[string]json.RawMessage
+	if err := json.Unmarshal(transformedJSON, &wrapped); err != nil {
+		return nil, err
+	}
+
+	var productRequest ProductCreateRequest
+	if product, ok := wrapped["product"]; ok {
+		if err := json.Unmarshal(product, &productRequest.Product); err != nil {
+			return nil, err
+		}
+		return &productRequest, nil
+	}
+
+	if err := json.Unmarshal(transformedJSON, &productRequest.Product); err != nil {
+		return nil, err
+	}
+	return &productRequest, nil
+}
+
+// toCollectionRequest builds a collection from transformed data
+func toCollectionRequest(transformedJSON []byte) (*ShopifyCollection, error) {
+	var wrapped map[string]json.RawMessage
+	if err := json.Unmarshal(transformedJSON, &wrapped); err != nil {
+		return nil, err
+	}
+
+	var collection ShopifyCollection
+	if nested, ok := wrapped["collection"]; ok {
+		if err := json.Unmarshal(nested, &collection); err != nil {
+			return nil, err
+		}
+		return &collection, nil
+	}
+
+	if err := json.Unmarshal(transformedJSON, &collection); err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// toCustomerRequest builds a customer from transformed data
+func toCustomerRequest(transformedJSON []byte) (*ShopifyCustomer, error) {
+	var wrapped map[string]json.RawMessage
+	if err := json.Unmarshal(transformedJSON, &wrapped); err != nil {
+		return nil, err
+	}
+
+	var customer ShopifyCustomer
+	if nested, ok := wrapped["customer"]; ok {
+		if err := json.Unmarshal(nested, &customer); err != nil {
+			return nil, err
+		}
+		return &customer, nil
+	}
+
+	if err := json.Unmarshal(transformedJSON, &customer); err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// toOrderCreateRequest builds an order request from transformed data
+func toOrderCreateRequest(transformedJSON []byte) (*OrderCreateRequest, error) {
+	var wrapped map[string]json.RawMessage
+	if err := json.Unmarshal(transformedJSON, &wrapped); err != nil {
+		return nil, err
+	}
+
+	var orderRequest OrderCreateRequest
+	if order, ok := wrapped["order"]; ok {
+		if err := json.Unmarshal(order, &orderRequest.Order); err != nil {
+			return nil, err
+		}
+		return &orderRequest, nil
+	}
+
+	if err := json.Unmarshal(transformedJSON, &orderRequest.Order); err != nil {
+		return nil, err
+	}
+	return &orderRequest, nil
 }
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/jsonpath"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// entityCacheSize is the number of entities an entity lookup cache holds
+const entityCacheSize = 1000
+
+// maxEntityCacheRuns is the number of runs whose entity caches are kept at
+// once; the oldest is dropped when a new run starts
+const maxEntityCacheRuns = 16
+
+// entityNamePattern matches Shopware entity names, e.g. product-manufacturer
+var entityNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// shopwareIDPattern matches Shopware IDs, which are hex UUIDs without dashes
+var shopwareIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// entityAliases maps the short entity names used by older mappings to
+// Shopware entity names
+var entityAliases = map[string]string{
+	"manufacturer": "product-manufacturer",
+}
+
+// EntityLookupConfig is the transform config of an entity_lookup mapping
+type EntityLookupConfig struct {
+	EntityType string `json:"entity_type"` // Shopware entity name, e.g. product-manufacturer or category
+	Property   string `json:"property"`    // Path of the property to return, e.g. name or media.url
+	LanguageID string `json:"language_id"` // Language of translated properties; empty uses the system language
+}
+
+// parseEntityLookupConfig parses and validates an entity_lookup transform
+// config. Entity names may use underscores, which Shopware writes as dashes.
+func parseEntityLookupConfig(transformConfig string) (*EntityLookupConfig, error) {
+	var config EntityLookupConfig
+	if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
+		return nil, fmt.Errorf("invalid transform config: %w", err)
+	}
+
+	config.EntityType = strings.ReplaceAll(strings.ToLower(config.EntityType), "_", "-")
+	if alias, ok := entityAliases[config.EntityType]; ok {
+		config.EntityType = alias
+	}
+	if !entityNamePattern.MatchString(config.EntityType) {
+		return nil, fmt.Errorf("entity_lookup transform needs a Shopware entity name, got %q", config.EntityType)
+	}
+
+	if config.Property == "" {
+		return nil, fmt.Errorf("entity_lookup transform needs a property")
+	}
+	if _, err := jsonpath.Compile(config.Property); err != nil {
+		return nil, fmt.Errorf("invalid property: %w", err)
+	}
+
+	if config.LanguageID != "" && !shopwareIDPattern.MatchString(config.LanguageID) {
+		return nil, fmt.Errorf("invalid language_id %q", config.LanguageID)
+	}
+
+	return &config, nil
+}
+
+// lookupEntity loads the entity a value references from the dataflow's
+// source Shopware and returns one of its properties. An array of IDs returns
+// the property of every entity. Translated properties are preferred over the
+// raw ones, which are empty for entities not translated to the system
+// language.
+func (s *FieldMappingService) lookupEntity(value interface{}, transformConfig string, ctx *transformContext) (interface{}, error) {
+	config, err := parseEntityLookupConfig(transformConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if ids, ok := value.([]interface{}); ok {
+		results := make([]interface{}, len(ids))
+		for i, id := range ids {
+			result, err := s.lookupEntityProperty(id, config, ctx)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			results[i] = result
+		}
+		return results, nil
+	}
+
+	return s.lookupEntityProperty(value, config, ctx)
+}
+
+// lookupEntityProperty returns a property of the entity with the given ID
+func (s *FieldMappingService) lookupEntityProperty(value interface{}, config *EntityLookupConfig, ctx *transformContext) (interface{}, error) {
+	id, _ := value.(string)
+	if id == "" {
+		return nil, fmt.Errorf("empty %s ID", config.EntityType)
+	}
+	if !shopwareIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid %s ID %q", config.EntityType, id)
+	}
+
+	entity, err := s.loadEntity(config.EntityType, id, config.LanguageID, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if translated, ok := entity["translated"].(map[string]interface{}); ok {
+		if result, found, _ := jsonpath.Lookup(translated, config.Property); found && result != nil {
+			return result, nil
+		}
+	}
+
+	result, found, err := jsonpath.Lookup(entity, config.Property)
+	if err != nil {
+		return nil, err
+	}
+	if !found || result == nil {
+		return nil, fmt.Errorf("%s %s has no %s", config.EntityType, id, config.Property)
+	}
+
+	return result, nil
+}
+
+// loadEntity returns an entity from the context's cache, fetching it from
+// the source connector on a miss. Missing entities are cached too, so a
+// dangling reference is requested only once per run.
+func (s *FieldMappingService) loadEntity(entityType, id, languageID string, ctx *transformContext) (map[string]interface{}, error) {
+	key := entityType + "|" + languageID + "|" + id
+	if entity, ok := ctx.entities.get(key); ok {
+		if entity == nil {
+			return nil, fmt.Errorf("%w: %s %s", ErrShopwareEntityNotFound, entityType, id)
+		}
+		return entity, nil
+	}
+
+	connector, err := s.sourceConnector(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, err := s.shopwareService.GetTranslatedEntity(connector, entityType, id, languageID)
+	if errors.Is(err, ErrShopwareEntityNotFound) {
+		ctx.entities.put(key, nil)
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error looking up %s %s: %w", entityType, id, err)
+	}
+
+	ctx.entities.put(key, entity)
+	return entity, nil
+}
+
+// sourceConnector returns the source connector of the dataflow being
+// transformed, loading it on first use
+func (s *FieldMappingService) sourceConnector(ctx *transformContext) (*models.Connector, error) {
+	if ctx.connector != nil {
+		return ctx.connector, nil
+	}
+
+	var dataflow models.Dataflow
+	if err := s.db.Preload("SourceConnector").First(&dataflow, ctx.dataflowID).Error; err != nil {
+		return nil, fmt.Errorf("error loading source connector: %w", err)
+	}
+	if dataflow.SourceConnector.Type != models.ConnectorTypeShopware {
+		return nil, fmt.Errorf("Shopware lookups need a Shopware source connector")
+	}
+
+	ctx.connector = &dataflow.SourceConnector
+	return ctx.connector, nil
+}
+
+// entityCache is a least recently used cache of Shopware entities. A nil
+// entity records that the entity does not exist.
+type entityCache struct {
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// entityCacheEntry is an element of an entity cache's order list
+type entityCacheEntry struct {
+	key    string
+	entity map[string]interface{}
+}
+
+// newEntityCache creates an empty entity cache
+func newEntityCache() *entityCache {
+	return &entityCache{
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns a cached entity and marks it as recently used
+func (c *entityCache) get(key string) (map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return element.Value.(*entityCacheEntry).entity, true
+}
+
+// put caches an entity, evicting the least recently used one when full
+func (c *entityCache) put(key string, entity map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*entityCacheEntry).entity = entity
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entityCacheEntry{key: key, entity: entity})
+
+	if c.order.Len() > entityCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entityCacheEntry).key)
+	}
+}
+
+// entityCacheRegistry holds the entity caches of dataflow runs, whose pages
+// are transformed by separate jobs
+type entityCacheRegistry struct {
+	mu     sync.Mutex
+	runs   []uint
+	caches map[uint]*entityCache
+}
+
+// entityLookupCaches holds the entity caches of the runs in progress
+var entityLookupCaches = &entityCacheRegistry{
+	caches: make(map[uint]*entityCache),
+}
+
+// forRun returns the entity cache of a run, creating it on first use
+func (r *entityCacheRegistry) forRun(runID uint) *entityCache {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cache, ok := r.caches[runID]; ok {
+		return cache
+	}
+
+	if len(r.runs) >= maxEntityCacheRuns {
+		delete(r.caches, r.runs[0])
+		r.runs = r.runs[1:]
+	}
+
+	cache := newEntityCache()
+	r.caches[runID] = cache
+	r.runs = append(r.runs, runID)
+	return cache
+}
+
+// release drops the entity cache of a run
+func (r *entityCacheRegistry) release(runID uint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.caches, runID)
+	for i, id := range r.runs {
+		if id == runID {
+			r.runs = append(r.runs[:i], r.runs[i+1:]...)
+			break
+		}
+	}
+}
+
+// ReleaseEntityLookupCache drops the entity lookup cache of a finished run
+func ReleaseEntityLookupCache(runID uint) {
+	entityLookupCaches.release(runID)
+}
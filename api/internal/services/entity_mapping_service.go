@@ -0,0 +1,111 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EntityMappingService handles source-to-destination entity ID mappings
+type EntityMappingService struct {
+	db *gorm.DB
+}
+
+// NewEntityMappingService creates a new entity mapping service
+func NewEntityMappingService(db *gorm.DB) *EntityMappingService {
+	return &EntityMappingService{
+		db: db,
+	}
+}
+
+// FindMapping finds the mapping for a source entity. It returns nil when the
+// entity has not been synced yet.
+func (s *EntityMappingService) FindMapping(sourceConnectorID, destConnectorID uint, entityType models.EntityType, sourceID string) (*models.EntityMapping, error) {
+	var mapping models.EntityMapping
+
+	err := s.db.Where("source_connector_id = ? AND dest_connector_id = ? AND entity_type = ? AND source_id = ?",
+		sourceConnectorID, destConnectorID, entityType, sourceID).
+		First(&mapping).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// FindMappingByDestID finds the mapping for a destination entity. It returns
+// nil when no source entity is mapped to it.
+func (s *EntityMappingService) FindMappingByDestID(destConnectorID uint, entityType models.EntityType, destID string) (*models.EntityMapping, error) {
+	var mapping models.EntityMapping
+
+	err := s.db.Where("dest_connector_id = ? AND entity_type = ? AND dest_id = ?", destConnectorID, entityType, destID).
+		First(&mapping).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &mapping, nil
+}
+
+// ListMappingsByParent lists the mappings of the entities that belong to a
+// destination entity, e.g. the variants of a product
+func (s *EntityMappingService) ListMappingsByParent(sourceConnectorID, destConnectorID uint, entityType models.EntityType, parentID string) ([]models.EntityMapping, error) {
+	var mappings []models.EntityMapping
+
+	if err := s.db.Where("source_connector_id = ? AND dest_connector_id = ? AND entity_type = ? AND parent_id = ?",
+		sourceConnectorID, destConnectorID, entityType, parentID).
+		Find(&mappings).Error; err != nil {
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+// UpsertMapping creates the mapping for a source entity or updates the
+// destination ID and sync hash of an existing one
+func (s *EntityMappingService) UpsertMapping(mapping *models.EntityMapping) error {
+	now := time.Now()
+	mapping.LastSyncedAt = &now
+
+	return s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "source_connector_id"},
+			{Name: "dest_connector_id"},
+			{Name: "entity_type"},
+			{Name: "source_id"},
+		},
+		DoUpdates: clause.AssignmentColumns([]string{"dest_id", "parent_id", "last_synced_hash", "last_synced_at", "updated_at"}),
+	}).Create(mapping).Error
+}
+
+// ClearSyncedHash forgets the last payload written for a source entity, so
+// its next sync writes even an unchanged payload. It does nothing if the
+// entity has no mapping.
+func (s *EntityMappingService) ClearSyncedHash(sourceConnectorID, destConnectorID uint, entityType models.EntityType, sourceID string) error {
+	return s.db.Model(&models.EntityMapping{}).
+		Where("source_connector_id = ? AND dest_connector_id = ? AND entity_type = ? AND source_id = ?",
+			sourceConnectorID, destConnectorID, entityType, sourceID).
+		Update("last_synced_hash", "").Error
+}
+
+// DeleteMapping deletes a mapping
+func (s *EntityMappingService) DeleteMapping(mapping *models.EntityMapping) error {
+	return s.db.Delete(mapping).Error
+}
+
+// payloadHash returns a stable hash of a payload written to the destination
+func payloadHash(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// ErrShopwareAuth is returned when no Shopware access token could be obtained
+var ErrShopwareAuth = errors.New("shopware authentication failed")
+
+// APIError is an unexpected HTTP response from the Shopify or Shopware API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// ShopifyUserError is a userError returned by a Shopify mutation. The input
+// was rejected, so sending it again will fail the same way.
+type ShopifyUserError struct {
+	Field   []string `json:"field"`
+	Message string   `json:"message"`
+}
+
+func (e *ShopifyUserError) Error() string {
+	return e.Message
+}
+
+// ClassifiedError is an error with an explicit error class
+type ClassifiedError struct {
+	Class models.ErrorClass
+	Err   error
+}
+
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// classifyAs wraps an error with an explicit error class
+func classifyAs(class models.ErrorClass, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ClassifiedError{Class: class, Err: err}
+}
+
+// ClassifyError determines the error class of a migration failure. Only
+// failures known to be temporary get a class the default policy retries:
+// rate limits, server errors, network failures, authentication failures and
+// references to entities that are not synced yet. Other failures are
+// validation, transform or unknown errors, which are not retried by default.
+func ClassifyError(err error) models.ErrorClass {
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+
+	var userError *ShopifyUserError
+	if errors.As(err, &userError) {
+		return models.ErrorClassValidation
+	}
+
+	if errors.Is(err, ErrShopwareAuth) {
+		return models.ErrorClassAuth
+	}
+
+	if errors.Is(err, ErrProductNotSynced) || errors.Is(err, ErrShopifyProductNotFound) || errors.Is(err, ErrShopwareProductNotFound) {
+		return models.ErrorClassDependency
+	}
+
+	var apiError *APIError
+	if errors.As(err, &apiError) {
+		switch {
+		case apiError.StatusCode == http.StatusTooManyRequests:
+			return models.ErrorClassRateLimited
+		case apiError.StatusCode >= 500:
+			return models.ErrorClassServerError
+		case apiError.StatusCode == http.StatusUnauthorized || apiError.StatusCode == http.StatusForbidden:
+			return models.ErrorClassAuth
+		default:
+			return models.ErrorClassValidation
+		}
+	}
+
+	var netError net.Error
+	if errors.As(err, &netError) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) {
+		return models.ErrorClassNetwork
+	}
+
+	return models.ErrorClassUnknown
+}
+
+// shopifyUserError returns the first of a mutation's userErrors as an error
+func shopifyUserError(action string, userErrors []ShopifyUserError) error {
+	if len(userErrors) == 0 {
+		return nil
+	}
+	return fmt.Errorf("error %s: %w", action, &userErrors[0])
+}
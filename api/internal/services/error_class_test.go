@@ -0,0 +1,60 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"syscall"
+	"testing"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want models.ErrorClass
+	}{
+		{"rate limited", &APIError{StatusCode: 429}, models.ErrorClassRateLimited},
+		{"server error", fmt.Errorf("wrapped: %w", &APIError{StatusCode: 503}), models.ErrorClassServerError},
+		{"unauthorized", &APIError{StatusCode: 401}, models.ErrorClassAuth},
+		{"bad request", &APIError{StatusCode: 400}, models.ErrorClassValidation},
+		{"token request", fmt.Errorf("%w: expired", ErrShopwareAuth), models.ErrorClassAuth},
+		{"user error", shopifyUserError("creating product", []ShopifyUserError{{Message: "Title can't be blank"}}), models.ErrorClassValidation},
+		{"connection refused", &url.Error{Op: "Post", URL: "https://shop", Err: errors.New("connection refused")}, models.ErrorClassNetwork},
+		{"connection reset", fmt.Errorf("reading body: %w", syscall.ECONNRESET), models.ErrorClassNetwork},
+		{"truncated body", fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF), models.ErrorClassNetwork},
+		{"product not synced", fmt.Errorf("%w: line item", ErrProductNotSynced), models.ErrorClassDependency},
+		{"explicit class", classifyAs(models.ErrorClassTransform, errors.New("bad path")), models.ErrorClassTransform},
+		{"unclassified", errors.New("something else"), models.ErrorClassUnknown},
+	}
+
+	for _, test := range tests {
+		if got := ClassifyError(test.err); got != test.want {
+			t.Errorf("%s: ClassifyError() = %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestDefaultPolicyRetriesOnlyTemporaryFailures(t *testing.T) {
+	policy := models.RetryPolicy{}
+
+	for _, class := range []models.ErrorClass{
+		models.ErrorClassRateLimited, models.ErrorClassServerError, models.ErrorClassNetwork,
+		models.ErrorClassAuth, models.ErrorClassDependency,
+	} {
+		if !policy.IsRetryable(class) {
+			t.Errorf("%s is not retried by default", class)
+		}
+	}
+
+	for _, class := range []models.ErrorClass{
+		models.ErrorClassValidation, models.ErrorClassTransform, models.ErrorClassUnknown,
+	} {
+		if policy.IsRetryable(class) {
+			t.Errorf("%s is retried by default", class)
+		}
+	}
+}
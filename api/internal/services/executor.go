@@ -0,0 +1,109 @@
+package services
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/config"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// Executor runs a migration for a dataflow. StartExecution returns an
+// identifier for the execution which is stored on the migration log.
+type Executor interface {
+	StartExecution(dataflowID, migrationID uint, sourceData json.RawMessage) (string, error)
+}
+
+var (
+	_ Executor = (*StepFunctionsService)(nil)
+	_ Executor = (*LocalExecutor)(nil)
+	_ Executor = (*routingExecutor)(nil)
+)
+
+// NewExecutor creates the executor selected in the configuration
+func NewExecutor(cfg *config.Config, db *gorm.DB) Executor {
+	local := NewLocalExecutor(db, cfg.Executor.Workers, cfg.Executor.QueueSize)
+
+	switch cfg.Executor.Type {
+	case config.ExecutorTypeLocal:
+		return local
+	default:
+		return &routingExecutor{
+			db:            db,
+			stepFunctions: NewStepFunctionsService(cfg.AWS, db),
+			local:         local,
+		}
+	}
+}
+
+// routingExecutor starts Step Functions executions for the dataflows the
+// Step Functions workflow can run, and runs all others on the local executor
+type routingExecutor struct {
+	db            *gorm.DB
+	stepFunctions Executor
+	local         *LocalExecutor
+}
+
+// StartExecution starts an execution on the executor that can run the
+// migration's dataflow
+func (e *routingExecutor) StartExecution(dataflowID, migrationID uint, sourceData json.RawMessage) (string, error) {
+	var dataflow models.Dataflow
+	if err := e.db.First(&dataflow, dataflowID).Error; err != nil {
+		return "", err
+	}
+
+	if runsLocally(&dataflow) {
+		return e.local.StartExecution(dataflowID, migrationID, sourceData)
+	}
+	return e.stepFunctions.StartExecution(dataflowID, migrationID, sourceData)
+}
+
+// Stop stops the local executor
+func (e *routingExecutor) Stop() {
+	e.local.Stop()
+}
+
+// runsLocally reports whether a dataflow can only run in-process. The Step
+// Functions workflow only creates orders and products from field mappings.
+// Products are upserted through their entity mapping, which the workflow
+// never sees, so only orders run there; all other dataflows and Shopify to
+// Shopware dataflows need the in-process pipeline.
+func runsLocally(dataflow *models.Dataflow) bool {
+	if dataflow.IsReverse() {
+		return true
+	}
+	return dataflow.Type != models.DataflowTypeOrder
+}
+
+// dispatchMigration starts an execution for a pending migration log and
+// stores the execution ARN on it. The local executor only queues the
+// migration in memory, so its logs stay pending until a worker claims them;
+// the retry scheduler dispatches them again if a restart loses the queue.
+func dispatchMigration(db *gorm.DB, executor Executor, migrationLog *models.MigrationLog) error {
+	executionARN, err := executor.StartExecution(migrationLog.DataflowID, migrationLog.ID, json.RawMessage(migrationLog.SourcePayload))
+	if err != nil {
+		return err
+	}
+
+	// Also refreshes updated_at, which starts the pending lease
+	db.Model(&models.MigrationLog{}).
+		Where("id = ?", migrationLog.ID).
+		Update("execution_arn", executionARN)
+
+	if isLocalExecution(executionARN) {
+		return nil
+	}
+
+	db.Model(&models.MigrationLog{}).
+		Where("id = ? AND status = ?", migrationLog.ID, models.MigrationStatusPending).
+		Update("status", models.MigrationStatusInProgress)
+
+	return nil
+}
+
+// isLocalExecution reports whether an execution was queued on the local
+// executor
+func isLocalExecution(executionARN string) bool {
+	return strings.HasPrefix(executionARN, "local:")
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+func TestRunsLocally(t *testing.T) {
+	tests := []struct {
+		dataflow models.Dataflow
+		want     bool
+	}{
+		{models.Dataflow{Type: models.DataflowTypeOrder}, false},
+		{models.Dataflow{Type: models.DataflowTypeProduct}, true},
+		{models.Dataflow{Type: models.DataflowTypeInventory}, true},
+		{models.Dataflow{Type: models.DataflowTypeCategory}, true},
+		{models.Dataflow{Type: models.DataflowTypeCustomer}, true},
+		{models.Dataflow{Type: models.DataflowTypeOrder, Direction: models.DataflowDirectionShopifyToShopware}, true},
+	}
+
+	for _, test := range tests {
+		if got := runsLocally(&test.dataflow); got != test.want {
+			t.Errorf("runsLocally(%s, %q) = %v, want %v", test.dataflow.Type, test.dataflow.Direction, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/expr"
+)
+
+// expressionKey identifies a source expression of a dataflow
+type expressionKey struct {
+	dataflowID uint
+	source     string
+}
+
+// sourceExpressions holds the compiled source expressions of each dataflow,
+// so an expression is compiled once rather than for every migration. A
+// mapping change invalidates the programs of its dataflow.
+var sourceExpressions = newTTLCache[expressionKey, *expr.Program](0, 0)
+
+// sourceExpression returns the compiled program of an expression of a
+// dataflow, compiling it on first use
+func sourceExpression(dataflowID uint, source string) (*expr.Program, error) {
+	return sourceExpressions.load(expressionKey{dataflowID: dataflowID, source: source}, func() (*expr.Program, error) {
+		return expr.Compile(source)
+	})
+}
+
+// invalidateSourceExpressions drops the compiled programs of a dataflow
+func invalidateSourceExpressions(dataflowID uint) {
+	sourceExpressions.invalidateFunc(func(key expressionKey) bool {
+		return key.dataflowID == dataflowID
+	})
+}
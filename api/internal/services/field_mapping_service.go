@@ -4,36 +4,84 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/expr"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/jsonpath"
 	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
 	"gorm.io/gorm"
 )
 
 // FieldMappingService handles field mapping operations
 type FieldMappingService struct {
-	db *gorm.DB
+	db                 *gorm.DB
+	lookupTableService *LookupTableService
+	shopwareService    *ShopwareService
 }
 
 // NewFieldMappingService creates a new field mapping service
 func NewFieldMappingService(db *gorm.DB) *FieldMappingService {
 	return &FieldMappingService{
-		db: db,
+		db:                 db,
+		lookupTableService: NewLookupTableService(db),
+		shopwareService:    NewShopwareService(db),
 	}
 }
 
+// transformContext is the state transformations share while one source
+// document is transformed
+type transformContext struct {
+	dataflowID uint
+	source     map[string]interface{} // The whole source document
+	entities   *entityCache           // Entities loaded by entity lookups
+	connector  *models.Connector      // Source connector, loaded on first use
+}
+
 // MappingResult contains the transformed data and any errors
 type MappingResult struct {
 	Data  map[string]interface{}
 	Error error
+	Trace []MappingTrace
+}
+
+// MappingTrace records how a single field mapping was applied
+type MappingTrace struct {
+	MappingID        uint                      `json:"mapping_id"`
+	SourceField      string                    `json:"source_field"`
+	SourceExpression string                    `json:"source_expression,omitempty"`
+	DestField        string                    `json:"dest_field"`
+	TransformType    models.TransformationType `json:"transform_type"`
+	SourceValue      interface{}               `json:"source_value"`
+	UsedDefault      bool                      `json:"used_default,omitempty"`
+	Skipped          bool                      `json:"skipped,omitempty"` // Source field missing and no default value
+	Output           interface{}               `json:"output"`
+	Error            string                    `json:"error,omitempty"`
+	Steps            []StepTrace               `json:"steps,omitempty"` // One entry per transform step
+}
+
+// StepTrace records how a single transform step was applied
+type StepTrace struct {
+	Type    models.TransformationType `json:"type"`
+	Input   interface{}               `json:"input"`
+	Output  interface{}               `json:"output"`
+	Skipped bool                      `json:"skipped,omitempty"`
+	Error   string                    `json:"error,omitempty"`
 }
 
 // CreateFieldMapping creates a new field mapping
 func (s *FieldMappingService) CreateFieldMapping(fieldMapping *models.FieldMapping) error {
-	return s.db.Create(fieldMapping).Error
+	if err := validateFieldMapping(fieldMapping); err != nil {
+		return err
+	}
+
+	if err := s.db.Create(fieldMapping).Error; err != nil {
+		return err
+	}
+
+	invalidateSourceExpressions(fieldMapping.DataflowID)
+	return nil
 }
 
 // GetFieldMapping gets a field mapping by ID
@@ -69,9 +117,19 @@ func (s *FieldMappingService) UpdateFieldMapping(id uint, fieldMapping *models.F
 		return err
 	}
 
+	if err := validateFieldMapping(fieldMapping); err != nil {
+		return err
+	}
+
 	// Update the field mapping
 	fieldMapping.ID = existingFieldMapping.ID
-	return s.db.Save(fieldMapping).Error
+	if err := s.db.Save(fieldMapping).Error; err != nil {
+		return err
+	}
+
+	invalidateSourceExpressions(existingFieldMapping.DataflowID)
+	invalidateSourceExpressions(fieldMapping.DataflowID)
+	return nil
 }
 
 // DeleteFieldMapping deletes a field mapping
@@ -83,11 +141,34 @@ func (s *FieldMappingService) DeleteFieldMapping(id uint) error {
 	}
 
 	// Delete the field mapping
-	return s.db.Delete(existingFieldMapping).Error
+	if err := s.db.Delete(existingFieldMapping).Error; err != nil {
+		return err
+	}
+
+	invalidateSourceExpressions(existingFieldMapping.DataflowID)
+	return nil
 }
 
 // TransformData transforms data based on field mappings
 func (s *FieldMappingService) TransformData(dataflowID uint, sourceData []byte) (*MappingResult, error) {
+	return s.transformData(dataflowID, sourceData, false, newEntityCache())
+}
+
+// TransformDataForRun transforms data like TransformData for a document of
+// a dataflow run. Entity lookups share a cache across the whole run.
+func (s *FieldMappingService) TransformDataForRun(dataflowID, runID uint, sourceData []byte) (*MappingResult, error) {
+	return s.transformData(dataflowID, sourceData, false, entityLookupCaches.forRun(runID))
+}
+
+// PreviewTransform transforms data like TransformData but applies every
+// field mapping even after one fails, so the trace shows all errors at once
+func (s *FieldMappingService) PreviewTransform(dataflowID uint, sourceData []byte) (*MappingResult, error) {
+	return s.transformData(dataflowID, sourceData, true, newEntityCache())
+}
+
+// transformData applies the field mappings of a dataflow to the source data
+// and records a trace entry for every mapping
+func (s *FieldMappingService) transformData(dataflowID uint, sourceData []byte, continueOnError bool, entities *entityCache) (*MappingResult, error) {
 	// Get field mappings for the dataflow
 	fieldMappings, err := s.ListFieldMappings(dataflowID)
 	if err != nil {
@@ -101,44 +182,244 @@ func (s *FieldMappingService) TransformData(dataflowID uint, sourceData []byte)
 	}
 
 	// Create destination object
-	destObj := make(map[string]interface{})
+	result := &MappingResult{Data: make(map[string]interface{})}
+	ctx := &transformContext{dataflowID: dataflowID, source: sourceObj, entities: entities}
+
+	// fail records a mapping error; the first one becomes the result error
+	fail := func(trace *MappingTrace, err error) {
+		trace.Error = err.Error()
+		result.Trace = append(result.Trace, *trace)
+		if result.Error == nil {
+			result.Error = err
+		}
+	}
 
 	// Apply field mappings
 	for _, mapping := range fieldMappings {
-		// Get source value using dot notation (supports nested fields)
-		sourceValue, err := getNestedValue(sourceObj, mapping.SourceField)
+		trace := MappingTrace{
+			MappingID:        mapping.ID,
+			SourceField:      mapping.SourceField,
+			SourceExpression: mapping.SourceExpression,
+			DestField:        mapping.DestField,
+			TransformType:    mapping.TransformType,
+		}
+
+		sourceValue, found, err := s.sourceValue(dataflowID, mapping, sourceObj)
 		if err != nil {
+			fail(&trace, err)
+			if !continueOnError {
+				return result, nil
+			}
+			continue
+		}
+		if !found {
 			if mapping.IsRequired {
-				return &MappingResult{Error: fmt.Errorf("required field %s not found in source data", mapping.SourceField)}, nil
+				fail(&trace, fmt.Errorf("required field %s not found in source data", mappingSource(mapping)))
+				if !continueOnError {
+					return result, nil
+				}
+				continue
 			}
 			// Use default value if provided
 			if mapping.DefaultValue != "" {
 				sourceValue = mapping.DefaultValue
+				trace.UsedDefault = true
 			} else {
 				// Skip this field
+				trace.Skipped = true
+				result.Trace = append(result.Trace, trace)
 				continue
 			}
 		}
+		trace.SourceValue = sourceValue
 
 		// Apply transformation if needed
-		transformedValue, err := s.applyTransformation(sourceValue, mapping)
+		transformedValue, steps, err := s.applyTransformation(sourceValue, mapping, ctx)
+		trace.Steps = steps
+		if errors.Is(err, errSkipField) {
+			trace.Skipped = true
+			result.Trace = append(result.Trace, trace)
+			continue
+		}
 		if err != nil {
-			return &MappingResult{Error: fmt.Errorf("error transforming field %s: %w", mapping.SourceField, err)}, nil
+			fail(&trace, fmt.Errorf("error transforming field %s: %w", mappingSource(mapping), err))
+			if !continueOnError {
+				return result, nil
+			}
+			continue
 		}
+		trace.Output = transformedValue
 
 		// Set destination value (supports nested fields)
-		if err := setNestedValue(destObj, mapping.DestField, transformedValue); err != nil {
-			return &MappingResult{Error: fmt.Errorf("error setting field %s: %w", mapping.DestField, err)}, nil
+		if err := setNestedValue(result.Data, mapping.DestField, transformedValue); err != nil {
+			fail(&trace, fmt.Errorf("error setting field %s: %w", mapping.DestField, err))
+			if !continueOnError {
+				return result, nil
+			}
+			continue
 		}
+
+		result.Trace = append(result.Trace, trace)
 	}
 
-	return &MappingResult{Data: destObj}, nil
+	return result, nil
 }
 
-// applyTransformation applies a transformation to a value
-func (s *FieldMappingService) applyTransformation(value interface{}, mapping models.FieldMapping) (interface{}, error) {
-	switch mapping.TransformType {
-	case models.TransformationTypeNone:
+// sourceValue reads the source value of a mapping. A mapping with a source
+// expression evaluates it against the whole source document; otherwise the
+// source field is read using dot notation (supports nested fields). A
+// missing field, or an expression that yields null, is reported as not found.
+func (s *FieldMappingService) sourceValue(dataflowID uint, mapping models.FieldMapping, sourceObj map[string]interface{}) (interface{}, bool, error) {
+	if mapping.SourceExpression == "" {
+		value, err := getNestedValue(sourceObj, mapping.SourceField)
+		return value, err == nil, nil
+	}
+
+	program, err := sourceExpression(dataflowID, mapping.SourceExpression)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, err := program.Run(sourceObj)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, value != nil, nil
+}
+
+// mappingSource describes where a mapping reads its value, for errors
+func mappingSource(mapping models.FieldMapping) string {
+	if mapping.SourceExpression != "" {
+		return mapping.SourceExpression
+	}
+	return mapping.SourceField
+}
+
+// validateFieldMapping checks that a field mapping's source expression and
+// paths compile and that its transform steps are valid
+func validateFieldMapping(fieldMapping *models.FieldMapping) error {
+	if fieldMapping.SourceExpression != "" {
+		if _, err := expr.Compile(fieldMapping.SourceExpression); err != nil {
+			return fmt.Errorf("%w: %v", models.ErrInvalidExpression, err)
+		}
+	} else if fieldMapping.SourceField != "" {
+		if _, err := jsonpath.Compile(fieldMapping.SourceField); err != nil {
+			return fmt.Errorf("%w: %v", models.ErrInvalidPath, err)
+		}
+	}
+
+	if fieldMapping.DestField != "" {
+		if _, err := jsonpath.Compile(fieldMapping.DestField); err != nil {
+			return fmt.Errorf("%w: %v", models.ErrInvalidPath, err)
+		}
+	}
+
+	if err := parseTransformConfig(fieldMapping.TransformType, fieldMapping.TransformConfig); err != nil {
+		return fmt.Errorf("%w: %v", models.ErrInvalidTransformConfig, err)
+	}
+
+	return validateTransformSteps(fieldMapping.TransformSteps)
+}
+
+// parseTransformConfig checks the config of the transformation types that
+// parse it up front. Other types are checked when they run.
+func parseTransformConfig(transformType models.TransformationType, config string) error {
+	var err error
+	switch transformType {
+	case models.TransformationTypeConditional:
+		_, err = parseConditionalConfig(config)
+	case models.TransformationTypeMath:
+		_, err = parseMathConfig(config)
+	case models.TransformationTypeTemplate:
+		_, err = parseTemplateConfig(config)
+	case models.TransformationTypeLookupTable:
+		_, err = parseLookupTableConfig(config)
+	case models.TransformationTypeEntityLookup:
+		_, err = parseEntityLookupConfig(config)
+	case models.TransformationTypeReference:
+		_, err = parseReferenceConfig(config)
+	}
+	return err
+}
+
+// validateTransformSteps checks that every step of a transform pipeline has a
+// known type and a config that type can use
+func validateTransformSteps(steps []models.TransformStep) error {
+	for i, step := range steps {
+		config := step.ConfigString()
+
+		var err error
+		switch step.Type {
+		case models.TransformationTypeNone:
+		case models.TransformationTypeConditional,
+			models.TransformationTypeMath,
+			models.TransformationTypeTemplate,
+			models.TransformationTypeLookupTable,
+			models.TransformationTypeEntityLookup,
+			models.TransformationTypeReference:
+			err = parseTransformConfig(step.Type, config)
+		case models.TransformationTypeFormat,
+			models.TransformationTypeConvert,
+			models.TransformationTypeMap,
+			models.TransformationTypeGraphQLID,
+			models.TransformationTypeArrayMap,
+			models.TransformationTypeJsonPath,
+			models.TransformationTypeMediaMap,
+			models.TransformationTypeMetafield:
+			if !json.Valid([]byte(config)) {
+				err = fmt.Errorf("invalid transform config")
+			}
+		default:
+			err = fmt.Errorf("unsupported transformation type: %q", step.Type)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: step %d (%s): %v", models.ErrInvalidTransformStep, i+1, step.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// applyTransformation applies a mapping's transformation to a value. A
+// mapping with transform steps runs them in order, feeding each step's output
+// into the next; otherwise its single TransformType is applied.
+func (s *FieldMappingService) applyTransformation(value interface{}, mapping models.FieldMapping, ctx *transformContext) (interface{}, []StepTrace, error) {
+	if len(mapping.TransformSteps) == 0 {
+		output, err := s.applyTransform(value, mapping.TransformType, mapping.TransformConfig, ctx)
+		return output, nil, err
+	}
+
+	var steps []StepTrace
+	for i, step := range mapping.TransformSteps {
+		stepTrace := StepTrace{Type: step.Type, Input: value}
+
+		output, err := s.applyTransform(value, step.Type, step.ConfigString(), ctx)
+		if errors.Is(err, errSkipField) {
+			stepTrace.Skipped = true
+			steps = append(steps, stepTrace)
+			return nil, steps, err
+		}
+		if err != nil {
+			stepTrace.Error = err.Error()
+			steps = append(steps, stepTrace)
+			return nil, steps, fmt.Errorf("step %d (%s): %w", i+1, step.Type, err)
+		}
+
+		stepTrace.Output = output
+		steps = append(steps, stepTrace)
+		value = output
+	}
+
+	return value, steps, nil
+}
+
+// applyTransform applies a single transformation to a value. The context
+// gives transformations the source document and the entity cache.
+func (s *FieldMappingService) applyTransform(value interface{}, transformType models.TransformationType, transformConfig string, ctx *transformContext) (interface{}, error) {
+	switch transformType {
+	case models.TransformationTypeNone, "":
 		return value, nil
 
 	case models.TransformationTypeFormat:
@@ -148,7 +429,7 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 			DestFormat   string `json:"dest_format"`
 		}
 
-		if err := json.Unmarshal([]byte(mapping.TransformConfig), &config); err != nil {
+		if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
 			return nil, fmt.Errorf("invalid transform config: %w", err)
 		}
 
@@ -167,7 +448,7 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 			Type string `json:"type"`
 		}
 
-		if err := json.Unmarshal([]byte(mapping.TransformConfig), &config); err != nil {
+		if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
 			return nil, fmt.Errorf("invalid transform config: %w", err)
 		}
 
@@ -175,6 +456,9 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 		case "string":
 			return fmt.Sprintf("%v", value), nil
 		case "int":
+			if f, ok := value.(float64); ok {
+				return int(f), nil
+			}
 			if str, ok := value.(string); ok {
 				i, err := strconv.Atoi(str)
 				if err != nil {
@@ -184,6 +468,9 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 			}
 			return nil, fmt.Errorf("value is not a string")
 		case "float":
+			if f, ok := value.(float64); ok {
+				return f, nil
+			}
 			if str, ok := value.(string); ok {
 				f, err := strconv.ParseFloat(str, 64)
 				if err != nil {
@@ -209,7 +496,7 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 		// For value mappings
 		var config map[string]interface{}
 
-		if err := json.Unmarshal([]byte(mapping.TransformConfig), &config); err != nil {
+		if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
 			return nil, fmt.Errorf("invalid transform config: %w", err)
 		}
 
@@ -226,19 +513,8 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 		return nil, fmt.Errorf("no mapping found for value: %v", value)
 
 	case models.TransformationTypeTemplate:
-		// For template-based transformations
-		var config struct {
-			Template string `json:"template"`
-		}
-
-		if err := json.Unmarshal([]byte(mapping.TransformConfig), &config); err != nil {
-			return nil, fmt.Errorf("invalid transform config: %w", err)
-		}
-
-		// Simple template replacement
-		result := config.Template
-		result = strings.ReplaceAll(result, "{{value}}", fmt.Sprintf("%v", value))
-		return result, nil
+		// Render a template over the source document
+		return applyTemplate(value, transformConfig, ctx.source)
 
 	case models.TransformationTypeGraphQLID:
 		var config struct {
@@ -246,7 +522,7 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 			Direction    string `json:"direction"` // "to_global" or "from_global"
 		}
 
-		if err := json.Unmarshal([]byte(mapping.TransformConfig), &config); err != nil {
+		if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
 			return nil, fmt.Errorf("invalid transform config: %w", err)
 		}
 
@@ -268,7 +544,7 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 			Mapping    map[string]string `json:"mapping"`
 		}
 
-		if err := json.Unmarshal([]byte(mapping.TransformConfig), &config); err != nil {
+		if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
 			return nil, fmt.Errorf("invalid transform config: %w", err)
 		}
 
@@ -281,7 +557,7 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 			Path string `json:"path"`
 		}
 
-		if err := json.Unmarshal([]byte(mapping.TransformConfig), &config); err != nil {
+		if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
 			return nil, fmt.Errorf("invalid transform config: %w", err)
 		}
 
@@ -294,7 +570,7 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 			BaseURL string `json:"base_url"`
 		}
 
-		if err := json.Unmarshal([]byte(mapping.TransformConfig), &config); err != nil {
+		if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
 			return nil, fmt.Errorf("invalid transform config: %w", err)
 		}
 
@@ -308,31 +584,38 @@ func (s *FieldMappingService) applyTransformation(value interface{}, mapping mod
 			Type      string `json:"type"`
 		}
 
-		if err := json.Unmarshal([]byte(mapping.TransformConfig), &config); err != nil {
+		if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
 			return nil, fmt.Errorf("invalid transform config: %w", err)
 		}
 
 		return createMetafield(value, config)
 
 	case models.TransformationTypeEntityLookup:
-		// Look up entity by ID and return a property
-		var config struct {
-			EntityType string `json:"entity_type"`
-			Property   string `json:"property"`
-		}
+		// Look up a Shopware entity by ID and return a property
+		return s.lookupEntity(value, transformConfig, ctx)
 
-		if err := json.Unmarshal([]byte(mapping.TransformConfig), &config); err != nil {
-			return nil, fmt.Errorf("invalid transform config: %w", err)
-		}
+	case models.TransformationTypeConditional:
+		// Choose the output with rules on the value or other source fields
+		return applyConditional(value, transformConfig, ctx.source)
+
+	case models.TransformationTypeMath:
+		// Arithmetic on a numeric value
+		return applyMath(value, transformConfig)
+
+	case models.TransformationTypeLookupTable:
+		// Map the value through a shared lookup table
+		return s.applyLookupTable(value, transformConfig)
 
-		return s.lookupEntity(value, config)
+	case models.TransformationTypeReference:
+		// Resolve a Shopware reference ID to its ISO code or name
+		return s.resolveReference(value, transformConfig, ctx)
 
 	default:
-		return nil, fmt.Errorf("unsupported transformation type: %s", mapping.TransformType)
+		return nil, fmt.Errorf("unsupported transformation type: %s", transformType)
 	}
 
 	//default:
-	//	return nil, fmt.Errorf("unsupported transformation type: %s", mapping.TransformType)
+	//	return nil, fmt.Errorf("unsupported transformation type: %s", transformType)
 	//}
 }
 
@@ -360,7 +643,6 @@ func transformMedia(value interface{}, config struct {
 		var (
 			url string
 			alt string
-			_   string
 		)
 
 		if urlVal, found := mediaMap["url"]; found && urlVal != nil {
@@ -371,11 +653,9 @@ func transformMedia(value interface{}, config struct {
 			alt = fmt.Sprintf("%s", altVal)
 		}
 
-		if titleVal, found := mediaMap["title"]; found && titleVal != nil {
-			_ = fmt.Sprintf("%s", titleVal)
-		} else {
-			// Use alt text as fallback for title
-			_ = alt
+		// Shopify media only have alt text, so the title stands in for it
+		if titleVal, found := mediaMap["title"]; found && titleVal != nil && alt == "" {
+			alt = fmt.Sprintf("%s", titleVal)
 		}
 
 		// Construct the full URL
@@ -396,6 +676,13 @@ func transformMedia(value interface{}, config struct {
 			"position":         i + 1,
 		}
 
+		// Keep what the media sync needs to track the Shopware file
+		for _, field := range []string{"sourceId", "checksum", "private", "mimeType", "fileName"} {
+			if fieldVal, found := mediaMap[field]; found && fieldVal != nil {
+				destItem[field] = fieldVal
+			}
+		}
+
 		// Add the media item to the destination array
 		destMedia = append(destMedia, destItem)
 	}
@@ -403,63 +690,6 @@ func transformMedia(value interface{}, config struct {
 	return destMedia, nil
 }
 
-// lookupEntity looks up an entity by ID and returns a property
-func (s *FieldMappingService) lookupEntity(value interface{}, config struct {
-	EntityType string `json:"entity_type"`
-	Property   string `json:"property"`
-}) (interface{}, error) {
-	// Convert value to string ID
-	strID := fmt.Sprintf("%v", value)
-	if strID == "" {
-		return nil, fmt.Errorf("empty entity ID")
-	}
-
-	// For Shopware, entity types include: product, category, manufacturer, etc.
-	switch config.EntityType {
-	case "manufacturer":
-		// Look up manufacturer
-		var manufacturer map[string]interface{}
-		err := s.db.Table("manufacturer").
-			Where("id = ?", strID).
-			First(&manufacturer).Error
-
-		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return strID, nil // Return original ID if not found
-			}
-			return nil, fmt.Errorf("error looking up manufacturer: %w", err)
-		}
-
-		// Extract the requested property
-		if prop, ok := manufacturer[config.Property]; ok {
-			return prop, nil
-		}
-		return strID, nil // Return original ID if property not found
-
-	case "category":
-		// Look up category
-		var category map[string]interface{}
-		err := s.db.Table("category").
-			Where("id = ?", strID).
-			First(&category).Error
-
-		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return strID, nil
-			}
-			return nil, fmt.Errorf("error looking up category: %w", err)
-		}
-
-		if prop, ok := category[config.Property]; ok {
-			return prop, nil
-		}
-		return strID, nil
-
-	default:
-		return strID, fmt.Errorf("unsupported entity type: %s", config.EntityType)
-	}
-}
-
 // transformArray transforms an array based on the mapping configuration
 func transformArray(value interface{}, config struct {
 	SourcePath string            `json:"source_path"`
@@ -625,207 +855,36 @@ func extractJsonPath(value interface{}, path string) (interface{}, error) {
 		return value, nil
 	}
 
-	// Parse the JSON path
-	components := strings.Split(path, ".")
-	current := value
-
-	for _, component := range components {
-		// Handle array indexing in the path (e.g., items[0])
-		var index int = -1
-		var key string
-
-		if match := arrayIndexRegex.FindStringSubmatch(component); len(match) > 0 {
-			key = match[1]
-			indexStr := match[2]
-			idx, err := strconv.Atoi(indexStr)
-			if err != nil {
-				return nil, fmt.Errorf("invalid array index in path: %s", component)
-			}
-			index = idx
-		} else {
-			key = component
-		}
-
-		// Navigate the object
-		switch v := current.(type) {
-		case map[string]interface{}:
-			if val, exists := v[key]; exists {
-				if index >= 0 {
-					// Try to access array element
-					if arr, ok := val.([]interface{}); ok {
-						if index < len(arr) {
-							current = arr[index]
-						} else {
-							return nil, fmt.Errorf("array index out of bounds: %d", index)
-						}
-					} else {
-						return nil, fmt.Errorf("value at key %s is not an array", key)
-					}
-				} else {
-					current = val
-				}
-			} else {
-				return nil, fmt.Errorf("key %s not found in object", key)
-			}
-		case []interface{}:
-			return nil, fmt.Errorf("cannot access property %s of an array", key)
-		default:
-			return nil, fmt.Errorf("cannot access property %s of a non-object", key)
-		}
+	result, found, err := jsonpath.Lookup(value, path)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("path %s not found", path)
 	}
 
-	return current, nil
+	return result, nil
 }
 
-// Add a regex for parsing array indices in JSON paths
-var arrayIndexRegex = regexp.MustCompile(`^([^\[]+)\[(\d+)\]$`)
-
-// getNestedValue gets a value from a nested object using dot notation
+// getNestedValue gets a value from a nested object using a JSON path. Plain
+// dot notation with array indexes (items[0].name) is a valid JSON path.
 func getNestedValue(obj map[string]interface{}, path string) (interface{}, error) {
-	parts := strings.Split(path, ".")
-	var current interface{} = obj
-
-	for _, part := range parts {
-		// Handle array access, e.g. "items[0]"
-		if strings.Contains(part, "[") && strings.Contains(part, "]") {
-			key := part[:strings.Index(part, "[")]
-			indexStr := part[strings.Index(part, "[")+1 : strings.Index(part, "]")]
-			index, err := strconv.Atoi(indexStr)
-			if err != nil {
-				return nil, fmt.Errorf("invalid array index: %s", indexStr)
-			}
-
-			if currentMap, ok := current.(map[string]interface{}); ok {
-				if arr, ok := currentMap[key].([]interface{}); ok {
-					if index < 0 || index >= len(arr) {
-						return nil, fmt.Errorf("array index out of bounds: %d", index)
-					}
-					current = arr[index]
-				} else {
-					return nil, fmt.Errorf("field %s is not an array", key)
-				}
-			} else {
-				return nil, fmt.Errorf("cannot access %s: parent is not an object", key)
-			}
-		} else {
-			// Regular object access
-			if currentMap, ok := current.(map[string]interface{}); ok {
-				var exists bool
-				current, exists = currentMap[part]
-				if !exists {
-					return nil, fmt.Errorf("field %s not found", part)
-				}
-			} else {
-				return nil, fmt.Errorf("cannot access %s: parent is not an object", part)
-			}
-		}
+	value, found, err := jsonpath.Lookup(obj, path)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("field %s not found", path)
 	}
 
-	return current, nil
+	return value, nil
 }
 
-// setNestedValue sets a value in a nested object using dot notation
+// setNestedValue sets a value in a nested object using a JSON path, creating
+// missing objects and arrays. A wildcard spreads an array value over the
+// elements (variants[*].sku) or writes any other value to each of them.
 func setNestedValue(obj map[string]interface{}, path string, value interface{}) error {
-	parts := strings.Split(path, ".")
-
-	// For all but the last part, ensure the path exists
-	current := obj
-	for i := 0; i < len(parts)-1; i++ {
-		part := parts[i]
-
-		// Handle array access, e.g. "items[0]"
-		if strings.Contains(part, "[") && strings.Contains(part, "]") {
-			key := part[:strings.Index(part, "[")]
-			indexStr := part[strings.Index(part, "[")+1 : strings.Index(part, "]")]
-			index, err := strconv.Atoi(indexStr)
-			if err != nil {
-				return fmt.Errorf("invalid array index: %s", indexStr)
-			}
-
-			// Ensure the key exists and is an array
-			if _, ok := current[key]; !ok {
-				current[key] = make([]interface{}, index+1)
-			}
-
-			arr, ok := current[key].([]interface{})
-			if !ok {
-				return fmt.Errorf("field %s is not an array", key)
-			}
-
-			// Ensure the array is big enough
-			if index >= len(arr) {
-				newArr := make([]interface{}, index+1)
-				copy(newArr, arr)
-				arr = newArr
-				current[key] = arr
-			}
-
-			// If this is not the last part, ensure the array element is an object
-			if i < len(parts)-2 {
-				if arr[index] == nil {
-					arr[index] = make(map[string]interface{})
-				}
-
-				if nextMap, ok := arr[index].(map[string]interface{}); ok {
-					current = nextMap
-				} else {
-					return fmt.Errorf("array element at index %d is not an object", index)
-				}
-			}
-		} else {
-			// Regular object access
-			if _, ok := current[part]; !ok {
-				current[part] = make(map[string]interface{})
-			}
-
-			nextMap, ok := current[part].(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("field %s is not an object", part)
-			}
-
-			current = nextMap
-		}
-	}
-
-	// Set the value at the last part
-	lastPart := parts[len(parts)-1]
-
-	// Handle array access for the last part
-	if strings.Contains(lastPart, "[") && strings.Contains(lastPart, "]") {
-		key := lastPart[:strings.Index(lastPart, "[")]
-		indexStr := lastPart[strings.Index(lastPart, "[")+1 : strings.Index(lastPart, "]")]
-		index, err := strconv.Atoi(indexStr)
-		if err != nil {
-			return fmt.Errorf("invalid array index: %s", indexStr)
-		}
-
-		// Ensure the key exists and is an array
-		if _, ok := current[key]; !ok {
-			current[key] = make([]interface{}, index+1)
-		}
-
-		arr, ok := current[key].([]interface{})
-		if !ok {
-			return fmt.Errorf("field %s is not an array", key)
-		}
-
-		// Ensure the array is big enough
-		if index >= len(arr) {
-			newArr := make([]interface{}, index+1)
-			copy(newArr, arr)
-			arr = newArr
-			current[key] = arr
-		}
-
-		// Set the value at the specified index
-		arr[index] = value
-	} else {
-		// Regular object access
-		current[lastPart] = value
-	}
-
-	return nil
-
+	return jsonpath.Set(obj, path, value)
 }
 
 // convertToGraphQLGlobalID converts a regular ID to a Shopify GraphQL Global ID
@@ -870,26 +929,17 @@ func (s *FieldMappingService) GetDefaultProductMappings(dataflowID uint) []model
 		},
 		{
 			DataflowID:    dataflowID,
-			SourceField:   "productNumber",
-			DestField:     "variants[0].sku",
-			IsRequired:    false,
+			SourceField:   "variants",
+			DestField:     "variants",
+			IsRequired:    true,
 			TransformType: models.TransformationTypeNone,
 		},
 		{
-			DataflowID:      dataflowID,
-			SourceField:     "stock",
-			DestField:       "variants[0].inventoryQuantity",
-			IsRequired:      false,
-			TransformType:   models.TransformationTypeConvert,
-			TransformConfig: `{"type": "int"}`,
-		},
-		{
-			DataflowID:      dataflowID,
-			SourceField:     "price[0].gross",
-			DestField:       "variants[0].price",
-			IsRequired:      true,
-			TransformType:   models.TransformationTypeConvert,
-			TransformConfig: `{"type": "string"}`,
+			DataflowID:    dataflowID,
+			SourceField:   "variantOptions",
+			DestField:     "options",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
 		},
 		{
 			DataflowID:      dataflowID,
@@ -909,12 +959,11 @@ func (s *FieldMappingService) GetDefaultProductMappings(dataflowID uint) []model
 			TransformConfig: `{"entity_type": "manufacturer", "property": "name"}`,
 		},
 		{
-			DataflowID:      dataflowID,
-			SourceField:     "categoryIds",
-			DestField:       "collections",
-			IsRequired:      false,
-			TransformType:   models.TransformationTypeArrayMap,
-			TransformConfig: `{"source_path": "id", "dest_path": "id"}`,
+			DataflowID:    dataflowID,
+			SourceField:   "categoryIds",
+			DestField:     "collections",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
 		},
 		{
 			DataflowID:      dataflowID,
@@ -938,14 +987,6 @@ func (s *FieldMappingService) GetDefaultProductMappings(dataflowID uint) []model
 			IsRequired:    false,
 			TransformType: models.TransformationTypeNone,
 		},
-		{
-			DataflowID:      dataflowID,
-			SourceField:     "weight",
-			DestField:       "variants[0].weight",
-			IsRequired:      false,
-			TransformType:   models.TransformationTypeConvert,
-			TransformConfig: `{"type": "float"}`,
-		},
 		{
 			DataflowID:      dataflowID,
 			SourceField:     "width",
@@ -972,3 +1013,189 @@ func (s *FieldMappingService) GetDefaultProductMappings(dataflowID uint) []model
 		},
 	}
 }
+
+// GetDefaultCategoryMappings returns a set of default field mappings for category migration
+func (s *FieldMappingService) GetDefaultCategoryMappings(dataflowID uint) []models.FieldMapping {
+	return []models.FieldMapping{
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "collectionTitle",
+			DestField:     "title",
+			IsRequired:    true,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "description",
+			DestField:     "descriptionHtml",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "media.url",
+			DestField:     "image.src",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "media.alt",
+			DestField:     "image.altText",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "metaTitle",
+			DestField:     "seo.title",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "metaDescription",
+			DestField:     "seo.description",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+	}
+}
+
+// GetDefaultOrderMappings returns a set of default field mappings for order migration
+func (s *FieldMappingService) GetDefaultOrderMappings(dataflowID uint) []models.FieldMapping {
+	return []models.FieldMapping{
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "customerEmail",
+			DestField:     "email",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "currencyCode",
+			DestField:     "currency",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "items",
+			DestField:     "lineItems",
+			IsRequired:    true,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "billing",
+			DestField:     "billingAddress",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "shipping",
+			DestField:     "shippingAddress",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "shippingLines",
+			DestField:     "shippingLines",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "discountCode",
+			DestField:     "discountCode",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "financialStatus",
+			DestField:     "financialStatus",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "processedAt",
+			DestField:     "processedAt",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "customerComment",
+			DestField:     "note",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+	}
+}
+
+// GetDefaultCustomerMappings returns a set of default field mappings for customer migration
+func (s *FieldMappingService) GetDefaultCustomerMappings(dataflowID uint) []models.FieldMapping {
+	return []models.FieldMapping{
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "firstName",
+			DestField:     "firstName",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "lastName",
+			DestField:     "lastName",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "email",
+			DestField:     "email",
+			IsRequired:    true,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "phone",
+			DestField:     "phone",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "addressList",
+			DestField:     "addresses",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "groupTags",
+			DestField:     "tags",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "emailMarketing",
+			DestField:     "emailMarketingConsent",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+		{
+			DataflowID:    dataflowID,
+			SourceField:   "smsMarketing",
+			DestField:     "smsMarketingConsent",
+			IsRequired:    false,
+			TransformType: models.TransformationTypeNone,
+		},
+	}
+}
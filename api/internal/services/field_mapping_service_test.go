@@ -0,0 +1,127 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// step builds a transform step with a JSON config
+func step(transformType models.TransformationType, config string) models.TransformStep {
+	return models.TransformStep{Type: transformType, Config: json.RawMessage(config)}
+}
+
+func TestApplyTransformationSteps(t *testing.T) {
+	service := &FieldMappingService{}
+	ctx := &transformContext{source: map[string]interface{}{"stock": 0.0}}
+
+	mapping := models.FieldMapping{
+		TransformSteps: []models.TransformStep{
+			step(models.TransformationTypeMath, `{"operation": "multiply", "operand": 1.19}`),
+			step(models.TransformationTypeMath, `{"operation": "round", "precision": 2}`),
+			step(models.TransformationTypeConvert, `"{\"type\": \"string\"}"`),
+		},
+	}
+
+	output, trace, err := service.applyTransformation(10.0, mapping, ctx)
+	if err != nil {
+		t.Fatalf("applyTransformation: %v", err)
+	}
+	if output != "11.9" {
+		t.Errorf("output = %v, want 11.9", output)
+	}
+
+	// Each step receives the previous step's output
+	if len(trace) != 3 {
+		t.Fatalf("got %d step traces, want 3", len(trace))
+	}
+	if trace[1].Input != trace[0].Output || trace[2].Input != trace[1].Output {
+		t.Errorf("trace = %+v, want each input to be the previous output", trace)
+	}
+}
+
+func TestApplyTransformationStepSkip(t *testing.T) {
+	service := &FieldMappingService{}
+	ctx := &transformContext{source: map[string]interface{}{"stock": 0.0}}
+
+	mapping := models.FieldMapping{
+		TransformSteps: []models.TransformStep{
+			step(models.TransformationTypeConditional, `{"rules": [{"conditions": [{"field": "stock", "operator": "equals", "value": 0}], "skip": true}]}`),
+			step(models.TransformationTypeMath, `{"operation": "add", "operand": 1}`),
+		},
+	}
+
+	_, trace, err := service.applyTransformation(5.0, mapping, ctx)
+	if !errors.Is(err, errSkipField) {
+		t.Fatalf("applyTransformation error = %v, want %v", err, errSkipField)
+	}
+	if len(trace) != 1 || !trace[0].Skipped {
+		t.Errorf("trace = %+v, want the skipping step only", trace)
+	}
+}
+
+func TestApplyTransformationStepError(t *testing.T) {
+	service := &FieldMappingService{}
+	ctx := &transformContext{}
+
+	mapping := models.FieldMapping{
+		TransformSteps: []models.TransformStep{
+			step(models.TransformationTypeMap, `{"a": "b"}`),
+			step(models.TransformationTypeMath, `{"operation": "add", "operand": 1}`),
+		},
+	}
+
+	_, trace, err := service.applyTransformation("a", mapping, ctx)
+	if err == nil || !strings.Contains(err.Error(), "step 2 (math)") {
+		t.Fatalf("applyTransformation error = %v, want an error from step 2", err)
+	}
+	if len(trace) != 2 || trace[1].Error == "" {
+		t.Errorf("trace = %+v, want the failed step with its error", trace)
+	}
+}
+
+func TestApplyTransformationWithoutSteps(t *testing.T) {
+	service := &FieldMappingService{}
+	mapping := models.FieldMapping{
+		TransformType:   models.TransformationTypeMap,
+		TransformConfig: `{"1": "one", "_default": "many"}`,
+	}
+
+	output, trace, err := service.applyTransformation(2.0, mapping, &transformContext{})
+	if err != nil || output != "many" || trace != nil {
+		t.Errorf("applyTransformation = %v, %v, %v, want many without a trace", output, trace, err)
+	}
+}
+
+func TestValidateTransformSteps(t *testing.T) {
+	tests := []struct {
+		steps []models.TransformStep
+		valid bool
+	}{
+		{nil, true},
+		{[]models.TransformStep{step(models.TransformationTypeNone, ``)}, true},
+		{[]models.TransformStep{step(models.TransformationTypeMath, `{"operation": "add", "operand": 1}`)}, true},
+		{[]models.TransformStep{step(models.TransformationTypeMath, `"{\"operation\": \"round\"}"`)}, true},
+		{[]models.TransformStep{step(models.TransformationTypeMap, `{"a": "b"}`)}, true},
+		{[]models.TransformStep{step(models.TransformationTypeMath, `{"operation": "divide", "operand": 0}`)}, false},
+		{[]models.TransformStep{step(models.TransformationTypeMap, `{"a": `)}, false},
+		{[]models.TransformStep{step("unknown", `{}`)}, false},
+		{[]models.TransformStep{
+			step(models.TransformationTypeMath, `{"operation": "add", "operand": 1}`),
+			step(models.TransformationTypeConditional, `{"rules": []}`),
+		}, false},
+	}
+
+	for _, test := range tests {
+		err := validateTransformSteps(test.steps)
+		if test.valid && err != nil {
+			t.Errorf("validateTransformSteps(%+v): %v", test.steps, err)
+		}
+		if !test.valid && !errors.Is(err, models.ErrInvalidTransformStep) {
+			t.Errorf("validateTransformSteps(%+v) = %v, want %v", test.steps, err, models.ErrInvalidTransformStep)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/jsonpath"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// InventorySyncService writes Shopware stock levels to Shopify locations.
+// It skips field mappings and product writes, so it is cheap enough to run
+// on every stock change.
+type InventorySyncService struct {
+	db             *gorm.DB
+	shopifyService *ShopifyService
+	mappingService *EntityMappingService
+	echoService    *SyncEchoService
+}
+
+// NewInventorySyncService creates a new inventory sync service
+func NewInventorySyncService(db *gorm.DB) *InventorySyncService {
+	return &InventorySyncService{
+		db:             db,
+		shopifyService: NewShopifyService(db),
+		mappingService: NewEntityMappingService(db),
+		echoService:    NewSyncEchoService(db),
+	}
+}
+
+// InventoryLevel is the quantity of a Shopware product at a Shopify location
+type InventoryLevel struct {
+	SourceID   string `json:"source_id"`
+	SKU        string `json:"sku"`
+	LocationID string `json:"location_id"`
+	Quantity   int    `json:"quantity"`
+}
+
+// InventoryLevels reads the quantity of every configured location from a
+// Shopware product. A product with variant children has levels for each
+// child instead of itself. Locations whose quantity path finds nothing are
+// left out.
+func InventoryLevels(config *models.InventoryConfig, sourceData []byte) ([]InventoryLevel, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var product map[string]interface{}
+	if err := json.Unmarshal(sourceData, &product); err != nil {
+		return nil, fmt.Errorf("error parsing source data: %w", err)
+	}
+
+	items := []map[string]interface{}{product}
+	if children, ok := product["children"].([]interface{}); ok && len(children) > 0 {
+		items = items[:0]
+		for _, child := range children {
+			if item, ok := child.(map[string]interface{}); ok {
+				items = append(items, item)
+			}
+		}
+	}
+
+	var levels []InventoryLevel
+	for _, item := range items {
+		sourceID := stringField(item, "id")
+		if sourceID == "" {
+			return nil, fmt.Errorf("product without an ID in source data")
+		}
+
+		for _, location := range config.Locations {
+			value, found, err := jsonpath.Lookup(item, location.Path())
+			if err != nil {
+				return nil, err
+			}
+			if !found || value == nil {
+				continue
+			}
+
+			quantity, ok := toFloat(value)
+			if !ok {
+				return nil, fmt.Errorf("quantity %v of product %s at %s is not a number", value, sourceID, location.Path())
+			}
+
+			levels = append(levels, InventoryLevel{
+				SourceID:   sourceID,
+				SKU:        stringField(item, "productNumber"),
+				LocationID: location.LocationID,
+				Quantity:   int(math.Round(quantity)),
+			})
+		}
+	}
+
+	return levels, nil
+}
+
+// SyncInventory sets the Shopify quantities of a Shopware product and
+// returns how many were set. Products whose levels did not change since the
+// last sync are skipped. Products without a Shopify variant fail the sync
+// after the others are written, so the migration is retried once the
+// product sync has created them.
+func (s *InventorySyncService) SyncInventory(dataflow *models.Dataflow, sourceData []byte) (int, error) {
+	levels, err := InventoryLevels(dataflow.Inventory, sourceData)
+	if err != nil {
+		return 0, classifyAs(models.ErrorClassTransform, err)
+	}
+
+	// Group the levels by product, keeping their order
+	var sourceIDs []string
+	levelsBySource := make(map[string][]InventoryLevel)
+	for _, level := range levels {
+		if _, ok := levelsBySource[level.SourceID]; !ok {
+			sourceIDs = append(sourceIDs, level.SourceID)
+		}
+		levelsBySource[level.SourceID] = append(levelsBySource[level.SourceID], level)
+	}
+
+	var quantities []InventoryQuantity
+	var changed []*models.EntityMapping
+	var missing []string
+
+	for _, sourceID := range sourceIDs {
+		itemLevels := levelsBySource[sourceID]
+
+		mapping, err := s.inventoryItem(dataflow, sourceID, itemLevels[0].SKU)
+		if errors.Is(err, ErrShopifyProductNotFound) {
+			missing = append(missing, sourceID)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		payload, err := json.Marshal(itemLevels)
+		if err != nil {
+			return 0, err
+		}
+		hash := payloadHash(payload)
+		if mapping.LastSyncedHash == hash {
+			continue
+		}
+
+		for _, level := range itemLevels {
+			quantities = append(quantities, InventoryQuantity{
+				InventoryItemID: mapping.DestID,
+				LocationID:      level.LocationID,
+				Quantity:        level.Quantity,
+			})
+		}
+		mapping.LastSyncedHash = hash
+		changed = append(changed, mapping)
+	}
+
+	if len(quantities) > 0 {
+		// Recorded so the Shopify webhooks of the write are not synced back
+		// by a Shopify to Shopware dataflow
+		written := make(map[string]string, len(quantities))
+		for _, quantity := range quantities {
+			written[inventoryLevelKey(quantity.InventoryItemID, quantity.LocationID)] = strconv.Itoa(quantity.Quantity)
+		}
+		if err := s.echoService.RecordWrites(dataflow.DestConnectorID, written); err != nil {
+			return 0, fmt.Errorf("error recording inventory writes: %w", err)
+		}
+
+		if err := s.shopifyService.SetInventoryQuantities(&dataflow.DestConnector, quantities); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, mapping := range changed {
+		if err := s.mappingService.UpsertMapping(mapping); err != nil {
+			return len(quantities), fmt.Errorf("error saving inventory item mapping: %w", err)
+		}
+	}
+
+	if len(missing) > 0 {
+		return len(quantities), fmt.Errorf("%w for Shopware products %s", ErrShopifyProductNotFound, strings.Join(missing, ", "))
+	}
+
+	return len(quantities), nil
+}
+
+// inventoryItem returns the inventory item mapping of a Shopware product.
+// An unmapped product's inventory item is found through its variant mapping
+// or its SKU, and the mapping is saved.
+func (s *InventorySyncService) inventoryItem(dataflow *models.Dataflow, sourceID, sku string) (*models.EntityMapping, error) {
+	mapping, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeInventoryItem, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up inventory item mapping: %w", err)
+	}
+	if mapping != nil {
+		return mapping, nil
+	}
+
+	connector := &dataflow.DestConnector
+
+	var inventoryItemID string
+	variant, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeVariant, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up variant mapping: %w", err)
+	}
+
+	switch {
+	case variant != nil:
+		inventoryItemID, err = s.shopifyService.GetVariantInventoryItem(connector, variant.DestID)
+	case sku != "":
+		inventoryItemID, err = s.shopifyService.FindInventoryItemBySKU(connector, sku)
+	default:
+		err = fmt.Errorf("%w: product %s has no variant mapping or SKU", ErrShopifyProductNotFound, sourceID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	mapping = &models.EntityMapping{
+		SourceConnectorID: dataflow.SourceConnectorID,
+		DestConnectorID:   dataflow.DestConnectorID,
+		EntityType:        models.EntityTypeInventoryItem,
+		SourceID:          sourceID,
+		DestID:            inventoryItemID,
+	}
+	if err := s.mappingService.UpsertMapping(mapping); err != nil {
+		return nil, fmt.Errorf("error saving inventory item mapping: %w", err)
+	}
+
+	return mapping, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+func TestInventoryLevels(t *testing.T) {
+	config := &models.InventoryConfig{
+		Locations: []models.InventoryLocation{
+			{LocationID: "gid://shopify/Location/1"},
+			{LocationID: "gid://shopify/Location/2", QuantityPath: "extensions.warehouses[?(@.name == 'Berlin')].stock"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		product string
+		want    []InventoryLevel
+	}{
+		{
+			name:    "product without children",
+			product: `{"id":"p1","productNumber":"SW1","availableStock":4.6,"extensions":{"warehouses":[{"name":"Berlin","stock":2}]}}`,
+			want: []InventoryLevel{
+				{SourceID: "p1", SKU: "SW1", LocationID: "gid://shopify/Location/1", Quantity: 5},
+				{SourceID: "p1", SKU: "SW1", LocationID: "gid://shopify/Location/2", Quantity: 2},
+			},
+		},
+		{
+			name:    "children replace the parent and missing quantities are left out",
+			product: `{"id":"p1","availableStock":10,"children":[{"id":"c1","productNumber":"SW1.1","availableStock":3},{"id":"c2","productNumber":"SW1.2","availableStock":null}]}`,
+			want: []InventoryLevel{
+				{SourceID: "c1", SKU: "SW1.1", LocationID: "gid://shopify/Location/1", Quantity: 3},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		levels, err := InventoryLevels(config, []byte(test.product))
+		if err != nil {
+			t.Errorf("%s: InventoryLevels: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(levels, test.want) {
+			t.Errorf("%s: InventoryLevels = %+v, want %+v", test.name, levels, test.want)
+		}
+	}
+}
+
+func TestInventoryLevelsInvalid(t *testing.T) {
+	config := &models.InventoryConfig{
+		Locations: []models.InventoryLocation{{LocationID: "gid://shopify/Location/1"}},
+	}
+
+	if _, err := InventoryLevels(&models.InventoryConfig{}, []byte(`{"id":"p1"}`)); !errors.Is(err, models.ErrInvalidInventoryConfig) {
+		t.Errorf("InventoryLevels without locations = %v, want %v", err, models.ErrInvalidInventoryConfig)
+	}
+
+	for _, product := range []string{`not json`, `{"availableStock":1}`, `{"id":"p1","availableStock":"many"}`} {
+		if _, err := InventoryLevels(config, []byte(product)); err == nil {
+			t.Errorf("InventoryLevels(%s) succeeded, want an error", product)
+		}
+	}
+}
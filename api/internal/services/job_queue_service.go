@@ -0,0 +1,202 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrJobLeaseLost is returned when a worker no longer holds the lease on a job
+var ErrJobLeaseLost = errors.New("job lease lost")
+
+// JobQueueService handles the durable Postgres-backed job queue
+type JobQueueService struct {
+	db          *gorm.DB
+	maxAttempts int
+}
+
+// NewJobQueueService creates a new job queue service
+func NewJobQueueService(db *gorm.DB, maxAttempts int) *JobQueueService {
+	return &JobQueueService{
+		db:          db,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Enqueue adds a job to the queue to be run as soon as possible
+func (s *JobQueueService) Enqueue(jobType models.JobType, payload []byte) (*models.Job, error) {
+	return s.EnqueueAt(jobType, payload, time.Now())
+}
+
+// EnqueueAt adds a job to the queue to be run at or after runAt
+func (s *JobQueueService) EnqueueAt(jobType models.JobType, payload []byte, runAt time.Time) (*models.Job, error) {
+	job := models.Job{
+		Type:        jobType,
+		Status:      models.JobStatusQueued,
+		Payload:     string(payload),
+		MaxAttempts: s.maxAttempts,
+		RunAt:       runAt,
+	}
+
+	if err := s.db.Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("error enqueuing job: %w", err)
+	}
+
+	return &job, nil
+}
+
+// GetJob gets a job by ID
+func (s *JobQueueService) GetJob(id uint) (*models.Job, error) {
+	var job models.Job
+
+	if err := s.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Claim claims the next due job for a worker. Jobs whose lease has expired
+// because their worker crashed are claimed again. It returns nil when no job
+// is available.
+func (s *JobQueueService) Claim(workerID string, jobTypes []models.JobType, lease time.Duration) (*models.Job, error) {
+	var claimed *models.Job
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var job models.Job
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: clause.LockingOptionsSkipLocked}).
+			Where("type IN ?", jobTypes).
+			Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+				models.JobStatusQueued, now, models.JobStatusRunning, now).
+			Order("run_at ASC").
+			First(&job).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		// A job whose lease expired on its final attempt is not retried again
+		if job.Status == models.JobStatusRunning && job.Attempts >= job.MaxAttempts {
+			job.Status = models.JobStatusFailed
+			job.LastError = fmt.Sprintf("lease held by %s expired on the final attempt", job.LockedBy)
+			job.LockedBy = ""
+			job.LockedUntil = nil
+			job.CompletedAt = &now
+			return tx.Save(&job).Error
+		}
+
+		lockedUntil := now.Add(lease)
+		job.Status = models.JobStatusRunning
+		job.LockedBy = workerID
+		job.LockedUntil = &lockedUntil
+		job.Attempts++
+
+		if err := tx.Save(&job).Error; err != nil {
+			return err
+		}
+
+		claimed = &job
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error claiming job: %w", err)
+	}
+
+	return claimed, nil
+}
+
+// Heartbeat extends the lease a worker holds on a job
+func (s *JobQueueService) Heartbeat(job *models.Job, workerID string, lease time.Duration) error {
+	lockedUntil := time.Now().Add(lease)
+
+	result := s.db.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, models.JobStatusRunning, workerID).
+		Update("locked_until", lockedUntil)
+	if result.Error != nil {
+		return fmt.Errorf("error extending job lease: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrJobLeaseLost
+	}
+
+	job.LockedUntil = &lockedUntil
+	return nil
+}
+
+// Complete marks a job as completed
+func (s *JobQueueService) Complete(job *models.Job, workerID string) error {
+	now := time.Now()
+
+	result := s.db.Model(&models.Job{}).
+		Where("id = ? AND locked_by = ?", job.ID, workerID).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusCompleted,
+			"locked_by":    "",
+			"locked_until": nil,
+			"last_error":   "",
+			"completed_at": now,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("error completing job: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrJobLeaseLost
+	}
+
+	return nil
+}
+
+// Fail records a failed attempt. The job is queued again with a backoff
+// until it runs out of attempts.
+func (s *JobQueueService) Fail(job *models.Job, workerID string, cause error) error {
+	now := time.Now()
+
+	updates := map[string]interface{}{
+		"locked_by":    "",
+		"locked_until": nil,
+		"last_error":   cause.Error(),
+	}
+
+	if job.Attempts >= job.MaxAttempts {
+		updates["status"] = models.JobStatusFailed
+		updates["completed_at"] = now
+	} else {
+		updates["status"] = models.JobStatusQueued
+		updates["run_at"] = now.Add(jobBackoff(job.Attempts))
+	}
+
+	result := s.db.Model(&models.Job{}).
+		Where("id = ? AND locked_by = ?", job.ID, workerID).
+		Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("error failing job: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrJobLeaseLost
+	}
+
+	return nil
+}
+
+// jobBackoff returns the delay before a failed job is attempted again
+func jobBackoff(attempts int) time.Duration {
+	delay := 5 * time.Second
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= 5*time.Minute {
+			return 5 * time.Minute
+		}
+	}
+	return delay
+}
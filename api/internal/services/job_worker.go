@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/config"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// JobHandler processes a single job. Returning an error fails the attempt.
+// The context is cancelled when the worker loses the job's lease, since
+// another worker may then run it.
+type JobHandler func(ctx context.Context, job *models.Job) error
+
+// JobWorkerPool claims jobs from the queue and dispatches them to handlers
+type JobWorkerPool struct {
+	queue    *JobQueueService
+	config   config.QueueConfig
+	handlers map[models.JobType]JobHandler
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewJobWorkerPool creates a new job worker pool
+func NewJobWorkerPool(queue *JobQueueService, config config.QueueConfig) *JobWorkerPool {
+	return &JobWorkerPool{
+		queue:    queue,
+		config:   config,
+		handlers: make(map[models.JobType]JobHandler),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Register registers the handler for a job type. It must be called before Start.
+func (p *JobWorkerPool) Register(jobType models.JobType, handler JobHandler) {
+	p.handlers[jobType] = handler
+}
+
+// Start starts the workers
+func (p *JobWorkerPool) Start() {
+	hostname, _ := os.Hostname()
+
+	for i := 0; i < p.config.Workers; i++ {
+		workerID := fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i)
+		p.wg.Add(1)
+		go p.work(workerID)
+	}
+}
+
+// Stop signals the workers to stop and waits for running jobs to finish
+func (p *JobWorkerPool) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+}
+
+// work claims and runs jobs until the pool is stopped
+func (p *JobWorkerPool) work(workerID string) {
+	defer p.wg.Done()
+
+	jobTypes := make([]models.JobType, 0, len(p.handlers))
+	for jobType := range p.handlers {
+		jobTypes = append(jobTypes, jobType)
+	}
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		job, err := p.queue.Claim(workerID, jobTypes, p.config.LeaseDuration)
+		if err != nil {
+			fmt.Printf("Worker %s: %v\n", workerID, err)
+		}
+
+		if job == nil {
+			select {
+			case <-p.stop:
+				return
+			case <-time.After(p.config.PollInterval):
+			}
+			continue
+		}
+
+		p.run(workerID, job)
+	}
+}
+
+// run runs a claimed job while keeping its lease alive with heartbeats. If
+// the lease is lost, the handler is cancelled and its result is not
+// recorded; the job belongs to whichever worker claims it next.
+func (p *JobWorkerPool) run(workerID string, job *models.Job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	heartbeatStopped := make(chan struct{})
+	var leaseLost atomic.Bool
+
+	go func() {
+		defer close(heartbeatStopped)
+
+		ticker := time.NewTicker(p.config.LeaseDuration / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := p.queue.Heartbeat(job, workerID, p.config.LeaseDuration)
+				if errors.Is(err, ErrJobLeaseLost) {
+					leaseLost.Store(true)
+					cancel()
+					return
+				}
+				if err != nil {
+					fmt.Printf("Worker %s: heartbeat for job %d failed: %v\n", workerID, job.ID, err)
+				}
+			}
+		}
+	}()
+
+	err := p.handle(ctx, job)
+
+	close(done)
+	<-heartbeatStopped
+
+	if leaseLost.Load() {
+		fmt.Printf("Worker %s: lost lease on job %d, leaving it to the worker that reclaimed it\n", workerID, job.ID)
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("Worker %s: job %d (%s) failed on attempt %d: %v\n", workerID, job.ID, job.Type, job.Attempts, err)
+		err = p.queue.Fail(job, workerID, err)
+	} else {
+		err = p.queue.Complete(job, workerID)
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrJobLeaseLost) {
+			fmt.Printf("Worker %s: lost lease on job %d before recording its result\n", workerID, job.ID)
+			return
+		}
+		fmt.Printf("Worker %s: %v\n", workerID, err)
+	}
+}
+
+// handle dispatches a job to its handler, turning panics into failures
+func (p *JobWorkerPool) handle(ctx context.Context, job *models.Job) (err error) {
+	handler, ok := p.handlers[job.Type]
+	if !ok {
+		return fmt.Errorf("no handler registered for job type: %s", job.Type)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// LocalExecutor runs migrations in-process on a pool of goroutine workers.
+// It is used instead of AWS Step Functions for local development and
+// on-prem installations.
+type LocalExecutor struct {
+	dataflowService *DataflowService
+	queue           chan MigrationInput
+	wg              sync.WaitGroup
+	mu              sync.RWMutex
+	stopped         bool
+}
+
+// NewLocalExecutor creates a new local executor and starts its workers
+func NewLocalExecutor(db *gorm.DB, workers, queueSize int) *LocalExecutor {
+	if workers < 1 {
+		workers = 1
+	}
+
+	executor := &LocalExecutor{
+		dataflowService: NewDataflowService(db),
+		queue:           make(chan MigrationInput, queueSize),
+	}
+
+	for i := 0; i < workers; i++ {
+		executor.wg.Add(1)
+		go executor.work()
+	}
+
+	return executor
+}
+
+// StartExecution queues a migration for execution by the worker pool
+func (e *LocalExecutor) StartExecution(dataflowID, migrationID uint, sourceData json.RawMessage) (string, error) {
+	input := MigrationInput{
+		DataflowID:  dataflowID,
+		MigrationID: migrationID,
+		SourceData:  sourceData,
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.stopped {
+		return "", fmt.Errorf("local executor is stopped")
+	}
+
+	select {
+	case e.queue <- input:
+	default:
+		return "", fmt.Errorf("local executor queue is full")
+	}
+
+	return fmt.Sprintf("local:%d:%d", dataflowID, migrationID), nil
+}
+
+// Stop stops accepting work and waits for queued migrations to finish
+func (e *LocalExecutor) Stop() {
+	e.mu.Lock()
+	if !e.stopped {
+		e.stopped = true
+		close(e.queue)
+	}
+	e.mu.Unlock()
+
+	e.wg.Wait()
+}
+
+// work processes queued migrations until the queue is closed
+func (e *LocalExecutor) work() {
+	defer e.wg.Done()
+
+	for input := range e.queue {
+		if err := e.dataflowService.RunMigration(input.MigrationID, input.SourceData); err != nil {
+			fmt.Printf("Migration %d for dataflow %d failed: %v\n", input.MigrationID, input.DataflowID, err)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// Changes recorded on lookup table versions
+const (
+	lookupChangeCreate  = "create"
+	lookupChangeUpdate  = "update"
+	lookupChangeImport  = "import"
+	lookupChangeRestore = "restore"
+)
+
+// LookupTableService handles lookup table operations
+type LookupTableService struct {
+	db *gorm.DB
+}
+
+// NewLookupTableService creates a new lookup table service
+func NewLookupTableService(db *gorm.DB) *LookupTableService {
+	return &LookupTableService{
+		db: db,
+	}
+}
+
+// CreateLookupTable creates a new lookup table as version 1
+func (s *LookupTableService) CreateLookupTable(table *models.LookupTable) error {
+	if err := s.checkNameAvailable(table.Name, 0); err != nil {
+		return err
+	}
+
+	table.ID = 0
+	table.Version = 1
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(table).Error; err != nil {
+			return err
+		}
+		return saveLookupTableVersion(tx, table, lookupChangeCreate)
+	})
+}
+
+// GetLookupTable gets a lookup table by ID
+func (s *LookupTableService) GetLookupTable(id uint) (*models.LookupTable, error) {
+	var table models.LookupTable
+
+	if err := s.db.First(&table, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &table, nil
+}
+
+// GetLookupTableByName gets a lookup table by name
+func (s *LookupTableService) GetLookupTableByName(name string) (*models.LookupTable, error) {
+	var table models.LookupTable
+
+	if err := s.db.Where("name = ?", name).First(&table).Error; err != nil {
+		return nil, err
+	}
+
+	return &table, nil
+}
+
+// ListLookupTables lists all lookup tables
+func (s *LookupTableService) ListLookupTables() ([]models.LookupTable, error) {
+	var tables []models.LookupTable
+
+	if err := s.db.Order("name").Find(&tables).Error; err != nil {
+		return nil, err
+	}
+
+	return tables, nil
+}
+
+// UpdateLookupTable replaces a lookup table's name, description, entries and
+// default value, saving the result as a new version. A table sent with a
+// version must still be at that version.
+func (s *LookupTableService) UpdateLookupTable(id uint, table *models.LookupTable) error {
+	existingTable, err := s.GetLookupTable(id)
+	if err != nil {
+		return err
+	}
+
+	if table.Version != 0 && table.Version != existingTable.Version {
+		return models.ErrLookupTableChanged
+	}
+
+	if err := s.checkNameAvailable(table.Name, id); err != nil {
+		return err
+	}
+
+	table.ID = existingTable.ID
+	table.CreatedAt = existingTable.CreatedAt
+	table.Version = existingTable.Version
+
+	if err := s.saveVersion(table, lookupChangeUpdate); err != nil {
+		return err
+	}
+
+	lookupTables.invalidate(existingTable.Name)
+	lookupTables.invalidate(table.Name)
+	return nil
+}
+
+// DeleteLookupTable deletes a lookup table and its versions
+func (s *LookupTableService) DeleteLookupTable(id uint) error {
+	existingTable, err := s.GetLookupTable(id)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("lookup_table_id = ?", id).Delete(&models.LookupTableVersion{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(existingTable).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	lookupTables.invalidate(existingTable.Name)
+	return nil
+}
+
+// ListVersions lists the versions of a lookup table, newest first
+func (s *LookupTableService) ListVersions(id uint) ([]models.LookupTableVersion, error) {
+	if _, err := s.GetLookupTable(id); err != nil {
+		return nil, err
+	}
+
+	var versions []models.LookupTableVersion
+	if err := s.db.Where("lookup_table_id = ?", id).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+// RestoreVersion restores the entries and default value of an earlier
+// version. The restored contents are saved as a new version, so the history
+// is kept.
+func (s *LookupTableService) RestoreVersion(id uint, version int) (*models.LookupTable, error) {
+	table, err := s.GetLookupTable(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot models.LookupTableVersion
+	if err := s.db.Where("lookup_table_id = ? AND version = ?", id, version).First(&snapshot).Error; err != nil {
+		return nil, err
+	}
+
+	table.Entries = snapshot.Entries
+	table.DefaultValue = snapshot.DefaultValue
+
+	if err := s.saveVersion(table, lookupChangeRestore); err != nil {
+		return nil, err
+	}
+
+	lookupTables.invalidate(table.Name)
+	return table, nil
+}
+
+// ImportCSV imports key,value rows into a lookup table. An optional header
+// row "key,value" is skipped. With replace, the rows replace all entries;
+// otherwise they are merged into the existing entries.
+func (s *LookupTableService) ImportCSV(id uint, r io.Reader, replace bool) (*models.LookupTable, int, error) {
+	table, err := s.GetLookupTable(id)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := readLookupCSV(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	table.Entries = mergeLookupEntries(table.Entries, rows, replace)
+
+	if err := s.saveVersion(table, lookupChangeImport); err != nil {
+		return nil, 0, err
+	}
+
+	lookupTables.invalidate(table.Name)
+	return table, len(rows), nil
+}
+
+// ExportCSV writes the entries of a lookup table as key,value rows with a
+// header, ordered by key
+func (s *LookupTableService) ExportCSV(id uint, w io.Writer) error {
+	table, err := s.GetLookupTable(id)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(table.Entries))
+	for key := range table.Entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"key", "value"}); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := writer.Write([]string{key, table.Entries[key]}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// Lookup returns the value of a key in the named lookup table, or the
+// table's default value when the key has no entry. Tables are cached in
+// memory for lookupTableTTL.
+func (s *LookupTableService) Lookup(name, key string) (string, bool, error) {
+	table, err := lookupTables.load(name, func() (*models.LookupTable, error) {
+		return s.GetLookupTableByName(name)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", false, fmt.Errorf("lookup table %s not found", name)
+		}
+		return "", false, fmt.Errorf("error loading lookup table %s: %w", name, err)
+	}
+
+	if value, ok := table.Entries[key]; ok {
+		return value, true, nil
+	}
+	if table.DefaultValue != nil {
+		return *table.DefaultValue, true, nil
+	}
+
+	return "", false, nil
+}
+
+// saveVersion increments a table's version and saves it with a snapshot of
+// its contents. The table is only saved if it is still at the version it
+// was read at, so concurrent changes cannot overwrite each other.
+func (s *LookupTableService) saveVersion(table *models.LookupTable, change string) error {
+	readVersion := table.Version
+	table.Version++
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		update := tx.Model(table).
+			Where("version = ?", readVersion).
+			Select("*").
+			Omit("id", "created_at").
+			Updates(table)
+		if update.Error != nil {
+			return update.Error
+		}
+		if update.RowsAffected == 0 {
+			return models.ErrLookupTableChanged
+		}
+		return saveLookupTableVersion(tx, table, change)
+	})
+	if err != nil {
+		table.Version = readVersion
+		return err
+	}
+
+	return nil
+}
+
+// checkNameAvailable returns ErrLookupTableExists if another table uses the
+// name
+func (s *LookupTableService) checkNameAvailable(name string, id uint) error {
+	var count int64
+	if err := s.db.Model(&models.LookupTable{}).Where("name = ? AND id <> ?", name, id).Count(&count).Error; err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return models.ErrLookupTableExists
+	}
+
+	return nil
+}
+
+// saveLookupTableVersion saves a snapshot of a table's current contents
+func saveLookupTableVersion(tx *gorm.DB, table *models.LookupTable, change string) error {
+	return tx.Create(&models.LookupTableVersion{
+		LookupTableID: table.ID,
+		Version:       table.Version,
+		Entries:       table.Entries,
+		DefaultValue:  table.DefaultValue,
+		Change:        change,
+	}).Error
+}
+
+// mergeLookupEntries adds imported rows to a table's entries, overwriting
+// existing keys. With replace, the rows replace all entries.
+func mergeLookupEntries(entries map[string]string, rows [][2]string, replace bool) map[string]string {
+	if replace || entries == nil {
+		entries = make(map[string]string, len(rows))
+	}
+	for _, row := range rows {
+		entries[row[0]] = row[1]
+	}
+	return entries
+}
+
+// readLookupCSV reads key,value rows, skipping an optional header row
+func readLookupCSV(r io.Reader) ([][2]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rows [][2]string
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", models.ErrInvalidLookupCSV, err)
+		}
+
+		if line == 1 && len(record) == 2 && strings.EqualFold(record[0], "key") && strings.EqualFold(record[1], "value") {
+			continue
+		}
+		if len(record) != 2 {
+			return nil, fmt.Errorf("%w: line %d has %d columns, expected key,value", models.ErrInvalidLookupCSV, line, len(record))
+		}
+		if record[0] == "" {
+			return nil, fmt.Errorf("%w: line %d has an empty key", models.ErrInvalidLookupCSV, line)
+		}
+
+		rows = append(rows, [2]string{record[0], record[1]})
+	}
+
+	return rows, nil
+}
+
+// lookupTableTTL is how long a lookup table is cached. A change invalidates
+// the table at once in the process that made it; other processes pick it up
+// once their copy expires.
+const lookupTableTTL = 30 * time.Second
+
+// lookupTables holds lookup tables by name, so a transform does not load
+// the table for every value
+var lookupTables = newTTLCache[string, *models.LookupTable](lookupTableTTL, 0)
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	database "github.com/DulsaraNethmin/shopware-shopify-integration/internal/db"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB connects to the Postgres database in TEST_DATABASE_DSN and
+// migrates its schema. Tests that need a database are skipped without one.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connecting to the test database: %v", err)
+	}
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("migrating the test database: %v", err)
+	}
+
+	return db
+}
+
+func TestReadLookupCSV(t *testing.T) {
+	tests := []struct {
+		csv  string
+		want [][2]string
+	}{
+		{"key,value\nA,1\nB,2\n", [][2]string{{"A", "1"}, {"B", "2"}}},
+		{"Key,Value\nA,1\n", [][2]string{{"A", "1"}}},
+		{"A, 1\n\"B,C\",\"x, y\"\n", [][2]string{{"A", "1"}, {"B,C", "x, y"}}},
+		{"A,\n", [][2]string{{"A", ""}}},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		rows, err := readLookupCSV(strings.NewReader(test.csv))
+		if err != nil {
+			t.Errorf("readLookupCSV(%q): %v", test.csv, err)
+			continue
+		}
+		if !reflect.DeepEqual(rows, test.want) {
+			t.Errorf("readLookupCSV(%q) = %v, want %v", test.csv, rows, test.want)
+		}
+	}
+}
+
+func TestReadLookupCSVInvalid(t *testing.T) {
+	tests := []string{
+		"A,1,extra\n",
+		"A\n",
+		",1\n",
+		"key,value\nA,\"unterminated\n",
+	}
+
+	for _, csv := range tests {
+		if _, err := readLookupCSV(strings.NewReader(csv)); !errors.Is(err, models.ErrInvalidLookupCSV) {
+			t.Errorf("readLookupCSV(%q) = %v, want %v", csv, err, models.ErrInvalidLookupCSV)
+		}
+	}
+}
+
+func TestMergeLookupEntries(t *testing.T) {
+	rows := [][2]string{{"b", "20"}, {"c", "3"}}
+
+	tests := []struct {
+		entries map[string]string
+		replace bool
+		want    map[string]string
+	}{
+		{map[string]string{"a": "1", "b": "2"}, false, map[string]string{"a": "1", "b": "20", "c": "3"}},
+		{map[string]string{"a": "1", "b": "2"}, true, map[string]string{"b": "20", "c": "3"}},
+		{nil, false, map[string]string{"b": "20", "c": "3"}},
+	}
+
+	for _, test := range tests {
+		if got := mergeLookupEntries(test.entries, rows, test.replace); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("mergeLookupEntries(%v, replace=%v) = %v, want %v", test.entries, test.replace, got, test.want)
+		}
+	}
+}
+
+func TestLookupTableVersions(t *testing.T) {
+	service := NewLookupTableService(newTestDB(t))
+
+	table := &models.LookupTable{
+		Name:    fmt.Sprintf("test-%d", time.Now().UnixNano()),
+		Entries: map[string]string{"a": "1"},
+	}
+	if err := service.CreateLookupTable(table); err != nil {
+		t.Fatalf("CreateLookupTable: %v", err)
+	}
+	t.Cleanup(func() { service.DeleteLookupTable(table.ID) })
+
+	// An update sent with the current version succeeds
+	update := &models.LookupTable{Name: table.Name, Version: 1, Entries: map[string]string{"a": "2"}}
+	if err := service.UpdateLookupTable(table.ID, update); err != nil {
+		t.Fatalf("UpdateLookupTable: %v", err)
+	}
+	if update.Version != 2 {
+		t.Errorf("version after update = %d, want 2", update.Version)
+	}
+
+	// One sent with an older version was made without seeing that change
+	stale := &models.LookupTable{Name: table.Name, Version: 1, Entries: map[string]string{"a": "3"}}
+	if err := service.UpdateLookupTable(table.ID, stale); !errors.Is(err, models.ErrLookupTableChanged) {
+		t.Errorf("UpdateLookupTable with a stale version = %v, want %v", err, models.ErrLookupTableChanged)
+	}
+
+	// A copy read before another change cannot be saved over it
+	readCopy, err := service.GetLookupTable(table.ID)
+	if err != nil {
+		t.Fatalf("GetLookupTable: %v", err)
+	}
+
+	imported, count, err := service.ImportCSV(table.ID, strings.NewReader("key,value\nb,2\n"), false)
+	if err != nil {
+		t.Fatalf("ImportCSV: %v", err)
+	}
+	if count != 1 || imported.Version != 3 || !reflect.DeepEqual(imported.Entries, map[string]string{"a": "2", "b": "2"}) {
+		t.Errorf("merged import = %d rows, version %d, %v", count, imported.Version, imported.Entries)
+	}
+
+	if err := service.saveVersion(readCopy, lookupChangeUpdate); !errors.Is(err, models.ErrLookupTableChanged) {
+		t.Errorf("saveVersion of a stale copy = %v, want %v", err, models.ErrLookupTableChanged)
+	}
+	if readCopy.Version != 2 {
+		t.Errorf("version of the stale copy = %d, want it restored to 2", readCopy.Version)
+	}
+
+	imported, _, err = service.ImportCSV(table.ID, strings.NewReader("c,3\n"), true)
+	if err != nil {
+		t.Fatalf("ImportCSV with replace: %v", err)
+	}
+	if imported.Version != 4 || !reflect.DeepEqual(imported.Entries, map[string]string{"c": "3"}) {
+		t.Errorf("replacing import = version %d, %v", imported.Version, imported.Entries)
+	}
+
+	// Restoring saves the old contents as a new version
+	restored, err := service.RestoreVersion(table.ID, 1)
+	if err != nil {
+		t.Fatalf("RestoreVersion: %v", err)
+	}
+	if restored.Version != 5 || !reflect.DeepEqual(restored.Entries, map[string]string{"a": "1"}) {
+		t.Errorf("restored table = version %d, %v", restored.Version, restored.Entries)
+	}
+
+	versions, err := service.ListVersions(table.ID)
+	if err != nil {
+		t.Fatalf("ListVersions: %v", err)
+	}
+	var changes []string
+	for _, version := range versions {
+		changes = append(changes, version.Change)
+	}
+	wantChanges := []string{lookupChangeRestore, lookupChangeImport, lookupChangeImport, lookupChangeUpdate, lookupChangeCreate}
+	if !reflect.DeepEqual(changes, wantChanges) {
+		t.Errorf("version changes = %v, want %v", changes, wantChanges)
+	}
+
+	value, found, err := service.Lookup(table.Name, "a")
+	if err != nil || !found || value != "1" {
+		t.Errorf("Lookup after restore = %q, %v, %v, want 1", value, found, err)
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// LookupTableConfig is the transform config of a lookup_table mapping
+type LookupTableConfig struct {
+	Table   string  `json:"table"`   // Name of the lookup table
+	Default *string `json:"default"` // Used when neither the entry nor a table default exists
+}
+
+// parseLookupTableConfig parses and validates a lookup_table transform config
+func parseLookupTableConfig(transformConfig string) (*LookupTableConfig, error) {
+	var config LookupTableConfig
+	if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
+		return nil, fmt.Errorf("invalid transform config: %w", err)
+	}
+
+	if config.Table == "" {
+		return nil, fmt.Errorf("lookup_table transform needs a table")
+	}
+
+	return &config, nil
+}
+
+// applyLookupTable maps a value through a lookup table
+func (s *FieldMappingService) applyLookupTable(value interface{}, transformConfig string) (interface{}, error) {
+	config, err := parseLookupTableConfig(transformConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("%v", value)
+	mapped, found, err := s.lookupTableService.Lookup(config.Table, key)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return mapped, nil
+	}
+
+	if config.Default != nil {
+		return *config.Default, nil
+	}
+
+	return nil, fmt.Errorf("no entry for value %s in lookup table %s", key, config.Table)
+}
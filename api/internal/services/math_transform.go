@@ -0,0 +1,72 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// MathConfig is the transform config of a math mapping
+type MathConfig struct {
+	Operation string  `json:"operation"` // add, subtract, multiply, divide or round
+	Operand   float64 `json:"operand"`
+	Precision *int    `json:"precision"` // Decimal places of the result; omitted leaves it unrounded
+}
+
+// parseMathConfig parses and validates a math transform config
+func parseMathConfig(transformConfig string) (*MathConfig, error) {
+	var config MathConfig
+	if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
+		return nil, fmt.Errorf("invalid transform config: %w", err)
+	}
+
+	switch config.Operation {
+	case "add", "subtract", "multiply", "round":
+	case "divide":
+		if config.Operand == 0 {
+			return nil, fmt.Errorf("divide needs a non-zero operand")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported math operation: %q", config.Operation)
+	}
+
+	if config.Precision != nil && *config.Precision < 0 {
+		return nil, fmt.Errorf("precision must not be negative")
+	}
+
+	return &config, nil
+}
+
+// applyMath applies a math transform to a numeric value
+func applyMath(value interface{}, transformConfig string) (interface{}, error) {
+	config, err := parseMathConfig(transformConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	number, ok := toFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("value is not a number: %v", value)
+	}
+
+	switch config.Operation {
+	case "add":
+		number += config.Operand
+	case "subtract":
+		number -= config.Operand
+	case "multiply":
+		number *= config.Operand
+	case "divide":
+		number /= config.Operand
+	}
+
+	precision := 0
+	if config.Precision != nil {
+		precision = *config.Precision
+	} else if config.Operation != "round" {
+		return number, nil
+	}
+
+	scale := math.Pow(10, float64(precision))
+	return math.Round(number*scale) / scale, nil
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// syncMedia makes the media of a Shopify product match the product request.
+// Media whose checksum matches their mapping are kept and only get their alt
+// text updated; new or changed files are attached, and mapped media that are
+// no longer in the request are removed. Private files are uploaded through
+// a staged upload. The media are then put in the request's order, so the
+// cover image comes first. A request without media leaves them alone.
+func (s *ProductSyncService) syncMedia(dataflow *models.Dataflow, productID string, product *ShopifyProduct) error {
+	if product.Media == nil {
+		return nil
+	}
+
+	connector := &dataflow.DestConnector
+
+	current, err := s.shopifyService.GetProductMedia(connector, productID)
+	if err != nil {
+		return fmt.Errorf("error getting media: %w", err)
+	}
+
+	tracked, err := s.mappingService.ListMappingsByParent(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeMedia, productID)
+	if err != nil {
+		return fmt.Errorf("error looking up media mappings: %w", err)
+	}
+
+	existing := make(map[string]ShopifyExistingMedia, len(current))
+	for _, media := range current {
+		existing[media.ID] = media
+	}
+
+	trackedBySource := make(map[string]models.EntityMapping, len(tracked))
+	for _, mapping := range tracked {
+		trackedBySource[mapping.SourceID] = mapping
+	}
+
+	// The Shopify ID of every requested media item, empty until created
+	ids := make([]string, len(product.Media))
+	kept := make(map[string]bool)
+	alts := make(map[string]string)
+	var creates []ShopifyMedia
+	var createIndexes []int
+
+	for i, media := range product.Media {
+		mapping, ok := trackedBySource[media.SourceID]
+		if ok && media.SourceID != "" && mapping.LastSyncedHash == media.Checksum {
+			if shopifyMedia, found := existing[mapping.DestID]; found && !kept[mapping.DestID] {
+				ids[i] = mapping.DestID
+				kept[mapping.DestID] = true
+				if shopifyMedia.Alt != media.Alt {
+					alts[mapping.DestID] = media.Alt
+				}
+				continue
+			}
+		}
+
+		if media.Private {
+			source, err := s.stageMedia(dataflow, &media)
+			if err != nil {
+				return err
+			}
+			media.OriginalSource = source
+		}
+
+		creates = append(creates, media)
+		createIndexes = append(createIndexes, i)
+	}
+
+	var deleteIDs []string
+	for _, mapping := range tracked {
+		if _, found := existing[mapping.DestID]; found && !kept[mapping.DestID] {
+			deleteIDs = append(deleteIDs, mapping.DestID)
+		}
+	}
+
+	if len(deleteIDs) > 0 {
+		if err := s.shopifyService.DeleteProductMedia(connector, productID, deleteIDs); err != nil {
+			return err
+		}
+	}
+
+	if len(alts) > 0 {
+		if err := s.shopifyService.UpdateProductMediaAlt(connector, productID, alts); err != nil {
+			return err
+		}
+	}
+
+	if len(creates) > 0 {
+		createdIDs, err := s.shopifyService.CreateProductMedia(connector, productID, creates)
+		if err != nil {
+			return err
+		}
+		for i, index := range createIndexes {
+			ids[index] = createdIDs[i]
+		}
+	}
+
+	// Drop the mappings of removed media and of media deleted in Shopify
+	for _, mapping := range tracked {
+		if !kept[mapping.DestID] {
+			if err := s.mappingService.DeleteMapping(&mapping); err != nil {
+				return fmt.Errorf("error deleting media mapping: %w", err)
+			}
+		}
+	}
+
+	for i, media := range product.Media {
+		if media.SourceID == "" {
+			continue
+		}
+		if err := s.mappingService.UpsertMapping(&models.EntityMapping{
+			SourceConnectorID: dataflow.SourceConnectorID,
+			DestConnectorID:   dataflow.DestConnectorID,
+			EntityType:        models.EntityTypeMedia,
+			SourceID:          media.SourceID,
+			DestID:            ids[i],
+			ParentID:          productID,
+			LastSyncedHash:    media.Checksum,
+		}); err != nil {
+			return fmt.Errorf("error saving media mapping: %w", err)
+		}
+	}
+
+	if mediaOrderChanged(current, kept, ids) {
+		if err := s.shopifyService.ReorderProductMedia(connector, productID, ids); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stageMedia downloads a private Shopware file and uploads it to Shopify,
+// returning the URL to create the media from
+func (s *ProductSyncService) stageMedia(dataflow *models.Dataflow, media *ShopifyMedia) (string, error) {
+	content, err := s.shopwareService.DownloadMedia(&dataflow.SourceConnector, media.OriginalSource)
+	if err != nil {
+		return "", fmt.Errorf("error downloading media %s: %w", media.SourceID, err)
+	}
+
+	filename := media.FileName
+	if filename == "" {
+		filename = media.SourceID
+	}
+	mimeType := media.MimeType
+	if mimeType == "" {
+		mimeType = "image/jpeg"
+	}
+
+	source, err := s.shopifyService.StageMediaFile(&dataflow.DestConnector, filename, mimeType, content)
+	if err != nil {
+		return "", fmt.Errorf("error uploading media %s: %w", media.SourceID, err)
+	}
+	return source, nil
+}
+
+// mediaOrderChanged reports whether the product's media are not already in
+// the requested order. New media are appended by Shopify, so the kept media
+// followed by the new ones in request order need no reordering.
+func mediaOrderChanged(current []ShopifyExistingMedia, kept map[string]bool, ids []string) bool {
+	var order []string
+	for _, media := range current {
+		if kept[media.ID] {
+			order = append(order, media.ID)
+		}
+	}
+	for _, id := range ids {
+		if !kept[id] {
+			order = append(order, id)
+		}
+	}
+
+	if len(order) != len(ids) {
+		return true
+	}
+	for i := range ids {
+		if order[i] != ids[i] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package services
+
+import "testing"
+
+func TestMediaOrderChanged(t *testing.T) {
+	current := []ShopifyExistingMedia{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	tests := []struct {
+		name    string
+		kept    map[string]bool
+		ids     []string
+		changed bool
+	}{
+		{"same order", map[string]bool{"a": true, "b": true, "c": true}, []string{"a", "b", "c"}, false},
+		{"removed media", map[string]bool{"a": true, "c": true}, []string{"a", "c"}, false},
+		{"new media at the end", map[string]bool{"a": true, "b": true}, []string{"a", "b", "d"}, false},
+		{"new cover", map[string]bool{"a": true, "b": true}, []string{"d", "a", "b"}, true},
+		{"swapped", map[string]bool{"a": true, "b": true, "c": true}, []string{"b", "a", "c"}, true},
+	}
+
+	for _, test := range tests {
+		if changed := mediaOrderChanged(current, test.kept, test.ids); changed != test.changed {
+			t.Errorf("%s: mediaOrderChanged = %v, want %v", test.name, changed, test.changed)
+		}
+	}
+}
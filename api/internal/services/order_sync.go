@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// ErrProductNotSynced is returned when an order line item references a
+// Shopware product that has no Shopify counterpart yet
+var ErrProductNotSynced = errors.New("product was never synced to Shopify")
+
+// ErrOrderDiscountNotMapped is returned when an order has promotions but
+// its dataflow does not map them to the Shopify order's discount code
+var ErrOrderDiscountNotMapped = errors.New("order has promotions but no discountCode mapping")
+
+// checkOrderDiscount makes sure the promotions of a source order, found in
+// its "discountCode", are not dropped from the Shopify order
+func checkOrderDiscount(sourceData []byte, order *ShopifyOrder) error {
+	var source struct {
+		DiscountCode *ShopifyOrderDiscount `json:"discountCode"`
+	}
+	if err := json.Unmarshal(sourceData, &source); err != nil || source.DiscountCode == nil {
+		return nil
+	}
+
+	if order.DiscountCode == nil {
+		return classifyAs(models.ErrorClassTransform, fmt.Errorf("%w: add a mapping from discountCode to discountCode to apply %s", ErrOrderDiscountNotMapped, source.DiscountCode.Code))
+	}
+	return nil
+}
+
+// resolveLineItems sets the Shopify variant of every order line item that
+// references a Shopware product. A variant child is found through its
+// variant mapping; any other product through its product mapping, taking
+// its only variant or the variant with the item's SKU. Items without a
+// source product, such as custom items, are created as custom line items.
+func (s *DataflowService) resolveLineItems(dataflow *models.Dataflow, order *ShopifyOrder) error {
+	mappingService := NewEntityMappingService(s.db)
+	shopifyService := NewShopifyService(s.db)
+
+	for i := range order.LineItems {
+		item := &order.LineItems[i]
+		if item.SourceID == "" || item.VariantID != "" {
+			continue
+		}
+
+		variant, err := mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeVariant, item.SourceID)
+		if err != nil {
+			return fmt.Errorf("error looking up variant mapping: %w", err)
+		}
+		if variant != nil {
+			item.VariantID = variant.DestID
+			continue
+		}
+
+		product, err := mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeProduct, item.SourceID)
+		if err != nil {
+			return fmt.Errorf("error looking up product mapping: %w", err)
+		}
+		if product == nil {
+			return fmt.Errorf("%w: line item %q references Shopware product %s", ErrProductNotSynced, item.Title, item.SourceID)
+		}
+
+		variants, err := shopifyService.GetProductVariants(&dataflow.DestConnector, product.DestID)
+		if err != nil {
+			return fmt.Errorf("error getting variants of %s: %w", product.DestID, err)
+		}
+
+		for _, existing := range variants.Variants {
+			if len(variants.Variants) == 1 || (item.SKU != "" && existing.SKU == item.SKU) {
+				item.VariantID = existing.ID
+				break
+			}
+		}
+		if item.VariantID == "" {
+			return fmt.Errorf("%w: line item %q matches none of the variants of Shopify product %s", ErrProductNotSynced, item.Title, product.DestID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// PreviewService shows what a dataflow would send to Shopify for a source
+// entity without creating a migration log or calling Shopify
+type PreviewService struct {
+	db                  *gorm.DB
+	dataflowService     *DataflowService
+	fieldMappingService *FieldMappingService
+	mappingService      *EntityMappingService
+	shopwareService     *ShopwareService
+}
+
+// NewPreviewService creates a new preview service
+func NewPreviewService(db *gorm.DB, shopwareService *ShopwareService) *PreviewService {
+	return &PreviewService{
+		db:                  db,
+		dataflowService:     NewDataflowService(db),
+		fieldMappingService: NewFieldMappingService(db),
+		mappingService:      NewEntityMappingService(db),
+		shopwareService:     shopwareService,
+	}
+}
+
+// DataflowPreview is the outcome of a dataflow dry run
+type DataflowPreview struct {
+	SourceID    string                 `json:"source_id,omitempty"`
+	SourceData  json.RawMessage        `json:"source_data"`
+	Transformed map[string]interface{} `json:"transformed"`
+	Trace       []MappingTrace         `json:"trace"`
+	Errors      []string               `json:"errors,omitempty"`
+	Mutation    string                 `json:"mutation,omitempty"` // Shopify mutation, or Shopware request, that would be sent
+	Variables   map[string]interface{} `json:"variables,omitempty"`
+	DestID      string                 `json:"dest_id,omitempty"` // Shopify ID of the already synced entity
+	Note        string                 `json:"note,omitempty"`
+
+	// Order line items whose Shopify variant is not known from the entity
+	// mappings, with the reason
+	UnresolvedLineItems []string `json:"unresolved_line_items,omitempty"`
+}
+
+// PreviewDataflow runs a dataflow's field mappings on a Shopware entity. The
+// source is either fetched by ID or given as a raw payload.
+func (s *PreviewService) PreviewDataflow(dataflowID uint, sourceID string, payload json.RawMessage) (*DataflowPreview, error) {
+	dataflow, err := s.dataflowService.GetDataflow(dataflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceData := []byte(payload)
+	if len(sourceData) == 0 {
+		if sourceID == "" {
+			return nil, fmt.Errorf("either a source ID or a payload is required")
+		}
+
+		sourceData, err = sourceReader(s.db, s.shopwareService, dataflow).FetchSourceDataForDataflow(dataflow, sourceID)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching source data: %w", err)
+		}
+	}
+
+	if sourceID == "" {
+		var source struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(sourceData, &source)
+		sourceID = source.ID
+	}
+
+	// Inventory dataflows read stock without field mappings
+	if dataflow.IsReverse() {
+		return previewStock(sourceID, sourceData), nil
+	}
+	if dataflow.Type == models.DataflowTypeInventory {
+		return previewInventory(dataflow, sourceID, sourceData), nil
+	}
+
+	result, err := s.fieldMappingService.PreviewTransform(dataflow.ID, sourceData)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &DataflowPreview{
+		SourceID:    sourceID,
+		SourceData:  json.RawMessage(sourceData),
+		Transformed: result.Data,
+		Trace:       result.Trace,
+	}
+
+	for _, trace := range result.Trace {
+		if trace.Error != "" {
+			preview.Errors = append(preview.Errors, trace.Error)
+		}
+	}
+
+	if result.Error != nil {
+		preview.Note = "The transformation failed, so nothing would be sent to Shopify"
+		return preview, nil
+	}
+
+	transformedJSON, err := json.Marshal(result.Data)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling transformed data: %w", err)
+	}
+
+	if err := s.previewRequest(dataflow, preview, transformedJSON); err != nil {
+		preview.Errors = append(preview.Errors, err.Error())
+	}
+
+	return preview, nil
+}
+
+// previewRequest fills in the Shopify mutation and variables the dataflow
+// would send for the transformed data
+func (s *PreviewService) previewRequest(dataflow *models.Dataflow, preview *DataflowPreview, transformedJSON []byte) error {
+	var entityType models.EntityType
+	switch dataflow.Type {
+	case models.DataflowTypeProduct:
+		entityType = models.EntityTypeProduct
+	case models.DataflowTypeOrder:
+		entityType = models.EntityTypeOrder
+	case models.DataflowTypeCategory:
+		entityType = models.EntityTypeCollection
+	case models.DataflowTypeCustomer:
+		entityType = models.EntityTypeCustomer
+	default:
+		return fmt.Errorf("unsupported dataflow type: %s", dataflow.Type)
+	}
+
+	var mapping *models.EntityMapping
+	if preview.SourceID != "" {
+		var err error
+		mapping, err = s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, entityType, preview.SourceID)
+		if err != nil {
+			return fmt.Errorf("error looking up entity mapping: %w", err)
+		}
+	}
+
+	if mapping != nil {
+		preview.DestID = mapping.DestID
+		if mapping.LastSyncedHash == payloadHash(transformedJSON) {
+			preview.Note = "Unchanged since the last sync, so nothing would be sent to Shopify"
+			return nil
+		}
+	}
+
+	switch dataflow.Type {
+	case models.DataflowTypeProduct:
+		productRequest, err := toProductCreateRequest(transformedJSON)
+		if err != nil {
+			return fmt.Errorf("error unmarshaling transformed data: %w", err)
+		}
+
+		// The inputs UpdateProduct and CreateProduct send
+		var input map[string]interface{}
+		if mapping != nil {
+			input = buildProductInput(&productRequest.Product)
+			input["id"] = mapping.DestID
+			preview.Mutation = "productUpdate"
+		} else {
+			input = buildProductCreateInput(&productRequest.Product)
+			preview.Mutation = "productCreate"
+			preview.Note = "If a Shopify product with the same SKU exists, it is updated instead"
+		}
+		preview.Variables = map[string]interface{}{"input": input}
+
+	case models.DataflowTypeOrder:
+		if mapping != nil {
+			preview.Note = "The order was already created, so nothing would be sent to Shopify"
+			return nil
+		}
+
+		orderRequest, err := toOrderCreateRequest(transformedJSON)
+		if err != nil {
+			return fmt.Errorf("error unmarshaling transformed data: %w", err)
+		}
+
+		if err := checkOrderDiscount(preview.SourceData, &orderRequest.Order); err != nil {
+			return err
+		}
+
+		unresolved, err := s.previewLineItems(dataflow, &orderRequest.Order)
+		if err != nil {
+			return err
+		}
+		preview.UnresolvedLineItems = unresolved
+
+		preview.Mutation = "orderCreate"
+		preview.Variables = map[string]interface{}{"order": buildOrderInput(&orderRequest.Order)}
+
+	case models.DataflowTypeCategory:
+		collection, err := toCollectionRequest(transformedJSON)
+		if err != nil {
+			return fmt.Errorf("error unmarshaling transformed data: %w", err)
+		}
+
+		input := buildCollectionInput(collection)
+		if mapping != nil {
+			input["id"] = mapping.DestID
+			preview.Mutation = "collectionUpdate"
+		} else {
+			preview.Mutation = "collectionCreate"
+			preview.Note = "The new collection is filled with the synced products of the category"
+		}
+		preview.Variables = map[string]interface{}{"input": input}
+
+	case models.DataflowTypeCustomer:
+		customer, err := toCustomerRequest(transformedJSON)
+		if err != nil {
+			return fmt.Errorf("error unmarshaling transformed data: %w", err)
+		}
+
+		input := buildCustomerInput(customer, mapping == nil)
+		if mapping != nil {
+			input["id"] = mapping.DestID
+			preview.Mutation = "customerUpdate"
+			preview.Note = "Marketing consent is updated with separate mutations"
+		} else {
+			preview.Mutation = "customerCreate"
+			preview.Note = "If a Shopify customer with the same email exists, it is updated instead"
+		}
+		preview.Variables = map[string]interface{}{"input": input}
+	}
+
+	return nil
+}
+
+// previewLineItems sets the Shopify variant of the order line items whose
+// variant mapping is known and describes the others. Unlike a sync, it does
+// not look up the variants of mapped products in Shopify.
+func (s *PreviewService) previewLineItems(dataflow *models.Dataflow, order *ShopifyOrder) ([]string, error) {
+	var unresolved []string
+
+	for i := range order.LineItems {
+		item := &order.LineItems[i]
+		if item.SourceID == "" || item.VariantID != "" {
+			continue
+		}
+
+		variant, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeVariant, item.SourceID)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up variant mapping: %w", err)
+		}
+		if variant != nil {
+			item.VariantID = variant.DestID
+			continue
+		}
+
+		product, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeProduct, item.SourceID)
+		if err != nil {
+			return nil, fmt.Errorf("error looking up product mapping: %w", err)
+		}
+		if product == nil {
+			unresolved = append(unresolved, fmt.Sprintf("%q: Shopware product %s is not synced, so the sync would fail", item.Title, item.SourceID))
+		} else {
+			unresolved = append(unresolved, fmt.Sprintf("%q: the variant would be looked up in Shopify product %s", item.Title, product.DestID))
+		}
+	}
+
+	return unresolved, nil
+}
+
+// previewInventory shows the quantities an inventory dataflow would set
+func previewInventory(dataflow *models.Dataflow, sourceID string, sourceData []byte) *DataflowPreview {
+	preview := &DataflowPreview{
+		SourceID:   sourceID,
+		SourceData: json.RawMessage(sourceData),
+		Trace:      []MappingTrace{},
+	}
+
+	levels, err := InventoryLevels(dataflow.Inventory, sourceData)
+	if err != nil {
+		preview.Errors = append(preview.Errors, err.Error())
+		preview.Note = "The stock levels could not be read, so nothing would be sent to Shopify"
+		return preview
+	}
+
+	preview.Mutation = "inventorySetQuantities"
+	preview.Variables = map[string]interface{}{"levels": levels}
+	preview.Note = "Inventory items are resolved from the variant mappings or SKUs, and unchanged levels are skipped"
+	return preview
+}
+
+// previewStock shows the stock a Shopify to Shopware inventory dataflow
+// would set
+func previewStock(sourceID string, sourceData []byte) *DataflowPreview {
+	preview := &DataflowPreview{
+		SourceID:   sourceID,
+		SourceData: json.RawMessage(sourceData),
+		Trace:      []MappingTrace{},
+	}
+
+	var item ShopifyInventoryItem
+	if err := json.Unmarshal(sourceData, &item); err != nil {
+		preview.Errors = append(preview.Errors, err.Error())
+		preview.Note = "The inventory item could not be read, so nothing would be sent to Shopware"
+		return preview
+	}
+
+	preview.Mutation = "PATCH /api/product"
+	preview.Variables = map[string]interface{}{"inventoryItemId": item.ID, "stock": item.Stock}
+	preview.Note = "The Shopware product is resolved from the entity mappings or the SKU"
+	return preview
+}
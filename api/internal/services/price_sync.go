@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// applyShopPrices sets the price and compare-at price of every variant that
+// has prices by currency to its price in the shop currency, gross or net
+// depending on whether the shop's prices include taxes. Variants without a
+// price in the shop currency keep the mapped price.
+func applyShopPrices(variants []ShopifyVariant, shop *ShopPricing) {
+	for i := range variants {
+		price, compareAtPrice, ok := variants[i].amounts(shop.CurrencyCode, shop.TaxesIncluded)
+		if !ok {
+			continue
+		}
+		variants[i].Price = price
+		variants[i].CompareAtPrice = compareAtPrice
+	}
+}
+
+// syncPriceLists fills the dataflow's price lists with the prices of a
+// product's variants. The ids are the Shopify IDs of the variants.
+func (s *ProductSyncService) syncPriceLists(dataflow *models.Dataflow, shop *ShopPricing, variants []ShopifyVariant, ids []string) error {
+	if dataflow.Pricing == nil || len(ids) == 0 {
+		return nil
+	}
+
+	for _, priceList := range dataflow.Pricing.PriceLists {
+		var prices []PriceListPrice
+		var breaks []QuantityPriceBreak
+		for i := range variants {
+			price, priceBreaks, ok := priceListPrice(&variants[i], priceList, shop.TaxesIncluded)
+			if !ok {
+				continue
+			}
+
+			price.VariantID = ids[i]
+			prices = append(prices, price)
+			for _, priceBreak := range priceBreaks {
+				priceBreak.VariantID = ids[i]
+				breaks = append(breaks, priceBreak)
+			}
+		}
+
+		if err := s.shopifyService.UpdatePriceListPrices(&dataflow.DestConnector, priceList.PriceListID, priceList.Currency, ids, prices, breaks); err != nil {
+			return fmt.Errorf("error updating price list %s: %w", priceList.PriceListID, err)
+		}
+	}
+
+	return nil
+}
+
+// priceListPrice returns the price of a variant in a price list and its
+// quantity breaks. A list with a rule takes the rule's advanced prices: the
+// price for a single item is the fixed price, unless there is none, and the
+// others become quantity breaks. Otherwise the list takes the variant's
+// price in its currency.
+func priceListPrice(variant *ShopifyVariant, priceList models.PriceListConfig, taxesIncluded bool) (PriceListPrice, []QuantityPriceBreak, bool) {
+	amount, compareAtPrice, ok := variant.amounts(priceList.Currency, taxesIncluded)
+	price := PriceListPrice{Amount: amount, CompareAtPrice: compareAtPrice}
+
+	var breaks []QuantityPriceBreak
+	if priceList.RuleID != "" {
+		for _, advancedPrice := range variant.AdvancedPrices {
+			if advancedPrice.RuleID != priceList.RuleID || advancedPrice.Currency != priceList.Currency {
+				continue
+			}
+
+			amount := advancedPrice.Net
+			if taxesIncluded {
+				amount = advancedPrice.Gross
+			}
+
+			if advancedPrice.MinimumQuantity <= 1 {
+				price = PriceListPrice{Amount: amount}
+				ok = true
+				continue
+			}
+			breaks = append(breaks, QuantityPriceBreak{MinimumQuantity: advancedPrice.MinimumQuantity, Amount: amount})
+		}
+	}
+
+	// Quantity breaks need a fixed price to start from
+	if !ok {
+		return PriceListPrice{}, nil, false
+	}
+	return price, breaks, true
+}
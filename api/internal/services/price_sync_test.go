@@ -0,0 +1,171 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+const testRuleID = "0123456789abcdef0123456789abcdef"
+
+func testPricedVariant() ShopifyVariant {
+	return ShopifyVariant{
+		Price: "11.90",
+		Prices: []ShopifyVariantPrice{
+			{Currency: "EUR", Gross: "11.90", Net: "10.00", CompareAtGross: "14.90", CompareAtNet: "12.52"},
+			{Currency: "USD", Gross: "13.00", Net: "11.00"},
+		},
+		AdvancedPrices: []ShopifyVariantAdvancedPrice{
+			{RuleID: testRuleID, MinimumQuantity: 1, Currency: "EUR", Gross: "10.71", Net: "9.00"},
+			{RuleID: testRuleID, MinimumQuantity: 10, Currency: "EUR", Gross: "9.52", Net: "8.00"},
+			{RuleID: testRuleID, MinimumQuantity: 10, Currency: "USD", Gross: "10.00", Net: "8.40"},
+			{RuleID: "other", MinimumQuantity: 5, Currency: "EUR", Gross: "1.00", Net: "1.00"},
+		},
+	}
+}
+
+func TestApplyShopPrices(t *testing.T) {
+	tests := []struct {
+		shop                  ShopPricing
+		price, compareAtPrice string
+	}{
+		{ShopPricing{CurrencyCode: "EUR", TaxesIncluded: true}, "11.90", "14.90"},
+		{ShopPricing{CurrencyCode: "EUR", TaxesIncluded: false}, "10.00", "12.52"},
+		{ShopPricing{CurrencyCode: "USD", TaxesIncluded: true}, "13.00", ""},
+		// Without a price in the shop currency the mapped price is kept
+		{ShopPricing{CurrencyCode: "GBP", TaxesIncluded: true}, "11.90", ""},
+	}
+
+	for _, test := range tests {
+		variants := []ShopifyVariant{testPricedVariant()}
+		applyShopPrices(variants, &test.shop)
+
+		if variants[0].Price != test.price || variants[0].CompareAtPrice != test.compareAtPrice {
+			t.Errorf("applyShopPrices(%+v) = %s, %q, want %s, %q", test.shop, variants[0].Price, variants[0].CompareAtPrice, test.price, test.compareAtPrice)
+		}
+	}
+}
+
+func TestPriceListPrice(t *testing.T) {
+	tests := []struct {
+		name          string
+		priceList     models.PriceListConfig
+		taxesIncluded bool
+		price         PriceListPrice
+		breaks        []QuantityPriceBreak
+		ok            bool
+	}{
+		{
+			name:          "currency price",
+			priceList:     models.PriceListConfig{Currency: "EUR"},
+			taxesIncluded: true,
+			price:         PriceListPrice{Amount: "11.90", CompareAtPrice: "14.90"},
+			ok:            true,
+		},
+		{
+			name:      "net currency price",
+			priceList: models.PriceListConfig{Currency: "USD"},
+			price:     PriceListPrice{Amount: "11.00"},
+			ok:        true,
+		},
+		{
+			name:      "no price in the currency",
+			priceList: models.PriceListConfig{Currency: "GBP"},
+		},
+		{
+			name:          "rule with a fixed price and a quantity break",
+			priceList:     models.PriceListConfig{Currency: "EUR", RuleID: testRuleID},
+			taxesIncluded: true,
+			price:         PriceListPrice{Amount: "10.71"},
+			breaks:        []QuantityPriceBreak{{MinimumQuantity: 10, Amount: "9.52"}},
+			ok:            true,
+		},
+		{
+			name:      "rule breaks start from the currency price",
+			priceList: models.PriceListConfig{Currency: "USD", RuleID: testRuleID},
+			price:     PriceListPrice{Amount: "11.00"},
+			breaks:    []QuantityPriceBreak{{MinimumQuantity: 10, Amount: "8.40"}},
+			ok:        true,
+		},
+	}
+
+	for _, test := range tests {
+		variant := testPricedVariant()
+		price, breaks, ok := priceListPrice(&variant, test.priceList, test.taxesIncluded)
+		if ok != test.ok || price != test.price || !reflect.DeepEqual(breaks, test.breaks) {
+			t.Errorf("%s: priceListPrice = %+v, %+v, %v, want %+v, %+v, %v", test.name, price, breaks, ok, test.price, test.breaks, test.ok)
+		}
+	}
+
+	// Quantity breaks without a price to start from are left out
+	variant := ShopifyVariant{AdvancedPrices: []ShopifyVariantAdvancedPrice{
+		{RuleID: testRuleID, MinimumQuantity: 10, Currency: "GBP", Gross: "5.00", Net: "4.00"},
+	}}
+	if _, _, ok := priceListPrice(&variant, models.PriceListConfig{Currency: "GBP", RuleID: testRuleID}, true); ok {
+		t.Errorf("priceListPrice without a fixed price succeeded")
+	}
+}
+
+func TestVariantAdvancedPrices(t *testing.T) {
+	currencies := map[string]string{shopwareDefaultCurrencyID: "EUR", "usd": "USD"}
+
+	var advancedPrices []interface{}
+	json.Unmarshal([]byte(`[
+		{"ruleId":"r2","quantityStart":1,"price":[{"currencyId":"usd","gross":12,"net":10}]},
+		{"ruleId":"r1","quantityStart":10,"price":[{"currencyId":"b7d2554b0ce847cd82f3ac9bd1c0dfca","gross":9.52,"net":8},{"currencyId":"unknown","gross":1,"net":1}]},
+		{"ruleId":"r1","quantityStart":1,"price":[{"currencyId":"b7d2554b0ce847cd82f3ac9bd1c0dfca","gross":10.71,"net":9}]},
+		{"quantityStart":1,"price":[{"currencyId":"usd","gross":1,"net":1}]}
+	]`), &advancedPrices)
+
+	got, _ := json.Marshal(variantAdvancedPrices(advancedPrices, currencies))
+	want := `[{"currency":"EUR","gross":"10.71","minimumQuantity":1,"net":"9.00","ruleId":"r1"},{"currency":"EUR","gross":"9.52","minimumQuantity":10,"net":"8.00","ruleId":"r1"},{"currency":"USD","gross":"12.00","minimumQuantity":1,"net":"10.00","ruleId":"r2"}]`
+	if string(got) != want {
+		t.Errorf("variantAdvancedPrices = %s, want %s", got, want)
+	}
+}
+
+func TestUpdatePriceListPrices(t *testing.T) {
+	var variables map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		variables = request.Variables
+		w.Write([]byte(`{"data":{"quantityPricingByVariantUpdate":{"productVariants":[],"userErrors":[]}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	service := &ShopifyService{httpClient: server.Client()}
+	connector := &models.Connector{URL: server.URL}
+
+	err := service.UpdatePriceListPrices(connector, "gid://shopify/PriceList/1", "EUR",
+		[]string{"v1", "v2"},
+		[]PriceListPrice{{VariantID: "v1", Amount: "10.71", CompareAtPrice: "14.90"}},
+		[]QuantityPriceBreak{{VariantID: "v1", MinimumQuantity: 10, Amount: "9.52"}},
+	)
+	if err != nil {
+		t.Fatalf("UpdatePriceListPrices: %v", err)
+	}
+
+	// Variants without a price lose theirs, and all quantity breaks are replaced
+	got, _ := json.Marshal(variables["input"])
+	want := `{"pricesToAdd":[{"compareAtPrice":{"amount":"14.90","currencyCode":"EUR"},"price":{"amount":"10.71","currencyCode":"EUR"},"variantId":"v1"}],"pricesToDeleteByVariantId":["v2"],"quantityPriceBreaksToAdd":[{"minimumQuantity":10,"price":{"amount":"9.52","currencyCode":"EUR"},"variantId":"v1"}],"quantityPriceBreaksToDelete":[],"quantityPriceBreaksToDeleteByVariantId":["v1","v2"],"quantityRulesToAdd":[],"quantityRulesToDeleteByVariantId":[]}`
+	if string(got) != want {
+		t.Errorf("input = %s, want %s", got, want)
+	}
+}
+
+func TestUpdatePriceListPricesUserError(t *testing.T) {
+	service, connector := newFakeGraphQLShopify(t,
+		`{"data":{"quantityPricingByVariantUpdate":{"productVariants":null,"userErrors":[{"field":["priceListId"],"message":"Price list does not exist"}]}}}`)
+
+	err := service.UpdatePriceListPrices(connector, "gid://shopify/PriceList/1", "EUR", []string{"v1"}, nil, nil)
+	if err == nil {
+		t.Fatalf("UpdatePriceListPrices succeeded, want the user error")
+	}
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// ProductSyncService writes transformed products to Shopify without creating
+// duplicates, using the entity mapping table to track synced products
+type ProductSyncService struct {
+	db              *gorm.DB
+	shopifyService  *ShopifyService
+	shopwareService *ShopwareService
+	mappingService  *EntityMappingService
+}
+
+// NewProductSyncService creates a new product sync service
+func NewProductSyncService(db *gorm.DB) *ProductSyncService {
+	return &ProductSyncService{
+		db:              db,
+		shopifyService:  NewShopifyService(db),
+		shopwareService: NewShopwareService(db),
+		mappingService:  NewEntityMappingService(db),
+	}
+}
+
+// SyncProduct creates or updates a Shopify product for a Shopware product
+// and returns the Shopify product ID. A mapped product is updated; otherwise
+// an existing Shopify product with the same SKU is adopted before falling
+// back to creating a new one. A mapped product that was deleted in Shopify
+// loses its mapping and is adopted or created again.
+func (s *ProductSyncService) SyncProduct(dataflow *models.Dataflow, sourceID string, productRequest *ProductCreateRequest, hash string) (string, error) {
+	connector := &dataflow.DestConnector
+
+	mapping, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeProduct, sourceID)
+	if err != nil {
+		return "", fmt.Errorf("error looking up product mapping: %w", err)
+	}
+
+	var productID string
+
+	switch {
+	case mapping != nil && mapping.LastSyncedHash == hash:
+		// Nothing changed since the last sync
+		return mapping.DestID, nil
+
+	case mapping != nil:
+		response, err := s.shopifyService.UpdateProduct(connector, mapping.DestID, productRequest)
+		if errors.Is(err, ErrShopifyProductNotFound) {
+			if err := s.mappingService.DeleteMapping(mapping); err != nil {
+				return "", fmt.Errorf("error deleting product mapping: %w", err)
+			}
+			productID, err = s.adoptOrCreate(connector, productRequest)
+			if err != nil {
+				return "", err
+			}
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("error updating product in Shopify: %w", err)
+		}
+		productID = response.Product.ID
+
+	default:
+		productID, err = s.adoptOrCreate(connector, productRequest)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if productID == "" {
+		return "", fmt.Errorf("shopify returned no product ID")
+	}
+
+	// The product is mapped even if its variants or media fail, so a retry
+	// updates it instead of creating it again; without the hash the retry is
+	// not skipped
+	detailErr := s.syncProductDetails(dataflow, productID, &productRequest.Product)
+	if detailErr != nil {
+		hash = ""
+	}
+
+	if err := s.mappingService.UpsertMapping(&models.EntityMapping{
+		SourceConnectorID: dataflow.SourceConnectorID,
+		DestConnectorID:   dataflow.DestConnectorID,
+		EntityType:        models.EntityTypeProduct,
+		SourceID:          sourceID,
+		DestID:            productID,
+		LastSyncedHash:    hash,
+	}); err != nil {
+		return productID, fmt.Errorf("error saving product mapping: %w", err)
+	}
+
+	if detailErr != nil {
+		return productID, detailErr
+	}
+
+	return productID, nil
+}
+
+// adoptOrCreate updates the Shopify product with the product's SKU, or
+// creates a new product if there is none, and returns its ID
+func (s *ProductSyncService) adoptOrCreate(connector *models.Connector, productRequest *ProductCreateRequest) (string, error) {
+	existingID, err := s.findBySKU(connector, productRequest)
+	if err != nil {
+		return "", err
+	}
+
+	if existingID != "" {
+		response, err := s.shopifyService.UpdateProduct(connector, existingID, productRequest)
+		if err != nil {
+			return "", fmt.Errorf("error updating product in Shopify: %w", err)
+		}
+		return response.Product.ID, nil
+	}
+
+	response, err := s.shopifyService.CreateProduct(connector, productRequest)
+	if err != nil {
+		return "", fmt.Errorf("error creating product in Shopify: %w", err)
+	}
+	return response.Product.ID, nil
+}
+
+// syncProductDetails syncs what the product mutations do not carry: the
+// variants, media and collections of a product
+func (s *ProductSyncService) syncProductDetails(dataflow *models.Dataflow, productID string, product *ShopifyProduct) error {
+	if err := s.syncVariants(dataflow, productID, product); err != nil {
+		return fmt.Errorf("error syncing variants: %w", err)
+	}
+	if err := s.syncMedia(dataflow, productID, product); err != nil {
+		return fmt.Errorf("error syncing media: %w", err)
+	}
+	if err := s.syncCollections(dataflow, productID, product); err != nil {
+		return fmt.Errorf("error syncing collections: %w", err)
+	}
+	return nil
+}
+
+// findBySKU returns the ID of a Shopify product that already carries the
+// product's SKU, or an empty string if there is none
+func (s *ProductSyncService) findBySKU(connector *models.Connector, productRequest *ProductCreateRequest) (string, error) {
+	sku := productSKU(&productRequest.Product)
+	if sku == "" {
+		return "", nil
+	}
+
+	existing, err := s.shopifyService.FindProductBySKU(connector, sku)
+	if err != nil {
+		if errors.Is(err, ErrShopifyProductNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error looking up product by SKU: %w", err)
+	}
+
+	return existing.Product.ID, nil
+}
+
+// productSKU returns the SKU of the first variant that has one
+func productSKU(product *ShopifyProduct) string {
+	for _, variant := range product.Variants {
+		if variant.SKU != "" {
+			return variant.SKU
+		}
+	}
+	return ""
+}
+
+// productBulkCreateMutation is run once for every line of a bulk product import
+const productBulkCreateMutation = `
+	mutation call($input: ProductInput!) {
+		productCreate(input: $input) {
+			product {
+				id
+			}
+			userErrors {
+				field
+				message
+			}
+		}
+	}
+`
+
+// BulkProductCreate is a product created by a bulk import
+type BulkProductCreate struct {
+	SourceID string
+	Request  *ProductCreateRequest
+	Hash     string
+}
+
+// BulkProductResult is the outcome of one product of a bulk import
+type BulkProductResult struct {
+	ProductID string
+	Err       error
+}
+
+// StartBulkCreate starts a Shopify bulk mutation that creates the products
+func (s *ProductSyncService) StartBulkCreate(dataflow *models.Dataflow, products []BulkProductCreate) (*BulkOperation, error) {
+	lines := make([][]byte, len(products))
+	for i, product := range products {
+		line, err := json.Marshal(map[string]interface{}{
+			"input": buildProductCreateInput(&product.Request.Product),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling bulk product: %w", err)
+		}
+		lines[i] = line
+	}
+
+	operation, err := s.shopifyService.RunBulkMutation(&dataflow.DestConnector, productBulkCreateMutation, lines)
+	if err != nil {
+		return nil, fmt.Errorf("error starting bulk product import: %w", err)
+	}
+
+	return operation, nil
+}
+
+// FinishBulkCreate waits for a bulk product import to finish and saves the
+// mappings of the created products. The products must be in the order they
+// were submitted; the results are returned in the same order.
+func (s *ProductSyncService) FinishBulkCreate(dataflow *models.Dataflow, operationID string, products []BulkProductCreate) ([]BulkProductResult, error) {
+	operation, err := s.shopifyService.WaitForBulkOperation(&dataflow.DestConnector, operationID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := s.shopifyService.GetBulkResults(operation)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BulkProductResult, len(products))
+	for i := range results {
+		results[i].Err = fmt.Errorf("no result from bulk operation (status %s %s)", operation.Status, operation.ErrorCode)
+	}
+
+	for _, line := range lines {
+		if line.LineNumber < 0 || line.LineNumber >= len(products) {
+			continue
+		}
+
+		results[line.LineNumber] = bulkProductResult(line)
+	}
+
+	for i, result := range results {
+		if result.Err != nil {
+			continue
+		}
+
+		// Bulk mutations only create the products; their variants and media
+		// are added one product at a time
+		hash := products[i].Hash
+		var detailErr error
+		if products[i].Request != nil {
+			detailErr = s.syncProductDetails(dataflow, result.ProductID, &products[i].Request.Product)
+			if detailErr != nil {
+				hash = ""
+			}
+		}
+
+		if err := s.mappingService.UpsertMapping(&models.EntityMapping{
+			SourceConnectorID: dataflow.SourceConnectorID,
+			DestConnectorID:   dataflow.DestConnectorID,
+			EntityType:        models.EntityTypeProduct,
+			SourceID:          products[i].SourceID,
+			DestID:            result.ProductID,
+			LastSyncedHash:    hash,
+		}); err != nil {
+			results[i].Err = fmt.Errorf("error saving product mapping: %w", err)
+			continue
+		}
+
+		if detailErr != nil {
+			results[i].Err = detailErr
+		}
+	}
+
+	return results, nil
+}
+
+// bulkProductResult reads the outcome of one productCreate call from a bulk
+// result line
+func bulkProductResult(line BulkResultLine) BulkProductResult {
+	if len(line.Errors) > 0 {
+		return BulkProductResult{Err: fmt.Errorf("error creating product: %s", line.Errors[0].Message)}
+	}
+
+	var data struct {
+		ProductCreate struct {
+			Product *struct {
+				ID string `json:"id"`
+			} `json:"product"`
+			UserErrors []ShopifyUserError `json:"userErrors"`
+		} `json:"productCreate"`
+	}
+
+	if err := json.Unmarshal(line.Data, &data); err != nil {
+		return BulkProductResult{Err: fmt.Errorf("error parsing bulk result: %w", err)}
+	}
+
+	if err := shopifyUserError("creating product", data.ProductCreate.UserErrors); err != nil {
+		return BulkProductResult{Err: err}
+	}
+
+	if data.ProductCreate.Product == nil || data.ProductCreate.Product.ID == "" {
+		return BulkProductResult{Err: fmt.Errorf("shopify returned no product ID")}
+	}
+
+	return BulkProductResult{ProductID: data.ProductCreate.Product.ID}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// referenceResolvers return the value of a reference for a Shopware ID, or
+// "" if the ID is unknown
+var referenceResolvers = map[string]func(*ShopwareReferenceData, string) string{
+	"country_code": (*ShopwareReferenceData).CountryCode,
+	"country_iso3": func(r *ShopwareReferenceData, id string) string {
+		return r.Countries[id].ISO3
+	},
+	"country_name": func(r *ShopwareReferenceData, id string) string {
+		return r.Countries[id].Name
+	},
+	"province_code": (*ShopwareReferenceData).ProvinceCode,
+	"province_name": func(r *ShopwareReferenceData, id string) string {
+		return r.States[id].Name
+	},
+	"currency_code": (*ShopwareReferenceData).CurrencyCode,
+	"salutation_key": func(r *ShopwareReferenceData, id string) string {
+		return r.Salutations[id].Key
+	},
+	"salutation_name": func(r *ShopwareReferenceData, id string) string {
+		return r.Salutations[id].Name
+	},
+}
+
+// ReferenceConfig is the transform config of a reference mapping
+type ReferenceConfig struct {
+	Reference string `json:"reference"` // What to resolve the ID to, e.g. country_code or currency_code
+}
+
+// parseReferenceConfig parses and validates a reference transform config
+func parseReferenceConfig(transformConfig string) (*ReferenceConfig, error) {
+	var config ReferenceConfig
+	if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
+		return nil, fmt.Errorf("invalid transform config: %w", err)
+	}
+
+	if _, ok := referenceResolvers[config.Reference]; !ok {
+		return nil, fmt.Errorf("reference transform does not support reference %q", config.Reference)
+	}
+
+	return &config, nil
+}
+
+// resolveReference replaces a Shopware country, country state, currency or
+// salutation ID with its code or name from the source connector's reference
+// data. An array of IDs resolves every ID. An unknown ID reloads the
+// reference data once, in case it was created after the data was cached.
+func (s *FieldMappingService) resolveReference(value interface{}, transformConfig string, ctx *transformContext) (interface{}, error) {
+	config, err := parseReferenceConfig(transformConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	connector, err := s.sourceConnector(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	references, err := s.shopwareService.ReferenceData(connector)
+	if err != nil {
+		return nil, err
+	}
+
+	reloaded := false
+	resolve := func(value interface{}) (interface{}, error) {
+		id, _ := value.(string)
+		if id == "" {
+			return nil, fmt.Errorf("empty ID for %s", config.Reference)
+		}
+		result := referenceResolvers[config.Reference](references, id)
+		if result == "" && !reloaded {
+			reloaded = true
+			references, err = s.shopwareService.ReloadReferenceData(connector, references)
+			if err != nil {
+				return nil, err
+			}
+			result = referenceResolvers[config.Reference](references, id)
+		}
+		if result == "" {
+			return nil, fmt.Errorf("no %s for ID %s", config.Reference, id)
+		}
+		return result, nil
+	}
+
+	if ids, ok := value.([]interface{}); ok {
+		results := make([]interface{}, len(ids))
+		for i, id := range ids {
+			result, err := resolve(id)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			results[i] = result
+		}
+		return results, nil
+	}
+
+	return resolve(value)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxReplayBatch is the largest number of migrations replayed by one request
+const maxReplayBatch = 500
+
+// ReplayService starts failed migrations again on request
+type ReplayService struct {
+	db              *gorm.DB
+	dataflowService *DataflowService
+	shopwareService *ShopwareService
+	executor        Executor
+}
+
+// NewReplayService creates a new replay service
+func NewReplayService(db *gorm.DB, shopwareService *ShopwareService, executor Executor) *ReplayService {
+	return &ReplayService{
+		db:              db,
+		dataflowService: NewDataflowService(db),
+		shopwareService: shopwareService,
+		executor:        executor,
+	}
+}
+
+// ReplayFilter selects the migrations of a dataflow to replay
+type ReplayFilter struct {
+	Status        models.MigrationStatus // Defaults to dead_letter
+	From          *time.Time             // Created at or after
+	To            *time.Time             // Created before
+	ErrorContains string                 // Substring of the error message
+	Limit         int
+}
+
+// ReplayResult summarizes a bulk replay
+type ReplayResult struct {
+	Matched  int             `json:"matched"`
+	Replayed int             `json:"replayed"`
+	Failed   map[uint]string `json:"failed,omitempty"` // Migration ID to the reason it was not replayed
+}
+
+// isReplayable reports whether migrations with the given status may be replayed
+func isReplayable(status models.MigrationStatus) bool {
+	switch status {
+	case models.MigrationStatusFailed, models.MigrationStatusRetrying, models.MigrationStatusDeadLetter:
+		return true
+	}
+	return false
+}
+
+// ReplayMigration starts a migration again from its stored source payload,
+// or from fresh source data if refetch is set
+func (s *ReplayService) ReplayMigration(dataflowID, migrationID uint, refetch bool) (*models.MigrationLog, error) {
+	dataflow, err := s.dataflowService.GetDataflow(dataflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrationLog models.MigrationLog
+	if err := s.db.Where("id = ? AND dataflow_id = ?", migrationID, dataflowID).First(&migrationLog).Error; err != nil {
+		return nil, err
+	}
+
+	if !isReplayable(migrationLog.Status) {
+		return nil, models.ErrInvalidReplayState
+	}
+
+	if err := s.replay(dataflow, &migrationLog, refetch); err != nil {
+		return nil, err
+	}
+
+	return s.dataflowService.GetMigrationLog(migrationLog.ID)
+}
+
+// ReplayMigrations replays the migrations of a dataflow that match the filter
+func (s *ReplayService) ReplayMigrations(dataflowID uint, filter ReplayFilter, refetch bool) (*ReplayResult, error) {
+	dataflow, err := s.dataflowService.GetDataflow(dataflowID)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Status == "" {
+		filter.Status = models.MigrationStatusDeadLetter
+	}
+	if !isReplayable(filter.Status) {
+		return nil, models.ErrInvalidReplayState
+	}
+	if filter.Limit < 1 || filter.Limit > maxReplayBatch {
+		filter.Limit = maxReplayBatch
+	}
+
+	query := s.db.Where("dataflow_id = ? AND status = ?", dataflowID, filter.Status)
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if filter.ErrorContains != "" {
+		// strpos matches the text literally, so % and _ are not wildcards
+		query = query.Where("strpos(lower(error_message), lower(?)) > 0", filter.ErrorContains)
+	}
+
+	var migrationLogs []models.MigrationLog
+	if err := query.Order("created_at ASC").Limit(filter.Limit).Find(&migrationLogs).Error; err != nil {
+		return nil, err
+	}
+
+	result := &ReplayResult{
+		Matched: len(migrationLogs),
+		Failed:  map[uint]string{},
+	}
+
+	for i := range migrationLogs {
+		if err := s.replay(dataflow, &migrationLogs[i], refetch); err != nil {
+			result.Failed[migrationLogs[i].ID] = err.Error()
+			continue
+		}
+		result.Replayed++
+	}
+
+	return result, nil
+}
+
+// replay resets a migration log and hands it to the executor. Its attempts
+// start over so the dataflow's retry policy applies again.
+func (s *ReplayService) replay(dataflow *models.Dataflow, migrationLog *models.MigrationLog, refetch bool) error {
+	previousStatus := migrationLog.Status
+
+	if refetch {
+		sourceData, err := sourceReader(s.db, s.shopwareService, dataflow).FetchSourceDataForDataflow(dataflow, migrationLog.SourceIdentifier)
+		if err != nil {
+			return fmt.Errorf("error fetching source data: %w", err)
+		}
+		migrationLog.SourcePayload = string(sourceData)
+	}
+
+	// Claim the migration so a concurrent replay or retry does not start it twice
+	claim := s.db.Model(&models.MigrationLog{}).
+		Where("id = ? AND status = ?", migrationLog.ID, previousStatus).
+		Updates(map[string]interface{}{
+			"status":         models.MigrationStatusPending,
+			"source_payload": migrationLog.SourcePayload,
+			"attempts":       0,
+			"next_retry_at":  nil,
+			"completed_at":   nil,
+		})
+	if claim.Error != nil {
+		return claim.Error
+	}
+	if claim.RowsAffected == 0 {
+		return models.ErrInvalidReplayState
+	}
+
+	if err := dispatchMigration(s.db, s.executor, migrationLog); err != nil {
+		s.db.Model(&models.MigrationLog{}).
+			Where("id = ?", migrationLog.ID).
+			Updates(map[string]interface{}{
+				"status":        previousStatus,
+				"attempts":      migrationLog.Attempts,
+				"next_retry_at": migrationLog.NextRetryAt,
+				"completed_at":  migrationLog.CompletedAt,
+			})
+		return fmt.Errorf("error starting execution: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/config"
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// RetryScheduler periodically starts new executions for failed migrations
+// whose next retry is due
+type RetryScheduler struct {
+	db           *gorm.DB
+	executor     Executor
+	interval     time.Duration
+	batchSize    int
+	pendingLease time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewRetryScheduler creates a new retry scheduler
+func NewRetryScheduler(db *gorm.DB, executor Executor, cfg config.RetryConfig) *RetryScheduler {
+	return &RetryScheduler{
+		db:           db,
+		executor:     executor,
+		interval:     cfg.Interval,
+		batchSize:    cfg.BatchSize,
+		pendingLease: cfg.PendingLease,
+		stop:         make(chan struct{}),
+	}
+}
+
+// Start starts the scheduler loop
+func (s *RetryScheduler) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the scheduler loop and waits for it to exit
+func (s *RetryScheduler) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// run retries due migrations on every tick until the scheduler is stopped
+func (s *RetryScheduler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if _, err := s.RetryDueMigrations(); err != nil {
+				fmt.Printf("Error retrying migrations: %v\n", err)
+			}
+			if _, err := s.RedispatchStaleMigrations(); err != nil {
+				fmt.Printf("Error dispatching stale migrations: %v\n", err)
+			}
+		}
+	}
+}
+
+// RetryDueMigrations starts executions for retrying migrations whose next
+// retry is due and returns how many were started. Migrations of inactive
+// dataflows wait until the dataflow is activated again.
+func (s *RetryScheduler) RetryDueMigrations() (int, error) {
+	var migrationLogs []models.MigrationLog
+
+	err := s.db.Joins("JOIN dataflows ON dataflows.id = migration_logs.dataflow_id AND dataflows.deleted_at IS NULL").
+		Where("migration_logs.status = ? AND migration_logs.next_retry_at <= ?", models.MigrationStatusRetrying, time.Now()).
+		Where("dataflows.status = ?", models.DataflowStatusActive).
+		Order("migration_logs.next_retry_at ASC").
+		Limit(s.batchSize).
+		Find(&migrationLogs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	started := 0
+	for i := range migrationLogs {
+		migrationLog := &migrationLogs[i]
+
+		// Claim the migration so no other instance retries it as well
+		claim := s.db.Model(&models.MigrationLog{}).
+			Where("id = ? AND status = ?", migrationLog.ID, models.MigrationStatusRetrying).
+			Updates(map[string]interface{}{
+				"status":        models.MigrationStatusPending,
+				"next_retry_at": nil,
+			})
+		if claim.Error != nil {
+			return started, claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			continue
+		}
+
+		if err := dispatchMigration(s.db, s.executor, migrationLog); err != nil {
+			// Put the migration back and try again on a later tick
+			nextRetryAt := time.Now().Add(s.interval)
+			s.db.Model(&models.MigrationLog{}).
+				Where("id = ?", migrationLog.ID).
+				Updates(map[string]interface{}{
+					"status":        models.MigrationStatusRetrying,
+					"next_retry_at": nextRetryAt,
+				})
+			fmt.Printf("Error starting retry of migration %d: %v\n", migrationLog.ID, err)
+			continue
+		}
+
+		started++
+	}
+
+	return started, nil
+}
+
+// RedispatchStaleMigrations dispatches locally queued migrations again that
+// stayed pending longer than the pending lease, e.g. because the process
+// restarted before a worker ran them, and returns how many were dispatched
+func (s *RetryScheduler) RedispatchStaleMigrations() (int, error) {
+	var migrationLogs []models.MigrationLog
+
+	err := s.db.Joins("JOIN dataflows ON dataflows.id = migration_logs.dataflow_id AND dataflows.deleted_at IS NULL").
+		Where("migration_logs.status = ? AND migration_logs.execution_arn LIKE ? AND migration_logs.updated_at <= ?",
+			models.MigrationStatusPending, "local:%", time.Now().Add(-s.pendingLease)).
+		Where("dataflows.status = ?", models.DataflowStatusActive).
+		Order("migration_logs.updated_at ASC").
+		Limit(s.batchSize).
+		Find(&migrationLogs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	started := 0
+	for i := range migrationLogs {
+		// A worker claims the migration when it runs, so a migration that
+		// is still queued as well only runs once
+		if err := dispatchMigration(s.db, s.executor, &migrationLogs[i]); err != nil {
+			fmt.Printf("Error dispatching stale migration %d: %v\n", migrationLogs[i].ID, err)
+			continue
+		}
+		started++
+	}
+
+	return started, nil
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// BulkOperationStatus represents the status of a Shopify bulk operation
+type BulkOperationStatus string
+
+// Bulk operation statuses reported by Shopify
+const (
+	BulkOperationStatusCreated   BulkOperationStatus = "CREATED"
+	BulkOperationStatusRunning   BulkOperationStatus = "RUNNING"
+	BulkOperationStatusCompleted BulkOperationStatus = "COMPLETED"
+	BulkOperationStatusFailed    BulkOperationStatus = "FAILED"
+	BulkOperationStatusCanceling BulkOperationStatus = "CANCELING"
+	BulkOperationStatusCanceled  BulkOperationStatus = "CANCELED"
+	BulkOperationStatusExpired   BulkOperationStatus = "EXPIRED"
+)
+
+// BulkOperation represents a Shopify bulk operation
+type BulkOperation struct {
+	ID             string              `json:"id"`
+	Status         BulkOperationStatus `json:"status"`
+	ErrorCode      string              `json:"errorCode"`
+	ObjectCount    string              `json:"objectCount"`
+	URL            string              `json:"url"`
+	PartialDataURL string              `json:"partialDataUrl"`
+}
+
+// Finished reports whether the bulk operation has stopped running
+func (o *BulkOperation) Finished() bool {
+	switch o.Status {
+	case BulkOperationStatusCompleted, BulkOperationStatusFailed,
+		BulkOperationStatusCanceled, BulkOperationStatusExpired:
+		return true
+	}
+	return false
+}
+
+// BulkResultLine is one line of a bulk mutation result file. LineNumber is
+// the zero-based line of the input file the result belongs to.
+type BulkResultLine struct {
+	LineNumber int             `json:"__lineNumber"`
+	Data       json.RawMessage `json:"data"`
+	Errors     []struct {
+		Message string `json:"message"`
+	} `json:"errors,omitempty"`
+}
+
+// stagedUploadTarget is where a staged upload file is posted
+type stagedUploadTarget struct {
+	URL         string `json:"url"`
+	ResourceURL string `json:"resourceUrl"`
+	Parameters  []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"parameters"`
+}
+
+// RunBulkMutation uploads the JSONL variables file and starts a bulk
+// mutation. Each line holds the variables for one call of the mutation.
+func (s *ShopifyService) RunBulkMutation(connector *models.Connector, mutation string, lines [][]byte) (*BulkOperation, error) {
+	var file bytes.Buffer
+	for _, line := range lines {
+		file.Write(line)
+		file.WriteByte('\n')
+	}
+
+	target, err := s.createStagedUpload(connector, "BULK_MUTATION_VARIABLES", "bulk_mutation.jsonl", "text/jsonl")
+	if err != nil {
+		return nil, err
+	}
+
+	stagedUploadPath, err := s.uploadStagedFile(target, "bulk_mutation.jsonl", file.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	variables := map[string]interface{}{
+		"mutation":         mutation,
+		"stagedUploadPath": stagedUploadPath,
+	}
+
+	query := `
+		mutation runBulkMutation($mutation: String!, $stagedUploadPath: String!) {
+			bulkOperationRunMutation(mutation: $mutation, stagedUploadPath: $stagedUploadPath) {
+				bulkOperation {
+					id
+					status
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, variables, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		BulkOperationRunMutation struct {
+			BulkOperation BulkOperation      `json:"bulkOperation"`
+			UserErrors    []ShopifyUserError `json:"userErrors"`
+		} `json:"bulkOperationRunMutation"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if err := shopifyUserError("starting bulk operation", result.BulkOperationRunMutation.UserErrors); err != nil {
+		return nil, err
+	}
+
+	return &result.BulkOperationRunMutation.BulkOperation, nil
+}
+
+// CurrentBulkOperation gets the shop's most recent bulk mutation
+func (s *ShopifyService) CurrentBulkOperation(connector *models.Connector) (*BulkOperation, error) {
+	query := `
+		query currentBulkOperation {
+			currentBulkOperation(type: MUTATION) {
+				id
+				status
+				errorCode
+				objectCount
+				url
+				partialDataUrl
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, nil, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		CurrentBulkOperation *BulkOperation `json:"currentBulkOperation"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if result.CurrentBulkOperation == nil {
+		return nil, fmt.Errorf("no bulk operation found")
+	}
+
+	return result.CurrentBulkOperation, nil
+}
+
+// GetBulkOperation gets a bulk operation by ID
+func (s *ShopifyService) GetBulkOperation(connector *models.Connector, operationID string) (*BulkOperation, error) {
+	variables := map[string]interface{}{
+		"id": operationID,
+	}
+
+	query := `
+		query getBulkOperation($id: ID!) {
+			node(id: $id) {
+				... on BulkOperation {
+					id
+					status
+					errorCode
+					objectCount
+					url
+					partialDataUrl
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, variables, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Node *BulkOperation `json:"node"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if result.Node == nil || result.Node.ID == "" {
+		return nil, fmt.Errorf("no bulk operation found: %s", operationID)
+	}
+
+	return result.Node, nil
+}
+
+// WaitForBulkOperation polls the bulk operation with the given ID until it
+// has finished. The current bulk operation is polled while it is the one;
+// once another operation has become current, such as one started by a
+// retry or by hand, the operation is looked up by its ID.
+func (s *ShopifyService) WaitForBulkOperation(connector *models.Connector, operationID string) (*BulkOperation, error) {
+	deadline := time.Now().Add(s.bulkTimeout)
+	current := true
+
+	for {
+		var operation *BulkOperation
+		var err error
+		if current {
+			operation, err = s.CurrentBulkOperation(connector)
+			if err == nil && operation.ID != operationID {
+				current = false
+			}
+		}
+		if !current {
+			operation, err = s.GetBulkOperation(connector, operationID)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if operation.Finished() {
+			return operation, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for bulk operation %s", operationID)
+		}
+
+		time.Sleep(s.bulkPollInterval)
+	}
+}
+
+// GetBulkResults downloads and parses the result file of a finished bulk
+// operation. A failed operation may still have a partial result file.
+func (s *ShopifyService) GetBulkResults(operation *BulkOperation) ([]BulkResultLine, error) {
+	url := operation.URL
+	if url == "" {
+		url = operation.PartialDataURL
+	}
+	if url == "" {
+		return nil, nil
+	}
+
+	resp, err := s.bulkClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading bulk results: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("bulk results download failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var results []BulkResultLine
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var result BulkResultLine
+		if err := json.Unmarshal(line, &result); err != nil {
+			return nil, fmt.Errorf("error parsing bulk result line: %w", err)
+		}
+		results = append(results, result)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading bulk results: %w", err)
+	}
+
+	return results, nil
+}
+
+// createStagedUpload reserves an upload target for a file, such as a bulk
+// mutation variables file or an image
+func (s *ShopifyService) createStagedUpload(connector *models.Connector, resource, filename, mimeType string) (*stagedUploadTarget, error) {
+	variables := map[string]interface{}{
+		"input": []map[string]interface{}{
+			{
+				"resource":   resource,
+				"filename":   filename,
+				"mimeType":   mimeType,
+				"httpMethod": "POST",
+			},
+		},
+	}
+
+	mutation := `
+		mutation stagedUploadsCreate($input: [StagedUploadInput!]!) {
+			stagedUploadsCreate(input: $input) {
+				stagedTargets {
+					url
+					resourceUrl
+					parameters {
+						name
+						value
+					}
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		StagedUploadsCreate struct {
+			StagedTargets []stagedUploadTarget `json:"stagedTargets"`
+			UserErrors    []ShopifyUserError   `json:"userErrors"`
+		} `json:"stagedUploadsCreate"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if err := shopifyUserError("creating staged upload", result.StagedUploadsCreate.UserErrors); err != nil {
+		return nil, err
+	}
+
+	if len(result.StagedUploadsCreate.StagedTargets) == 0 {
+		return nil, fmt.Errorf("shopify returned no staged upload target")
+	}
+
+	return &result.StagedUploadsCreate.StagedTargets[0], nil
+}
+
+// uploadStagedFile posts a file to a staged upload target and returns the
+// staged upload path that refers to it
+func (s *ShopifyService) uploadStagedFile(target *stagedUploadTarget, filename string, content []byte) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	stagedUploadPath := ""
+	for _, parameter := range target.Parameters {
+		if parameter.Name == "key" {
+			stagedUploadPath = parameter.Value
+		}
+		if err := writer.WriteField(parameter.Name, parameter.Value); err != nil {
+			return "", fmt.Errorf("error writing upload form: %w", err)
+		}
+	}
+
+	// The file must be the last field of the form
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return "", fmt.Errorf("error writing upload form: %w", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		return "", fmt.Errorf("error writing upload form: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("error writing upload form: %w", err)
+	}
+
+	if stagedUploadPath == "" {
+		return "", fmt.Errorf("staged upload target has no key parameter")
+	}
+
+	req, err := http.NewRequest(http.MethodPost, target.URL, &body)
+	if err != nil {
+		return "", fmt.Errorf("error creating upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := s.bulkClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error uploading staged file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("staged file upload failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return stagedUploadPath, nil
+}
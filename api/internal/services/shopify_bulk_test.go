@@ -0,0 +1,215 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// fakeBulkShopify serves the Shopify endpoints used by a bulk mutation: the
+// GraphQL API, the staged upload target and the result file
+type fakeBulkShopify struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu       sync.Mutex
+	uploaded string
+	polls    int
+	queries  []string
+}
+
+const fakeBulkOperationID = "gid://shopify/BulkOperation/1"
+
+func newFakeBulkShopify(t *testing.T) *fakeBulkShopify {
+	f := &fakeBulkShopify{t: t}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/api/2025-04/graphql.json", f.graphQL)
+	mux.HandleFunc("/upload", f.upload)
+	mux.HandleFunc("/results.jsonl", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data":{"productCreate":{"product":{"id":"gid://shopify/Product/11"}}},"__lineNumber":1}`+"\n")
+		io.WriteString(w, "\n")
+		io.WriteString(w, `{"data":{"productCreate":{"product":null}},"errors":[{"message":"Title can't be blank"}],"__lineNumber":0}`+"\n")
+	})
+
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeBulkShopify) graphQL(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		f.t.Errorf("decoding GraphQL request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var data interface{}
+	switch {
+	case strings.Contains(request.Query, "stagedUploadsCreate"):
+		f.queries = append(f.queries, "stagedUploadsCreate")
+		data = map[string]interface{}{
+			"stagedUploadsCreate": map[string]interface{}{
+				"stagedTargets": []map[string]interface{}{{
+					"url": f.server.URL + "/upload",
+					"parameters": []map[string]string{
+						{"name": "key", "value": "tmp/bulk_mutation.jsonl"},
+						{"name": "policy", "value": "signed"},
+					},
+				}},
+			},
+		}
+	case strings.Contains(request.Query, "bulkOperationRunMutation"):
+		f.queries = append(f.queries, "bulkOperationRunMutation")
+		if got := request.Variables["stagedUploadPath"]; got != "tmp/bulk_mutation.jsonl" {
+			f.t.Errorf("stagedUploadPath = %v, want the key of the staged upload", got)
+		}
+		data = map[string]interface{}{
+			"bulkOperationRunMutation": map[string]interface{}{
+				"bulkOperation": map[string]string{"id": fakeBulkOperationID, "status": "CREATED"},
+			},
+		}
+	case strings.Contains(request.Query, "currentBulkOperation"):
+		f.queries = append(f.queries, "currentBulkOperation")
+		f.polls++
+		// The operation runs on the first poll; then another operation
+		// becomes current and ours must be looked up by its ID
+		operation := map[string]string{"id": fakeBulkOperationID, "status": "RUNNING"}
+		if f.polls > 1 {
+			operation = map[string]string{"id": "gid://shopify/BulkOperation/2", "status": "RUNNING"}
+		}
+		data = map[string]interface{}{"currentBulkOperation": operation}
+	case strings.Contains(request.Query, "node(id: $id)"):
+		f.queries = append(f.queries, "node")
+		if got := request.Variables["id"]; got != fakeBulkOperationID {
+			f.t.Errorf("node id = %v, want %s", got, fakeBulkOperationID)
+		}
+		data = map[string]interface{}{
+			"node": map[string]string{
+				"id":     fakeBulkOperationID,
+				"status": "COMPLETED",
+				"url":    f.server.URL + "/results.jsonl",
+			},
+		}
+	default:
+		f.t.Errorf("unexpected GraphQL query: %s", request.Query)
+		http.Error(w, "unexpected query", http.StatusBadRequest)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func (f *fakeBulkShopify) upload(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		f.t.Errorf("parsing upload form: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if got := r.FormValue("policy"); got != "signed" {
+		f.t.Errorf("upload policy = %q, want the staged target's parameter", got)
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		f.t.Errorf("reading uploaded file: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	content, _ := io.ReadAll(file)
+	f.mu.Lock()
+	f.uploaded = string(content)
+	f.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func TestBulkMutationFlow(t *testing.T) {
+	fake := newFakeBulkShopify(t)
+
+	service := &ShopifyService{
+		httpClient:       fake.server.Client(),
+		bulkClient:       fake.server.Client(),
+		bulkPollInterval: time.Millisecond,
+		bulkTimeout:      5 * time.Second,
+	}
+	connector := &models.Connector{URL: fake.server.URL, AccessToken: "token"}
+
+	lines := [][]byte{
+		[]byte(`{"product":{"title":""}}`),
+		[]byte(`{"product":{"title":"Shirt"}}`),
+	}
+
+	operation, err := service.RunBulkMutation(connector, productBulkCreateMutation, lines)
+	if err != nil {
+		t.Fatalf("RunBulkMutation: %v", err)
+	}
+	if operation.ID != fakeBulkOperationID {
+		t.Fatalf("operation ID = %q, want %q", operation.ID, fakeBulkOperationID)
+	}
+
+	wantUpload := `{"product":{"title":""}}` + "\n" + `{"product":{"title":"Shirt"}}` + "\n"
+	if fake.uploaded != wantUpload {
+		t.Errorf("uploaded file = %q, want %q", fake.uploaded, wantUpload)
+	}
+
+	operation, err = service.WaitForBulkOperation(connector, operation.ID)
+	if err != nil {
+		t.Fatalf("WaitForBulkOperation: %v", err)
+	}
+	if operation.Status != BulkOperationStatusCompleted {
+		t.Fatalf("operation status = %s, want %s", operation.Status, BulkOperationStatusCompleted)
+	}
+
+	wantQueries := []string{"stagedUploadsCreate", "bulkOperationRunMutation", "currentBulkOperation", "currentBulkOperation", "node"}
+	if strings.Join(fake.queries, ",") != strings.Join(wantQueries, ",") {
+		t.Errorf("queries = %v, want %v", fake.queries, wantQueries)
+	}
+
+	results, err := service.GetBulkResults(operation)
+	if err != nil {
+		t.Fatalf("GetBulkResults: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d result lines, want 2", len(results))
+	}
+
+	// Results are not in input order; the line number ties them to the input
+	if results[0].LineNumber != 1 || len(results[0].Errors) != 0 {
+		t.Errorf("first result = line %d with %d errors, want line 1 without errors", results[0].LineNumber, len(results[0].Errors))
+	}
+	if !strings.Contains(string(results[0].Data), "gid://shopify/Product/11") {
+		t.Errorf("first result data = %s, want the created product", results[0].Data)
+	}
+	if results[1].LineNumber != 0 || len(results[1].Errors) != 1 || results[1].Errors[0].Message != "Title can't be blank" {
+		t.Errorf("second result = %+v, want line 0 with its error", results[1])
+	}
+}
+
+func TestGetBulkResultsWithoutFile(t *testing.T) {
+	service := &ShopifyService{}
+
+	results, err := service.GetBulkResults(&BulkOperation{Status: BulkOperationStatusFailed})
+	if err != nil {
+		t.Fatalf("GetBulkResults: %v", err)
+	}
+	if results != nil {
+		t.Errorf("results = %v, want none", results)
+	}
+}
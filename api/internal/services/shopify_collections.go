@@ -0,0 +1,256 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// maxCollectionProducts is the most products collectionAddProducts accepts
+// in one call
+const maxCollectionProducts = 250
+
+// ShopifyCollection represents a Shopify custom collection
+type ShopifyCollection struct {
+	Title    string                  `json:"title"`
+	BodyHTML string                  `json:"descriptionHtml,omitempty"`
+	Handle   string                  `json:"handle,omitempty"`
+	Image    *ShopifyCollectionImage `json:"image,omitempty"`
+	SEO      *ShopifySEO             `json:"seo,omitempty"`
+}
+
+// ShopifyCollectionImage represents the image of a Shopify collection
+type ShopifyCollectionImage struct {
+	Src     string `json:"src"`
+	AltText string `json:"altText,omitempty"`
+}
+
+// buildCollectionInput builds the GraphQL CollectionInput for a collection
+func buildCollectionInput(collection *ShopifyCollection) map[string]interface{} {
+	input := map[string]interface{}{
+		"title":           collection.Title,
+		"descriptionHtml": collection.BodyHTML,
+	}
+
+	if collection.Handle != "" {
+		input["handle"] = collection.Handle
+	}
+
+	if collection.Image != nil && collection.Image.Src != "" {
+		input["image"] = map[string]interface{}{
+			"src":     collection.Image.Src,
+			"altText": collection.Image.AltText,
+		}
+	}
+
+	if collection.SEO != nil && (collection.SEO.Title != "" || collection.SEO.Description != "") {
+		input["seo"] = map[string]interface{}{
+			"title":       collection.SEO.Title,
+			"description": collection.SEO.Description,
+		}
+	}
+
+	return input
+}
+
+// CreateCollection creates a custom collection and returns its ID
+func (s *ShopifyService) CreateCollection(connector *models.Connector, collection *ShopifyCollection) (string, error) {
+	variables := map[string]interface{}{
+		"input": buildCollectionInput(collection),
+	}
+
+	mutation := `
+		mutation createCollection($input: CollectionInput!) {
+			collectionCreate(input: $input) {
+				collection {
+					id
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	return s.writeCollection(connector, mutation, "collectionCreate", variables)
+}
+
+// UpdateCollection updates a custom collection and returns its ID
+func (s *ShopifyService) UpdateCollection(connector *models.Connector, collectionID string, collection *ShopifyCollection) (string, error) {
+	input := buildCollectionInput(collection)
+	input["id"] = collectionID
+
+	variables := map[string]interface{}{
+		"input": input,
+	}
+
+	mutation := `
+		mutation updateCollection($input: CollectionInput!) {
+			collectionUpdate(input: $input) {
+				collection {
+					id
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	return s.writeCollection(connector, mutation, "collectionUpdate", variables)
+}
+
+// writeCollection runs a collection create or update mutation
+func (s *ShopifyService) writeCollection(connector *models.Connector, mutation, field string, variables map[string]interface{}) (string, error) {
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return "", err
+	}
+
+	var result map[string]struct {
+		Collection *struct {
+			ID string `json:"id"`
+		} `json:"collection"`
+		UserErrors []ShopifyUserError `json:"userErrors"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return "", fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	payload := result[field]
+	if err := shopifyUserError("writing collection", payload.UserErrors); err != nil {
+		return "", err
+	}
+
+	if payload.Collection == nil {
+		return "", fmt.Errorf("shopify returned no collection")
+	}
+
+	return payload.Collection.ID, nil
+}
+
+// GetProductCollections gets the IDs of the collections a product is in
+func (s *ShopifyService) GetProductCollections(connector *models.Connector, productID string) ([]string, error) {
+	variables := map[string]interface{}{
+		"id": productID,
+	}
+
+	query := `
+		query getProductCollections($id: ID!) {
+			product(id: $id) {
+				collections(first: 250) {
+					nodes {
+						id
+					}
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, variables, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Product *struct {
+			Collections struct {
+				Nodes []struct {
+					ID string `json:"id"`
+				} `json:"nodes"`
+			} `json:"collections"`
+		} `json:"product"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if result.Product == nil {
+		return nil, fmt.Errorf("%w: %s", ErrShopifyProductNotFound, productID)
+	}
+
+	ids := make([]string, len(result.Product.Collections.Nodes))
+	for i, node := range result.Product.Collections.Nodes {
+		ids[i] = node.ID
+	}
+	return ids, nil
+}
+
+// AddProductsToCollection adds products to a custom collection, in batches
+// of at most maxCollectionProducts
+func (s *ShopifyService) AddProductsToCollection(connector *models.Connector, collectionID string, productIDs []string) error {
+	mutation := `
+		mutation addProductsToCollection($id: ID!, $productIds: [ID!]!) {
+			collectionAddProducts(id: $id, productIds: $productIds) {
+				collection {
+					id
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	return s.changeCollectionProducts(connector, mutation, "collectionAddProducts", "adding products to collection", collectionID, productIDs)
+}
+
+// RemoveProductsFromCollection removes products from a custom collection.
+// Shopify removes them asynchronously.
+func (s *ShopifyService) RemoveProductsFromCollection(connector *models.Connector, collectionID string, productIDs []string) error {
+	mutation := `
+		mutation removeProductsFromCollection($id: ID!, $productIds: [ID!]!) {
+			collectionRemoveProducts(id: $id, productIds: $productIds) {
+				job {
+					id
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	return s.changeCollectionProducts(connector, mutation, "collectionRemoveProducts", "removing products from collection", collectionID, productIDs)
+}
+
+// changeCollectionProducts runs a collection membership mutation in batches
+func (s *ShopifyService) changeCollectionProducts(connector *models.Connector, mutation, field, action, collectionID string, productIDs []string) error {
+	for start := 0; start < len(productIDs); start += maxCollectionProducts {
+		end := start + maxCollectionProducts
+		if end > len(productIDs) {
+			end = len(productIDs)
+		}
+
+		variables := map[string]interface{}{
+			"id":         collectionID,
+			"productIds": productIDs[start:end],
+		}
+
+		var response GraphQLResponse
+		if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+			return err
+		}
+
+		var result map[string]struct {
+			UserErrors []ShopifyUserError `json:"userErrors"`
+		}
+
+		if err := json.Unmarshal(response.Data, &result); err != nil {
+			return fmt.Errorf("error parsing GraphQL response: %w", err)
+		}
+
+		if err := shopifyUserError(action, result[field].UserErrors); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
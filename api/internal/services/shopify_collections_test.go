@@ -0,0 +1,84 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+func TestBuildCollectionInput(t *testing.T) {
+	tests := []struct {
+		collection ShopifyCollection
+		want       string
+	}{
+		{
+			ShopifyCollection{Title: "Shirts"},
+			`{"descriptionHtml":"","title":"Shirts"}`,
+		},
+		{
+			ShopifyCollection{
+				Title:    "Shirts",
+				BodyHTML: "All shirts",
+				Handle:   "shirts",
+				Image:    &ShopifyCollectionImage{Src: "https://shop.test/shirts.jpg", AltText: "Shirts"},
+				SEO:      &ShopifySEO{Title: "Buy shirts"},
+			},
+			`{"descriptionHtml":"All shirts","handle":"shirts","image":{"altText":"Shirts","src":"https://shop.test/shirts.jpg"},"seo":{"description":"","title":"Buy shirts"},"title":"Shirts"}`,
+		},
+		{
+			// Empty images and SEO are left out
+			ShopifyCollection{Title: "Shirts", Image: &ShopifyCollectionImage{}, SEO: &ShopifySEO{}},
+			`{"descriptionHtml":"","title":"Shirts"}`,
+		},
+	}
+
+	for _, test := range tests {
+		got, _ := json.Marshal(buildCollectionInput(&test.collection))
+		if string(got) != test.want {
+			t.Errorf("buildCollectionInput(%+v) = %s, want %s", test.collection, got, test.want)
+		}
+	}
+}
+
+func TestAddProductsToCollectionBatches(t *testing.T) {
+	var batches []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Variables struct {
+				ProductIDs []string `json:"productIds"`
+			} `json:"variables"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		batches = append(batches, len(request.Variables.ProductIDs))
+		w.Write([]byte(`{"data":{"collectionAddProducts":{"collection":{"id":"gid://shopify/Collection/1"},"userErrors":[]}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	service := &ShopifyService{httpClient: server.Client()}
+	connector := &models.Connector{URL: server.URL}
+
+	productIDs := make([]string, maxCollectionProducts+1)
+	for i := range productIDs {
+		productIDs[i] = fmt.Sprintf("gid://shopify/Product/%d", i)
+	}
+
+	if err := service.AddProductsToCollection(connector, "gid://shopify/Collection/1", productIDs); err != nil {
+		t.Fatalf("AddProductsToCollection: %v", err)
+	}
+	if len(batches) != 2 || batches[0] != maxCollectionProducts || batches[1] != 1 {
+		t.Errorf("batches = %v, want %d and 1 products", batches, maxCollectionProducts)
+	}
+}
+
+func TestCreateCollectionUserError(t *testing.T) {
+	service, connector := newFakeGraphQLShopify(t,
+		`{"data":{"collectionCreate":{"collection":null,"userErrors":[{"field":["title"],"message":"Title can't be blank"}]}}}`)
+
+	if _, err := service.CreateCollection(connector, &ShopifyCollection{}); err == nil {
+		t.Fatalf("CreateCollection succeeded, want the user error")
+	}
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// ShopifyMarketingConsent represents the email or SMS marketing consent of a
+// Shopify customer
+type ShopifyMarketingConsent struct {
+	MarketingState      string `json:"marketingState"`
+	MarketingOptInLevel string `json:"marketingOptInLevel,omitempty"`
+	ConsentUpdatedAt    string `json:"consentUpdatedAt,omitempty"`
+}
+
+// buildMarketingConsentInput builds the GraphQL input for a marketing consent
+func buildMarketingConsentInput(consent *ShopifyMarketingConsent) map[string]interface{} {
+	input := map[string]interface{}{
+		"marketingState": consent.MarketingState,
+	}
+	if consent.MarketingOptInLevel != "" {
+		input["marketingOptInLevel"] = consent.MarketingOptInLevel
+	}
+	if consent.ConsentUpdatedAt != "" {
+		input["consentUpdatedAt"] = consent.ConsentUpdatedAt
+	}
+	return input
+}
+
+// buildCustomerInput builds the GraphQL CustomerInput for a customer. The
+// addresses replace the customer's addresses; the first becomes the default.
+// Marketing consent can only be set this way on creation.
+func buildCustomerInput(customer *ShopifyCustomer, create bool) map[string]interface{} {
+	input := map[string]interface{}{
+		"firstName": customer.FirstName,
+		"lastName":  customer.LastName,
+		"email":     customer.Email,
+	}
+
+	if customer.Phone != "" {
+		input["phone"] = customer.Phone
+	}
+
+	if customer.Note != "" {
+		input["note"] = customer.Note
+	}
+
+	if customer.Tags != nil {
+		input["tags"] = customer.Tags
+	}
+
+	if customer.Addresses != nil {
+		addresses := make([]map[string]interface{}, len(customer.Addresses))
+		for i := range customer.Addresses {
+			addresses[i] = buildMailingAddress(&customer.Addresses[i])
+		}
+		input["addresses"] = addresses
+	}
+
+	if create {
+		if customer.EmailMarketingConsent != nil && customer.Email != "" {
+			input["emailMarketingConsent"] = buildMarketingConsentInput(customer.EmailMarketingConsent)
+		}
+		if customer.SMSMarketingConsent != nil && customer.Phone != "" {
+			input["smsMarketingConsent"] = buildMarketingConsentInput(customer.SMSMarketingConsent)
+		}
+	}
+
+	return input
+}
+
+// CreateCustomer creates a customer with its marketing consent and returns
+// its ID
+func (s *ShopifyService) CreateCustomer(connector *models.Connector, customer *ShopifyCustomer) (string, error) {
+	variables := map[string]interface{}{
+		"input": buildCustomerInput(customer, true),
+	}
+
+	mutation := `
+		mutation createCustomer($input: CustomerInput!) {
+			customerCreate(input: $input) {
+				customer {
+					id
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	return s.writeCustomer(connector, mutation, "customerCreate", variables)
+}
+
+// UpdateCustomer updates a customer and its marketing consent and returns
+// its ID
+func (s *ShopifyService) UpdateCustomer(connector *models.Connector, customerID string, customer *ShopifyCustomer) (string, error) {
+	input := buildCustomerInput(customer, false)
+	input["id"] = customerID
+
+	variables := map[string]interface{}{
+		"input": input,
+	}
+
+	mutation := `
+		mutation updateCustomer($input: CustomerInput!) {
+			customerUpdate(input: $input) {
+				customer {
+					id
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	id, err := s.writeCustomer(connector, mutation, "customerUpdate", variables)
+	if err != nil {
+		return "", err
+	}
+
+	if customer.EmailMarketingConsent != nil && customer.Email != "" {
+		if err := s.updateMarketingConsent(connector, id, "customerEmailMarketingConsentUpdate", "emailMarketingConsent", "CustomerEmailMarketingConsentUpdateInput", customer.EmailMarketingConsent); err != nil {
+			return id, err
+		}
+	}
+
+	if customer.SMSMarketingConsent != nil && customer.Phone != "" {
+		if err := s.updateMarketingConsent(connector, id, "customerSmsMarketingConsentUpdate", "smsMarketingConsent", "CustomerSmsMarketingConsentUpdateInput", customer.SMSMarketingConsent); err != nil {
+			return id, err
+		}
+	}
+
+	return id, nil
+}
+
+// writeCustomer runs a customer create or update mutation
+func (s *ShopifyService) writeCustomer(connector *models.Connector, mutation, field string, variables map[string]interface{}) (string, error) {
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return "", err
+	}
+
+	var result map[string]struct {
+		Customer *struct {
+			ID string `json:"id"`
+		} `json:"customer"`
+		UserErrors []ShopifyUserError `json:"userErrors"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return "", fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	payload := result[field]
+	if err := shopifyUserError("writing customer", payload.UserErrors); err != nil {
+		return "", err
+	}
+
+	if payload.Customer == nil {
+		return "", fmt.Errorf("shopify returned no customer")
+	}
+
+	return payload.Customer.ID, nil
+}
+
+// updateMarketingConsent runs one of the customer marketing consent
+// mutations, which are the only way to change consent after creation
+func (s *ShopifyService) updateMarketingConsent(connector *models.Connector, customerID, field, consentField, inputType string, consent *ShopifyMarketingConsent) error {
+	variables := map[string]interface{}{
+		"input": map[string]interface{}{
+			"customerId": customerID,
+			consentField: buildMarketingConsentInput(consent),
+		},
+	}
+
+	mutation := fmt.Sprintf(`
+		mutation updateMarketingConsent($input: %s!) {
+			%s(input: $input) {
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`, inputType, field)
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return err
+	}
+
+	var result map[string]struct {
+		UserErrors []ShopifyUserError `json:"userErrors"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	return shopifyUserError("updating marketing consent", result[field].UserErrors)
+}
+
+// FindCustomerByEmail returns the ID of the customer with an email address,
+// or "" if there is none
+func (s *ShopifyService) FindCustomerByEmail(connector *models.Connector, email string) (string, error) {
+	variables := map[string]interface{}{
+		"query": fmt.Sprintf("email:%q", strings.ToLower(email)),
+	}
+
+	query := `
+		query findCustomerByEmail($query: String!) {
+			customers(first: 1, query: $query) {
+				nodes {
+					id
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, variables, &response); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Customers struct {
+			Nodes []struct {
+				ID string `json:"id"`
+			} `json:"nodes"`
+		} `json:"customers"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return "", fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if len(result.Customers.Nodes) == 0 {
+		return "", nil
+	}
+	return result.Customers.Nodes[0].ID, nil
+}
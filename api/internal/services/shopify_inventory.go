@@ -0,0 +1,303 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// maxInventoryQuantities is the most quantities inventorySetQuantities
+// accepts in one call
+const maxInventoryQuantities = 250
+
+// InventoryQuantity is the available quantity of an inventory item at a
+// location
+type InventoryQuantity struct {
+	InventoryItemID string `json:"inventoryItemId"`
+	LocationID      string `json:"locationId"`
+	Quantity        int    `json:"quantity"`
+}
+
+// GetVariantInventoryItem gets the ID of a variant's inventory item
+func (s *ShopifyService) GetVariantInventoryItem(connector *models.Connector, variantID string) (string, error) {
+	variables := map[string]interface{}{
+		"id": variantID,
+	}
+
+	query := `
+		query getVariantInventoryItem($id: ID!) {
+			productVariant(id: $id) {
+				inventoryItem {
+					id
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, variables, &response); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		ProductVariant *struct {
+			InventoryItem struct {
+				ID string `json:"id"`
+			} `json:"inventoryItem"`
+		} `json:"productVariant"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return "", fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if result.ProductVariant == nil {
+		return "", fmt.Errorf("%w: variant %s does not exist", ErrShopifyProductNotFound, variantID)
+	}
+
+	return result.ProductVariant.InventoryItem.ID, nil
+}
+
+// FindInventoryItemBySKU finds the inventory item of the variant with a SKU
+func (s *ShopifyService) FindInventoryItemBySKU(connector *models.Connector, sku string) (string, error) {
+	variables := map[string]interface{}{
+		"query": fmt.Sprintf("sku:%s", sku),
+	}
+
+	query := `
+		query findInventoryItemBySKU($query: String!) {
+			productVariants(first: 1, query: $query) {
+				edges {
+					node {
+						sku
+						inventoryItem {
+							id
+						}
+					}
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, variables, &response); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		ProductVariants struct {
+			Edges []struct {
+				Node struct {
+					SKU           string `json:"sku"`
+					InventoryItem struct {
+						ID string `json:"id"`
+					} `json:"inventoryItem"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"productVariants"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return "", fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	// The search matches prefixes too, so check the SKU is exact
+	for _, edge := range result.ProductVariants.Edges {
+		if edge.Node.SKU == sku {
+			return edge.Node.InventoryItem.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w with SKU: %s", ErrShopifyProductNotFound, sku)
+}
+
+// SetInventoryQuantities sets the available quantities of inventory items,
+// in batches of at most maxInventoryQuantities
+func (s *ShopifyService) SetInventoryQuantities(connector *models.Connector, quantities []InventoryQuantity) error {
+	mutation := `
+		mutation setInventory($input: InventorySetQuantitiesInput!) {
+			inventorySetQuantities(input: $input) {
+				inventoryAdjustmentGroup {
+					id
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	for start := 0; start < len(quantities); start += maxInventoryQuantities {
+		end := start + maxInventoryQuantities
+		if end > len(quantities) {
+			end = len(quantities)
+		}
+
+		variables := map[string]interface{}{
+			"input": map[string]interface{}{
+				"name":                  "available",
+				"reason":                "correction",
+				"ignoreCompareQuantity": true,
+				"quantities":            quantities[start:end],
+			},
+		}
+
+		var response GraphQLResponse
+		if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+			return err
+		}
+
+		var result struct {
+			InventorySetQuantities struct {
+				UserErrors []ShopifyUserError `json:"userErrors"`
+			} `json:"inventorySetQuantities"`
+		}
+
+		if err := json.Unmarshal(response.Data, &result); err != nil {
+			return fmt.Errorf("error parsing GraphQL response: %w", err)
+		}
+
+		if err := shopifyUserError("setting inventory quantities", result.InventorySetQuantities.UserErrors); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ShopifyInventoryLevel is the available quantity of an inventory item at a
+// location
+type ShopifyInventoryLevel struct {
+	LocationID string `json:"locationId"`
+	Available  int    `json:"available"`
+}
+
+// ShopifyInventoryItem is an inventory item with its available quantities
+type ShopifyInventoryItem struct {
+	ID        string                  `json:"id"`
+	SKU       string                  `json:"sku"`
+	VariantID string                  `json:"variantId"`
+	Levels    []ShopifyInventoryLevel `json:"levels"`
+	Stock     int                     `json:"stock"` // Sum of the levels at the dataflow's locations
+}
+
+// GetInventoryItem gets an inventory item with its available quantity at
+// every location it is stocked at
+func (s *ShopifyService) GetInventoryItem(connector *models.Connector, inventoryItemID string) (*ShopifyInventoryItem, error) {
+	variables := map[string]interface{}{
+		"id": inventoryItemID,
+	}
+
+	query := `
+		query getInventoryItem($id: ID!) {
+			inventoryItem(id: $id) {
+				id
+				sku
+				variant {
+					id
+				}
+				inventoryLevels(first: 250) {
+					nodes {
+						location {
+							id
+						}
+						quantities(names: ["available"]) {
+							name
+							quantity
+						}
+					}
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, variables, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		InventoryItem *struct {
+			ID      string `json:"id"`
+			SKU     string `json:"sku"`
+			Variant *struct {
+				ID string `json:"id"`
+			} `json:"variant"`
+			InventoryLevels struct {
+				Nodes []struct {
+					Location struct {
+						ID string `json:"id"`
+					} `json:"location"`
+					Quantities []struct {
+						Name     string `json:"name"`
+						Quantity int    `json:"quantity"`
+					} `json:"quantities"`
+				} `json:"nodes"`
+			} `json:"inventoryLevels"`
+		} `json:"inventoryItem"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if result.InventoryItem == nil {
+		return nil, fmt.Errorf("no inventory item found: %s", inventoryItemID)
+	}
+
+	item := &ShopifyInventoryItem{
+		ID:     result.InventoryItem.ID,
+		SKU:    result.InventoryItem.SKU,
+		Levels: []ShopifyInventoryLevel{},
+	}
+	if result.InventoryItem.Variant != nil {
+		item.VariantID = result.InventoryItem.Variant.ID
+	}
+
+	for _, node := range result.InventoryItem.InventoryLevels.Nodes {
+		level := ShopifyInventoryLevel{LocationID: node.Location.ID}
+		for _, quantity := range node.Quantities {
+			if quantity.Name == "available" {
+				level.Available = quantity.Quantity
+			}
+		}
+		item.Levels = append(item.Levels, level)
+	}
+
+	return item, nil
+}
+
+// FetchSourceDataForDataflow fetches the source document of a Shopify to
+// Shopware dataflow as JSON. Only inventory dataflows read from Shopify:
+// their document is the inventory item, with the stock summed over the
+// dataflow's locations.
+func (s *ShopifyService) FetchSourceDataForDataflow(dataflow *models.Dataflow, id string) ([]byte, error) {
+	if dataflow.Type != models.DataflowTypeInventory {
+		return nil, fmt.Errorf("%w: %s", models.ErrUnsupportedDirection, dataflow.Type)
+	}
+
+	item, err := s.GetInventoryItem(&dataflow.SourceConnector, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get inventory item data: %w", err)
+	}
+
+	locations := make(map[string]bool)
+	if dataflow.Inventory != nil {
+		for _, location := range dataflow.Inventory.Locations {
+			locations[location.LocationID] = true
+		}
+	}
+	for _, level := range item.Levels {
+		if locations[level.LocationID] {
+			item.Stock += level.Available
+		}
+	}
+
+	sourceData, err := json.Marshal(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal inventory item data: %w", err)
+	}
+	return sourceData, nil
+}
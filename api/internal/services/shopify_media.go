@@ -0,0 +1,276 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// ShopifyExistingMedia is a media item a Shopify product already has
+type ShopifyExistingMedia struct {
+	ID     string `json:"id"`
+	Alt    string `json:"alt"`
+	Status string `json:"status"`
+}
+
+// GetProductMedia gets the media of a product in their order
+func (s *ShopifyService) GetProductMedia(connector *models.Connector, productID string) ([]ShopifyExistingMedia, error) {
+	variables := map[string]interface{}{
+		"id": productID,
+	}
+
+	query := `
+		query getProductMedia($id: ID!) {
+			product(id: $id) {
+				media(first: 250) {
+					nodes {
+						id
+						alt
+						status
+					}
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, variables, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Product *struct {
+			Media struct {
+				Nodes []ShopifyExistingMedia `json:"nodes"`
+			} `json:"media"`
+		} `json:"product"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if result.Product == nil {
+		return nil, fmt.Errorf("%w: %s", ErrShopifyProductNotFound, productID)
+	}
+
+	return result.Product.Media.Nodes, nil
+}
+
+// CreateProductMedia attaches media to a product and returns their IDs in
+// the order given. Shopify fetches each file from its original source.
+func (s *ShopifyService) CreateProductMedia(connector *models.Connector, productID string, media []ShopifyMedia) ([]string, error) {
+	inputs := make([]map[string]interface{}, len(media))
+	for i, item := range media {
+		contentType := item.MediaContentType
+		if contentType == "" {
+			contentType = "IMAGE"
+		}
+		inputs[i] = map[string]interface{}{
+			"originalSource":   item.OriginalSource,
+			"alt":              item.Alt,
+			"mediaContentType": contentType,
+		}
+	}
+
+	variables := map[string]interface{}{
+		"productId": productID,
+		"media":     inputs,
+	}
+
+	mutation := `
+		mutation createProductMedia($productId: ID!, $media: [CreateMediaInput!]!) {
+			productCreateMedia(productId: $productId, media: $media) {
+				media {
+					id
+				}
+				mediaUserErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		ProductCreateMedia struct {
+			Media []struct {
+				ID string `json:"id"`
+			} `json:"media"`
+			MediaUserErrors []ShopifyUserError `json:"mediaUserErrors"`
+		} `json:"productCreateMedia"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if err := shopifyUserError("creating product media", result.ProductCreateMedia.MediaUserErrors); err != nil {
+		return nil, err
+	}
+
+	if len(result.ProductCreateMedia.Media) != len(media) {
+		return nil, fmt.Errorf("shopify created %d of %d media", len(result.ProductCreateMedia.Media), len(media))
+	}
+
+	ids := make([]string, len(media))
+	for i, item := range result.ProductCreateMedia.Media {
+		ids[i] = item.ID
+	}
+	return ids, nil
+}
+
+// UpdateProductMediaAlt sets the alt text of product media
+func (s *ShopifyService) UpdateProductMediaAlt(connector *models.Connector, productID string, alts map[string]string) error {
+	inputs := make([]map[string]interface{}, 0, len(alts))
+	for id, alt := range alts {
+		inputs = append(inputs, map[string]interface{}{
+			"id":  id,
+			"alt": alt,
+		})
+	}
+
+	variables := map[string]interface{}{
+		"productId": productID,
+		"media":     inputs,
+	}
+
+	mutation := `
+		mutation updateProductMedia($productId: ID!, $media: [UpdateMediaInput!]!) {
+			productUpdateMedia(productId: $productId, media: $media) {
+				mediaUserErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return err
+	}
+
+	var result struct {
+		ProductUpdateMedia struct {
+			MediaUserErrors []ShopifyUserError `json:"mediaUserErrors"`
+		} `json:"productUpdateMedia"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	return shopifyUserError("updating product media", result.ProductUpdateMedia.MediaUserErrors)
+}
+
+// DeleteProductMedia removes media from a product
+func (s *ShopifyService) DeleteProductMedia(connector *models.Connector, productID string, ids []string) error {
+	variables := map[string]interface{}{
+		"productId": productID,
+		"mediaIds":  ids,
+	}
+
+	mutation := `
+		mutation deleteProductMedia($productId: ID!, $mediaIds: [ID!]!) {
+			productDeleteMedia(productId: $productId, mediaIds: $mediaIds) {
+				deletedMediaIds
+				mediaUserErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return err
+	}
+
+	var result struct {
+		ProductDeleteMedia struct {
+			MediaUserErrors []ShopifyUserError `json:"mediaUserErrors"`
+		} `json:"productDeleteMedia"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	return shopifyUserError("deleting product media", result.ProductDeleteMedia.MediaUserErrors)
+}
+
+// ReorderProductMedia puts the media of a product in the given order. The
+// first media item is the product's featured image. Shopify applies the
+// order asynchronously.
+func (s *ShopifyService) ReorderProductMedia(connector *models.Connector, productID string, ids []string) error {
+	moves := make([]map[string]interface{}, len(ids))
+	for i, id := range ids {
+		moves[i] = map[string]interface{}{
+			"id":          id,
+			"newPosition": strconv.Itoa(i),
+		}
+	}
+
+	variables := map[string]interface{}{
+		"id":    productID,
+		"moves": moves,
+	}
+
+	mutation := `
+		mutation reorderProductMedia($id: ID!, $moves: [MoveInput!]!) {
+			productReorderMedia(id: $id, moves: $moves) {
+				job {
+					id
+				}
+				mediaUserErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return err
+	}
+
+	var result struct {
+		ProductReorderMedia struct {
+			MediaUserErrors []ShopifyUserError `json:"mediaUserErrors"`
+		} `json:"productReorderMedia"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	return shopifyUserError("reordering product media", result.ProductReorderMedia.MediaUserErrors)
+}
+
+// StageMediaFile uploads a file Shopify cannot fetch itself and returns the
+// URL to create media from
+func (s *ShopifyService) StageMediaFile(connector *models.Connector, filename, mimeType string, content []byte) (string, error) {
+	target, err := s.createStagedUpload(connector, "IMAGE", filename, mimeType)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := s.uploadStagedFile(target, filename, content); err != nil {
+		return "", err
+	}
+
+	if target.ResourceURL == "" {
+		return "", fmt.Errorf("staged upload target has no resource URL")
+	}
+	return target.ResourceURL, nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// shopPricingTTL is how long a shop's currency and tax setting are cached
+const shopPricingTTL = time.Hour
+
+// ShopifyVariantPrice is the price of a variant in one currency
+type ShopifyVariantPrice struct {
+	Currency       string `json:"currency"`
+	Gross          string `json:"gross"`
+	Net            string `json:"net"`
+	CompareAtGross string `json:"compareAtGross,omitempty"`
+	CompareAtNet   string `json:"compareAtNet,omitempty"`
+}
+
+// ShopifyVariantAdvancedPrice is a Shopware advanced price of a variant:
+// the price under a rule from a minimum quantity on, in one currency
+type ShopifyVariantAdvancedPrice struct {
+	RuleID          string `json:"ruleId"`
+	MinimumQuantity int    `json:"minimumQuantity"`
+	Currency        string `json:"currency"`
+	Gross           string `json:"gross"`
+	Net             string `json:"net"`
+}
+
+// amounts returns the price and compare-at price of a variant in a
+// currency, gross if the shop's prices include taxes and net otherwise
+func (v *ShopifyVariant) amounts(currency string, taxesIncluded bool) (string, string, bool) {
+	for _, price := range v.Prices {
+		if price.Currency != currency {
+			continue
+		}
+		if taxesIncluded {
+			return price.Gross, price.CompareAtGross, true
+		}
+		return price.Net, price.CompareAtNet, true
+	}
+	return "", "", false
+}
+
+// ShopPricing is the currency and tax setting of a Shopify shop
+type ShopPricing struct {
+	CurrencyCode  string `json:"currencyCode"`
+	TaxesIncluded bool   `json:"taxesIncluded"`
+}
+
+// shopPricings holds the pricing of Shopify connectors
+var shopPricings = newTTLCache[string, ShopPricing](shopPricingTTL, 0)
+
+// GetShopPricing gets the currency of a shop and whether its prices include
+// taxes
+func (s *ShopifyService) GetShopPricing(connector *models.Connector) (*ShopPricing, error) {
+	key := fmt.Sprintf("%d|%s", connector.ID, connector.URL)
+
+	if pricing, ok := shopPricings.get(key); ok {
+		return &pricing, nil
+	}
+
+	query := `
+		query getShopPricing {
+			shop {
+				currencyCode
+				taxesIncluded
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, nil, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Shop ShopPricing `json:"shop"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	shopPricings.put(key, result.Shop)
+
+	return &result.Shop, nil
+}
+
+// PriceListPrice is the fixed price of a variant in a price list
+type PriceListPrice struct {
+	VariantID      string
+	Amount         string
+	CompareAtPrice string
+}
+
+// QuantityPriceBreak is the price of a variant in a price list from a
+// minimum quantity on
+type QuantityPriceBreak struct {
+	VariantID       string
+	MinimumQuantity int
+	Amount          string
+}
+
+// UpdatePriceListPrices replaces the prices of variants in a price list.
+// Variants without a fixed price lose theirs, and the quantity breaks of all
+// variants are replaced.
+func (s *ShopifyService) UpdatePriceListPrices(connector *models.Connector, priceListID, currency string, variantIDs []string, prices []PriceListPrice, breaks []QuantityPriceBreak) error {
+	priced := make(map[string]bool, len(prices))
+	pricesToAdd := make([]map[string]interface{}, len(prices))
+	for i, price := range prices {
+		priced[price.VariantID] = true
+		input := map[string]interface{}{
+			"variantId": price.VariantID,
+			"price": map[string]interface{}{
+				"amount":       price.Amount,
+				"currencyCode": currency,
+			},
+		}
+		if price.CompareAtPrice != "" {
+			input["compareAtPrice"] = map[string]interface{}{
+				"amount":       price.CompareAtPrice,
+				"currencyCode": currency,
+			}
+		}
+		pricesToAdd[i] = input
+	}
+
+	pricesToDelete := []string{}
+	for _, id := range variantIDs {
+		if !priced[id] {
+			pricesToDelete = append(pricesToDelete, id)
+		}
+	}
+
+	breaksToAdd := make([]map[string]interface{}, len(breaks))
+	for i, priceBreak := range breaks {
+		breaksToAdd[i] = map[string]interface{}{
+			"variantId":       priceBreak.VariantID,
+			"minimumQuantity": priceBreak.MinimumQuantity,
+			"price": map[string]interface{}{
+				"amount":       priceBreak.Amount,
+				"currencyCode": currency,
+			},
+		}
+	}
+
+	variables := map[string]interface{}{
+		"priceListId": priceListID,
+		"input": map[string]interface{}{
+			"pricesToAdd":                            pricesToAdd,
+			"pricesToDeleteByVariantId":              pricesToDelete,
+			"quantityPriceBreaksToAdd":               breaksToAdd,
+			"quantityPriceBreaksToDelete":            []string{},
+			"quantityPriceBreaksToDeleteByVariantId": variantIDs,
+			"quantityRulesToAdd":                     []interface{}{},
+			"quantityRulesToDeleteByVariantId":       []string{},
+		},
+	}
+
+	mutation := `
+		mutation updatePriceListPrices($priceListId: ID!, $input: QuantityPricingByVariantUpdateInput!) {
+			quantityPricingByVariantUpdate(priceListId: $priceListId, input: $input) {
+				productVariants {
+					id
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return err
+	}
+
+	var result struct {
+		QuantityPricingByVariantUpdate struct {
+			UserErrors []ShopifyUserError `json:"userErrors"`
+		} `json:"quantityPricingByVariantUpdate"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	return shopifyUserError("updating price list prices", result.QuantityPricingByVariantUpdate.UserErrors)
+}
@@ -3,19 +3,29 @@ package services
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
 	"gorm.io/gorm"
 )
 
+// ErrShopifyProductNotFound is returned when a product lookup finds no product
+var ErrShopifyProductNotFound = errors.New("no product found")
+
 // ShopifyService handles Shopify API operations
 type ShopifyService struct {
 	db         *gorm.DB
 	httpClient *http.Client
+
+	// Bulk operations transfer large files and run for a long time
+	bulkClient       *http.Client
+	bulkPollInterval time.Duration
+	bulkTimeout      time.Duration
 }
 
 // NewShopifyService creates a new Shopify service
@@ -25,6 +35,11 @@ func NewShopifyService(db *gorm.DB) *ShopifyService {
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		bulkClient: &http.Client{
+			Timeout: 10 * time.Minute,
+		},
+		bulkPollInterval: 5 * time.Second,
+		bulkTimeout:      2 * time.Hour,
 	}
 }
 
@@ -40,16 +55,19 @@ type OrderCreateRequest struct {
 
 // ShopifyProduct represents a Shopify product
 type ShopifyProduct struct {
-	Title            string            `json:"title"`
-	BodyHTML         string            `json:"bodyHtml,omitempty"`
-	Vendor           string            `json:"vendor,omitempty"`
-	ProductType      string            `json:"productType,omitempty"`
-	Tags             []string          `json:"tags,omitempty"`
-	Status           string            `json:"status,omitempty"`
-	Images           []ShopifyImage    `json:"images,omitempty"`
-	Variants         []ShopifyVariant  `json:"variants,omitempty"`
-	Options          []ShopifyOption   `json:"options,omitempty"`
-	MetafieldsGlobal ShopifyMetafields `json:"metafields,omitempty"`
+	Title       string             `json:"title"`
+	BodyHTML    string             `json:"descriptionHtml,omitempty"`
+	Vendor      string             `json:"vendor,omitempty"`
+	ProductType string             `json:"productType,omitempty"`
+	Tags        []string           `json:"tags,omitempty"`
+	Status      string             `json:"status,omitempty"`
+	Images      []ShopifyImage     `json:"images,omitempty"`
+	Media       []ShopifyMedia     `json:"media,omitempty"`
+	Variants    []ShopifyVariant   `json:"variants,omitempty"`
+	Options     []ShopifyOption    `json:"options,omitempty"`
+	Metafields  []ShopifyMetafield `json:"metafields,omitempty"`
+	Collections []string           `json:"collections,omitempty"` // Shopware category IDs, resolved to synced collections
+	SEO         *ShopifySEO        `json:"seo,omitempty"`
 }
 
 // ShopifyImage represents a Shopify product image
@@ -59,6 +77,20 @@ type ShopifyImage struct {
 	Position int    `json:"position,omitempty"`
 }
 
+// ShopifyMedia represents a Shopify product media item. The source ID and
+// checksum identify the Shopware file and are not sent to Shopify.
+type ShopifyMedia struct {
+	OriginalSource   string `json:"originalSource"`
+	Alt              string `json:"alt,omitempty"`
+	MediaContentType string `json:"mediaContentType,omitempty"`
+	Position         int    `json:"position,omitempty"`
+	SourceID         string `json:"sourceId,omitempty"`
+	Checksum         string `json:"checksum,omitempty"`
+	Private          bool   `json:"private,omitempty"` // Not publicly reachable, so uploaded instead of fetched by Shopify
+	MimeType         string `json:"mimeType,omitempty"`
+	FileName         string `json:"fileName,omitempty"`
+}
+
 // ShopifyVariant represents a Shopify product variant
 type ShopifyVariant struct {
 	Title               string  `json:"title,omitempty"`
@@ -75,6 +107,18 @@ type ShopifyVariant struct {
 	WeightUnit          string  `json:"weightUnit,omitempty"`
 	InventoryQuantity   int     `json:"inventoryQuantity,omitempty"`
 	RequiresShipping    bool    `json:"requiresShipping,omitempty"`
+
+	OptionValues []ShopifyVariantOptionValue `json:"optionValues,omitempty"`
+	SourceID     string                      `json:"sourceId,omitempty"` // Shopware product the variant is synced from; not sent to Shopify
+
+	Prices         []ShopifyVariantPrice         `json:"prices,omitempty"`         // Prices by currency, resolved against the shop settings
+	AdvancedPrices []ShopifyVariantAdvancedPrice `json:"advancedPrices,omitempty"` // Rule and quantity based prices, for price lists
+}
+
+// ShopifyVariantOptionValue is the value a variant has for a product option
+type ShopifyVariantOptionValue struct {
+	OptionName string `json:"optionName"`
+	Name       string `json:"name"`
 }
 
 // ShopifyOption represents a Shopify product option
@@ -83,8 +127,16 @@ type ShopifyOption struct {
 	Values []string `json:"values"`
 }
 
-// ShopifyMetafields represents Shopify product metafields
-type ShopifyMetafields struct {
+// ShopifyMetafield represents a Shopify product metafield
+type ShopifyMetafield struct {
+	Namespace string      `json:"namespace"`
+	Key       string      `json:"key"`
+	Value     interface{} `json:"value"`
+	Type      string      `json:"type"`
+}
+
+// ShopifySEO represents Shopify product SEO information
+type ShopifySEO struct {
 	Title       string `json:"title,omitempty"`
 	Description string `json:"description,omitempty"`
 }
@@ -108,23 +160,33 @@ type ProductCreateResponse struct {
 
 // ShopifyOrder represents a Shopify order
 type ShopifyOrder struct {
-	Email               string            `json:"email,omitempty"`
-	Gateway             string            `json:"gateway,omitempty"`
-	Test                bool              `json:"test,omitempty"`
-	TotalPrice          string            `json:"totalPrice,omitempty"`
-	SubtotalPrice       string            `json:"subtotalPrice,omitempty"`
-	TotalTax            string            `json:"totalTax,omitempty"`
-	Currency            string            `json:"currency,omitempty"`
-	FinancialStatus     string            `json:"financialStatus,omitempty"`
-	Confirmed           bool              `json:"confirmed,omitempty"`
-	TotalDiscounts      string            `json:"totalDiscounts,omitempty"`
-	TotalLineItemsPrice string            `json:"totalLineItemsPrice,omitempty"`
-	BillingAddress      ShopifyAddress    `json:"billingAddress,omitempty"`
-	ShippingAddress     ShopifyAddress    `json:"shippingAddress,omitempty"`
-	LineItems           []ShopifyLineItem `json:"lineItems"`
-	Customer            ShopifyCustomer   `json:"customer,omitempty"`
-	Note                string            `json:"note,omitempty"`
-	Tags                []string          `json:"tags,omitempty"`
+	Email               string                `json:"email,omitempty"`
+	Gateway             string                `json:"gateway,omitempty"`
+	Test                bool                  `json:"test,omitempty"`
+	TotalPrice          string                `json:"totalPrice,omitempty"`
+	SubtotalPrice       string                `json:"subtotalPrice,omitempty"`
+	TotalTax            string                `json:"totalTax,omitempty"`
+	Currency            string                `json:"currency,omitempty"`
+	FinancialStatus     string                `json:"financialStatus,omitempty"`
+	Confirmed           bool                  `json:"confirmed,omitempty"`
+	TotalDiscounts      string                `json:"totalDiscounts,omitempty"`
+	TotalLineItemsPrice string                `json:"totalLineItemsPrice,omitempty"`
+	BillingAddress      ShopifyAddress        `json:"billingAddress,omitempty"`
+	ShippingAddress     ShopifyAddress        `json:"shippingAddress,omitempty"`
+	LineItems           []ShopifyLineItem     `json:"lineItems"`
+	Customer            ShopifyCustomer       `json:"customer,omitempty"`
+	Note                string                `json:"note,omitempty"`
+	Tags                []string              `json:"tags,omitempty"`
+	ProcessedAt         string                `json:"processedAt,omitempty"`
+	ShippingLines       []ShopifyShippingLine `json:"shippingLines,omitempty"`
+	DiscountCode        *ShopifyOrderDiscount `json:"discountCode,omitempty"`
+}
+
+// ShopifyOrderDiscount represents a fixed discount applied to a Shopify
+// order under a discount code
+type ShopifyOrderDiscount struct {
+	Code   string `json:"code"`
+	Amount string `json:"amount"`
 }
 
 // ShopifyAddress represents a Shopify address
@@ -133,6 +195,7 @@ type ShopifyAddress struct {
 	LastName     string `json:"lastName"`
 	Address1     string `json:"address1"`
 	Address2     string `json:"address2,omitempty"`
+	Company      string `json:"company,omitempty"`
 	City         string `json:"city"`
 	Province     string `json:"province,omitempty"`
 	Country      string `json:"country"`
@@ -143,8 +206,11 @@ type ShopifyAddress struct {
 	Default      bool   `json:"default,omitempty"`
 }
 
-// ShopifyLineItem represents a Shopify order line item
+// ShopifyLineItem represents a Shopify order line item. SourceID is the
+// Shopware product of the item; it is resolved to VariantID before the order
+// is created.
 type ShopifyLineItem struct {
+	SourceID   string            `json:"sourceId,omitempty"`
 	VariantID  string            `json:"variantId,omitempty"`
 	ProductID  string            `json:"productId,omitempty"`
 	Title      string            `json:"title"`
@@ -164,6 +230,12 @@ type ShopifyTaxLine struct {
 	Rate  string `json:"rate"`
 }
 
+// ShopifyShippingLine represents a Shopify order shipping line
+type ShopifyShippingLine struct {
+	Title string `json:"title"`
+	Price string `json:"price"`
+}
+
 // ShopifyProperty represents a Shopify line item property
 type ShopifyProperty struct {
 	Name  string `json:"name"`
@@ -172,11 +244,16 @@ type ShopifyProperty struct {
 
 // ShopifyCustomer represents a Shopify customer
 type ShopifyCustomer struct {
-	FirstName        string `json:"firstName"`
-	LastName         string `json:"lastName"`
-	Email            string `json:"email"`
-	Phone            string `json:"phone,omitempty"`
-	AcceptsMarketing bool   `json:"acceptsMarketing,omitempty"`
+	FirstName             string                   `json:"firstName"`
+	LastName              string                   `json:"lastName"`
+	Email                 string                   `json:"email"`
+	Phone                 string                   `json:"phone,omitempty"`
+	AcceptsMarketing      bool                     `json:"acceptsMarketing,omitempty"`
+	Note                  string                   `json:"note,omitempty"`
+	Tags                  []string                 `json:"tags,omitempty"`
+	Addresses             []ShopifyAddress         `json:"addresses,omitempty"`
+	EmailMarketingConsent *ShopifyMarketingConsent `json:"emailMarketingConsent,omitempty"`
+	SMSMarketingConsent   *ShopifyMarketingConsent `json:"smsMarketingConsent,omitempty"`
 }
 
 // OrderCreateResponse represents a Shopify order create response
@@ -198,7 +275,10 @@ type OrderCreateResponse struct {
 type GraphQLResponse struct {
 	Data   json.RawMessage `json:"data"`
 	Errors []struct {
-		Message string `json:"message"`
+		Message    string `json:"message"`
+		Extensions struct {
+			Code string `json:"code"`
+		} `json:"extensions"`
 	} `json:"errors,omitempty"`
 }
 
@@ -227,28 +307,14 @@ func (s *ShopifyService) TestConnection(connector *models.Connector) error {
 		return err
 	}
 
-	// Check if there are any errors in the response
-	if len(response.Errors) > 0 {
-		return fmt.Errorf("GraphQL error: %s", response.Errors[0].Message)
-	}
-
 	return nil
 }
 
 // CreateProduct creates a product in Shopify using GraphQL
 func (s *ShopifyService) CreateProduct(connector *models.Connector, productRequest *ProductCreateRequest) (*ProductCreateResponse, error) {
-	product := productRequest.Product
-
 	// Prepare variables for the GraphQL mutation
 	variables := map[string]interface{}{
-		"input": map[string]interface{}{
-			"title":           product.Title,
-			"descriptionHtml": product.BodyHTML,
-			"vendor":          product.Vendor,
-			"productType":     product.ProductType,
-			"tags":            product.Tags, // This is already a []string
-			"status":          product.Status,
-		},
+		"input": buildProductCreateInput(&productRequest.Product),
 	}
 
 	// Create the GraphQL mutation
@@ -303,10 +369,7 @@ func (s *ShopifyService) CreateProduct(connector *models.Connector, productReque
 					} `json:"edges"`
 				} `json:"variants"`
 			} `json:"product"`
-			UserErrors []struct {
-				Field   string `json:"field"`
-				Message string `json:"message"`
-			} `json:"userErrors"`
+			UserErrors []ShopifyUserError `json:"userErrors"`
 		} `json:"productCreate"`
 	}
 
@@ -315,8 +378,12 @@ func (s *ShopifyService) CreateProduct(connector *models.Connector, productReque
 	}
 
 	// Check for user errors
-	if len(result.ProductCreate.UserErrors) > 0 {
-		return nil, fmt.Errorf("error creating product: %s", result.ProductCreate.UserErrors[0].Message)
+	if err := shopifyUserError("creating product", result.ProductCreate.UserErrors); err != nil {
+		return nil, err
+	}
+
+	if result.ProductCreate.Product.ID == "" {
+		return nil, fmt.Errorf("shopify returned no product ID")
 	}
 
 	// Convert the GraphQL response to our expected response format
@@ -346,20 +413,15 @@ func (s *ShopifyService) CreateProduct(connector *models.Connector, productReque
 	return productResponse, nil
 }
 
-// UpdateProduct updates a product in Shopify using GraphQL
+// UpdateProduct updates a product in Shopify using GraphQL. A product that
+// does not exist returns ErrShopifyProductNotFound.
 func (s *ShopifyService) UpdateProduct(connector *models.Connector, productID string, productRequest *ProductCreateRequest) (*ProductCreateResponse, error) {
-	product := productRequest.Product
-
 	// Prepare variables for the GraphQL mutation
+	input := buildProductInput(&productRequest.Product)
+	input["id"] = productID
+
 	variables := map[string]interface{}{
-		"input": map[string]interface{}{
-			"title":           product.Title,
-			"descriptionHtml": product.BodyHTML,
-			"vendor":          product.Vendor,
-			"productType":     product.ProductType,
-			"tags":            product.Tags, // This is already a []string
-			"status":          product.Status,
-		},
+		"input": input,
 	}
 
 	// Create the GraphQL mutation
@@ -414,10 +476,7 @@ func (s *ShopifyService) UpdateProduct(connector *models.Connector, productID st
 					} `json:"edges"`
 				} `json:"variants"`
 			} `json:"product"`
-			UserErrors []struct {
-				Field   string `json:"field"`
-				Message string `json:"message"`
-			} `json:"userErrors"`
+			UserErrors []ShopifyUserError `json:"userErrors"`
 		} `json:"productUpdate"`
 	}
 
@@ -425,9 +484,16 @@ func (s *ShopifyService) UpdateProduct(connector *models.Connector, productID st
 		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
 	}
 
+	// A product that was deleted in Shopify is reported as an error on its ID
+	for _, userError := range result.ProductUpdate.UserErrors {
+		if len(userError.Field) == 1 && userError.Field[0] == "id" {
+			return nil, fmt.Errorf("%w with ID: %s: %s", ErrShopifyProductNotFound, productID, userError.Message)
+		}
+	}
+
 	// Check for user errors
-	if len(result.ProductUpdate.UserErrors) > 0 {
-		return nil, fmt.Errorf("error updating product: %s", result.ProductUpdate.UserErrors[0].Message)
+	if err := shopifyUserError("updating product", result.ProductUpdate.UserErrors); err != nil {
+		return nil, err
 	}
 
 	// Convert the GraphQL response to our expected response format
@@ -457,81 +523,231 @@ func (s *ShopifyService) UpdateProduct(connector *models.Connector, productID st
 	return productResponse, nil
 }
 
-// CreateOrder creates an order in Shopify using GraphQL
-func (s *ShopifyService) CreateOrder(connector *models.Connector, orderRequest *OrderCreateRequest) (*OrderCreateResponse, error) {
-	// Implement GraphQL mutation for order creation
-	// This will be a complex mutation as orders have many fields and relationships
-	// This is a simplified example - you'll need to adapt it for your specific requirements
+// buildProductInput builds the GraphQL ProductInput for a product
+func buildProductInput(product *ShopifyProduct) map[string]interface{} {
+	input := map[string]interface{}{
+		"title":           product.Title,
+		"descriptionHtml": product.BodyHTML,
+		"vendor":          product.Vendor,
+		"productType":     product.ProductType,
+		"tags":            product.Tags, // This is already a []string
+		"status":          product.Status,
+	}
 
-	order := orderRequest.Order
+	if product.SEO != nil && (product.SEO.Title != "" || product.SEO.Description != "") {
+		input["seo"] = map[string]interface{}{
+			"title":       product.SEO.Title,
+			"description": product.SEO.Description,
+		}
+	}
+
+	// Metafield values must be sent as strings
+	var metafields []map[string]interface{}
+	for _, metafield := range product.Metafields {
+		if metafield.Namespace == "" || metafield.Key == "" {
+			continue
+		}
+		metafields = append(metafields, map[string]interface{}{
+			"namespace": metafield.Namespace,
+			"key":       metafield.Key,
+			"value":     fmt.Sprintf("%v", metafield.Value),
+			"type":      metafield.Type,
+		})
+	}
+	if len(metafields) > 0 {
+		input["metafields"] = metafields
+	}
+
+	return input
+}
+
+// buildProductCreateInput builds the ProductInput of a new product. Options
+// can only be set when a product is created; Shopify then creates a single
+// variant with the first value of every option.
+func buildProductCreateInput(product *ShopifyProduct) map[string]interface{} {
+	input := buildProductInput(product)
 
+	var options []map[string]interface{}
+	for _, option := range product.Options {
+		if option.Name == "" || len(option.Values) == 0 {
+			continue
+		}
+		options = append(options, buildOptionInput(option))
+	}
+	if len(options) > 0 {
+		input["productOptions"] = options
+	}
+
+	return input
+}
+
+// buildOrderInput builds the GraphQL OrderCreateOrderInput for an order.
+// Amounts are in the order's currency.
+func buildOrderInput(order *ShopifyOrder) map[string]interface{} {
 	// Prepare line items
 	lineItems := make([]map[string]interface{}, len(order.LineItems))
 	for i, item := range order.LineItems {
 		lineItem := map[string]interface{}{
 			"title":    item.Title,
 			"quantity": item.Quantity,
-			"price":    item.Price,
+			"priceSet": moneyBag(item.Price, order.Currency),
 		}
 		if item.VariantID != "" {
 			lineItem["variantId"] = item.VariantID
 		}
+		if item.SKU != "" {
+			lineItem["sku"] = item.SKU
+		}
+		if len(item.TaxLines) > 0 {
+			taxLines := make([]map[string]interface{}, len(item.TaxLines))
+			for j, taxLine := range item.TaxLines {
+				taxLines[j] = map[string]interface{}{
+					"title":    taxLine.Title,
+					"rate":     taxLine.Rate,
+					"priceSet": moneyBag(taxLine.Price, order.Currency),
+				}
+			}
+			lineItem["taxLines"] = taxLines
+		}
 		lineItems[i] = lineItem
 	}
 
-	// Prepare variables for the GraphQL mutation
-	variables := map[string]interface{}{
-		"input": map[string]interface{}{
-			"email":       order.Email,
-			"lineItems":   lineItems,
-			"note":        order.Note,
-			"tags":        order.Tags,
-			"customerId":  nil, // You'd need to fetch or create a customer ID
-			"processedAt": time.Now().Format(time.RFC3339),
-		},
+	processedAt := order.ProcessedAt
+	if processedAt == "" {
+		processedAt = time.Now().Format(time.RFC3339)
+	}
+
+	input := map[string]interface{}{
+		"email":       order.Email,
+		"lineItems":   lineItems,
+		"note":        order.Note,
+		"tags":        order.Tags,
+		"processedAt": processedAt,
+	}
+
+	if order.Currency != "" {
+		input["currency"] = order.Currency
+	}
+
+	if order.FinancialStatus != "" {
+		input["financialStatus"] = order.FinancialStatus
 	}
 
 	if order.BillingAddress.FirstName != "" {
-		variables["input"].(map[string]interface{})["billingAddress"] = map[string]interface{}{
-			"firstName": order.BillingAddress.FirstName,
-			"lastName":  order.BillingAddress.LastName,
-			"address1":  order.BillingAddress.Address1,
-			"address2":  order.BillingAddress.Address2,
-			"city":      order.BillingAddress.City,
-			"province":  order.BillingAddress.Province,
-			"country":   order.BillingAddress.Country,
-			"zip":       order.BillingAddress.Zip,
-			"phone":     order.BillingAddress.Phone,
-		}
+		input["billingAddress"] = buildMailingAddress(&order.BillingAddress)
 	}
 
 	if order.ShippingAddress.FirstName != "" {
-		variables["input"].(map[string]interface{})["shippingAddress"] = map[string]interface{}{
-			"firstName": order.ShippingAddress.FirstName,
-			"lastName":  order.ShippingAddress.LastName,
-			"address1":  order.ShippingAddress.Address1,
-			"address2":  order.ShippingAddress.Address2,
-			"city":      order.ShippingAddress.City,
-			"province":  order.ShippingAddress.Province,
-			"country":   order.ShippingAddress.Country,
-			"zip":       order.ShippingAddress.Zip,
-			"phone":     order.ShippingAddress.Phone,
+		input["shippingAddress"] = buildMailingAddress(&order.ShippingAddress)
+	}
+
+	if len(order.ShippingLines) > 0 {
+		shippingLines := make([]map[string]interface{}, len(order.ShippingLines))
+		for i, line := range order.ShippingLines {
+			shippingLines[i] = map[string]interface{}{
+				"title":    line.Title,
+				"priceSet": moneyBag(line.Price, order.Currency),
+			}
+		}
+		input["shippingLines"] = shippingLines
+	}
+
+	if order.DiscountCode != nil {
+		input["discountCode"] = map[string]interface{}{
+			"itemFixedDiscountCode": map[string]interface{}{
+				"code":      order.DiscountCode.Code,
+				"amountSet": moneyBag(order.DiscountCode.Amount, order.Currency),
+			},
+		}
+	}
+
+	return input
+}
+
+// buildMailingAddress builds the GraphQL MailingAddressInput for an address.
+// Country and province codes are preferred over their names.
+func buildMailingAddress(address *ShopifyAddress) map[string]interface{} {
+	input := map[string]interface{}{
+		"firstName": address.FirstName,
+		"lastName":  address.LastName,
+		"address1":  address.Address1,
+		"address2":  address.Address2,
+		"company":   address.Company,
+		"city":      address.City,
+		"zip":       address.Zip,
+		"phone":     address.Phone,
+	}
+
+	if address.CountryCode != "" {
+		input["countryCode"] = address.CountryCode
+	} else {
+		input["country"] = address.Country
+	}
+
+	if address.ProvinceCode != "" {
+		input["provinceCode"] = address.ProvinceCode
+	} else if address.Province != "" {
+		input["province"] = address.Province
+	}
+
+	return input
+}
+
+// moneyBag builds a GraphQL MoneyBagInput for an amount
+func moneyBag(amount, currency string) map[string]interface{} {
+	if amount == "" {
+		amount = "0"
+	}
+	return map[string]interface{}{
+		"shopMoney": map[string]interface{}{
+			"amount":       amount,
+			"currencyCode": currency,
+		},
+	}
+}
+
+// CreateOrder creates an order in Shopify using GraphQL. An order without a
+// currency is created in the shop's currency.
+func (s *ShopifyService) CreateOrder(connector *models.Connector, orderRequest *OrderCreateRequest) (*OrderCreateResponse, error) {
+	order := orderRequest.Order
+	if order.Currency == "" {
+		pricing, err := s.GetShopPricing(connector)
+		if err != nil {
+			return nil, err
 		}
+		order.Currency = pricing.CurrencyCode
+	}
+
+	// Prepare variables for the GraphQL mutation
+	variables := map[string]interface{}{
+		"order": buildOrderInput(&order),
 	}
 
 	// Create the GraphQL mutation
 	mutation := `
-		mutation createOrder($input: OrderInput!) {
-			orderCreate(input: $input) {
+		mutation createOrder($order: OrderCreateOrderInput!) {
+			orderCreate(order: $order) {
 				order {
 					id
 					name
 					email
 					createdAt
 					updatedAt
-					totalPrice
-					subtotalPrice
-					totalTax
+					totalPriceSet {
+						shopMoney {
+							amount
+						}
+					}
+					subtotalPriceSet {
+						shopMoney {
+							amount
+						}
+					}
+					totalTaxSet {
+						shopMoney {
+							amount
+						}
+					}
 					displayFinancialStatus
 				}
 				userErrors {
@@ -551,20 +767,17 @@ func (s *ShopifyService) CreateOrder(connector *models.Connector, orderRequest *
 	var result struct {
 		OrderCreate struct {
 			Order struct {
-				ID                     string    `json:"id"`
-				Name                   string    `json:"name"`
-				Email                  string    `json:"email"`
-				CreatedAt              time.Time `json:"createdAt"`
-				UpdatedAt              time.Time `json:"updatedAt"`
-				TotalPrice             string    `json:"totalPrice"`
-				SubtotalPrice          string    `json:"subtotalPrice"`
-				TotalTax               string    `json:"totalTax"`
-				DisplayFinancialStatus string    `json:"displayFinancialStatus"`
+				ID                     string          `json:"id"`
+				Name                   string          `json:"name"`
+				Email                  string          `json:"email"`
+				CreatedAt              time.Time       `json:"createdAt"`
+				UpdatedAt              time.Time       `json:"updatedAt"`
+				TotalPriceSet          shopifyMoneyBag `json:"totalPriceSet"`
+				SubtotalPriceSet       shopifyMoneyBag `json:"subtotalPriceSet"`
+				TotalTaxSet            shopifyMoneyBag `json:"totalTaxSet"`
+				DisplayFinancialStatus string          `json:"displayFinancialStatus"`
 			} `json:"order"`
-			UserErrors []struct {
-				Field   string `json:"field"`
-				Message string `json:"message"`
-			} `json:"userErrors"`
+			UserErrors []ShopifyUserError `json:"userErrors"`
 		} `json:"orderCreate"`
 	}
 
@@ -573,8 +786,11 @@ func (s *ShopifyService) CreateOrder(connector *models.Connector, orderRequest *
 	}
 
 	// Check for user errors
-	if len(result.OrderCreate.UserErrors) > 0 {
-		return nil, fmt.Errorf("error creating order: %s", result.OrderCreate.UserErrors[0].Message)
+	if err := shopifyUserError("creating order", result.OrderCreate.UserErrors); err != nil {
+		return nil, err
+	}
+	if result.OrderCreate.Order.ID == "" {
+		return nil, fmt.Errorf("shopify returned no order ID")
 	}
 
 	// Convert the GraphQL response to our expected response format
@@ -584,21 +800,19 @@ func (s *ShopifyService) CreateOrder(connector *models.Connector, orderRequest *
 	orderResponse.Order.Email = result.OrderCreate.Order.Email
 	orderResponse.Order.CreatedAt = result.OrderCreate.Order.CreatedAt
 	orderResponse.Order.UpdatedAt = result.OrderCreate.Order.UpdatedAt
-	orderResponse.Order.TotalPrice = result.OrderCreate.Order.TotalPrice
-	orderResponse.Order.SubtotalPrice = result.OrderCreate.Order.SubtotalPrice
-	orderResponse.Order.TotalTax = result.OrderCreate.Order.TotalTax
+	orderResponse.Order.TotalPrice = result.OrderCreate.Order.TotalPriceSet.ShopMoney.Amount
+	orderResponse.Order.SubtotalPrice = result.OrderCreate.Order.SubtotalPriceSet.ShopMoney.Amount
+	orderResponse.Order.TotalTax = result.OrderCreate.Order.TotalTaxSet.ShopMoney.Amount
 	orderResponse.Order.FinancialStatus = result.OrderCreate.Order.DisplayFinancialStatus
 
 	return orderResponse, nil
 }
 
-// RegisterWebhooks registers webhooks with Shopify using GraphQL
-func (s *ShopifyService) RegisterWebhooks(connector *models.Connector, callbackURL string) error {
-	// Shopify webhooks are not needed for this integration as it's one-way
-	// from Shopware to Shopify, but we'll implement the method for completeness
-
-	// Note: In GraphQL, you would use the webhookSubscriptionCreate mutation
-	return nil
+// shopifyMoneyBag is a MoneyBag in a GraphQL response
+type shopifyMoneyBag struct {
+	ShopMoney struct {
+		Amount string `json:"amount"`
+	} `json:"shopMoney"`
 }
 
 // GetProductByID gets a product from Shopify by ID using GraphQL
@@ -822,7 +1036,7 @@ func (s *ShopifyService) FindProductBySKU(connector *models.Connector, sku strin
 
 	// Check if any variants were found
 	if len(result.ProductVariants.Edges) == 0 {
-		return nil, fmt.Errorf("no product found with SKU: %s", sku)
+		return nil, fmt.Errorf("%w with SKU: %s", ErrShopifyProductNotFound, sku)
 	}
 
 	// Extract the product from the first variant
@@ -855,8 +1069,10 @@ func (s *ShopifyService) FindProductBySKU(connector *models.Connector, sku strin
 	return productResponse, nil
 }
 
-// executeGraphQL is a helper method to execute GraphQL queries and mutations
-func (s *ShopifyService) executeGraphQL(connector *models.Connector, query string, variables map[string]interface{}, response interface{}) error {
+// executeGraphQL is a helper method to execute GraphQL queries and mutations.
+// Top-level GraphQL errors are returned as errors, so callers only need to
+// check the userErrors of their mutation.
+func (s *ShopifyService) executeGraphQL(connector *models.Connector, query string, variables map[string]interface{}, response *GraphQLResponse) error {
 	// Prepare the request body
 	requestBody := map[string]interface{}{
 		"query":     query,
@@ -869,7 +1085,7 @@ func (s *ShopifyService) executeGraphQL(connector *models.Connector, query strin
 	}
 
 	// Create the GraphQL endpoint URL
-	url := fmt.Sprintf("https://%s/admin/api/2025-04/graphql.json", connector.URL)
+	url := shopifyAdminURL(connector, "graphql.json")
 
 	// Create the request
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonBody))
@@ -896,7 +1112,10 @@ func (s *ShopifyService) executeGraphQL(connector *models.Connector, query strin
 
 	// Check for HTTP errors
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("GraphQL request failed with status %d: %s", resp.StatusCode, string(body))
+		return &APIError{
+			StatusCode: resp.StatusCode,
+			Message:    fmt.Sprintf("GraphQL request failed with status %d: %s", resp.StatusCode, string(body)),
+		}
 	}
 
 	// Unmarshal the response
@@ -904,5 +1123,42 @@ func (s *ShopifyService) executeGraphQL(connector *models.Connector, query strin
 		return fmt.Errorf("error unmarshaling GraphQL response: %w", err)
 	}
 
+	// Errors are answered with 200, so their code decides how they are
+	// retried: throttled queries are rate limited, a missing access scope is
+	// an auth error
+	if len(response.Errors) > 0 {
+		graphQLError := response.Errors[0]
+		switch graphQLError.Extensions.Code {
+		case "THROTTLED":
+			return &APIError{
+				StatusCode: http.StatusTooManyRequests,
+				Message:    fmt.Sprintf("GraphQL request throttled: %s", graphQLError.Message),
+			}
+		case "ACCESS_DENIED":
+			return &APIError{
+				StatusCode: http.StatusForbidden,
+				Message:    fmt.Sprintf("GraphQL access denied: %s", graphQLError.Message),
+			}
+		case "INTERNAL_SERVER_ERROR":
+			return &APIError{
+				StatusCode: http.StatusInternalServerError,
+				Message:    fmt.Sprintf("GraphQL server error: %s", graphQLError.Message),
+			}
+		}
+		return fmt.Errorf("GraphQL error: %s", graphQLError.Message)
+	}
+
 	return nil
 }
+
+// shopifyAdminURL returns an Admin API URL for a connector. Connector URLs
+// are usually a bare shop domain; a URL that already has a scheme is used as
+// is, which allows pointing a connector at a local fake Shopify server.
+func shopifyAdminURL(connector *models.Connector, path string) string {
+	base := strings.TrimRight(connector.URL, "/")
+	if !strings.HasPrefix(base, "http://") && !strings.HasPrefix(base, "https://") {
+		base = "https://" + base
+	}
+
+	return fmt.Sprintf("%s/admin/api/2025-04/%s", base, path)
+}
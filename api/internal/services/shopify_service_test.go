@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// newFakeGraphQLShopify serves a fixed GraphQL response body
+func newFakeGraphQLShopify(t *testing.T, body string) (*ShopifyService, *models.Connector) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+
+	return &ShopifyService{httpClient: server.Client()}, &models.Connector{URL: server.URL}
+}
+
+func TestUpdateProductNotFound(t *testing.T) {
+	service, connector := newFakeGraphQLShopify(t,
+		`{"data":{"productUpdate":{"product":null,"userErrors":[{"field":["id"],"message":"Product does not exist"}]}}}`)
+
+	_, err := service.UpdateProduct(connector, "gid://shopify/Product/1", &ProductCreateRequest{})
+	if !errors.Is(err, ErrShopifyProductNotFound) {
+		t.Fatalf("UpdateProduct error = %v, want %v", err, ErrShopifyProductNotFound)
+	}
+}
+
+func TestUpdateProductUserError(t *testing.T) {
+	service, connector := newFakeGraphQLShopify(t,
+		`{"data":{"productUpdate":{"product":null,"userErrors":[{"field":["title"],"message":"Title can't be blank"}]}}}`)
+
+	_, err := service.UpdateProduct(connector, "gid://shopify/Product/1", &ProductCreateRequest{})
+	if err == nil || errors.Is(err, ErrShopifyProductNotFound) {
+		t.Fatalf("UpdateProduct error = %v, want the user error", err)
+	}
+}
+
+func TestCreateProductGraphQLError(t *testing.T) {
+	service, connector := newFakeGraphQLShopify(t,
+		`{"data":null,"errors":[{"message":"Variable $input of type ProductInput! was provided invalid value"}]}`)
+
+	_, err := service.CreateProduct(connector, &ProductCreateRequest{})
+	if err == nil || !strings.Contains(err.Error(), "invalid value") {
+		t.Fatalf("CreateProduct error = %v, want the GraphQL error", err)
+	}
+}
+
+func TestCreateProductWithoutID(t *testing.T) {
+	service, connector := newFakeGraphQLShopify(t,
+		`{"data":{"productCreate":{"product":null,"userErrors":[]}}}`)
+
+	if _, err := service.CreateProduct(connector, &ProductCreateRequest{}); err == nil {
+		t.Fatalf("CreateProduct without a product ID succeeded")
+	}
+}
+
+func TestGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		body  string
+		class models.ErrorClass
+	}{
+		{`{"data":{"inventorySetQuantities":null},"errors":[{"message":"Access denied for inventorySetQuantities field.","extensions":{"code":"ACCESS_DENIED"}}]}`, models.ErrorClassAuth},
+		{`{"errors":[{"message":"Throttled","extensions":{"code":"THROTTLED"}}]}`, models.ErrorClassRateLimited},
+		{`{"errors":[{"message":"Internal error","extensions":{"code":"INTERNAL_SERVER_ERROR"}}]}`, models.ErrorClassServerError},
+		{`{"errors":[{"message":"Field 'foo' doesn't exist on type 'Mutation'"}]}`, models.ErrorClassUnknown},
+	}
+
+	for _, test := range tests {
+		service, connector := newFakeGraphQLShopify(t, test.body)
+
+		err := service.SetInventoryQuantities(connector, []InventoryQuantity{{}})
+		if err == nil {
+			t.Errorf("SetInventoryQuantities with %s succeeded, want an error", test.body)
+			continue
+		}
+		if class := ClassifyError(err); class != test.class {
+			t.Errorf("SetInventoryQuantities with %s: error class = %s, want %s", test.body, class, test.class)
+		}
+	}
+}
+
+func TestCreateOrderWithoutID(t *testing.T) {
+	tests := []string{
+		`{"data":{"orderCreate":{"order":null,"userErrors":[]}}}`,
+		`{"data":{"orderCreate":null},"errors":[{"message":"Variable $order of type OrderCreateOrderInput! was provided invalid value"}]}`,
+	}
+
+	for _, body := range tests {
+		service, connector := newFakeGraphQLShopify(t, body)
+
+		if _, err := service.CreateOrder(connector, &OrderCreateRequest{}); err == nil {
+			t.Errorf("CreateOrder with %s succeeded, want an error", body)
+		}
+	}
+}
@@ -0,0 +1,368 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// ShopifyProductVariants holds the options and variants of a Shopify product
+type ShopifyProductVariants struct {
+	Options  []ShopifyOption
+	Variants []ShopifyExistingVariant
+}
+
+// ShopifyExistingVariant is a variant read from Shopify
+type ShopifyExistingVariant struct {
+	ID    string
+	SKU   string
+	Title string
+}
+
+// HasOption reports whether the product has an option with the given name
+func (p *ShopifyProductVariants) HasOption(name string) bool {
+	for _, option := range p.Options {
+		if option.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetProductVariants gets the options and variants of a Shopify product
+func (s *ShopifyService) GetProductVariants(connector *models.Connector, productID string) (*ShopifyProductVariants, error) {
+	variables := map[string]interface{}{
+		"id": productID,
+	}
+
+	query := `
+		query getProductVariants($id: ID!) {
+			product(id: $id) {
+				options {
+					name
+					optionValues {
+						name
+					}
+				}
+				variants(first: 250) {
+					edges {
+						node {
+							id
+							sku
+							title
+						}
+					}
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, variables, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Product *struct {
+			Options []struct {
+				Name         string `json:"name"`
+				OptionValues []struct {
+					Name string `json:"name"`
+				} `json:"optionValues"`
+			} `json:"options"`
+			Variants struct {
+				Edges []struct {
+					Node struct {
+						ID    string `json:"id"`
+						SKU   string `json:"sku"`
+						Title string `json:"title"`
+					} `json:"node"`
+				} `json:"edges"`
+			} `json:"variants"`
+		} `json:"product"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if result.Product == nil {
+		return nil, fmt.Errorf("%w with ID: %s", ErrShopifyProductNotFound, productID)
+	}
+
+	productVariants := &ShopifyProductVariants{}
+	for _, option := range result.Product.Options {
+		values := make([]string, len(option.OptionValues))
+		for i, value := range option.OptionValues {
+			values[i] = value.Name
+		}
+		productVariants.Options = append(productVariants.Options, ShopifyOption{Name: option.Name, Values: values})
+	}
+	for _, edge := range result.Product.Variants.Edges {
+		productVariants.Variants = append(productVariants.Variants, ShopifyExistingVariant{
+			ID:    edge.Node.ID,
+			SKU:   edge.Node.SKU,
+			Title: edge.Node.Title,
+		})
+	}
+
+	return productVariants, nil
+}
+
+// CreateProductOptions adds options to an existing product. Existing
+// variants are left as they are and get the first value of each new option.
+func (s *ShopifyService) CreateProductOptions(connector *models.Connector, productID string, options []ShopifyOption) error {
+	inputs := make([]map[string]interface{}, len(options))
+	for i, option := range options {
+		inputs[i] = buildOptionInput(option)
+	}
+
+	variables := map[string]interface{}{
+		"productId": productID,
+		"options":   inputs,
+	}
+
+	mutation := `
+		mutation createOptions($productId: ID!, $options: [OptionCreateInput!]!) {
+			productOptionsCreate(productId: $productId, options: $options, variantStrategy: LEAVE_AS_IS) {
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return err
+	}
+
+	var result struct {
+		ProductOptionsCreate struct {
+			UserErrors []ShopifyUserError `json:"userErrors"`
+		} `json:"productOptionsCreate"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	return shopifyUserError("creating product options", result.ProductOptionsCreate.UserErrors)
+}
+
+// BulkCreateVariants creates variants of a product and returns their IDs in
+// the order of the variants. With removeStandalone, the variant Shopify
+// created along with the product is deleted.
+func (s *ShopifyService) BulkCreateVariants(connector *models.Connector, productID string, variants []ShopifyVariant, removeStandalone bool) ([]string, error) {
+	strategy := "DEFAULT"
+	if removeStandalone {
+		strategy = "REMOVE_STANDALONE_VARIANT"
+	}
+
+	variables := map[string]interface{}{
+		"productId": productID,
+		"variants":  buildVariantInputs(variants, nil),
+		"strategy":  strategy,
+	}
+
+	mutation := `
+		mutation createVariants($productId: ID!, $variants: [ProductVariantsBulkInput!]!, $strategy: ProductVariantsBulkCreateStrategy) {
+			productVariantsBulkCreate(productId: $productId, variants: $variants, strategy: $strategy) {
+				productVariants {
+					id
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		ProductVariantsBulkCreate struct {
+			ProductVariants []struct {
+				ID string `json:"id"`
+			} `json:"productVariants"`
+			UserErrors []ShopifyUserError `json:"userErrors"`
+		} `json:"productVariantsBulkCreate"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if err := shopifyUserError("creating variants", result.ProductVariantsBulkCreate.UserErrors); err != nil {
+		return nil, err
+	}
+
+	if len(result.ProductVariantsBulkCreate.ProductVariants) != len(variants) {
+		return nil, fmt.Errorf("shopify created %d of %d variants", len(result.ProductVariantsBulkCreate.ProductVariants), len(variants))
+	}
+
+	ids := make([]string, len(variants))
+	for i, variant := range result.ProductVariantsBulkCreate.ProductVariants {
+		ids[i] = variant.ID
+	}
+
+	return ids, nil
+}
+
+// BulkUpdateVariants updates variants of a product. ids holds the Shopify ID
+// of every variant.
+func (s *ShopifyService) BulkUpdateVariants(connector *models.Connector, productID string, ids []string, variants []ShopifyVariant) error {
+	variables := map[string]interface{}{
+		"productId": productID,
+		"variants":  buildVariantInputs(variants, ids),
+	}
+
+	mutation := `
+		mutation updateVariants($productId: ID!, $variants: [ProductVariantsBulkInput!]!) {
+			productVariantsBulkUpdate(productId: $productId, variants: $variants) {
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return err
+	}
+
+	var result struct {
+		ProductVariantsBulkUpdate struct {
+			UserErrors []ShopifyUserError `json:"userErrors"`
+		} `json:"productVariantsBulkUpdate"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	return shopifyUserError("updating variants", result.ProductVariantsBulkUpdate.UserErrors)
+}
+
+// BulkDeleteVariants deletes variants of a product
+func (s *ShopifyService) BulkDeleteVariants(connector *models.Connector, productID string, ids []string) error {
+	variables := map[string]interface{}{
+		"productId":   productID,
+		"variantsIds": ids,
+	}
+
+	mutation := `
+		mutation deleteVariants($productId: ID!, $variantsIds: [ID!]!) {
+			productVariantsBulkDelete(productId: $productId, variantsIds: $variantsIds) {
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+		return err
+	}
+
+	var result struct {
+		ProductVariantsBulkDelete struct {
+			UserErrors []ShopifyUserError `json:"userErrors"`
+		} `json:"productVariantsBulkDelete"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	return shopifyUserError("deleting variants", result.ProductVariantsBulkDelete.UserErrors)
+}
+
+// buildOptionInput builds the OptionCreateInput for a product option
+func buildOptionInput(option ShopifyOption) map[string]interface{} {
+	values := make([]map[string]interface{}, len(option.Values))
+	for i, value := range option.Values {
+		values[i] = map[string]interface{}{"name": value}
+	}
+
+	return map[string]interface{}{
+		"name":   option.Name,
+		"values": values,
+	}
+}
+
+// buildVariantInputs builds the ProductVariantsBulkInput of every variant,
+// with the variant's ID when ids is given
+func buildVariantInputs(variants []ShopifyVariant, ids []string) []map[string]interface{} {
+	inputs := make([]map[string]interface{}, len(variants))
+	for i := range variants {
+		inputs[i] = buildVariantInput(&variants[i])
+		if ids != nil {
+			inputs[i]["id"] = ids[i]
+		}
+	}
+	return inputs
+}
+
+// buildVariantInput builds the ProductVariantsBulkInput for a variant. SKU
+// and weight belong to the variant's inventory item. Weights default to
+// kilograms, the unit Shopware uses.
+func buildVariantInput(variant *ShopifyVariant) map[string]interface{} {
+	input := map[string]interface{}{}
+
+	if variant.Price != "" {
+		input["price"] = variant.Price
+	}
+	if variant.CompareAtPrice != "" {
+		input["compareAtPrice"] = variant.CompareAtPrice
+	}
+	if variant.Barcode != "" {
+		input["barcode"] = variant.Barcode
+	}
+	if variant.InventoryPolicy != "" {
+		input["inventoryPolicy"] = variant.InventoryPolicy
+	}
+
+	if len(variant.OptionValues) > 0 {
+		optionValues := make([]map[string]interface{}, len(variant.OptionValues))
+		for i, optionValue := range variant.OptionValues {
+			optionValues[i] = map[string]interface{}{
+				"optionName": optionValue.OptionName,
+				"name":       optionValue.Name,
+			}
+		}
+		input["optionValues"] = optionValues
+	}
+
+	inventoryItem := map[string]interface{}{}
+	if variant.SKU != "" {
+		inventoryItem["sku"] = variant.SKU
+	}
+	if variant.Weight > 0 {
+		unit := variant.WeightUnit
+		if unit == "" {
+			unit = "KILOGRAMS"
+		}
+		inventoryItem["measurement"] = map[string]interface{}{
+			"weight": map[string]interface{}{
+				"value": variant.Weight,
+				"unit":  unit,
+			},
+		}
+	}
+	if len(inventoryItem) > 0 {
+		input["inventoryItem"] = inventoryItem
+	}
+
+	return input
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// shopifyWebhookTopics are the Shopify webhook topics the integration
+// subscribes to, by the topic name Shopify sends in X-Shopify-Topic
+var shopifyWebhookTopics = map[string]string{
+	"orders/fulfilled":        "ORDERS_FULFILLED",
+	"orders/cancelled":        "ORDERS_CANCELLED",
+	"refunds/create":          "REFUNDS_CREATE",
+	"inventory_levels/update": "INVENTORY_LEVELS_UPDATE",
+}
+
+// IsShopifyWebhookTopic reports whether a Shopify webhook topic is handled
+func IsShopifyWebhookTopic(topic string) bool {
+	_, ok := shopifyWebhookTopics[topic]
+	return ok
+}
+
+// VerifyShopifyWebhook reports whether the X-Shopify-Hmac-Sha256 signature
+// of a webhook body was made with the app's client secret
+func VerifyShopifyWebhook(secret string, body []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// RegisterWebhooks subscribes the callback URL to the Shopify topics the
+// integration handles. Topics already subscribed to the URL are skipped.
+func (s *ShopifyService) RegisterWebhooks(connector *models.Connector, callbackURL string) error {
+	mutation := `
+		mutation createWebhookSubscription($topic: WebhookSubscriptionTopic!, $webhookSubscription: WebhookSubscriptionInput!) {
+			webhookSubscriptionCreate(topic: $topic, webhookSubscription: $webhookSubscription) {
+				webhookSubscription {
+					id
+				}
+				userErrors {
+					field
+					message
+				}
+			}
+		}
+	`
+
+	for _, topic := range shopifyWebhookTopics {
+		variables := map[string]interface{}{
+			"topic": topic,
+			"webhookSubscription": map[string]interface{}{
+				"callbackUrl": callbackURL,
+				"format":      "JSON",
+			},
+		}
+
+		var response GraphQLResponse
+		if err := s.executeGraphQL(connector, mutation, variables, &response); err != nil {
+			return err
+		}
+
+		var result struct {
+			WebhookSubscriptionCreate struct {
+				UserErrors []ShopifyUserError `json:"userErrors"`
+			} `json:"webhookSubscriptionCreate"`
+		}
+
+		if err := json.Unmarshal(response.Data, &result); err != nil {
+			return fmt.Errorf("error parsing GraphQL response: %w", err)
+		}
+
+		userErrors := result.WebhookSubscriptionCreate.UserErrors
+		if len(userErrors) > 0 && strings.Contains(userErrors[0].Message, "already been taken") {
+			continue
+		}
+		if err := shopifyUserError(fmt.Sprintf("subscribing to %s", topic), userErrors); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetOrderFinancialStatus gets the financial status of a Shopify order, e.g.
+// PAID or PARTIALLY_REFUNDED
+func (s *ShopifyService) GetOrderFinancialStatus(connector *models.Connector, orderID string) (string, error) {
+	variables := map[string]interface{}{
+		"id": orderID,
+	}
+
+	query := `
+		query getOrderFinancialStatus($id: ID!) {
+			order(id: $id) {
+				displayFinancialStatus
+			}
+		}
+	`
+
+	var response GraphQLResponse
+	if err := s.executeGraphQL(connector, query, variables, &response); err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Order *struct {
+			DisplayFinancialStatus string `json:"displayFinancialStatus"`
+		} `json:"order"`
+	}
+
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		return "", fmt.Errorf("error parsing GraphQL response: %w", err)
+	}
+
+	if result.Order == nil {
+		return "", fmt.Errorf("no order found: %s", orderID)
+	}
+
+	return result.Order.DisplayFinancialStatus, nil
+}
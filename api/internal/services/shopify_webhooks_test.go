@@ -0,0 +1,38 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func TestVerifyShopifyWebhook(t *testing.T) {
+	body := []byte(`{"id":1,"topic":"orders/fulfilled"}`)
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		secret    string
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{"valid signature", "secret", body, signature, true},
+		{"other secret", "other", body, signature, false},
+		{"changed body", "secret", []byte(`{"id":2,"topic":"orders/fulfilled"}`), signature, false},
+		{"no secret", "", body, signature, false},
+		{"no signature", "secret", body, "", false},
+		{"signature not base64", "secret", body, "not base64!", false},
+		{"truncated signature", "secret", body, signature[:20], false},
+	}
+
+	for _, test := range tests {
+		if got := VerifyShopifyWebhook(test.secret, test.body, test.signature); got != test.want {
+			t.Errorf("%s: VerifyShopifyWebhook = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
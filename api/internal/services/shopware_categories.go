@@ -0,0 +1,66 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// categoryAssociations are loaded with every category for its image
+var categoryAssociations = map[string]interface{}{
+	"media": map[string]interface{}{},
+}
+
+// fetchCategory fetches a Shopware category as a source document
+func (s *ShopwareService) fetchCategory(connector *models.Connector, id string, config *models.CategoryConfig) ([]byte, error) {
+	category, err := s.GetEntityDocument(connector, "category", id, categoryAssociations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get category data: %w", err)
+	}
+
+	sourceData, err := json.Marshal(categorySourceDocument(category, config))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal category data: %w", err)
+	}
+	return sourceData, nil
+}
+
+// categorySourceDocument prepares a Shopware category for the field
+// mappings. It adds "collectionTitle", the category name or its path
+// depending on the config.
+func categorySourceDocument(document map[string]interface{}, config *models.CategoryConfig) map[string]interface{} {
+	document = withTranslatedFallback(document, "name", "description", "metaTitle", "metaDescription")
+
+	title := stringField(document, "name")
+	if config.TitleMode() == models.CategoryTitlePath {
+		if path := categoryPath(document, config.IncludeRoot); len(path) > 0 {
+			title = strings.Join(path, config.Separator())
+		}
+	}
+	document["collectionTitle"] = title
+
+	return document
+}
+
+// categoryPath returns the names of a category and its parents from its
+// breadcrumb, starting at the top
+func categoryPath(document map[string]interface{}, includeRoot bool) []string {
+	breadcrumb, _ := document["breadcrumb"].([]interface{})
+	if translated, ok := document["translated"].(map[string]interface{}); ok {
+		if names, ok := translated["breadcrumb"].([]interface{}); ok && len(names) > 0 {
+			breadcrumb = names
+		}
+	}
+
+	var path []string
+	for i, item := range breadcrumb {
+		name, ok := item.(string)
+		if !ok || name == "" || (i == 0 && !includeRoot && len(breadcrumb) > 1) {
+			continue
+		}
+		path = append(path, name)
+	}
+	return path
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+func TestCategorySourceDocument(t *testing.T) {
+	category := func() map[string]interface{} {
+		return map[string]interface{}{
+			"name":       nil,
+			"breadcrumb": []interface{}{"Home", "Clothing", "Shirts"},
+			"translated": map[string]interface{}{
+				"name":       "Hemden",
+				"breadcrumb": []interface{}{"Start", "Kleidung", "Hemden"},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		config *models.CategoryConfig
+		title  string
+	}{
+		{"default title", nil, "Hemden"},
+		{"name title", &models.CategoryConfig{Title: models.CategoryTitleName}, "Hemden"},
+		{"path title", &models.CategoryConfig{Title: models.CategoryTitlePath}, "Kleidung > Hemden"},
+		{"path title with the root", &models.CategoryConfig{Title: models.CategoryTitlePath, IncludeRoot: true}, "Start > Kleidung > Hemden"},
+		{"path title with a separator", &models.CategoryConfig{Title: models.CategoryTitlePath, PathSeparator: " / "}, "Kleidung / Hemden"},
+	}
+
+	for _, test := range tests {
+		document := categorySourceDocument(category(), test.config)
+		if document["collectionTitle"] != test.title {
+			t.Errorf("%s: collectionTitle = %v, want %s", test.name, document["collectionTitle"], test.title)
+		}
+	}
+}
+
+func TestCategoryPath(t *testing.T) {
+	tests := []struct {
+		breadcrumb  []interface{}
+		includeRoot bool
+		want        []string
+	}{
+		{[]interface{}{"Home", "Clothing", "Shirts"}, false, []string{"Clothing", "Shirts"}},
+		{[]interface{}{"Home", "Clothing", "Shirts"}, true, []string{"Home", "Clothing", "Shirts"}},
+		// The root category itself keeps its name
+		{[]interface{}{"Home"}, false, []string{"Home"}},
+		{[]interface{}{"Home", "", "Shirts"}, false, []string{"Shirts"}},
+		{nil, false, nil},
+	}
+
+	for _, test := range tests {
+		path := categoryPath(map[string]interface{}{"breadcrumb": test.breadcrumb}, test.includeRoot)
+		if len(path) != len(test.want) {
+			t.Errorf("categoryPath(%v, %v) = %v, want %v", test.breadcrumb, test.includeRoot, path, test.want)
+			continue
+		}
+		for i := range path {
+			if path[i] != test.want[i] {
+				t.Errorf("categoryPath(%v, %v) = %v, want %v", test.breadcrumb, test.includeRoot, path, test.want)
+				break
+			}
+		}
+	}
+}
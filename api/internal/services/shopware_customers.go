@@ -0,0 +1,187 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// customerAssociations are loaded with every customer
+var customerAssociations = map[string]interface{}{
+	"addresses":              map[string]interface{}{},
+	"defaultBillingAddress":  map[string]interface{}{},
+	"defaultShippingAddress": map[string]interface{}{},
+	"group":                  map[string]interface{}{},
+}
+
+// e164Pattern matches phone numbers in E.164 format, the only format
+// Shopify accepts for customers
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// phoneSeparators are removed from phone numbers before they are checked
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "", "/", "")
+
+// fetchCustomer fetches a Shopware customer as a source document
+func (s *ShopwareService) fetchCustomer(connector *models.Connector, id string) ([]byte, error) {
+	customer, err := s.GetEntityDocument(connector, "customer", id, customerAssociations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer data: %w", err)
+	}
+
+	customer, err = s.prepareCustomer(connector, customer)
+	if err != nil {
+		return nil, err
+	}
+
+	sourceData, err := json.Marshal(customer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal customer data: %w", err)
+	}
+	return sourceData, nil
+}
+
+// prepareCustomer loads the reference data and newsletter recipient a
+// customer document needs and prepares it for the field mappings
+func (s *ShopwareService) prepareCustomer(connector *models.Connector, customer map[string]interface{}) (map[string]interface{}, error) {
+	references, err := s.ReferenceData(connector)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := s.newsletterRecipient(connector, stringField(customer, "email"))
+	if err != nil {
+		return nil, err
+	}
+
+	return customerSourceDocument(customer, references, recipient), nil
+}
+
+// newsletterRecipient returns the most recently updated newsletter recipient
+// with an email address, or nil if there is none
+func (s *ShopwareService) newsletterRecipient(connector *models.Connector, email string) (map[string]interface{}, error) {
+	if email == "" {
+		return nil, nil
+	}
+
+	result, err := s.SearchEntities(connector, "newsletter-recipient", SearchCriteria{
+		Page:  1,
+		Limit: 1,
+		Filter: []map[string]interface{}{
+			{"type": "equals", "field": "email", "value": email},
+		},
+		Sort: []map[string]interface{}{{"field": "updatedAt", "order": "DESC"}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching newsletter recipient: %w", err)
+	}
+
+	if len(result.Data) == 0 {
+		return nil, nil
+	}
+	return result.Data[0], nil
+}
+
+// customerSourceDocument prepares a Shopware customer for the field
+// mappings. It adds "addressList" (the customer's addresses in Shopify's
+// shape, the default shipping address first), "phone" (the default billing
+// address's phone number if it is in E.164 format), "groupTags" (the name of
+// the customer group) and, if the customer has a newsletter recipient,
+// "emailMarketing" and "smsMarketing" consent.
+func customerSourceDocument(document map[string]interface{}, references *ShopwareReferenceData, recipient map[string]interface{}) map[string]interface{} {
+	defaultID := stringField(document, "defaultShippingAddressId")
+
+	addresses, _ := document["addresses"].([]interface{})
+	addressList := []interface{}{}
+	for _, item := range addresses {
+		address, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		converted := orderAddress(address, references)
+		if stringField(address, "id") == defaultID {
+			addressList = append([]interface{}{converted}, addressList...)
+		} else {
+			addressList = append(addressList, converted)
+		}
+	}
+	document["addressList"] = addressList
+
+	phone := ""
+	if billing, ok := document["defaultBillingAddress"].(map[string]interface{}); ok {
+		phone = e164Phone(stringField(billing, "phoneNumber"))
+	}
+	document["phone"] = phone
+
+	groupTags := []interface{}{}
+	if group, ok := document["group"].(map[string]interface{}); ok {
+		if name := translatedString(group, "name"); name != "" {
+			groupTags = append(groupTags, name)
+		}
+	}
+	document["groupTags"] = groupTags
+
+	if consent := marketingConsent(recipient); consent != nil {
+		document["emailMarketing"] = consent
+		if phone != "" {
+			document["smsMarketing"] = consent
+		}
+	}
+
+	return document
+}
+
+// marketingConsent converts the status of a Shopware newsletter recipient to
+// a Shopify marketing consent, or nil if the status is unknown
+func marketingConsent(recipient map[string]interface{}) map[string]interface{} {
+	if recipient == nil {
+		return nil
+	}
+
+	consentUpdatedAt := stringField(recipient, "confirmedAt")
+	if consentUpdatedAt == "" {
+		consentUpdatedAt = stringField(recipient, "updatedAt")
+	}
+	if consentUpdatedAt == "" {
+		consentUpdatedAt = stringField(recipient, "createdAt")
+	}
+
+	consent := map[string]interface{}{
+		"consentUpdatedAt": consentUpdatedAt,
+	}
+
+	switch stringField(recipient, "status") {
+	case "direct":
+		consent["marketingState"] = "SUBSCRIBED"
+		consent["marketingOptInLevel"] = "SINGLE_OPT_IN"
+	case "optIn":
+		consent["marketingState"] = "SUBSCRIBED"
+		consent["marketingOptInLevel"] = "CONFIRMED_OPT_IN"
+	case "notSet":
+		// Waiting for the double opt-in confirmation
+		consent["marketingState"] = "PENDING"
+		consent["marketingOptInLevel"] = "CONFIRMED_OPT_IN"
+	case "optOut":
+		consent["marketingState"] = "UNSUBSCRIBED"
+	default:
+		return nil
+	}
+
+	return consent
+}
+
+// e164Phone returns a phone number in E.164 format, or "" if it cannot be
+// written that way. Numbers starting with 00 are read as international.
+func e164Phone(phone string) string {
+	phone = phoneSeparators.Replace(phone)
+	if strings.HasPrefix(phone, "00") {
+		phone = "+" + phone[2:]
+	}
+	if !e164Pattern.MatchString(phone) {
+		return ""
+	}
+	return phone
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestE164Phone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+	}{
+		{"+4930123456", "+4930123456"},
+		{"+49 30 123-456", "+4930123456"},
+		{"+1 (555) 010.0199", "+15550100199"},
+		{"0049 30/123456", "+4930123456"},
+		{"030 123456", ""},
+		{"+0301234567", ""},
+		{"+49301234567890123", ""},
+		{"+49 30 ext 1", ""},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := e164Phone(test.phone); got != test.want {
+			t.Errorf("e164Phone(%q) = %q, want %q", test.phone, got, test.want)
+		}
+	}
+}
+
+func TestMarketingConsent(t *testing.T) {
+	tests := []struct {
+		recipient map[string]interface{}
+		want      string
+	}{
+		{nil, `null`},
+		{
+			map[string]interface{}{"status": "direct", "createdAt": "2024-01-01"},
+			`{"consentUpdatedAt":"2024-01-01","marketingOptInLevel":"SINGLE_OPT_IN","marketingState":"SUBSCRIBED"}`,
+		},
+		{
+			map[string]interface{}{"status": "optIn", "confirmedAt": "2024-01-03", "updatedAt": "2024-01-02"},
+			`{"consentUpdatedAt":"2024-01-03","marketingOptInLevel":"CONFIRMED_OPT_IN","marketingState":"SUBSCRIBED"}`,
+		},
+		{
+			map[string]interface{}{"status": "notSet", "updatedAt": "2024-01-02", "createdAt": "2024-01-01"},
+			`{"consentUpdatedAt":"2024-01-02","marketingOptInLevel":"CONFIRMED_OPT_IN","marketingState":"PENDING"}`,
+		},
+		{
+			map[string]interface{}{"status": "optOut", "updatedAt": "2024-01-02"},
+			`{"consentUpdatedAt":"2024-01-02","marketingState":"UNSUBSCRIBED"}`,
+		},
+		{map[string]interface{}{"status": "undefined"}, `null`},
+	}
+
+	for _, test := range tests {
+		got, _ := json.Marshal(marketingConsent(test.recipient))
+		if string(got) != test.want {
+			t.Errorf("marketingConsent(%v) = %s, want %s", test.recipient, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// withMedia resolves the media of a Shopware product document. Every entry
+// of "media" is a product_media with its Shopware media nested; the entries
+// get the url, alt, title, mimeType, fileName and private flag of their
+// media, a sourceId (the product_media ID) and a checksum of the file. The
+// cover comes first, then the others by position. Entries that are not
+// images are left out.
+func withMedia(document map[string]interface{}) map[string]interface{} {
+	items, ok := document["media"].([]interface{})
+	if !ok {
+		return document
+	}
+
+	coverID := stringField(document, "coverId")
+
+	var entries []map[string]interface{}
+	for _, item := range items {
+		productMedia, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		media, ok := productMedia["media"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		mimeType := stringField(media, "mimeType")
+		if mimeType != "" && !strings.HasPrefix(mimeType, "image/") {
+			continue
+		}
+
+		url := stringField(media, "url")
+		fileName := stringField(media, "fileName")
+		if extension := stringField(media, "fileExtension"); fileName != "" && extension != "" {
+			fileName += "." + extension
+		}
+
+		entry := map[string]interface{}{
+			"sourceId": productMedia["id"],
+			"mediaId":  productMedia["mediaId"],
+			"position": intField(productMedia, "position"),
+			"cover":    productMedia["id"] == coverID && coverID != "",
+			"url":      url,
+			"alt":      translatedString(media, "alt"),
+			"title":    translatedString(media, "title"),
+			"mimeType": mimeType,
+			"fileName": fileName,
+			"private":  media["private"] == true,
+			"checksum": mediaChecksum(media),
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i]["cover"] != entries[j]["cover"] {
+			return entries[i]["cover"] == true
+		}
+		return entries[i]["position"].(int) < entries[j]["position"].(int)
+	})
+
+	list := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		list[i] = entry
+	}
+	document["media"] = list
+	return document
+}
+
+// mediaChecksum identifies the file of a Shopware media. Shopware replaces
+// the file of a media in place, so its upload time and size are part of it.
+func mediaChecksum(media map[string]interface{}) string {
+	return payloadHash([]byte(fmt.Sprintf("%s|%s|%v|%v",
+		stringField(media, "id"),
+		stringField(media, "url"),
+		media["fileSize"],
+		media["uploadedAt"],
+	)))
+}
+
+// DownloadMedia downloads a Shopware media file with the connector's
+// credentials, for files Shopify cannot fetch itself
+func (s *ShopwareService) DownloadMedia(connector *models.Connector, url string) ([]byte, error) {
+	if url == "" {
+		return nil, fmt.Errorf("media has no URL to download from")
+	}
+	if !strings.HasPrefix(url, "http") {
+		url = strings.TrimSuffix(connector.URL, "/") + "/" + strings.TrimPrefix(url, "/")
+	}
+
+	accessToken, err := s.GetAccessToken(connector)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error downloading media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading media: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, shopwareAPIError(resp, body)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWithMedia(t *testing.T) {
+	var document map[string]interface{}
+	json.Unmarshal([]byte(`{"coverId":"pm2","media":[
+		{"id":"pm1","mediaId":"m1","position":0,"media":{"id":"m1","url":"https://shop.test/a.jpg","mimeType":"image/jpeg","fileName":"a","fileExtension":"jpg","alt":"A","translated":{"alt":"Alt A"}}},
+		{"id":"pm3","mediaId":"m3","position":1,"media":{"id":"m3","url":"https://shop.test/manual.pdf","mimeType":"application/pdf"}},
+		{"id":"pm2","mediaId":"m2","position":2,"media":{"id":"m2","url":"","mimeType":"image/png","private":true,"title":"Cover"}},
+		{"id":"pm4","mediaId":"m4","position":1}
+	]}`), &document)
+
+	media, _ := withMedia(document)["media"].([]interface{})
+	if len(media) != 2 {
+		t.Fatalf("got %d media, want the 2 images", len(media))
+	}
+
+	cover := media[0].(map[string]interface{})
+	if cover["sourceId"] != "pm2" || cover["cover"] != true || cover["private"] != true || cover["title"] != "Cover" {
+		t.Errorf("first media = %v, want the private cover image", cover)
+	}
+
+	image := media[1].(map[string]interface{})
+	if image["sourceId"] != "pm1" || image["cover"] != false || image["alt"] != "Alt A" || image["fileName"] != "a.jpg" {
+		t.Errorf("second media = %v, want pm1 with its translated alt and full file name", image)
+	}
+	if image["checksum"] == "" || image["checksum"] == cover["checksum"] {
+		t.Errorf("checksums = %v and %v, want distinct ones", image["checksum"], cover["checksum"])
+	}
+}
+
+func TestWithMediaWithoutMedia(t *testing.T) {
+	document := withMedia(map[string]interface{}{"id": "p1"})
+	if _, ok := document["media"]; ok {
+		t.Errorf("withMedia added media to a product without them: %v", document)
+	}
+}
+
+func TestMediaChecksum(t *testing.T) {
+	media := map[string]interface{}{"id": "m1", "url": "https://shop.test/a.jpg", "fileSize": 100.0, "uploadedAt": "2024-01-01T00:00:00Z"}
+	checksum := mediaChecksum(media)
+
+	if mediaChecksum(map[string]interface{}{"id": "m1", "url": "https://shop.test/a.jpg", "fileSize": 100.0, "uploadedAt": "2024-01-01T00:00:00Z"}) != checksum {
+		t.Errorf("mediaChecksum differs for the same file")
+	}
+
+	// A file replaced in place keeps its ID and URL
+	media["uploadedAt"] = "2024-02-01T00:00:00Z"
+	if mediaChecksum(media) == checksum {
+		t.Errorf("mediaChecksum did not change for a new upload")
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// OrderStateChange is a change to a Shopware order made in Shopify
+type OrderStateChange string
+
+const (
+	// OrderStateChangeFulfilled means the order was fulfilled
+	OrderStateChangeFulfilled OrderStateChange = "fulfilled"
+	// OrderStateChangeCancelled means the order was cancelled
+	OrderStateChangeCancelled OrderStateChange = "cancelled"
+	// OrderStateChangeRefunded means the order was refunded in full
+	OrderStateChangeRefunded OrderStateChange = "refunded"
+	// OrderStateChangeRefundedPartially means part of the order was refunded
+	OrderStateChangeRefundedPartially OrderStateChange = "refunded_partially"
+)
+
+// stateTransitions are the transitions that lead a Shopware state machine
+// from each current state to the target state of a change. States without
+// an entry are already at the target or cannot reach it.
+type stateTransitions map[string][]string
+
+// orderStatePlan holds the transitions of a change for the order, its
+// deliveries and its latest transaction
+type orderStatePlan struct {
+	order       stateTransitions
+	delivery    stateTransitions
+	transaction stateTransitions
+}
+
+// orderStatePlans maps every change to the Shopware transitions it needs
+var orderStatePlans = map[OrderStateChange]orderStatePlan{
+	OrderStateChangeFulfilled: {
+		order: stateTransitions{
+			"open":        {"process", "complete"},
+			"in_progress": {"complete"},
+		},
+		delivery: stateTransitions{
+			"open":              {"ship"},
+			"shipped_partially": {"ship"},
+		},
+	},
+	OrderStateChangeCancelled: {
+		order: stateTransitions{
+			"open":        {"cancel"},
+			"in_progress": {"cancel"},
+		},
+		delivery: stateTransitions{
+			"open": {"cancel"},
+		},
+		transaction: stateTransitions{
+			"open":        {"cancel"},
+			"in_progress": {"cancel"},
+			"authorized":  {"cancel"},
+		},
+	},
+	OrderStateChangeRefunded: {
+		transaction: stateTransitions{
+			"paid":               {"refund"},
+			"paid_partially":     {"refund"},
+			"refunded_partially": {"refund"},
+		},
+	},
+	OrderStateChangeRefundedPartially: {
+		transaction: stateTransitions{
+			"paid":           {"refund_partially"},
+			"paid_partially": {"refund_partially"},
+		},
+	},
+}
+
+// TransitionOrder moves a Shopware order, its deliveries and its latest
+// transaction to the states of a change. The current states are read first,
+// so a repeated change does nothing. No emails are sent to the customer.
+func (s *ShopwareService) TransitionOrder(connector *models.Connector, orderID string, change OrderStateChange) error {
+	plan, ok := orderStatePlans[change]
+	if !ok {
+		return fmt.Errorf("unsupported order state change: %s", change)
+	}
+
+	order, err := s.GetOrder(connector, orderID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.applyTransitions(connector, "order", orderID, stateName(order), plan.order); err != nil {
+		return err
+	}
+
+	deliveries, _ := order["deliveries"].([]interface{})
+	for _, item := range deliveries {
+		delivery, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := s.applyTransitions(connector, "order_delivery", stringField(delivery, "id"), stateName(delivery), plan.delivery); err != nil {
+			return err
+		}
+	}
+
+	// Shopware uses the latest transaction as the order's payment
+	transactions, _ := order["transactions"].([]interface{})
+	if len(transactions) > 0 {
+		transaction, _ := transactions[len(transactions)-1].(map[string]interface{})
+		if err := s.applyTransitions(connector, "order_transaction", stringField(transaction, "id"), stateName(transaction), plan.transaction); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyTransitions runs the transitions of an entity's current state
+func (s *ShopwareService) applyTransitions(connector *models.Connector, entity, id, state string, transitions stateTransitions) error {
+	for _, transition := range transitions[state] {
+		path := fmt.Sprintf("/api/_action/%s/%s/state/%s", entity, id, transition)
+		if _, err := s.adminRequest(connector, http.MethodPost, path, map[string]interface{}{"sendMail": false}); err != nil {
+			return fmt.Errorf("error running %s transition %s on %s: %w", entity, transition, id, err)
+		}
+	}
+	return nil
+}
+
+// stateName returns the technical name of an entity's state machine state
+func stateName(entity map[string]interface{}) string {
+	state, _ := entity["stateMachineState"].(map[string]interface{})
+	return stringField(state, "technicalName")
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// orderAssociations are loaded with every order, so that its line items,
+// addresses, customer, deliveries and transactions come along in one
+// request. Transactions are sorted oldest first, so the last one is the
+// order's payment. Countries, states and the currency are resolved through
+// the connector's reference data.
+var orderAssociations = map[string]interface{}{
+	"lineItems": map[string]interface{}{},
+	"orderCustomer": map[string]interface{}{
+		"associations": map[string]interface{}{
+			"customer": map[string]interface{}{},
+		},
+	},
+	"billingAddress": map[string]interface{}{},
+	"addresses":      map[string]interface{}{},
+	"deliveries": map[string]interface{}{
+		"associations": map[string]interface{}{
+			"shippingOrderAddress": map[string]interface{}{},
+			"shippingMethod":       map[string]interface{}{},
+			"stateMachineState":    map[string]interface{}{},
+		},
+	},
+	"transactions": map[string]interface{}{
+		"sort": []map[string]interface{}{
+			{"field": "createdAt", "order": "ASC"},
+		},
+		"associations": map[string]interface{}{
+			"paymentMethod":     map[string]interface{}{},
+			"stateMachineState": map[string]interface{}{},
+		},
+	},
+	"stateMachineState": map[string]interface{}{},
+}
+
+// shopifyFinancialStatuses maps Shopware payment states to Shopify order
+// financial statuses
+var shopifyFinancialStatuses = map[string]string{
+	"paid":               "PAID",
+	"paid_partially":     "PARTIALLY_PAID",
+	"authorized":         "AUTHORIZED",
+	"refunded":           "REFUNDED",
+	"refunded_partially": "PARTIALLY_REFUNDED",
+	"cancelled":          "VOIDED",
+}
+
+// GetOrder gets a Shopware order with its associations
+func (s *ShopwareService) GetOrder(connector *models.Connector, orderID string) (map[string]interface{}, error) {
+	order, err := s.GetEntityDocument(connector, "order", orderID, orderAssociations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order data: %w", err)
+	}
+	return order, nil
+}
+
+// orderSourceDocument prepares a Shopware order for the field mappings. It
+// adds the order in Shopify's shape: "currencyCode", "customerEmail",
+// "items" (product and custom line items with their sourceId, sku, title,
+// quantity, price and taxLines), "discountCode" (the order's promotions),
+// "billing" and "shipping" addresses, "shippingLines", "financialStatus"
+// and "processedAt".
+func orderSourceDocument(document map[string]interface{}, references *ShopwareReferenceData) map[string]interface{} {
+	document["currencyCode"] = references.CurrencyCode(stringField(document, "currencyId"))
+	if customer, ok := document["orderCustomer"].(map[string]interface{}); ok {
+		document["customerEmail"] = stringField(customer, "email")
+	}
+
+	document["items"] = orderItems(document)
+	if discount := orderDiscountCode(document); discount != nil {
+		document["discountCode"] = discount
+	}
+
+	if billing, ok := document["billingAddress"].(map[string]interface{}); ok {
+		document["billing"] = orderAddress(billing, references)
+	}
+
+	deliveries, _ := document["deliveries"].([]interface{})
+	shippingLines := []interface{}{}
+	for _, item := range deliveries {
+		delivery, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if address, ok := delivery["shippingOrderAddress"].(map[string]interface{}); ok && document["shipping"] == nil {
+			document["shipping"] = orderAddress(address, references)
+		}
+
+		method, _ := delivery["shippingMethod"].(map[string]interface{})
+		costs, _ := delivery["shippingCosts"].(map[string]interface{})
+		price, _ := costs["totalPrice"].(float64)
+		shippingLines = append(shippingLines, map[string]interface{}{
+			"title": translatedString(method, "name"),
+			"price": formatAmount(price),
+		})
+	}
+	document["shippingLines"] = shippingLines
+
+	// The latest transaction holds the payment state
+	financialStatus := "PENDING"
+	transactions, _ := document["transactions"].([]interface{})
+	if len(transactions) > 0 {
+		transaction, _ := transactions[len(transactions)-1].(map[string]interface{})
+		if status, ok := shopifyFinancialStatuses[stateName(transaction)]; ok {
+			financialStatus = status
+		}
+	}
+	document["financialStatus"] = financialStatus
+
+	document["processedAt"] = stringField(document, "orderDateTime")
+
+	return document
+}
+
+// orderItems lists the top-level product and custom line items of an order
+// in Shopify's shape, in their order position. Promotions become the
+// order's discount code; other line items are left out.
+func orderItems(document map[string]interface{}) []interface{} {
+	lineItems, _ := document["lineItems"].([]interface{})
+
+	var items []map[string]interface{}
+	var positions []int
+	for _, item := range lineItems {
+		lineItem, ok := item.(map[string]interface{})
+		if !ok || lineItem["parentId"] != nil {
+			continue
+		}
+
+		itemType := stringField(lineItem, "type")
+		if itemType != "product" && itemType != "custom" {
+			continue
+		}
+
+		unitPrice, _ := lineItem["unitPrice"].(float64)
+		entry := map[string]interface{}{
+			"title":    stringField(lineItem, "label"),
+			"quantity": intField(lineItem, "quantity"),
+			"price":    formatAmount(unitPrice),
+			"taxLines": orderTaxLines(lineItem),
+		}
+		if itemType == "product" {
+			entry["sourceId"] = stringField(lineItem, "productId")
+			if payload, ok := lineItem["payload"].(map[string]interface{}); ok {
+				entry["sku"] = stringField(payload, "productNumber")
+			}
+		}
+
+		items = append(items, entry)
+		positions = append(positions, intField(lineItem, "position"))
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return positions[order[i]] < positions[order[j]]
+	})
+
+	list := make([]interface{}, len(items))
+	for i, index := range order {
+		list[i] = items[index]
+	}
+	return list
+}
+
+// orderDiscountCode combines the top-level promotion line items of an order
+// into one fixed discount, since a Shopify order takes a single discount
+// code. Its code lists the promotion codes, or the labels of promotions
+// without a code. Orders without promotions have none.
+func orderDiscountCode(document map[string]interface{}) map[string]interface{} {
+	lineItems, _ := document["lineItems"].([]interface{})
+
+	var codes []string
+	var amount float64
+	for _, item := range lineItems {
+		lineItem, ok := item.(map[string]interface{})
+		if !ok || lineItem["parentId"] != nil || stringField(lineItem, "type") != "promotion" {
+			continue
+		}
+
+		// Promotions have a negative price
+		totalPrice, _ := lineItem["totalPrice"].(float64)
+		amount -= totalPrice
+
+		code := ""
+		if payload, ok := lineItem["payload"].(map[string]interface{}); ok {
+			code = stringField(payload, "code")
+		}
+		if code == "" {
+			code = stringField(lineItem, "label")
+		}
+		codes = append(codes, code)
+	}
+
+	if len(codes) == 0 {
+		return nil
+	}
+	return map[string]interface{}{
+		"code":   strings.Join(codes, ", "),
+		"amount": formatAmount(amount),
+	}
+}
+
+// orderTaxLines reads the calculated taxes of a line item
+func orderTaxLines(lineItem map[string]interface{}) []interface{} {
+	price, _ := lineItem["price"].(map[string]interface{})
+	taxes, _ := price["calculatedTaxes"].([]interface{})
+
+	taxLines := []interface{}{}
+	for _, item := range taxes {
+		tax, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		amount, _ := tax["tax"].(float64)
+		rate, _ := tax["taxRate"].(float64)
+		taxLines = append(taxLines, map[string]interface{}{
+			"title": fmt.Sprintf("%s%%", strconv.FormatFloat(rate, 'f', -1, 64)),
+			"price": formatAmount(amount),
+			"rate":  strconv.FormatFloat(rate/100, 'f', -1, 64),
+		})
+	}
+	return taxLines
+}
+
+// orderAddress converts a Shopware order address to a Shopify address
+func orderAddress(address map[string]interface{}, references *ShopwareReferenceData) map[string]interface{} {
+	countryID := stringField(address, "countryId")
+	stateID := stringField(address, "countryStateId")
+
+	return map[string]interface{}{
+		"firstName":    stringField(address, "firstName"),
+		"lastName":     stringField(address, "lastName"),
+		"address1":     stringField(address, "street"),
+		"address2":     stringField(address, "additionalAddressLine1"),
+		"city":         stringField(address, "city"),
+		"zip":          stringField(address, "zipcode"),
+		"phone":        stringField(address, "phoneNumber"),
+		"company":      stringField(address, "company"),
+		"country":      references.Countries[countryID].Name,
+		"countryCode":  references.CountryCode(countryID),
+		"province":     references.States[stateID].Name,
+		"provinceCode": references.ProvinceCode(stateID),
+	}
+}
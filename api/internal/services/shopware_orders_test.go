@@ -0,0 +1,63 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestOrderDiscountCode(t *testing.T) {
+	document := map[string]interface{}{
+		"lineItems": []interface{}{
+			map[string]interface{}{"type": "product", "label": "Shirt", "totalPrice": 40.0},
+			map[string]interface{}{"type": "promotion", "label": "Summer sale", "totalPrice": -5.5,
+				"payload": map[string]interface{}{"code": "SUMMER"}},
+			map[string]interface{}{"type": "promotion", "label": "Loyalty", "totalPrice": -2.0,
+				"payload": map[string]interface{}{"code": ""}},
+			map[string]interface{}{"type": "promotion", "label": "Nested", "totalPrice": -1.0, "parentId": "abc"},
+		},
+	}
+
+	want := map[string]interface{}{"code": "SUMMER, Loyalty", "amount": "7.50"}
+	if got := orderDiscountCode(document); !reflect.DeepEqual(got, want) {
+		t.Errorf("orderDiscountCode() = %v, want %v", got, want)
+	}
+
+	items := orderItems(document)
+	if len(items) != 1 {
+		t.Errorf("orderItems() has %d items, want only the product", len(items))
+	}
+
+	delete(document, "lineItems")
+	if got := orderDiscountCode(document); got != nil {
+		t.Errorf("orderDiscountCode() without promotions = %v, want nil", got)
+	}
+}
+
+func TestCheckOrderDiscount(t *testing.T) {
+	source := []byte(`{"discountCode":{"code":"SUMMER","amount":"5.00"}}`)
+
+	err := checkOrderDiscount(source, &ShopifyOrder{})
+	if !errors.Is(err, ErrOrderDiscountNotMapped) {
+		t.Errorf("unmapped discount: err = %v, want %v", err, ErrOrderDiscountNotMapped)
+	}
+
+	order := &ShopifyOrder{Currency: "EUR", DiscountCode: &ShopifyOrderDiscount{Code: "SUMMER", Amount: "5.00"}}
+	if err := checkOrderDiscount(source, order); err != nil {
+		t.Errorf("mapped discount: err = %v", err)
+	}
+	if err := checkOrderDiscount([]byte(`{"items":[]}`), &ShopifyOrder{}); err != nil {
+		t.Errorf("order without promotions: err = %v", err)
+	}
+
+	input := buildOrderInput(order)
+	want := map[string]interface{}{
+		"itemFixedDiscountCode": map[string]interface{}{
+			"code":      "SUMMER",
+			"amountSet": moneyBag("5.00", "EUR"),
+		},
+	}
+	if !reflect.DeepEqual(input["discountCode"], want) {
+		t.Errorf("discountCode input = %v, want %v", input["discountCode"], want)
+	}
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// shopwareDefaultCurrencyID is the ID of the system default currency, which
+// every Shopware price has an entry for
+const shopwareDefaultCurrencyID = "b7d2554b0ce847cd82f3ac9bd1c0dfca"
+
+// referenceDataTTL is how long a connector's reference data is cached
+const referenceDataTTL = time.Hour
+
+// referenceReloadInterval is how soon after loading a connector's reference
+// data an unknown ID may reload it
+const referenceReloadInterval = time.Minute
+
+// referencePageSize is the page size reference entities are loaded with
+const referencePageSize = 500
+
+// ShopwareCountry is a Shopware country
+type ShopwareCountry struct {
+	ISO  string // ISO 3166-1 alpha-2 code, e.g. DE
+	ISO3 string // ISO 3166-1 alpha-3 code, e.g. DEU
+	Name string
+}
+
+// ShopwareCountryState is a Shopware country state
+type ShopwareCountryState struct {
+	CountryID string
+	ShortCode string // ISO 3166-2 code, e.g. US-NY
+	Name      string
+}
+
+// ShopwareSalutation is a Shopware salutation
+type ShopwareSalutation struct {
+	Key  string // Salutation key, e.g. mr, mrs or not_specified
+	Name string
+}
+
+// ShopwareReferenceData holds the countries, country states, currencies and
+// salutations of a Shopware connector by ID, which Shopware entities only
+// reference
+type ShopwareReferenceData struct {
+	Countries   map[string]ShopwareCountry
+	States      map[string]ShopwareCountryState
+	Currencies  map[string]string // ISO 4217 code by currency ID
+	Salutations map[string]ShopwareSalutation
+	LoadedAt    time.Time
+}
+
+// CountryCode returns the ISO code of a country, or "" if it is unknown
+func (r *ShopwareReferenceData) CountryCode(countryID string) string {
+	return r.Countries[countryID].ISO
+}
+
+// ProvinceCode returns the code of a country state without its country
+// prefix, e.g. NY for US-NY, or "" if it is unknown
+func (r *ShopwareReferenceData) ProvinceCode(stateID string) string {
+	state, ok := r.States[stateID]
+	if !ok {
+		return ""
+	}
+
+	prefix := r.CountryCode(state.CountryID) + "-"
+	if len(prefix) > 1 && strings.HasPrefix(state.ShortCode, prefix) {
+		return strings.TrimPrefix(state.ShortCode, prefix)
+	}
+	if i := strings.Index(state.ShortCode, "-"); i >= 0 {
+		return state.ShortCode[i+1:]
+	}
+	return state.ShortCode
+}
+
+// CurrencyCode returns the ISO code of a currency, or "" if it is unknown
+func (r *ShopwareReferenceData) CurrencyCode(currencyID string) string {
+	return r.Currencies[currencyID]
+}
+
+// shopwareReferences holds the reference data of Shopware connectors
+var shopwareReferences = newTTLCache[string, *ShopwareReferenceData](referenceDataTTL, 0)
+
+// ReferenceData returns the countries, country states, currencies and
+// salutations of a Shopware connector. They are loaded once and cached for
+// an hour.
+func (s *ShopwareService) ReferenceData(connector *models.Connector) (*ShopwareReferenceData, error) {
+	key := shopwareTokenKey(connector)
+
+	if data, ok := shopwareReferences.get(key); ok {
+		return data, nil
+	}
+
+	data := &ShopwareReferenceData{
+		Countries:   make(map[string]ShopwareCountry),
+		States:      make(map[string]ShopwareCountryState),
+		Currencies:  make(map[string]string),
+		Salutations: make(map[string]ShopwareSalutation),
+		LoadedAt:    time.Now(),
+	}
+
+	err := s.searchAll(connector, "country", func(country map[string]interface{}) {
+		data.Countries[stringField(country, "id")] = ShopwareCountry{
+			ISO:  strings.ToUpper(stringField(country, "iso")),
+			ISO3: strings.ToUpper(stringField(country, "iso3")),
+			Name: translatedString(country, "name"),
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.searchAll(connector, "country-state", func(state map[string]interface{}) {
+		data.States[stringField(state, "id")] = ShopwareCountryState{
+			CountryID: stringField(state, "countryId"),
+			ShortCode: strings.ToUpper(stringField(state, "shortCode")),
+			Name:      translatedString(state, "name"),
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.searchAll(connector, "currency", func(currency map[string]interface{}) {
+		if code := strings.ToUpper(stringField(currency, "isoCode")); code != "" {
+			data.Currencies[stringField(currency, "id")] = code
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.searchAll(connector, "salutation", func(salutation map[string]interface{}) {
+		data.Salutations[stringField(salutation, "id")] = ShopwareSalutation{
+			Key:  stringField(salutation, "salutationKey"),
+			Name: translatedString(salutation, "displayName"),
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shopwareReferences.put(key, data)
+
+	return data, nil
+}
+
+// ReloadReferenceData loads a Shopware connector's reference data again, so
+// entities created since it was cached are known. Data loaded less than a
+// minute ago is returned as it is, so unknown IDs cannot reload it on every
+// lookup.
+func (s *ShopwareService) ReloadReferenceData(connector *models.Connector, data *ShopwareReferenceData) (*ShopwareReferenceData, error) {
+	if time.Since(data.LoadedAt) < referenceReloadInterval {
+		return data, nil
+	}
+
+	shopwareReferences.invalidate(shopwareTokenKey(connector))
+	return s.ReferenceData(connector)
+}
+
+// searchAll calls fn with every entity of a Shopware entity type
+func (s *ShopwareService) searchAll(connector *models.Connector, entity string, fn func(map[string]interface{})) error {
+	for page := 1; ; page++ {
+		result, err := s.SearchEntities(connector, entity, SearchCriteria{
+			Page:  page,
+			Limit: referencePageSize,
+			Sort:  []map[string]interface{}{{"field": "id", "order": "ASC"}},
+		})
+		if err != nil {
+			return fmt.Errorf("error fetching %s entities: %w", entity, err)
+		}
+
+		for _, document := range result.Data {
+			fn(document)
+		}
+
+		if len(result.Data) < referencePageSize {
+			return nil
+		}
+	}
+}
+
+// CurrencyCodes returns the ISO codes of a Shopware connector's currencies
+// by currency ID
+func (s *ShopwareService) CurrencyCodes(connector *models.Connector) (map[string]string, error) {
+	data, err := s.ReferenceData(connector)
+	if err != nil {
+		return nil, err
+	}
+	return data.Currencies, nil
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -79,51 +80,6 @@ type Image struct {
 	Size int    `json:"fileSize"`
 }
 
-// OrderResponse represents a Shopware order response
-type OrderResponse struct {
-	ID              string        `json:"id"`
-	OrderNumber     string        `json:"orderNumber"`
-	Customer        Customer      `json:"orderCustomer"`
-	BillingAddress  Address       `json:"billingAddress"`
-	ShippingAddress Address       `json:"shippingAddress"`
-	LineItems       []OrderItem   `json:"lineItems"`
-	TotalPrice      float64       `json:"amountTotal"`
-	TaxStatus       string        `json:"taxStatus"`
-	PaymentStatus   PaymentStatus `json:"stateMachineState"`
-	CreatedAt       time.Time     `json:"createdAt"`
-}
-
-// Customer represents a Shopware customer
-type Customer struct {
-	ID        string `json:"id"`
-	Email     string `json:"email"`
-	FirstName string `json:"firstName"`
-	LastName  string `json:"lastName"`
-}
-
-// Address represents a Shopware address
-type Address struct {
-	Street     string `json:"street"`
-	City       string `json:"city"`
-	PostalCode string `json:"zipcode"`
-	Country    string `json:"countryId"`
-}
-
-// OrderItem represents a Shopware order item
-type OrderItem struct {
-	ID         string  `json:"id"`
-	ProductID  string  `json:"productId"`
-	Quantity   int     `json:"quantity"`
-	UnitPrice  float64 `json:"unitPrice"`
-	TotalPrice float64 `json:"totalPrice"`
-}
-
-// PaymentStatus represents a Shopware payment status
-type PaymentStatus struct {
-	TechnicalName string `json:"technicalName"`
-	Name          string `json:"name"`
-}
-
 // TestConnection tests the connection to Shopware
 func (s *ShopwareService) TestConnection(connector *models.Connector) error {
 	url := fmt.Sprintf("%s/api/oauth/token", connector.URL)
@@ -165,8 +121,25 @@ func (s *ShopwareService) TestConnection(connector *models.Connector) error {
 	return nil
 }
 
-// GetAccessToken gets an access token from Shopware
+// GetAccessToken gets an access token from Shopware. Tokens are cached per
+// connector until shortly before they expire.
 func (s *ShopwareService) GetAccessToken(connector *models.Connector) (string, error) {
+	if accessToken, ok := shopwareTokens.get(shopwareTokenKey(connector)); ok {
+		return accessToken, nil
+	}
+
+	accessToken, expiresIn, err := s.requestAccessToken(connector)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrShopwareAuth, err)
+	}
+
+	cacheShopwareToken(connector, accessToken, expiresIn)
+	return accessToken, nil
+}
+
+// requestAccessToken requests a client credentials token from Shopware and
+// returns it with its lifetime in seconds
+func (s *ShopwareService) requestAccessToken(connector *models.Connector) (string, int, error) {
 	url := fmt.Sprintf("%s/api/oauth/token", connector.URL)
 
 	requestBody, err := json.Marshal(map[string]string{
@@ -177,25 +150,25 @@ func (s *ShopwareService) GetAccessToken(connector *models.Connector) (string, e
 	})
 
 	if err != nil {
-		return "", fmt.Errorf("error marshaling request body: %w", err)
+		return "", 0, fmt.Errorf("error marshaling request body: %w", err)
 	}
 
 	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", fmt.Errorf("error creating request: %w", err)
+		return "", 0, fmt.Errorf("error creating request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("error making request: %w", err)
+		return "", 0, fmt.Errorf("error making request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("error response from Shopware: %s - %s", resp.Status, string(body))
+		return "", 0, fmt.Errorf("error response from Shopware: %s - %s", resp.Status, string(body))
 	}
 
 	var tokenResponse struct {
@@ -205,10 +178,10 @@ func (s *ShopwareService) GetAccessToken(connector *models.Connector) (string, e
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&tokenResponse); err != nil {
-		return "", fmt.Errorf("error decoding response: %w", err)
+		return "", 0, fmt.Errorf("error decoding response: %w", err)
 	}
 
-	return tokenResponse.AccessToken, nil
+	return tokenResponse.AccessToken, tokenResponse.ExpiresIn, nil
 }
 
 // GetProduct gets a product from Shopware
@@ -346,7 +319,7 @@ func (s *ShopwareService) GetProduct(connector *models.Connector, productID stri
 	fmt.Printf("API response body: %s\n", string(body))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error response from Shopware: %s - %s", resp.Status, string(body))
+		return nil, shopwareAPIError(resp, body)
 	}
 
 	// Parse the nested response
@@ -374,51 +347,296 @@ func (s *ShopwareService) GetProduct(connector *models.Connector, productID stri
 	return &product, nil
 }
 
-// Get All Products
-func (s *ShopwareService) GetAllProducts(connector *models.Connector) ([]ProductResponse, error) {
-	accessToken, err := s.GetAccessToken(connector)
+// FetchSourceData fetches the source document for an entity as JSON
+func (s *ShopwareService) FetchSourceData(connector *models.Connector, dataflowType models.DataflowType, id string) ([]byte, error) {
+	switch dataflowType {
+	case models.DataflowTypeProduct:
+		product, err := s.GetEntityDocument(connector, "product", id, productAssociations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get product data: %w", err)
+		}
+
+		// A variant child is synced as part of its parent
+		if parentID, ok := product["parentId"].(string); ok && parentID != "" {
+			product, err = s.GetEntityDocument(connector, "product", parentID, productAssociations)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get parent product data: %w", err)
+			}
+		}
+
+		currencies, err := s.CurrencyCodes(connector)
+		if err != nil {
+			return nil, err
+		}
+
+		sourceData, err := json.Marshal(productSourceDocument(product, currencies))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal product data: %w", err)
+		}
+		return sourceData, nil
+
+	case models.DataflowTypeCategory:
+		return s.fetchCategory(connector, id, nil)
+
+	case models.DataflowTypeCustomer:
+		return s.fetchCustomer(connector, id)
+
+	case models.DataflowTypeOrder:
+		order, err := s.GetOrder(connector, id)
+		if err != nil {
+			return nil, err
+		}
+
+		references, err := s.ReferenceData(connector)
+		if err != nil {
+			return nil, err
+		}
+
+		sourceData, err := json.Marshal(orderSourceDocument(order, references))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal order data: %w", err)
+		}
+		return sourceData, nil
+
+	default:
+		return nil, fmt.Errorf("fetching source data not supported for dataflow type: %s", dataflowType)
+	}
+}
+
+// FetchSourceDataForDataflow fetches the source document of a dataflow as
+// JSON. Inventory dataflows load the product with its variant children and
+// the associations their locations read stock from; category dataflows
+// title the collection as configured.
+func (s *ShopwareService) FetchSourceDataForDataflow(dataflow *models.Dataflow, id string) ([]byte, error) {
+	switch dataflow.Type {
+	case models.DataflowTypeInventory:
+		return s.fetchInventory(dataflow, id)
+	case models.DataflowTypeCategory:
+		return s.fetchCategory(&dataflow.SourceConnector, id, dataflow.Category)
+	default:
+		return s.FetchSourceData(&dataflow.SourceConnector, dataflow.Type, id)
+	}
+}
+
+// fetchInventory fetches the product an inventory dataflow reads stock from
+func (s *ShopwareService) fetchInventory(dataflow *models.Dataflow, id string) ([]byte, error) {
+	associations := inventoryAssociations(dataflow.Inventory)
+	product, err := s.GetEntityDocument(&dataflow.SourceConnector, "product", id, associations)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get product data: %w", err)
 	}
-	url := fmt.Sprintf("%s/api/product", connector.URL)
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+
+	// Stock of a variant child is synced with its siblings
+	if parentID, ok := product["parentId"].(string); ok && parentID != "" {
+		product, err = s.GetEntityDocument(&dataflow.SourceConnector, "product", parentID, associations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent product data: %w", err)
+		}
+	}
+
+	sourceData, err := json.Marshal(product)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("failed to marshal product data: %w", err)
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
-	req.Header.Set("Accept", "application/json")
-	resp, err := s.httpClient.Do(req)
+	return sourceData, nil
+}
+
+// inventoryAssociations returns the product associations an inventory
+// dataflow loads: the variant children, and the configured associations on
+// both the product and its children
+func inventoryAssociations(config *models.InventoryConfig) map[string]interface{} {
+	extra := make(map[string]interface{})
+	if config != nil {
+		for _, association := range config.Associations {
+			extra[association] = map[string]interface{}{}
+		}
+	}
+
+	associations := map[string]interface{}{
+		"children": map[string]interface{}{
+			"associations": extra,
+		},
+	}
+	for name, association := range extra {
+		associations[name] = association
+	}
+	return associations
+}
+
+// withTranslatedFallback fills empty fields of a Shopware document from its
+// "translated" object, as GetProduct does for name and description
+func withTranslatedFallback(document map[string]interface{}, fields ...string) map[string]interface{} {
+	translated, ok := document["translated"].(map[string]interface{})
+	if !ok {
+		return document
+	}
+
+	for _, field := range fields {
+		if value, ok := document[field]; ok && value != nil && value != "" {
+			continue
+		}
+		if value, ok := translated[field]; ok && value != nil {
+			document[field] = value
+		}
+	}
+
+	return document
+}
+
+// SearchCriteria represents Shopware Admin API search criteria
+type SearchCriteria struct {
+	Page           int                      `json:"page"`
+	Limit          int                      `json:"limit"`
+	TotalCountMode int                      `json:"total-count-mode,omitempty"`
+	IDs            []string                 `json:"ids,omitempty"`
+	Filter         []map[string]interface{} `json:"filter,omitempty"`
+	Sort           []map[string]interface{} `json:"sort,omitempty"`
+	Associations   map[string]interface{}   `json:"associations,omitempty"`
+}
+
+// SearchResult represents a page of Shopware Admin API search results
+type SearchResult struct {
+	Total int                      `json:"total"`
+	Data  []map[string]interface{} `json:"data"`
+}
+
+// SearchEntities fetches one page of entities through the Admin API search
+// endpoint, e.g. POST /api/search/product
+func (s *ShopwareService) SearchEntities(connector *models.Connector, entity string, criteria SearchCriteria) (*SearchResult, error) {
+	body, err := s.adminRequest(connector, http.MethodPost, "/api/search/"+entity, criteria)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("error response from Shopware: %s - %s", resp.Status, string(body))
+
+	var result SearchResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding search response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// ErrShopwareEntityNotFound is returned when a Shopware entity does not exist
+var ErrShopwareEntityNotFound = errors.New("entity not found in Shopware")
+
+// GetTranslatedEntity gets a single entity with the sw-language-id header
+// set, so its "translated" object is in that language. An empty language
+// uses the system language.
+func (s *ShopwareService) GetTranslatedEntity(connector *models.Connector, entity, id, languageID string) (map[string]interface{}, error) {
+	var headers map[string]string
+	if languageID != "" {
+		headers = map[string]string{"sw-language-id": languageID}
 	}
+
+	body, err := s.adminRequestWithHeaders(connector, http.MethodPost, "/api/search/"+entity, SearchCriteria{
+		Page:  1,
+		Limit: 1,
+		IDs:   []string{id},
+	}, headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var result SearchResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error decoding search response: %w", err)
+	}
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrShopwareEntityNotFound, entity, id)
+	}
+
+	return result.Data[0], nil
+}
+
+// GetAllProducts gets all products from Shopware, page by page
+func (s *ShopwareService) GetAllProducts(connector *models.Connector) ([]ProductResponse, error) {
 	var products []ProductResponse
-	if err := json.NewDecoder(resp.Body).Decode(&products); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
+
+	for page := 1; ; page++ {
+		result, err := s.SearchEntities(connector, "product", SearchCriteria{
+			Page:  page,
+			Limit: 100,
+			Sort:  []map[string]interface{}{{"field": "id", "order": "ASC"}},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		pageJSON, err := json.Marshal(result.Data)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding search results: %w", err)
+		}
+
+		var pageProducts []ProductResponse
+		if err := json.Unmarshal(pageJSON, &pageProducts); err != nil {
+			return nil, fmt.Errorf("error decoding response: %w", err)
+		}
+
+		products = append(products, pageProducts...)
+
+		if len(result.Data) < 100 {
+			return products, nil
+		}
+	}
+}
+
+// GetEntityDocument gets the full JSON document of a single entity through
+// the search endpoint so that associations can be requested with it
+func (s *ShopwareService) GetEntityDocument(connector *models.Connector, entity, id string, associations map[string]interface{}) (map[string]interface{}, error) {
+	result, err := s.SearchEntities(connector, entity, SearchCriteria{
+		Page:         1,
+		Limit:        1,
+		IDs:          []string{id},
+		Associations: associations,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(result.Data) == 0 {
+		return nil, fmt.Errorf("%s %s not found in Shopware", entity, id)
 	}
-	return products, nil
+
+	return result.Data[0], nil
+}
+
+// adminRequest sends an authenticated request to the Shopware Admin API and
+// returns the response body
+func (s *ShopwareService) adminRequest(connector *models.Connector, method, path string, payload interface{}) ([]byte, error) {
+	return s.adminRequestWithHeaders(connector, method, path, payload, nil)
 }
 
-// GetOrder gets an order from Shopware
-func (s *ShopwareService) GetOrder(connector *models.Connector, orderID string) (*OrderResponse, error) {
+// adminRequestWithHeaders is adminRequest with extra request headers, e.g.
+// sw-language-id to read translations in a specific language
+func (s *ShopwareService) adminRequestWithHeaders(connector *models.Connector, method, path string, payload interface{}, headers map[string]string) ([]byte, error) {
 	accessToken, err := s.GetAccessToken(connector)
 	if err != nil {
 		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/api/order/%s", connector.URL, orderID)
+	var requestBody io.Reader
+	if payload != nil {
+		jsonBody, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling request body: %w", err)
+		}
+		requestBody = bytes.NewBuffer(jsonBody)
+	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequest(method, connector.URL+path, requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
 
 	resp, err := s.httpClient.Do(req)
 	if err != nil {
@@ -426,17 +644,21 @@ func (s *ShopwareService) GetOrder(connector *models.Connector, orderID string)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("error response from Shopware: %s - %s", resp.Status, string(body))
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
 
-	var order OrderResponse
-	if err := json.NewDecoder(resp.Body).Decode(&order); err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
+	if resp.StatusCode == http.StatusUnauthorized {
+		// The cached token was revoked or expired early
+		shopwareTokens.invalidate(shopwareTokenKey(connector))
 	}
 
-	return &order, nil
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, shopwareAPIError(resp, body)
+	}
+
+	return body, nil
 }
 
 // RegisterWebhooks registers webhooks with Shopware
@@ -481,6 +703,18 @@ func (s *ShopwareService) RegisterWebhooks(connector *models.Connector, callback
 		return err
 	}
 
+	// Register category webhook
+	if err := s.registerWebhook(connector, accessToken, "category.written", callbackURL); err != nil {
+		return err
+	}
+
+	// Register customer webhooks
+	for _, event := range []string{"customer.written", "customer.register"} {
+		if err := s.registerWebhook(connector, accessToken, event, callbackURL); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -582,3 +816,11 @@ func (s *ShopwareService) GetWebhooks(connector *models.Connector) ([]map[string
 	// If all parsing attempts fail, return error with response content
 	return nil, fmt.Errorf("unable to parse webhook response: %s", string(body))
 }
+
+// shopwareAPIError builds the error for an unexpected Shopware API response
+func shopwareAPIError(resp *http.Response, body []byte) error {
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    fmt.Sprintf("error response from Shopware: %s - %s", resp.Status, string(body)),
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// ErrShopwareProductNotFound is returned when a Shopware product lookup
+// finds no product
+var ErrShopwareProductNotFound = errors.New("no Shopware product found")
+
+// FindProductBySKU returns the ID of the Shopware product or variant whose
+// product number is a SKU
+func (s *ShopwareService) FindProductBySKU(connector *models.Connector, sku string) (string, error) {
+	result, err := s.SearchEntities(connector, "product", SearchCriteria{
+		Page:  1,
+		Limit: 1,
+		Filter: []map[string]interface{}{
+			{"type": "equals", "field": "productNumber", "value": sku},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("error searching products by SKU: %w", err)
+	}
+
+	if len(result.Data) == 0 {
+		return "", fmt.Errorf("%w with product number: %s", ErrShopwareProductNotFound, sku)
+	}
+	return stringField(result.Data[0], "id"), nil
+}
+
+// SetProductStock sets the stock of a Shopware product or variant. Shopware
+// derives the available stock from it.
+func (s *ShopwareService) SetProductStock(connector *models.Connector, productID string, stock int) error {
+	path := fmt.Sprintf("/api/product/%s", productID)
+	if _, err := s.adminRequest(connector, http.MethodPatch, path, map[string]interface{}{"stock": stock}); err != nil {
+		return fmt.Errorf("error setting stock of product %s: %w", productID, err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// shopwareTokenMargin is how long before its expiry a cached token is
+// replaced, so that a request never starts with a token about to expire
+const shopwareTokenMargin = 30 * time.Second
+
+// shopwareTokens holds Shopware access tokens by connector credentials.
+// Every token expires with its own lifetime.
+var shopwareTokens = newTTLCache[string, string](0, 0)
+
+// shopwareTokenKey identifies a connector's credentials
+func shopwareTokenKey(connector *models.Connector) string {
+	return fmt.Sprintf("%d|%s|%s", connector.ID, connector.URL, connector.ApiKey)
+}
+
+// cacheShopwareToken caches a connector's token that expires in the given
+// number of seconds
+func cacheShopwareToken(connector *models.Connector, token string, expiresIn int) {
+	ttl := time.Duration(expiresIn)*time.Second - shopwareTokenMargin
+	if ttl <= 0 {
+		return
+	}
+	shopwareTokens.putFor(shopwareTokenKey(connector), token, ttl)
+}
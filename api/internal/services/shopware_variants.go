@@ -0,0 +1,377 @@
+package services
+
+import (
+	"sort"
+	"strconv"
+)
+
+// productAssociations are loaded with every product, so that its advanced
+// prices, media, variant children and their options come along in one
+// request
+var productAssociations = map[string]interface{}{
+	"prices": map[string]interface{}{},
+	"media": map[string]interface{}{
+		"associations": map[string]interface{}{
+			"media": map[string]interface{}{},
+		},
+	},
+	"children": map[string]interface{}{
+		"associations": map[string]interface{}{
+			"prices": map[string]interface{}{},
+			"options": map[string]interface{}{
+				"associations": map[string]interface{}{
+					"group": map[string]interface{}{},
+				},
+			},
+		},
+	},
+}
+
+// productSourceDocument prepares a Shopware product for the field mappings.
+// Currencies maps Shopware currency IDs to ISO codes.
+func productSourceDocument(document map[string]interface{}, currencies map[string]string) map[string]interface{} {
+	return withMedia(withVariants(withTranslatedFallback(document, "name", "description"), currencies))
+}
+
+// withVariants adds the product's variants in Shopify's shape to a Shopware
+// product document. "variants" lists one entry per variant child, or a
+// single entry for the product itself if it has no children, with its
+// sourceId, sku, prices, barcode, weight and optionValues. "variantOptions"
+// lists the option groups the children use, with their values. Children
+// inherit the prices and weight of the parent when they have none.
+func withVariants(document map[string]interface{}, currencies map[string]string) map[string]interface{} {
+	children, _ := document["children"].([]interface{})
+	if len(children) == 0 {
+		document["variants"] = []interface{}{shopwareVariant(document, nil, currencies)}
+		document["variantOptions"] = []interface{}{}
+		return document
+	}
+
+	var groups []*variantOptionGroup
+	groupsByName := make(map[string]*variantOptionGroup)
+
+	var variants []map[string]interface{}
+	var variantValues []map[string]string
+	for _, item := range children {
+		child, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		values := make(map[string]string)
+		options, _ := child["options"].([]interface{})
+		for _, item := range options {
+			option, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			group, _ := option["group"].(map[string]interface{})
+			groupName := translatedString(group, "name")
+			value := translatedString(option, "name")
+			if groupName == "" || value == "" {
+				continue
+			}
+
+			g, ok := groupsByName[groupName]
+			if !ok {
+				g = &variantOptionGroup{name: groupName, position: intField(group, "position")}
+				groupsByName[groupName] = g
+				groups = append(groups, g)
+			}
+			g.add(value, intField(option, "position"))
+			values[groupName] = value
+		}
+
+		variants = append(variants, shopwareVariant(child, document, currencies))
+		variantValues = append(variantValues, values)
+	}
+
+	// Sort so that the same product always produces the same payload
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].position != groups[j].position {
+			return groups[i].position < groups[j].position
+		}
+		return groups[i].name < groups[j].name
+	})
+
+	variantOptions := make([]interface{}, len(groups))
+	for i, g := range groups {
+		variantOptions[i] = map[string]interface{}{
+			"name":   g.name,
+			"values": g.sortedValues(),
+		}
+	}
+
+	// Option values follow the order of the options
+	for i, variant := range variants {
+		optionValues := []interface{}{}
+		for _, g := range groups {
+			if value, ok := variantValues[i][g.name]; ok {
+				optionValues = append(optionValues, map[string]interface{}{
+					"optionName": g.name,
+					"name":       value,
+				})
+			}
+		}
+		variant["optionValues"] = optionValues
+	}
+
+	sort.SliceStable(variants, func(i, j int) bool {
+		return variants[i]["sku"].(string) < variants[j]["sku"].(string)
+	})
+
+	variantList := make([]interface{}, len(variants))
+	for i, variant := range variants {
+		variantList[i] = variant
+	}
+
+	document["variants"] = variantList
+	document["variantOptions"] = variantOptions
+	return document
+}
+
+// shopwareVariant builds the Shopify variant of a Shopware product. Fields
+// the product leaves empty are read from the parent, if any. "price" and
+// "compareAtPrice" are the gross and list price in the default currency;
+// "prices" has the gross and net amounts in every currency and
+// "advancedPrices" the rule and quantity based prices.
+func shopwareVariant(product, parent map[string]interface{}, currencies map[string]string) map[string]interface{} {
+	field := func(name string) interface{} {
+		if value, ok := product[name]; ok && value != nil {
+			return value
+		}
+		if parent != nil {
+			return parent[name]
+		}
+		return nil
+	}
+
+	variant := map[string]interface{}{
+		"sourceId": product["id"],
+		"sku":      stringField(product, "productNumber"),
+	}
+
+	prices := shopwarePrices(field("price"))
+	if price, ok := defaultPrice(prices); ok {
+		variant["price"] = formatAmount(price.gross)
+		if price.listGross > 0 {
+			variant["compareAtPrice"] = formatAmount(price.listGross)
+		}
+	}
+	variant["prices"] = variantPrices(prices, currencies)
+
+	// Advanced prices are inherited as a whole
+	advancedPrices, _ := product["prices"].([]interface{})
+	if len(advancedPrices) == 0 && parent != nil {
+		advancedPrices, _ = parent["prices"].([]interface{})
+	}
+	variant["advancedPrices"] = variantAdvancedPrices(advancedPrices, currencies)
+
+	if ean, ok := field("ean").(string); ok && ean != "" {
+		variant["barcode"] = ean
+	}
+	if weight, ok := field("weight").(float64); ok && weight > 0 {
+		variant["weight"] = weight
+		variant["weightUnit"] = "KILOGRAMS"
+	}
+
+	return variant
+}
+
+// shopwarePrice is the entry of a Shopware price collection for a currency
+type shopwarePrice struct {
+	currencyID string
+	gross      float64
+	net        float64
+	listGross  float64
+	listNet    float64
+}
+
+// shopwarePrices reads a Shopware price collection
+func shopwarePrices(value interface{}) []shopwarePrice {
+	entries, _ := value.([]interface{})
+
+	prices := make([]shopwarePrice, 0, len(entries))
+	for _, entry := range entries {
+		price, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		gross, ok := price["gross"].(float64)
+		if !ok {
+			continue
+		}
+		net, _ := price["net"].(float64)
+
+		p := shopwarePrice{currencyID: stringField(price, "currencyId"), gross: gross, net: net}
+		if listPrice, ok := price["listPrice"].(map[string]interface{}); ok {
+			p.listGross, _ = listPrice["gross"].(float64)
+			p.listNet, _ = listPrice["net"].(float64)
+		}
+		prices = append(prices, p)
+	}
+	return prices
+}
+
+// defaultPrice returns the price in the Shopware default currency, or the
+// first price if there is none
+func defaultPrice(prices []shopwarePrice) (shopwarePrice, bool) {
+	for _, price := range prices {
+		if price.currencyID == shopwareDefaultCurrencyID {
+			return price, true
+		}
+	}
+	if len(prices) == 0 {
+		return shopwarePrice{}, false
+	}
+	return prices[0], true
+}
+
+// variantPrices lists the prices of a variant by currency code. Prices in
+// currencies that are not known are left out.
+func variantPrices(prices []shopwarePrice, currencies map[string]string) []interface{} {
+	var entries []map[string]interface{}
+	for _, price := range prices {
+		currency, ok := currencies[price.currencyID]
+		if !ok {
+			continue
+		}
+
+		entry := map[string]interface{}{
+			"currency": currency,
+			"gross":    formatAmount(price.gross),
+			"net":      formatAmount(price.net),
+		}
+		if price.listGross > 0 {
+			entry["compareAtGross"] = formatAmount(price.listGross)
+			entry["compareAtNet"] = formatAmount(price.listNet)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i]["currency"].(string) < entries[j]["currency"].(string)
+	})
+
+	list := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		list[i] = entry
+	}
+	return list
+}
+
+// variantAdvancedPrices lists the advanced prices of a variant, one entry
+// per rule, starting quantity and currency
+func variantAdvancedPrices(advancedPrices []interface{}, currencies map[string]string) []interface{} {
+	var entries []map[string]interface{}
+	for _, item := range advancedPrices {
+		advancedPrice, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		ruleID := stringField(advancedPrice, "ruleId")
+		quantity := intField(advancedPrice, "quantityStart")
+		if ruleID == "" || quantity < 1 {
+			continue
+		}
+
+		for _, price := range shopwarePrices(advancedPrice["price"]) {
+			currency, ok := currencies[price.currencyID]
+			if !ok {
+				continue
+			}
+
+			entries = append(entries, map[string]interface{}{
+				"ruleId":          ruleID,
+				"minimumQuantity": quantity,
+				"currency":        currency,
+				"gross":           formatAmount(price.gross),
+				"net":             formatAmount(price.net),
+			})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a["ruleId"] != b["ruleId"] {
+			return a["ruleId"].(string) < b["ruleId"].(string)
+		}
+		if a["minimumQuantity"] != b["minimumQuantity"] {
+			return a["minimumQuantity"].(int) < b["minimumQuantity"].(int)
+		}
+		return a["currency"].(string) < b["currency"].(string)
+	})
+
+	list := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		list[i] = entry
+	}
+	return list
+}
+
+// formatAmount formats an amount as Shopify expects money values
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// translatedString reads a string field, preferring its translation
+func translatedString(document map[string]interface{}, field string) string {
+	if translated, ok := document["translated"].(map[string]interface{}); ok {
+		if value, ok := translated[field].(string); ok && value != "" {
+			return value
+		}
+	}
+	return stringField(document, field)
+}
+
+// stringField reads a string field of a document
+func stringField(document map[string]interface{}, field string) string {
+	value, _ := document[field].(string)
+	return value
+}
+
+// intField reads a numeric field of a document as an int
+func intField(document map[string]interface{}, field string) int {
+	value, _ := document[field].(float64)
+	return int(value)
+}
+
+// variantOptionGroup collects the values of an option group used by a
+// product's variants
+type variantOptionGroup struct {
+	name      string
+	position  int
+	values    []string
+	positions map[string]int
+}
+
+// add records a value of the group
+func (g *variantOptionGroup) add(value string, position int) {
+	if g.positions == nil {
+		g.positions = make(map[string]int)
+	}
+	if _, ok := g.positions[value]; ok {
+		return
+	}
+	g.positions[value] = position
+	g.values = append(g.values, value)
+}
+
+// sortedValues returns the group's values ordered by position, then name
+func (g *variantOptionGroup) sortedValues() []interface{} {
+	sort.SliceStable(g.values, func(i, j int) bool {
+		pi, pj := g.positions[g.values[i]], g.positions[g.values[j]]
+		if pi != pj {
+			return pi < pj
+		}
+		return g.values[i] < g.values[j]
+	})
+
+	values := make([]interface{}, len(g.values))
+	for i, value := range g.values {
+		values[i] = value
+	}
+	return values
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestWithVariants(t *testing.T) {
+	currencies := map[string]string{shopwareDefaultCurrencyID: "EUR", "usd": "USD"}
+
+	tests := []struct {
+		name           string
+		product        string
+		variants       string
+		variantOptions string
+	}{
+		{
+			name:           "product without children",
+			product:        `{"id":"p1","productNumber":"SW1","ean":"123","weight":0.5,"price":[{"currencyId":"b7d2554b0ce847cd82f3ac9bd1c0dfca","gross":11.9,"net":10,"listPrice":{"gross":14.9,"net":12.52}}]}`,
+			variants:       `[{"advancedPrices":[],"barcode":"123","compareAtPrice":"14.90","price":"11.90","prices":[{"compareAtGross":"14.90","compareAtNet":"12.52","currency":"EUR","gross":"11.90","net":"10.00"}],"sku":"SW1","sourceId":"p1","weight":0.5,"weightUnit":"KILOGRAMS"}]`,
+			variantOptions: `[]`,
+		},
+		{
+			name: "children inherit the parent's prices and weight",
+			product: `{"id":"p1","productNumber":"SW1","weight":1,
+				"price":[{"currencyId":"usd","gross":12,"net":10}],
+				"children":[
+					{"id":"c2","productNumber":"SW1.2","options":[
+						{"name":"L","position":2,"group":{"name":"Size","position":1}},
+						{"name":"Red","group":{"name":"Color","position":2}}
+					]},
+					{"id":"c1","productNumber":"SW1.1","price":[{"currencyId":"usd","gross":15,"net":12.5}],"options":[
+						{"name":"S","position":1,"group":{"name":"Size","position":1}}
+					]}
+				]}`,
+			variants:       `[{"advancedPrices":[],"optionValues":[{"name":"S","optionName":"Size"}],"price":"15.00","prices":[{"currency":"USD","gross":"15.00","net":"12.50"}],"sku":"SW1.1","sourceId":"c1","weight":1,"weightUnit":"KILOGRAMS"},{"advancedPrices":[],"optionValues":[{"name":"L","optionName":"Size"},{"name":"Red","optionName":"Color"}],"price":"12.00","prices":[{"currency":"USD","gross":"12.00","net":"10.00"}],"sku":"SW1.2","sourceId":"c2","weight":1,"weightUnit":"KILOGRAMS"}]`,
+			variantOptions: `[{"name":"Size","values":["S","L"]},{"name":"Color","values":["Red"]}]`,
+		},
+	}
+
+	for _, test := range tests {
+		var document map[string]interface{}
+		if err := json.Unmarshal([]byte(test.product), &document); err != nil {
+			t.Fatalf("%s: decoding product: %v", test.name, err)
+		}
+
+		document = withVariants(document, currencies)
+
+		variants, _ := json.Marshal(document["variants"])
+		if string(variants) != test.variants {
+			t.Errorf("%s: variants = %s, want %s", test.name, variants, test.variants)
+		}
+		variantOptions, _ := json.Marshal(document["variantOptions"])
+		if string(variantOptions) != test.variantOptions {
+			t.Errorf("%s: variantOptions = %s, want %s", test.name, variantOptions, test.variantOptions)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// SourceReader fetches the source documents of a dataflow
+type SourceReader interface {
+	FetchSourceDataForDataflow(dataflow *models.Dataflow, id string) ([]byte, error)
+}
+
+// sourceReader returns the connector service a dataflow reads from
+func sourceReader(db *gorm.DB, shopwareService *ShopwareService, dataflow *models.Dataflow) SourceReader {
+	if dataflow.IsReverse() {
+		return NewShopifyService(db)
+	}
+	return shopwareService
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// StockSyncService writes Shopify inventory to Shopware product stock for
+// Shopify to Shopware inventory dataflows
+type StockSyncService struct {
+	db              *gorm.DB
+	shopwareService *ShopwareService
+	mappingService  *EntityMappingService
+	echoService     *SyncEchoService
+}
+
+// NewStockSyncService creates a new stock sync service
+func NewStockSyncService(db *gorm.DB) *StockSyncService {
+	return &StockSyncService{
+		db:              db,
+		shopwareService: NewShopwareService(db),
+		mappingService:  NewEntityMappingService(db),
+		echoService:     NewSyncEchoService(db),
+	}
+}
+
+// SyncStock sets the stock of the Shopware product of a Shopify inventory
+// item and returns the product's ID. The write is recorded so the Shopware
+// webhook it triggers is not synced back to Shopify.
+func (s *StockSyncService) SyncStock(dataflow *models.Dataflow, sourceData []byte) (string, error) {
+	var item ShopifyInventoryItem
+	if err := json.Unmarshal(sourceData, &item); err != nil {
+		return "", classifyAs(models.ErrorClassTransform, fmt.Errorf("error parsing source data: %w", err))
+	}
+	if item.ID == "" {
+		return "", classifyAs(models.ErrorClassTransform, fmt.Errorf("inventory item without an ID in source data"))
+	}
+
+	mapping, err := s.product(dataflow, &item)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.echoService.RecordWrite(dataflow.DestConnectorID, mapping.DestID, strconv.Itoa(item.Stock)); err != nil {
+		return "", fmt.Errorf("error recording stock write: %w", err)
+	}
+
+	if err := s.shopwareService.SetProductStock(&dataflow.DestConnector, mapping.DestID, item.Stock); err != nil {
+		return "", err
+	}
+
+	// The levels last synced from Shopware to Shopify no longer match the
+	// product, so its next stock change must be written even if it returns
+	// to those levels
+	if err := s.mappingService.ClearSyncedHash(dataflow.DestConnectorID, dataflow.SourceConnectorID, models.EntityTypeInventoryItem, mapping.DestID); err != nil {
+		return mapping.DestID, fmt.Errorf("error clearing inventory item sync hash: %w", err)
+	}
+
+	return mapping.DestID, nil
+}
+
+// product returns the mapping of an inventory item to its Shopware product.
+// An unmapped item is found through the mappings of the Shopware to Shopify
+// dataflows that created it, or through its SKU, and the mapping is saved.
+func (s *StockSyncService) product(dataflow *models.Dataflow, item *ShopifyInventoryItem) (*models.EntityMapping, error) {
+	mapping, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeInventoryItem, item.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up inventory item mapping: %w", err)
+	}
+	if mapping != nil {
+		return mapping, nil
+	}
+
+	productID, err := s.syncedProduct(dataflow, models.EntityTypeInventoryItem, item.ID)
+	if err != nil {
+		return nil, err
+	}
+	if productID == "" && item.VariantID != "" {
+		productID, err = s.syncedProduct(dataflow, models.EntityTypeVariant, item.VariantID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if productID == "" {
+		if item.SKU == "" {
+			return nil, fmt.Errorf("%w: inventory item %s has no synced product or SKU", ErrShopwareProductNotFound, item.ID)
+		}
+		productID, err = s.shopwareService.FindProductBySKU(&dataflow.DestConnector, item.SKU)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mapping = &models.EntityMapping{
+		SourceConnectorID: dataflow.SourceConnectorID,
+		DestConnectorID:   dataflow.DestConnectorID,
+		EntityType:        models.EntityTypeInventoryItem,
+		SourceID:          item.ID,
+		DestID:            productID,
+	}
+	if err := s.mappingService.UpsertMapping(mapping); err != nil {
+		return nil, fmt.Errorf("error saving inventory item mapping: %w", err)
+	}
+
+	return mapping, nil
+}
+
+// syncedProduct returns the ID of the Shopware product a Shopify entity was
+// synced from, or "" if it was not synced from the dataflow's Shopware
+// connector
+func (s *StockSyncService) syncedProduct(dataflow *models.Dataflow, entityType models.EntityType, shopifyID string) (string, error) {
+	mapping, err := s.mappingService.FindMappingByDestID(dataflow.SourceConnectorID, entityType, shopifyID)
+	if err != nil {
+		return "", fmt.Errorf("error looking up %s mapping: %w", entityType, err)
+	}
+	if mapping == nil || mapping.SourceConnectorID != dataflow.DestConnectorID {
+		return "", nil
+	}
+	return mapping.SourceID, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// syncEchoTTL is how long a write is remembered. Webhooks for a write
+// normally arrive within seconds; queued ones can take a few minutes.
+const syncEchoTTL = 10 * time.Minute
+
+// SyncEchoService remembers values written by bidirectional dataflows, so a
+// webhook caused by the integration's own write is not synced back and the
+// two systems do not update each other forever
+type SyncEchoService struct {
+	db *gorm.DB
+}
+
+// NewSyncEchoService creates a new sync echo service
+func NewSyncEchoService(db *gorm.DB) *SyncEchoService {
+	return &SyncEchoService{
+		db: db,
+	}
+}
+
+// RecordWrite remembers that a value was written to an entity of a connector
+func (s *SyncEchoService) RecordWrite(connectorID uint, key, value string) error {
+	return s.RecordWrites(connectorID, map[string]string{key: value})
+}
+
+// RecordWrites remembers values written to entities of a connector, by key
+func (s *SyncEchoService) RecordWrites(connectorID uint, values map[string]string) error {
+	now := time.Now()
+
+	// Expired writes are pruned as new ones are recorded
+	if err := s.db.Where("expires_at < ?", now).Delete(&models.SyncEcho{}).Error; err != nil {
+		return err
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	echoes := make([]models.SyncEcho, 0, len(values))
+	for key, value := range values {
+		echoes = append(echoes, models.SyncEcho{
+			ConnectorID: connectorID,
+			Key:         key,
+			Value:       value,
+			ExpiresAt:   now.Add(syncEchoTTL),
+		})
+	}
+
+	return s.db.CreateInBatches(echoes, 100).Error
+}
+
+// IsEcho reports whether a change to an entity of a connector, received
+// in a webhook delivery, is the echo of a recorded write with the same
+// value. A matched write is consumed by the delivery: a retry of the same
+// delivery matches it again, while a later change to the same value is
+// synced.
+func (s *SyncEchoService) IsEcho(connectorID uint, key, value, deliveryID string) (bool, error) {
+	var echo models.SyncEcho
+	err := s.db.Where("connector_id = ? AND key = ? AND value = ? AND expires_at >= ? AND consumed_by IN ?",
+		connectorID, key, value, time.Now(), []string{"", deliveryID}).
+		Order("consumed_by DESC").
+		First(&echo).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if echo.ConsumedBy == deliveryID {
+		return true, nil
+	}
+
+	// Another delivery may consume the write at the same time; only one wins
+	result := s.db.Model(&models.SyncEcho{}).
+		Where("id = ? AND consumed_by = ?", echo.ID, "").
+		Update("consumed_by", deliveryID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// inventoryLevelKey is the echo key of an inventory item's level at a
+// Shopify location
+func inventoryLevelKey(inventoryItemID, locationID string) string {
+	return inventoryItemID + "|" + locationID
+}
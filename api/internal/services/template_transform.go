@@ -0,0 +1,283 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+	"unicode"
+)
+
+// maxTemplateOutput limits the size of a rendered template
+const maxTemplateOutput = 256 * 1024
+
+// errTemplateTooLarge is returned when a template renders more than
+// maxTemplateOutput bytes
+var errTemplateTooLarge = errors.New("template output too large")
+
+// TemplateConfig is the transform config of a template mapping. The
+// template is a Go text/template rendered over the whole source document,
+// so {{.name}} reads the source field name and {{value}} the mapped value.
+type TemplateConfig struct {
+	Template string `json:"template"`
+}
+
+// templateFuncs are the functions available in templates. The value
+// function is bound to the mapped value when a template runs.
+var templateFuncs = template.FuncMap{
+	"value":       func() interface{} { return nil },
+	"upper":       func(v interface{}) string { return strings.ToUpper(templateString(v)) },
+	"lower":       func(v interface{}) string { return strings.ToLower(templateString(v)) },
+	"truncate":    templateTruncate,
+	"slugify":     templateSlugify,
+	"join":        templateJoin,
+	"default":     templateDefault,
+	"formatMoney": templateFormatMoney,
+	"stripTags":   templateStripTags,
+	"date":        templateDate,
+}
+
+// maxCachedTemplates limits the number of parsed templates kept in memory
+const maxCachedTemplates = 1000
+
+// parsedTemplates holds parsed templates by their transform config, so a
+// template is parsed once rather than for every value. Configs are the
+// cache keys, so an edited mapping simply uses a new entry.
+var parsedTemplates = newTTLCache[string, *template.Template](0, maxCachedTemplates)
+
+// templateTextCommand is the command appended to every action of a
+// template. It prints missing and null values as nothing rather than
+// text/template's "<no value>".
+var templateTextCommand = template.Must(template.New("text").
+	Funcs(template.FuncMap{"templateText": templateText}).
+	Parse("{{templateText}}")).
+	Tree.Root.Nodes[0].(*parse.ActionNode).Pipe.Cmds[0]
+
+// templateText passes a value through, turning nil into an empty string
+func templateText(v interface{}) interface{} {
+	if v == nil {
+		return ""
+	}
+	return v
+}
+
+// parseTemplateConfig parses a template transform config and its template
+func parseTemplateConfig(transformConfig string) (*template.Template, error) {
+	var config TemplateConfig
+	if err := json.Unmarshal([]byte(transformConfig), &config); err != nil {
+		return nil, fmt.Errorf("invalid transform config: %w", err)
+	}
+
+	// Missing keys stay invalid rather than zero, so {{.a.b}} with no .a is
+	// missing too instead of an error; templateText prints them as nothing
+	funcs := template.FuncMap{"templateText": templateText}
+	tmpl, err := template.New("template").Funcs(templateFuncs).Funcs(funcs).Option("missingkey=default").Parse(config.Template)
+	if err != nil {
+		// Parse errors name the template line, e.g. template: template:3: ...
+		return nil, err
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			printMissingAsEmpty(t.Tree.Root)
+		}
+	}
+
+	return tmpl, nil
+}
+
+// printMissingAsEmpty pipes the value of every printing action in a parse
+// tree through templateText
+func printMissingAsEmpty(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			printMissingAsEmpty(child)
+		}
+	case *parse.ActionNode:
+		// Variable declarations print nothing
+		if len(n.Pipe.Decl) == 0 {
+			n.Pipe.Cmds = append(n.Pipe.Cmds, templateTextCommand)
+		}
+	case *parse.IfNode:
+		printMissingAsEmpty(n.List)
+		printMissingAsEmpty(n.ElseList)
+	case *parse.RangeNode:
+		printMissingAsEmpty(n.List)
+		printMissingAsEmpty(n.ElseList)
+	case *parse.WithNode:
+		printMissingAsEmpty(n.List)
+		printMissingAsEmpty(n.ElseList)
+	}
+}
+
+// applyTemplate renders a template transform over the source document
+func applyTemplate(value interface{}, transformConfig string, source map[string]interface{}) (interface{}, error) {
+	cached, err := parsedTemplates.load(transformConfig, func() (*template.Template, error) {
+		return parseTemplateConfig(transformConfig)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The cached template is shared, so the value is bound on a copy
+	tmpl, err := cached.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Funcs(template.FuncMap{
+		"value": func() interface{} { return value },
+	})
+
+	if source == nil {
+		source = map[string]interface{}{}
+	}
+
+	var out limitedBuffer
+	if err := tmpl.Execute(&out, source); err != nil {
+		// Execution errors name the line and column, e.g. template: template:2:14: ...
+		return nil, err
+	}
+
+	return out.String(), nil
+}
+
+// limitedBuffer is a buffer that fails once it holds maxTemplateOutput bytes
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+// Write appends to the buffer unless the limit would be exceeded
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > maxTemplateOutput {
+		return 0, errTemplateTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// templateString formats a template argument. Null is empty and whole
+// numbers have no decimal point.
+func templateString(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// templateTruncate shortens a string to at most n characters:
+// {{.description | truncate 160}}
+func templateTruncate(n int, v interface{}) string {
+	runes := []rune(templateString(v))
+	if n < 0 || len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n])
+}
+
+// templateSlugify turns a string into a URL handle: "Blue T-Shirt" becomes
+// "blue-t-shirt"
+func templateSlugify(v interface{}) string {
+	var sb strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(templateString(v)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteByte('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(sb.String(), "-")
+}
+
+// templateJoin joins the non-empty elements of an array:
+// {{.tags | join ", "}}
+func templateJoin(separator string, v interface{}) string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return templateString(v)
+	}
+
+	parts := make([]string, 0, len(list))
+	for _, item := range list {
+		if s := templateString(item); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, separator)
+}
+
+// templateDefault returns the fallback when the value is missing or empty:
+// {{.manufacturer.name | default "Unknown"}}
+func templateDefault(fallback interface{}, v interface{}) interface{} {
+	switch s := v.(type) {
+	case nil:
+		return fallback
+	case string:
+		if s == "" {
+			return fallback
+		}
+	}
+	return v
+}
+
+// templateFormatMoney formats an amount with two decimals and an optional
+// currency code: {{.price | formatMoney}} or {{formatMoney "EUR" .price}}
+func templateFormatMoney(args ...interface{}) (string, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", fmt.Errorf("formatMoney takes an amount and an optional currency")
+	}
+
+	amount, ok := toFloat(args[len(args)-1])
+	if !ok {
+		return "", fmt.Errorf("formatMoney: %v is not a number", args[len(args)-1])
+	}
+
+	formatted := strconv.FormatFloat(amount, 'f', 2, 64)
+	if len(args) == 2 {
+		formatted += " " + templateString(args[0])
+	}
+	return formatted, nil
+}
+
+// htmlTagPattern matches HTML tags
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// templateStripTags removes HTML tags and decodes entities:
+// {{.description | stripTags}}
+func templateStripTags(v interface{}) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTagPattern.ReplaceAllString(templateString(v), "")))
+}
+
+// templateDate reformats an RFC 3339 timestamp with a Go layout:
+// {{.releaseDate | date "2006-01-02"}}
+func templateDate(layout string, v interface{}) (string, error) {
+	s := templateString(v)
+	if s == "" {
+		return "", nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return "", fmt.Errorf("date: %w", err)
+	}
+	return t.Format(layout), nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// ttlCache is a map safe for concurrent use whose entries expire after a
+// time to live. Services are created per request or job, so anything they
+// keep between uses lives in a package-level ttlCache. A zero TTL keeps
+// entries until they are invalidated; a cache with a limit is emptied when
+// a new key would exceed it.
+type ttlCache[K comparable, V any] struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	limit   int
+	entries map[K]ttlEntry[V]
+}
+
+// ttlEntry is a cached value with its expiry, which is zero if it does not
+// expire
+type ttlEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+// newTTLCache creates an empty cache with a default TTL and a limit on the
+// number of entries, which is unlimited if it is zero
+func newTTLCache[K comparable, V any](ttl time.Duration, limit int) *ttlCache[K, V] {
+	return &ttlCache[K, V]{
+		ttl:     ttl,
+		limit:   limit,
+		entries: make(map[K]ttlEntry[V]),
+	}
+}
+
+// get returns a cached value that has not expired
+func (c *ttlCache[K, V]) get(key K) (V, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || (!entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt)) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// put caches a value for the cache's TTL
+func (c *ttlCache[K, V]) put(key K, value V) {
+	c.putFor(key, value, c.ttl)
+}
+
+// putFor caches a value for a TTL of its own, or until it is invalidated if
+// the TTL is zero
+func (c *ttlCache[K, V]) putFor(key K, value V, ttl time.Duration) {
+	entry := ttlEntry[V]{value: value}
+	if ttl > 0 {
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && c.limit > 0 && len(c.entries) >= c.limit {
+		c.entries = make(map[K]ttlEntry[V])
+	}
+	c.entries[key] = entry
+}
+
+// load returns a cached value, calling load and caching its result on a
+// miss. Errors are not cached.
+func (c *ttlCache[K, V]) load(key K, load func() (V, error)) (V, error) {
+	if value, ok := c.get(key); ok {
+		return value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+
+	c.put(key, value)
+	return value, nil
+}
+
+// invalidate drops a cached value
+func (c *ttlCache[K, V]) invalidate(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
+
+// invalidateFunc drops the cached values whose keys match
+func (c *ttlCache[K, V]) invalidateFunc(match func(K) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if match(key) {
+			delete(c.entries, key)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTTLCacheExpiry(t *testing.T) {
+	cache := newTTLCache[string, int](time.Hour, 0)
+
+	cache.put("kept", 1)
+	cache.putFor("expired", 2, time.Nanosecond)
+	cache.putFor("forever", 3, 0)
+	time.Sleep(time.Millisecond)
+
+	if value, ok := cache.get("kept"); !ok || value != 1 {
+		t.Errorf("get(kept) = %d, %v, want 1, true", value, ok)
+	}
+	if _, ok := cache.get("expired"); ok {
+		t.Errorf("get(expired) found an expired entry")
+	}
+	if value, ok := cache.get("forever"); !ok || value != 3 {
+		t.Errorf("get(forever) = %d, %v, want 3, true", value, ok)
+	}
+
+	cache.invalidate("kept")
+	if _, ok := cache.get("kept"); ok {
+		t.Errorf("get(kept) found an invalidated entry")
+	}
+}
+
+func TestTTLCacheLoad(t *testing.T) {
+	cache := newTTLCache[string, int](0, 0)
+	loads := 0
+	load := func() (int, error) {
+		loads++
+		return 42, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if value, err := cache.load("key", load); err != nil || value != 42 {
+			t.Fatalf("load() = %d, %v, want 42", value, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("loaded %d times, want once", loads)
+	}
+
+	failure := errors.New("unavailable")
+	if _, err := cache.load("failing", func() (int, error) { return 0, failure }); !errors.Is(err, failure) {
+		t.Errorf("load() error = %v, want %v", err, failure)
+	}
+	if _, ok := cache.get("failing"); ok {
+		t.Errorf("a failed load was cached")
+	}
+}
+
+func TestTTLCacheLimitAndInvalidateFunc(t *testing.T) {
+	cache := newTTLCache[int, string](0, 2)
+
+	cache.put(1, "a")
+	cache.put(2, "b")
+	cache.put(2, "c") // Replacing a key does not count against the limit
+	if _, ok := cache.get(1); !ok {
+		t.Fatalf("replacing an entry emptied the cache")
+	}
+
+	cache.put(3, "d")
+	if _, ok := cache.get(1); ok {
+		t.Errorf("a full cache was not emptied")
+	}
+	if value, _ := cache.get(3); value != "d" {
+		t.Errorf("get(3) = %q, want d", value)
+	}
+
+	cache.put(4, "e")
+	cache.invalidateFunc(func(key int) bool { return key%2 == 0 })
+	if _, ok := cache.get(4); ok {
+		t.Errorf("invalidateFunc kept a matching entry")
+	}
+	if _, ok := cache.get(3); !ok {
+		t.Errorf("invalidateFunc dropped an entry that did not match")
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+)
+
+// syncVariants makes the variants of a Shopify product match the product
+// request. Variants are matched to existing ones by their variant mapping,
+// then by SKU; a product without options reuses the default variant Shopify
+// created with it. Unmatched variants are created, and mapped variants that
+// are no longer in the request are deleted. Prices are taken in the shop
+// currency, and the dataflow's price lists are filled afterwards.
+func (s *ProductSyncService) syncVariants(dataflow *models.Dataflow, productID string, product *ShopifyProduct) error {
+	if len(product.Variants) == 0 {
+		return nil
+	}
+
+	connector := &dataflow.DestConnector
+
+	shop, err := s.shopifyService.GetShopPricing(connector)
+	if err != nil {
+		return fmt.Errorf("error getting shop pricing: %w", err)
+	}
+	applyShopPrices(product.Variants, shop)
+
+	current, err := s.shopifyService.GetProductVariants(connector, productID)
+	if err != nil {
+		return fmt.Errorf("error getting variants: %w", err)
+	}
+
+	tracked, err := s.mappingService.ListMappingsByParent(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeVariant, productID)
+	if err != nil {
+		return fmt.Errorf("error looking up variant mappings: %w", err)
+	}
+
+	// Options have to exist before variants can use them; missing values of
+	// existing options are created by the variant mutations
+	var missingOptions []ShopifyOption
+	for _, option := range product.Options {
+		if option.Name != "" && len(option.Values) > 0 && !current.HasOption(option.Name) {
+			missingOptions = append(missingOptions, option)
+		}
+	}
+	if len(missingOptions) > 0 {
+		if err := s.shopifyService.CreateProductOptions(connector, productID, missingOptions); err != nil {
+			return err
+		}
+	}
+
+	existing := make(map[string]bool, len(current.Variants))
+	bySKU := make(map[string]string, len(current.Variants))
+	for _, variant := range current.Variants {
+		existing[variant.ID] = true
+		if variant.SKU != "" {
+			bySKU[variant.SKU] = variant.ID
+		}
+	}
+
+	trackedBySource := make(map[string]string, len(tracked))
+	for _, mapping := range tracked {
+		trackedBySource[mapping.SourceID] = mapping.DestID
+	}
+
+	used := make(map[string]bool)
+	var updateIDs []string
+	var updates, creates []ShopifyVariant
+
+	for _, variant := range product.Variants {
+		id := trackedBySource[variant.SourceID]
+		if !existing[id] || used[id] {
+			id = bySKU[variant.SKU]
+		}
+		if id == "" && len(product.Variants) == 1 && len(product.Options) == 0 && len(current.Variants) == 1 {
+			id = current.Variants[0].ID
+		}
+
+		if id != "" && !used[id] {
+			used[id] = true
+			updateIDs = append(updateIDs, id)
+			updates = append(updates, variant)
+		} else {
+			creates = append(creates, variant)
+		}
+	}
+
+	var deleteIDs []string
+	for _, mapping := range tracked {
+		if existing[mapping.DestID] && !used[mapping.DestID] {
+			deleteIDs = append(deleteIDs, mapping.DestID)
+		}
+	}
+
+	// Delete first, so new variants can reuse the option combinations of
+	// deleted ones
+	if len(deleteIDs) > 0 {
+		if err := s.shopifyService.BulkDeleteVariants(connector, productID, deleteIDs); err != nil {
+			return err
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := s.shopifyService.BulkUpdateVariants(connector, productID, updateIDs, updates); err != nil {
+			return err
+		}
+	}
+
+	var createIDs []string
+	if len(creates) > 0 {
+		// A product none of whose variants matched only has the variant
+		// Shopify created with it, which the new variants replace
+		createIDs, err = s.shopifyService.BulkCreateVariants(connector, productID, creates, len(updates) == 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Drop the mappings of deleted variants and of variants removed in Shopify
+	for _, mapping := range tracked {
+		if !used[mapping.DestID] {
+			if err := s.mappingService.DeleteMapping(&mapping); err != nil {
+				return fmt.Errorf("error deleting variant mapping: %w", err)
+			}
+
+			// The variant's inventory item is gone with it
+			inventoryItem, err := s.mappingService.FindMapping(dataflow.SourceConnectorID, dataflow.DestConnectorID, models.EntityTypeInventoryItem, mapping.SourceID)
+			if err != nil {
+				return fmt.Errorf("error looking up inventory item mapping: %w", err)
+			}
+			if inventoryItem != nil {
+				if err := s.mappingService.DeleteMapping(inventoryItem); err != nil {
+					return fmt.Errorf("error deleting inventory item mapping: %w", err)
+				}
+			}
+		}
+	}
+
+	if err := s.saveVariantMappings(dataflow, productID, updates, updateIDs); err != nil {
+		return err
+	}
+	if err := s.saveVariantMappings(dataflow, productID, creates, createIDs); err != nil {
+		return err
+	}
+
+	variants := append(append([]ShopifyVariant{}, updates...), creates...)
+	ids := append(append([]string{}, updateIDs...), createIDs...)
+	return s.syncPriceLists(dataflow, shop, variants, ids)
+}
+
+// saveVariantMappings records the Shopify ID of every variant that has a
+// source ID
+func (s *ProductSyncService) saveVariantMappings(dataflow *models.Dataflow, productID string, variants []ShopifyVariant, ids []string) error {
+	for i, variant := range variants {
+		if variant.SourceID == "" {
+			continue
+		}
+
+		payload, err := json.Marshal(variant)
+		if err != nil {
+			return fmt.Errorf("error marshaling variant: %w", err)
+		}
+
+		if err := s.mappingService.UpsertMapping(&models.EntityMapping{
+			SourceConnectorID: dataflow.SourceConnectorID,
+			DestConnectorID:   dataflow.DestConnectorID,
+			EntityType:        models.EntityTypeVariant,
+			SourceID:          variant.SourceID,
+			DestID:            ids[i],
+			ParentID:          productID,
+			LastSyncedHash:    payloadHash(payload),
+		}); err != nil {
+			return fmt.Errorf("error saving variant mapping: %w", err)
+		}
+	}
+
+	return nil
+}
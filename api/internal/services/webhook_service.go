@@ -0,0 +1,588 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/DulsaraNethmin/shopware-shopify-integration/internal/models"
+	"gorm.io/gorm"
+)
+
+// WebhookService processes webhooks received from connected systems
+type WebhookService struct {
+	db              *gorm.DB
+	shopwareService *ShopwareService
+	executor        Executor
+	jobQueue        *JobQueueService
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(db *gorm.DB, shopwareService *ShopwareService, executor Executor, jobQueue *JobQueueService) *WebhookService {
+	return &WebhookService{
+		db:              db,
+		shopwareService: shopwareService,
+		executor:        executor,
+		jobQueue:        jobQueue,
+	}
+}
+
+// ShopwareWebhookRequest represents a webhook request from Shopware
+type ShopwareWebhookRequest struct {
+	Data struct {
+		Payload []struct {
+			Entity        string   `json:"entity"`
+			Operation     string   `json:"operation"`
+			PrimaryKey    string   `json:"primaryKey"`
+			UpdatedFields []string `json:"updatedFields"`
+			VersionId     string   `json:"versionId"`
+		} `json:"payload"`
+		Event string `json:"event"`
+	} `json:"data"`
+	Source struct {
+		URL     string `json:"url"`
+		EventID string `json:"eventId"`
+	} `json:"source"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// DataflowTypeForEvent returns the dataflow type handling a Shopware event
+func DataflowTypeForEvent(event string) (models.DataflowType, error) {
+	switch event {
+	case "product.written":
+		return models.DataflowTypeProduct, nil
+	case "order.placed":
+		return models.DataflowTypeOrder, nil
+	case "category.written":
+		return models.DataflowTypeCategory, nil
+	case "customer.written", "customer.register":
+		return models.DataflowTypeCustomer, nil
+	default:
+		return "", fmt.Errorf("unsupported event type: %s", event)
+	}
+}
+
+// EnqueueShopwareWebhook persists a Shopware webhook for asynchronous processing
+func (s *WebhookService) EnqueueShopwareWebhook(body []byte) (*models.Job, error) {
+	return s.jobQueue.Enqueue(models.JobTypeShopwareWebhook, body)
+}
+
+// jobDeliveryID identifies the webhook delivery of a job, so a retried job
+// matches the echoed writes its earlier attempts matched
+func jobDeliveryID(job *models.Job) string {
+	return fmt.Sprintf("job:%d", job.ID)
+}
+
+// HandleShopwareWebhookJob processes a queued Shopware webhook job
+func (s *WebhookService) HandleShopwareWebhookJob(ctx context.Context, job *models.Job) error {
+	return s.ProcessShopwareWebhook(ctx, []byte(job.Payload), jobDeliveryID(job))
+}
+
+// ProcessShopwareWebhook fetches the source data for a Shopware webhook and
+// starts an execution for each matching active dataflow. Errors returned
+// before any migration log is created are safe to retry. The delivery ID
+// must stay the same across retries of the webhook.
+func (s *WebhookService) ProcessShopwareWebhook(ctx context.Context, body []byte, deliveryID string) error {
+	var webhook ShopwareWebhookRequest
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return fmt.Errorf("invalid webhook payload: %w", err)
+	}
+
+	// Determine data type from the event type
+	dataflowType, err := DataflowTypeForEvent(webhook.Data.Event)
+	if err != nil {
+		return err
+	}
+
+	// Find the Shopware connector that has the URL matching the source URL;
+	// only its dataflows are started
+	connector, err := s.findSourceConnector(webhook.Source.URL)
+	if err != nil {
+		return err
+	}
+
+	// Stock changes arrive as product writes and also feed inventory dataflows
+	if webhook.Data.Event == "product.written" {
+		if err := s.processInventoryWebhook(ctx, connector, &webhook, deliveryID); err != nil {
+			return err
+		}
+	}
+
+	// Find active dataflows for this data type from the source connector
+	var dataflows []models.Dataflow
+	if err := s.db.Preload("SourceConnector").Preload("DestConnector").
+		Where("type = ? AND status = ? AND source_connector_id = ?", dataflowType, models.DataflowStatusActive, connector.ID).
+		Find(&dataflows).Error; err != nil {
+		return fmt.Errorf("error finding dataflows: %w", err)
+	}
+
+	if len(dataflows) == 0 {
+		return nil
+	}
+
+	// Extract source identifier from data
+	sourceID := ""
+	for _, payload := range webhook.Data.Payload {
+		if dataflowType == models.DataflowTypeProduct && payload.Entity == "product" {
+			sourceID = payload.PrimaryKey
+		} else if dataflowType == models.DataflowTypeOrder && payload.Entity == "order" {
+			sourceID = payload.PrimaryKey
+		} else if dataflowType == models.DataflowTypeCategory && payload.Entity == "category" {
+			sourceID = payload.PrimaryKey
+		} else if dataflowType == models.DataflowTypeCustomer && payload.Entity == "customer" {
+			sourceID = payload.PrimaryKey
+		}
+	}
+
+	if sourceID == "" {
+		return fmt.Errorf("could not determine source identifier")
+	}
+
+	// Products, orders and customers are fetched in full, with their
+	// associations
+	var sourceData []byte
+	if dataflowType == models.DataflowTypeProduct || dataflowType == models.DataflowTypeOrder || dataflowType == models.DataflowTypeCustomer {
+		sourceData, err = s.shopwareService.FetchSourceData(connector, dataflowType, sourceID)
+		if err != nil {
+			return err
+		}
+
+		// A change to a variant syncs its parent product
+		if dataflowType == models.DataflowTypeProduct {
+			var product struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(sourceData, &product); err == nil && product.ID != "" {
+				sourceID = product.ID
+			}
+		}
+	} else if dataflowType == models.DataflowTypeCategory {
+		// Collection titles depend on the dataflow, so every dataflow gets
+		// its own category document; all are fetched before any migration
+		// starts, so a failed fetch is safe to retry
+		categoryData := make([][]byte, len(dataflows))
+		for i := range dataflows {
+			categoryData[i], err = s.shopwareService.FetchSourceDataForDataflow(&dataflows[i], sourceID)
+			if err != nil {
+				return err
+			}
+		}
+
+		return s.startMigrations(ctx, dataflows, sourceID, categoryData)
+	}
+
+	// Process each matching dataflow
+	dataflowData := make([][]byte, len(dataflows))
+	for i := range dataflows {
+		dataflowData[i] = sourceData
+	}
+	return s.startMigrations(ctx, dataflows, sourceID, dataflowData)
+}
+
+// processInventoryWebhook starts an execution for each active inventory
+// dataflow of the products whose stock was written
+func (s *WebhookService) processInventoryWebhook(ctx context.Context, connector *models.Connector, webhook *ShopwareWebhookRequest, deliveryID string) error {
+	var sourceIDs []string
+	for _, payload := range webhook.Data.Payload {
+		if payload.Entity == "product" && stockChanged(payload.UpdatedFields) {
+			sourceIDs = append(sourceIDs, payload.PrimaryKey)
+		}
+	}
+
+	if len(sourceIDs) == 0 {
+		return nil
+	}
+
+	var dataflows []models.Dataflow
+	if err := s.db.Preload("SourceConnector").Preload("DestConnector").
+		Where("type = ? AND status = ? AND source_connector_id = ?", models.DataflowTypeInventory, models.DataflowStatusActive, connector.ID).
+		Find(&dataflows).Error; err != nil {
+		return fmt.Errorf("error finding dataflows: %w", err)
+	}
+
+	if len(dataflows) == 0 {
+		return nil
+	}
+
+	// Stock written by a Shopify to Shopware dataflow is not synced back
+	echoService := NewSyncEchoService(s.db)
+	echoes := make(map[string]bool)
+
+	var errs []error
+	for _, dataflow := range dataflows {
+		// Children of the same parent are synced together, once
+		started := make(map[string]bool)
+		for _, sourceID := range sourceIDs {
+			sourceData, err := s.shopwareService.FetchSourceDataForDataflow(&dataflow, sourceID)
+			if err != nil {
+				return err
+			}
+
+			echo, checked := echoes[sourceID]
+			if !checked {
+				echo, err = isStockEcho(echoService, connector.ID, sourceID, sourceData, deliveryID)
+				if err != nil {
+					return err
+				}
+				echoes[sourceID] = echo
+			}
+			if echo {
+				continue
+			}
+
+			var product struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(sourceData, &product); err == nil && product.ID != "" {
+				sourceID = product.ID
+			}
+			if started[sourceID] {
+				continue
+			}
+			started[sourceID] = true
+
+			if err := s.startMigration(ctx, &dataflow, sourceID, sourceData); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// stockChanged reports whether a product write touched its stock. Writes
+// that do not list their fields are assumed to.
+func stockChanged(updatedFields []string) bool {
+	if len(updatedFields) == 0 {
+		return true
+	}
+
+	for _, field := range updatedFields {
+		if field == "stock" || field == "availableStock" {
+			return true
+		}
+	}
+	return false
+}
+
+// isStockEcho reports whether the stock of a written product, found in its
+// inventory document, is the echo of a Shopify to Shopware stock write
+func isStockEcho(echoService *SyncEchoService, connectorID uint, productID string, sourceData []byte, deliveryID string) (bool, error) {
+	var product map[string]interface{}
+	if err := json.Unmarshal(sourceData, &product); err != nil {
+		return false, nil
+	}
+
+	items := []interface{}{product}
+	if children, ok := product["children"].([]interface{}); ok {
+		items = append(items, children...)
+	}
+
+	for _, entry := range items {
+		item, ok := entry.(map[string]interface{})
+		if !ok || stringField(item, "id") != productID {
+			continue
+		}
+
+		stock, ok := toFloat(item["stock"])
+		if !ok {
+			return false, nil
+		}
+		return echoService.IsEcho(connectorID, productID, strconv.Itoa(int(stock)), deliveryID)
+	}
+
+	return false, nil
+}
+
+// findSourceConnector finds the Shopware connector matching a webhook source URL
+func (s *WebhookService) findSourceConnector(sourceURL string) (*models.Connector, error) {
+	connector, err := s.findConnectorByHost(models.ConnectorTypeShopware, sourceURL)
+	if err != nil {
+		return nil, fmt.Errorf("could not find matching connector for the source URL %s: %w", sourceURL, err)
+	}
+
+	return connector, nil
+}
+
+// findConnectorByHost finds the connector of a type whose URL has the same
+// host as a URL or bare domain. Hosts are compared exactly, so a domain
+// does not match a connector whose URL merely contains it.
+func (s *WebhookService) findConnectorByHost(connectorType models.ConnectorType, rawURL string) (*models.Connector, error) {
+	host := connectorHost(rawURL)
+	if host == "" {
+		return nil, fmt.Errorf("invalid URL: %q", rawURL)
+	}
+
+	var connectors []models.Connector
+	if err := s.db.Where("type = ?", connectorType).Order("id").Find(&connectors).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range connectors {
+		if connectorHost(connectors[i].URL) == host {
+			return &connectors[i], nil
+		}
+	}
+
+	return nil, gorm.ErrRecordNotFound
+}
+
+// connectorHost returns the lower-case host of a URL or bare domain, without
+// its port
+func connectorHost(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+}
+
+// startMigration creates a migration log and starts an execution for it.
+// An error means the migration was not started, so the webhook job must be
+// retried; a log whose execution could not be started is marked failed.
+// Nothing is started once the context is cancelled.
+func (s *WebhookService) startMigration(ctx context.Context, dataflow *models.Dataflow, sourceID string, sourceData []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Create a migration log entry
+	migrationLog := models.MigrationLog{
+		DataflowID:       dataflow.ID,
+		Status:           models.MigrationStatusPending,
+		SourceIdentifier: sourceID,
+		SourcePayload:    string(sourceData),
+	}
+
+	if err := s.db.Create(&migrationLog).Error; err != nil {
+		return fmt.Errorf("error creating migration log for dataflow %d: %w", dataflow.ID, err)
+	}
+
+	// Start an execution with the configured executor
+	if err := dispatchMigration(s.db, s.executor, &migrationLog); err != nil {
+		migrationLog.Status = models.MigrationStatusFailed
+		migrationLog.ErrorMessage = fmt.Sprintf("Error starting execution, the webhook is retried: %v", err)
+		s.db.Save(&migrationLog)
+		return fmt.Errorf("error starting execution for dataflow %d: %w", dataflow.ID, err)
+	}
+
+	return nil
+}
+
+// startMigrations starts a migration for every dataflow, continuing past
+// failures, and returns the errors of those that could not be started
+func (s *WebhookService) startMigrations(ctx context.Context, dataflows []models.Dataflow, sourceID string, sourceData [][]byte) error {
+	var errs []error
+	for i := range dataflows {
+		if err := s.startMigration(ctx, &dataflows[i], sourceID, sourceData[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// shopifyWebhookJob is the payload of a Shopify webhook job
+type shopifyWebhookJob struct {
+	ConnectorID uint            `json:"connector_id"`
+	Topic       string          `json:"topic"`
+	Body        json.RawMessage `json:"body"`
+}
+
+// FindShopifyConnector finds the Shopify connector of a shop domain, as sent
+// in X-Shopify-Shop-Domain
+func (s *WebhookService) FindShopifyConnector(shopDomain string) (*models.Connector, error) {
+	if shopDomain == "" {
+		return nil, fmt.Errorf("missing shop domain")
+	}
+
+	connector, err := s.findConnectorByHost(models.ConnectorTypeShopify, shopDomain)
+	if err != nil {
+		return nil, fmt.Errorf("could not find matching connector for the shop %s: %w", shopDomain, err)
+	}
+
+	return connector, nil
+}
+
+// EnqueueShopifyWebhook persists a verified Shopify webhook for asynchronous
+// processing
+func (s *WebhookService) EnqueueShopifyWebhook(connector *models.Connector, topic string, body []byte) (*models.Job, error) {
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("invalid webhook payload")
+	}
+
+	payload, err := json.Marshal(shopifyWebhookJob{
+		ConnectorID: connector.ID,
+		Topic:       topic,
+		Body:        body,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.jobQueue.Enqueue(models.JobTypeShopifyWebhook, payload)
+}
+
+// HandleShopifyWebhookJob processes a queued Shopify webhook job
+func (s *WebhookService) HandleShopifyWebhookJob(ctx context.Context, job *models.Job) error {
+	var input shopifyWebhookJob
+	if err := json.Unmarshal([]byte(job.Payload), &input); err != nil {
+		return fmt.Errorf("invalid Shopify webhook job payload: %w", err)
+	}
+
+	return s.ProcessShopifyWebhook(ctx, input.ConnectorID, input.Topic, input.Body, jobDeliveryID(job))
+}
+
+// ProcessShopifyWebhook applies a Shopify order fulfillment, cancellation or
+// refund to the Shopware order it was created from, or starts the Shopify to
+// Shopware inventory dataflows of an inventory level update. Orders that
+// were not created by a dataflow are ignored. The delivery ID must stay the
+// same across retries of the webhook.
+func (s *WebhookService) ProcessShopifyWebhook(ctx context.Context, connectorID uint, topic string, body []byte, deliveryID string) error {
+	var connector models.Connector
+	if err := s.db.First(&connector, connectorID).Error; err != nil {
+		return fmt.Errorf("error loading connector %d: %w", connectorID, err)
+	}
+
+	if topic == "inventory_levels/update" {
+		return s.processInventoryLevelWebhook(ctx, &connector, body, deliveryID)
+	}
+
+	// Orders carry their own ID, refunds the ID of their order
+	var payload struct {
+		ID      int64 `json:"id"`
+		OrderID int64 `json:"order_id"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("invalid webhook payload: %w", err)
+	}
+
+	orderID := payload.ID
+	if topic == "refunds/create" {
+		orderID = payload.OrderID
+	}
+	if orderID == 0 {
+		return fmt.Errorf("could not determine the order of the %s webhook", topic)
+	}
+	orderGID := fmt.Sprintf("gid://shopify/Order/%d", orderID)
+
+	mappingService := NewEntityMappingService(s.db)
+	mapping, err := mappingService.FindMappingByDestID(connector.ID, models.EntityTypeOrder, orderGID)
+	if err != nil {
+		return fmt.Errorf("error looking up order mapping: %w", err)
+	}
+	if mapping == nil {
+		fmt.Printf("Ignoring %s webhook for order %s, which was not created from Shopware\n", topic, orderGID)
+		return nil
+	}
+
+	var change OrderStateChange
+	switch topic {
+	case "orders/fulfilled":
+		change = OrderStateChangeFulfilled
+	case "orders/cancelled":
+		change = OrderStateChangeCancelled
+	case "refunds/create":
+		// A refund's payload does not say whether the order is now fully
+		// refunded, so the order's financial status decides
+		status, err := NewShopifyService(s.db).GetOrderFinancialStatus(&connector, orderGID)
+		if err != nil {
+			return err
+		}
+		switch status {
+		case "REFUNDED":
+			change = OrderStateChangeRefunded
+		case "PARTIALLY_REFUNDED":
+			change = OrderStateChangeRefundedPartially
+		default:
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported Shopify webhook topic: %s", topic)
+	}
+
+	var sourceConnector models.Connector
+	if err := s.db.First(&sourceConnector, mapping.SourceConnectorID).Error; err != nil {
+		return fmt.Errorf("error loading connector %d: %w", mapping.SourceConnectorID, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.shopwareService.TransitionOrder(&sourceConnector, mapping.SourceID, change)
+}
+
+// processInventoryLevelWebhook starts an execution for each active Shopify
+// to Shopware inventory dataflow of the connector that reads the updated
+// location. Updates made by a Shopware to Shopify inventory dataflow are
+// ignored.
+func (s *WebhookService) processInventoryLevelWebhook(ctx context.Context, connector *models.Connector, body []byte, deliveryID string) error {
+	var payload struct {
+		InventoryItemID int64 `json:"inventory_item_id"`
+		LocationID      int64 `json:"location_id"`
+		Available       *int  `json:"available"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	if payload.InventoryItemID == 0 || payload.LocationID == 0 {
+		return fmt.Errorf("could not determine the inventory level of the inventory_levels/update webhook")
+	}
+
+	inventoryItemID := fmt.Sprintf("gid://shopify/InventoryItem/%d", payload.InventoryItemID)
+	locationID := fmt.Sprintf("gid://shopify/Location/%d", payload.LocationID)
+
+	var dataflows []models.Dataflow
+	if err := s.db.Preload("SourceConnector").Preload("DestConnector").
+		Where("type = ? AND status = ? AND direction = ? AND source_connector_id = ?",
+			models.DataflowTypeInventory, models.DataflowStatusActive, models.DataflowDirectionShopifyToShopware, connector.ID).
+		Find(&dataflows).Error; err != nil {
+		return fmt.Errorf("error finding dataflows: %w", err)
+	}
+
+	var matching []models.Dataflow
+	for _, dataflow := range dataflows {
+		for _, location := range dataflow.Inventory.Locations {
+			if location.LocationID == locationID {
+				matching = append(matching, dataflow)
+				break
+			}
+		}
+	}
+
+	if len(matching) == 0 {
+		return nil
+	}
+
+	if payload.Available != nil {
+		echo, err := NewSyncEchoService(s.db).IsEcho(connector.ID, inventoryLevelKey(inventoryItemID, locationID), strconv.Itoa(*payload.Available), deliveryID)
+		if err != nil {
+			return fmt.Errorf("error checking for an echoed inventory write: %w", err)
+		}
+		if echo {
+			return nil
+		}
+	}
+
+	// All documents are fetched before any migration starts, so a failed
+	// fetch is safe to retry
+	shopifyService := NewShopifyService(s.db)
+	sourceData := make([][]byte, len(matching))
+	for i := range matching {
+		var err error
+		sourceData[i], err = shopifyService.FetchSourceDataForDataflow(&matching[i], inventoryItemID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.startMigrations(ctx, matching, inventoryItemID, sourceData)
+}
@@ -0,0 +1,28 @@
+package services
+
+import "testing"
+
+func TestConnectorHost(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"my-shop.myshopify.com", "my-shop.myshopify.com"},
+		{"https://My-Shop.myshopify.com/", "my-shop.myshopify.com"},
+		{"http://shop.example.com:8000/shopware/", "shop.example.com"},
+		{"  shop.example.com.  ", "shop.example.com"},
+		{"https://shop.example.com/admin?x=1", "shop.example.com"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if got := connectorHost(test.url); got != test.want {
+			t.Errorf("connectorHost(%q) = %q, want %q", test.url, got, test.want)
+		}
+	}
+
+	// A domain contained in another shop's domain is a different host
+	if connectorHost("shop.myshopify.com") == connectorHost("https://my-shop.myshopify.com") {
+		t.Errorf("shop.myshopify.com matched my-shop.myshopify.com")
+	}
+}